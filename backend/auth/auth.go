@@ -0,0 +1,50 @@
+// Package auth hashes passwords and tracks who is making a request.
+package auth
+
+import (
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// userIDKey is the gin context key holding the authenticated user's ID
+const userIDKey = "auth.user_id"
+
+// SetUserID records the authenticated user for the rest of the request
+func SetUserID(c *gin.Context, userID string) {
+	c.Set(userIDKey, userID)
+}
+
+// UserID returns the ID of the authenticated user, if there is one
+func UserID(c *gin.Context) (string, bool) {
+	userID := c.GetString(userIDKey)
+	return userID, userID != ""
+}
+
+// HashPassword hashes a password with bcrypt for storage
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// dummyHash is compared against when there is no stored hash, so a login
+// for an unknown email takes as long as one with a wrong password and does
+// not reveal which emails are registered.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// CheckPassword reports whether password matches a hash from HashPassword.
+// An empty hash never matches but takes just as long to check.
+func CheckPassword(hash, password string) bool {
+	if hash == "" {
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
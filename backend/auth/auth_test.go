@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordHashing(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	require.NoError(t, err)
+	assert.NotEqual(t, "correct horse", hash)
+
+	assert.True(t, CheckPassword(hash, "correct horse"))
+	assert.False(t, CheckPassword(hash, "battery staple"))
+	assert.False(t, CheckPassword("", "correct horse"), "an empty hash never matches")
+}
+
+func TestUserID(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	_, ok := UserID(c)
+	assert.False(t, ok, "no user before SetUserID")
+
+	SetUserID(c, "user-1")
+	userID, ok := UserID(c)
+	assert.True(t, ok)
+	assert.Equal(t, "user-1", userID)
+}
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MinKeyLength is the minimum size of a signing key's secret in bytes
+const MinKeyLength = 32
+
+// Key is a secret used to sign tokens with HMAC-SHA256. Its ID is written
+// to each token's "kid" header so the key can be found again when verifying.
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// KeySet holds the key new tokens are signed with and older keys whose
+// tokens are still accepted. To rotate keys, add a new current key and keep
+// the old one as a previous key until every token it signed has expired.
+type KeySet struct {
+	current Key
+	keys    map[string][]byte // Key ID -> secret, including the current key
+}
+
+// NewKeySet creates a key set that signs with current and also verifies
+// tokens signed by any of the previous keys
+func NewKeySet(current Key, previous ...Key) (*KeySet, error) {
+	ks := &KeySet{current: current, keys: make(map[string][]byte)}
+	for _, key := range append([]Key{current}, previous...) {
+		if key.ID == "" {
+			return nil, errors.New("signing key ID is required")
+		}
+		if strings.ContainsAny(key.ID, ".:,") {
+			return nil, fmt.Errorf("signing key ID %q must not contain '.', ':' or ','", key.ID)
+		}
+		if len(key.Secret) < MinKeyLength {
+			return nil, fmt.Errorf("signing key %q must be at least %d bytes", key.ID, MinKeyLength)
+		}
+		if _, ok := ks.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate signing key ID %q", key.ID)
+		}
+		ks.keys[key.ID] = key.Secret
+	}
+	return ks, nil
+}
+
+// ParseKeySet reads a key set from a comma-separated list of
+// "id:base64-secret" pairs. The first key is the current one.
+func ParseKeySet(spec string) (*KeySet, error) {
+	var keys []Key
+	for _, entry := range strings.Split(spec, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			return nil, fmt.Errorf("signing key %q must have the form id:base64-secret", entry)
+		}
+		secret, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %q is not valid base64: %w", id, err)
+		}
+		keys = append(keys, Key{ID: id, Secret: secret})
+	}
+	return NewKeySet(keys[0], keys[1:]...)
+}
+
+// RandomKeySet creates a key set with a single random key. Tokens it signs
+// stop working when the process exits, so it is only suitable for local
+// development and tests.
+func RandomKeySet() *KeySet {
+	secret := make([]byte, MinKeyLength)
+	if _, err := rand.Read(secret); err != nil {
+		panic("auth: cannot generate signing key: " + err.Error())
+	}
+	ks, _ := NewKeySet(Key{ID: "dev", Secret: secret})
+	return ks
+}
+
+// lookup returns the secret of the key with the given ID
+func (ks *KeySet) lookup(id string) ([]byte, bool) {
+	secret, ok := ks.keys[id]
+	return secret, ok
+}
+
+// Sign returns an HMAC signature of value made with the current key, in the
+// form "<key ID>.<signature>". It is used for values such as cookies that
+// are handed to clients and must come back unmodified.
+func (ks *KeySet) Sign(value string) string {
+	return ks.current.ID + "." + mac(ks.current.Secret, value)
+}
+
+// Verify reports whether signature is a signature of value made by Sign
+// with any key in the set
+func (ks *KeySet) Verify(value, signature string) bool {
+	kid, sig, ok := strings.Cut(signature, ".")
+	if !ok {
+		return false
+	}
+	secret, ok := ks.lookup(kid)
+	if !ok {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(mac(secret, value)))
+}
+
+// mac returns the base64url HMAC-SHA256 of value
+func mac(secret []byte, value string) string {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"instapoll/backend/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Identify is middleware that authenticates the caller from an
+// "Authorization: Bearer <access token>" header, making their ID available
+// through UserID. Requests without the header continue anonymously;
+// requests with an invalid, expired or revoked token are rejected with 401
+// so clients know to refresh their session.
+func (m *Manager) Identify(c *gin.Context) {
+	header := c.GetHeader("Authorization")
+	if header == "" {
+		c.Next()
+		return
+	}
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header must use the Bearer scheme"})
+		return
+	}
+
+	claims, err := m.Verify(c.Request.Context(), strings.TrimSpace(token), TypeAccess)
+	if err != nil {
+		if !errors.Is(err, ErrInvalidToken) && !errors.Is(err, ErrRevokedToken) {
+			logging.FromContext(c.Request.Context()).Error("Failed to verify access token", "error", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify access token"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired access token"})
+		return
+	}
+	SetUserID(c, claims.UserID())
+	c.Next()
+}
+
+// RequireUser is middleware that rejects anonymous requests with 401.
+// It must run after Identify.
+func RequireUser(c *gin.Context) {
+	if _, ok := UserID(c); !ok {
+		c.Header("WWW-Authenticate", "Bearer")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+	c.Next()
+}
@@ -0,0 +1,230 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"instapoll/backend/store"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Token lifetimes used unless the Manager is configured otherwise
+const (
+	DefaultAccessTTL  = 15 * time.Minute
+	DefaultRefreshTTL = 30 * 24 * time.Hour
+)
+
+// Token types, stored in the "typ" claim so a refresh token cannot be used
+// as an access token or the other way round
+const (
+	TypeAccess  = "access"
+	TypeRefresh = "refresh"
+)
+
+var (
+	// ErrInvalidToken is returned for tokens that are malformed, expired,
+	// signed with an unknown key or of the wrong type
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrRevokedToken is returned for tokens that were revoked by logging
+	// out or by reusing a refresh token
+	ErrRevokedToken = errors.New("token has been revoked")
+)
+
+// Claims are the JWT claims of access and refresh tokens.
+// Every token issued from one login shares a family ID, so logging out, or
+// detecting a stolen refresh token, can revoke the whole session at once.
+type Claims struct {
+	jwt.RegisteredClaims
+	Type   string `json:"typ"`
+	Family string `json:"fam"`
+}
+
+// UserID returns the ID of the user the token was issued to
+func (c *Claims) UserID() string {
+	return c.Subject
+}
+
+// Tokens is a freshly issued access and refresh token pair
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"` // Always "Bearer"
+	ExpiresIn    int    `json:"expires_in"` // Access token lifetime in seconds
+}
+
+// Manager issues, verifies, rotates and revokes session tokens.
+// Access tokens are short-lived and sent as "Authorization: Bearer" on
+// each request. Refresh tokens can be exchanged for a new pair once: the
+// old refresh token is revoked by the exchange, and presenting it again is
+// treated as theft and revokes the whole session.
+type Manager struct {
+	keys       *KeySet
+	revoked    store.RevocationStore
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	now        func() time.Time
+}
+
+// NewManager creates a token manager signing with keys and recording
+// revocations in revoked, using the default token lifetimes
+func NewManager(keys *KeySet, revoked store.RevocationStore) *Manager {
+	return &Manager{
+		keys:       keys,
+		revoked:    revoked,
+		accessTTL:  DefaultAccessTTL,
+		refreshTTL: DefaultRefreshTTL,
+		now:        time.Now,
+	}
+}
+
+// Keys returns the key set tokens are signed with, for signing other values
+func (m *Manager) Keys() *KeySet {
+	return m.keys
+}
+
+// SetTTLs changes the lifetimes of newly issued tokens
+func (m *Manager) SetTTLs(access, refresh time.Duration) {
+	m.accessTTL = access
+	m.refreshTTL = refresh
+}
+
+// Issue starts a new session for a user
+func (m *Manager) Issue(userID string) (*Tokens, error) {
+	return m.issue(userID, uuid.New().String())
+}
+
+// issue signs an access and refresh token pair in the given session family
+func (m *Manager) issue(userID, family string) (*Tokens, error) {
+	now := m.now()
+	access, err := m.sign(userID, family, TypeAccess, now, m.accessTTL)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := m.sign(userID, family, TypeRefresh, now, m.refreshTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &Tokens{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(m.accessTTL.Seconds()),
+	}, nil
+}
+
+// sign creates a token of the given type with the current key
+func (m *Manager) sign(userID, family, tokenType string, now time.Time, ttl time.Duration) (string, error) {
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Subject:   userID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+		Type:   tokenType,
+		Family: family,
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = m.keys.current.ID
+	return token.SignedString(m.keys.current.Secret)
+}
+
+// Verify checks a token's signature, expiry and type, and that neither the
+// token nor its session has been revoked
+func (m *Manager) Verify(ctx context.Context, tokenString, tokenType string) (*Claims, error) {
+	claims, err := m.parse(tokenString, tokenType)
+	if err != nil {
+		return nil, err
+	}
+	revoked, err := m.revoked.IsRevoked(ctx, claims.ID, claims.Family)
+	if err != nil {
+		return nil, fmt.Errorf("checking token revocation: %w", err)
+	}
+	if revoked {
+		return nil, ErrRevokedToken
+	}
+	return claims, nil
+}
+
+// parse checks a token's signature, expiry and type without consulting the
+// revocation list
+func (m *Manager) parse(tokenString, tokenType string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		secret, ok := m.keys.lookup(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(m.now),
+	)
+	if err != nil || claims.Type != tokenType || claims.Subject == "" || claims.ID == "" || claims.Family == "" {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair in the same
+// session, revoking the refresh token it was given. A refresh token that
+// was already exchanged is a sign it has been stolen, so the whole session
+// is revoked and ErrRevokedToken returned.
+func (m *Manager) Refresh(ctx context.Context, refreshToken string) (*Tokens, *Claims, error) {
+	claims, err := m.parse(refreshToken, TypeRefresh)
+	if err != nil {
+		return nil, nil, err
+	}
+	revoked, err := m.revoked.IsRevoked(ctx, claims.Family)
+	if err != nil {
+		return nil, nil, fmt.Errorf("checking token revocation: %w", err)
+	}
+	if revoked {
+		return nil, nil, ErrRevokedToken
+	}
+
+	// Revoking the old token is what claims it: of two concurrent
+	// exchanges, only one is newly revoking it.
+	first, err := m.revoked.Revoke(ctx, claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return nil, nil, fmt.Errorf("revoking refresh token: %w", err)
+	}
+	if !first {
+		if err := m.revokeFamily(ctx, claims); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrRevokedToken
+	}
+
+	tokens, err := m.issue(claims.Subject, claims.Family)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tokens, claims, nil
+}
+
+// Revoke ends the session a refresh token belongs to, invalidating every
+// access and refresh token issued in it
+func (m *Manager) Revoke(ctx context.Context, refreshToken string) error {
+	claims, err := m.parse(refreshToken, TypeRefresh)
+	if err != nil {
+		return err
+	}
+	return m.revokeFamily(ctx, claims)
+}
+
+// revokeFamily revokes every token in the session of claims. The entry is
+// kept as long as a refresh token issued now would live, which outlasts
+// every token already issued in the session.
+func (m *Manager) revokeFamily(ctx context.Context, claims *Claims) error {
+	if _, err := m.revoked.Revoke(ctx, claims.Family, m.now().Add(m.refreshTTL)); err != nil {
+		return fmt.Errorf("revoking session: %w", err)
+	}
+	return nil
+}
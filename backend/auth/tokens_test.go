@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"instapoll/backend/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testKey returns a signing key whose secret is the ID repeated to a valid length
+func testKey(id string) Key {
+	return Key{ID: id, Secret: bytes.Repeat([]byte(id), MinKeyLength)}
+}
+
+// newTestManager returns a manager with a single key and an empty revocation list
+func newTestManager(t *testing.T) *Manager {
+	keys, err := NewKeySet(testKey("k1"))
+	require.NoError(t, err)
+	return NewManager(keys, store.NewMemoryRevocationStore())
+}
+
+func TestIssueAndVerify(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+
+	tokens, err := m.Issue("user-1")
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer", tokens.TokenType)
+	assert.Equal(t, int(DefaultAccessTTL.Seconds()), tokens.ExpiresIn)
+
+	claims, err := m.Verify(ctx, tokens.AccessToken, TypeAccess)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.UserID())
+
+	// Each token only works as its own type.
+	_, err = m.Verify(ctx, tokens.RefreshToken, TypeAccess)
+	assert.True(t, errors.Is(err, ErrInvalidToken), "refresh token should not be accepted as an access token")
+	_, err = m.Verify(ctx, tokens.AccessToken, TypeRefresh)
+	assert.True(t, errors.Is(err, ErrInvalidToken), "access token should not be accepted as a refresh token")
+
+	_, err = m.Verify(ctx, "not.a.token", TypeAccess)
+	assert.True(t, errors.Is(err, ErrInvalidToken))
+}
+
+func TestVerify_Expired(t *testing.T) {
+	m := newTestManager(t)
+	tokens, err := m.Issue("user-1")
+	require.NoError(t, err)
+
+	m.now = func() time.Time { return time.Now().Add(DefaultAccessTTL + time.Minute) }
+	_, err = m.Verify(context.Background(), tokens.AccessToken, TypeAccess)
+	assert.True(t, errors.Is(err, ErrInvalidToken), "expired token should be rejected")
+}
+
+func TestKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	revoked := store.NewMemoryRevocationStore()
+	oldKeys, err := NewKeySet(testKey("k1"))
+	require.NoError(t, err)
+	oldTokens, err := NewManager(oldKeys, revoked).Issue("user-1")
+	require.NoError(t, err)
+
+	// After rotating, tokens signed with the previous key still verify.
+	rotated, err := NewKeySet(testKey("k2"), testKey("k1"))
+	require.NoError(t, err)
+	m := NewManager(rotated, revoked)
+	_, err = m.Verify(ctx, oldTokens.AccessToken, TypeAccess)
+	assert.NoError(t, err)
+
+	// Once the old key is retired, they do not.
+	retired, err := NewKeySet(testKey("k2"))
+	require.NoError(t, err)
+	_, err = NewManager(retired, revoked).Verify(ctx, oldTokens.AccessToken, TypeAccess)
+	assert.True(t, errors.Is(err, ErrInvalidToken), "token signed with a retired key should be rejected")
+}
+
+func TestParseKeySet(t *testing.T) {
+	ks, err := ParseKeySet("new:" + "bmV3LXNlY3JldC1uZXctc2VjcmV0LW5ldy1zZWNyZXQtbmV3LXNlY3JldA==" + ", old:b2xkLXNlY3JldC1vbGQtc2VjcmV0LW9sZC1zZWNyZXQtb2xkLXNlY3JldA==")
+	require.NoError(t, err)
+	assert.Equal(t, "new", ks.current.ID)
+	_, ok := ks.lookup("old")
+	assert.True(t, ok)
+
+	for _, spec := range []string{
+		"",
+		"no-separator",
+		"k1:not base64!",
+		"k1:c2hvcnQ=", // Too short
+		"k.1:bmV3LXNlY3JldC1uZXctc2VjcmV0LW5ldy1zZWNyZXQtbmV3LXNlY3JldA==", // Separator in the ID
+		"k1:" + "bmV3LXNlY3JldC1uZXctc2VjcmV0LW5ldy1zZWNyZXQtbmV3LXNlY3JldA==,k1:bmV3LXNlY3JldC1uZXctc2VjcmV0LW5ldy1zZWNyZXQtbmV3LXNlY3JldA==",
+	} {
+		_, err := ParseKeySet(spec)
+		assert.Error(t, err, "spec %q should be rejected", spec)
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	oldKeys, err := NewKeySet(testKey("k1"))
+	require.NoError(t, err)
+	signature := oldKeys.Sign("voter-1")
+	assert.True(t, oldKeys.Verify("voter-1", signature))
+	assert.False(t, oldKeys.Verify("voter-2", signature), "signature should not match another value")
+	assert.False(t, oldKeys.Verify("voter-1", "k1.forged"))
+	assert.False(t, oldKeys.Verify("voter-1", "no-key-id"))
+
+	// Signatures made with a previous key still verify after rotation.
+	rotated, err := NewKeySet(testKey("k2"), testKey("k1"))
+	require.NoError(t, err)
+	assert.True(t, rotated.Verify("voter-1", signature))
+	other, err := NewKeySet(testKey("k3"))
+	require.NoError(t, err)
+	assert.False(t, other.Verify("voter-1", signature))
+}
+
+func TestRefreshRotation(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+	first, err := m.Issue("user-1")
+	require.NoError(t, err)
+
+	second, claims, err := m.Refresh(ctx, first.RefreshToken)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.UserID())
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+	_, err = m.Verify(ctx, second.AccessToken, TypeAccess)
+	assert.NoError(t, err)
+
+	// Reusing the exchanged refresh token revokes the whole session.
+	_, _, err = m.Refresh(ctx, first.RefreshToken)
+	assert.True(t, errors.Is(err, ErrRevokedToken), "reused refresh token should be rejected")
+	_, err = m.Verify(ctx, second.AccessToken, TypeAccess)
+	assert.True(t, errors.Is(err, ErrRevokedToken), "session should be revoked after refresh token reuse")
+	_, _, err = m.Refresh(ctx, second.RefreshToken)
+	assert.True(t, errors.Is(err, ErrRevokedToken), "newest refresh token should be revoked too")
+}
+
+func TestRevoke(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+	session, err := m.Issue("user-1")
+	require.NoError(t, err)
+	other, err := m.Issue("user-1")
+	require.NoError(t, err)
+
+	require.NoError(t, m.Revoke(ctx, session.RefreshToken))
+	_, err = m.Verify(ctx, session.AccessToken, TypeAccess)
+	assert.True(t, errors.Is(err, ErrRevokedToken))
+
+	// Other sessions of the same user are unaffected.
+	_, err = m.Verify(ctx, other.AccessToken, TypeAccess)
+	assert.NoError(t, err)
+}
+
+func TestMiddleware(t *testing.T) {
+	m := newTestManager(t)
+	tokens, err := m.Issue("user-1")
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(m.Identify)
+	r.GET("/public", func(c *gin.Context) {
+		userID, _ := UserID(c)
+		c.String(http.StatusOK, userID)
+	})
+	r.GET("/private", RequireUser, func(c *gin.Context) {
+		userID, _ := UserID(c)
+		c.String(http.StatusOK, userID)
+	})
+
+	tests := []struct {
+		name       string
+		path       string
+		header     string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "public anonymous", path: "/public", wantStatus: http.StatusOK, wantBody: ""},
+		{name: "public signed in", path: "/public", header: "Bearer " + tokens.AccessToken, wantStatus: http.StatusOK, wantBody: "user-1"},
+		{name: "private anonymous", path: "/private", wantStatus: http.StatusUnauthorized},
+		{name: "private signed in", path: "/private", header: "Bearer " + tokens.AccessToken, wantStatus: http.StatusOK, wantBody: "user-1"},
+		{name: "refresh token as access token", path: "/private", header: "Bearer " + tokens.RefreshToken, wantStatus: http.StatusUnauthorized},
+		{name: "invalid token on public route", path: "/public", header: "Bearer nonsense", wantStatus: http.StatusUnauthorized},
+		{name: "wrong scheme", path: "/private", header: "Basic dXNlcjpwYXNz", wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusOK {
+				assert.Equal(t, tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
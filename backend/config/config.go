@@ -0,0 +1,298 @@
+// Package config holds the service's settings. They are loaded by Load
+// from, in increasing order of precedence: built-in defaults, a YAML file,
+// environment variables and command-line flags, then validated so a bad
+// setting stops the service at startup rather than at first use.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"instapoll/backend/handlers"
+	"instapoll/backend/models"
+)
+
+// Storage backends
+const (
+	// BackendMongo keeps everything in MongoDB
+	BackendMongo = "mongo"
+	// BackendMemory keeps everything in process memory, which is handy for
+	// local development without a database; all data is lost on restart
+	BackendMemory = "memory"
+)
+
+// Config is every setting of the service
+type Config struct {
+	Server   Server   `yaml:"server"`
+	Storage  Storage  `yaml:"storage"`
+	Timeouts Timeouts `yaml:"timeouts"`
+	Limits   Limits   `yaml:"limits"`
+	Log      Log      `yaml:"log"`
+	Metrics  Metrics  `yaml:"metrics"`
+	Auth     Auth     `yaml:"auth"`
+	Slack    Slack    `yaml:"slack"`
+}
+
+// Server configures the HTTP server
+type Server struct {
+	Addr string `yaml:"addr"` // Address and port to listen on
+	TLS  TLS    `yaml:"tls"`
+	// TrustedProxies lists the addresses or CIDR ranges of reverse proxies
+	// whose X-Forwarded-For and X-Real-IP headers give the client's address,
+	// and whose X-Forwarded-Proto header says whether it used HTTPS. If
+	// empty, the headers are ignored and the connection itself is used.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// TLS configures HTTPS; the server speaks plain HTTP unless both files are set
+type TLS struct {
+	CertFile string `yaml:"cert_file"` // PEM certificate chain
+	KeyFile  string `yaml:"key_file"`  // PEM private key
+}
+
+// Enabled reports whether the server should serve HTTPS
+func (t TLS) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != ""
+}
+
+// Storage chooses where data is kept
+type Storage struct {
+	Backend string `yaml:"backend"` // BackendMongo or BackendMemory
+	Mongo   Mongo  `yaml:"mongo"`
+}
+
+// Mongo configures the MongoDB connection and the collections used
+type Mongo struct {
+	URI         string      `yaml:"uri"`
+	Database    string      `yaml:"database"`
+	Collections Collections `yaml:"collections"`
+}
+
+// Collections names the MongoDB collection of each kind of document
+type Collections struct {
+	Polls         string `yaml:"polls"`
+	Ballots       string `yaml:"ballots"`
+	Users         string `yaml:"users"`
+	RevokedTokens string `yaml:"revoked_tokens"`
+	Leases        string `yaml:"leases"`
+	Webhooks      string `yaml:"webhooks"`
+	Deliveries    string `yaml:"webhook_deliveries"`
+}
+
+// Timeouts bound how long operations may take
+type Timeouts struct {
+	Database time.Duration `yaml:"database"` // Connecting to MongoDB and creating its indexes
+	Request  time.Duration `yaml:"request"`  // A request's store operations on a single poll, account or webhook
+	List     time.Duration `yaml:"list"`     // Listing polls
+	Ballots  time.Duration `yaml:"ballots"`  // Reading all of a poll's ballots, e.g. to count them
+}
+
+// Handlers returns the timeouts of the HTTP handlers
+func (t Timeouts) Handlers() handlers.Timeouts {
+	return handlers.Timeouts{Request: t.Request, List: t.List, Ballots: t.Ballots}
+}
+
+// Limits bound the size of polls
+type Limits struct {
+	MaxTitleLength       int `yaml:"max_title_length"`
+	MaxDescriptionLength int `yaml:"max_description_length"`
+	MaxOptions           int `yaml:"max_options"`
+	MaxOptionLength      int `yaml:"max_option_length"`
+}
+
+// Poll returns the limits polls are validated against
+func (l Limits) Poll() models.Limits {
+	return models.Limits{
+		MaxTitleLength:       l.MaxTitleLength,
+		MaxDescriptionLength: l.MaxDescriptionLength,
+		MaxOptions:           l.MaxOptions,
+		MaxOptionLength:      l.MaxOptionLength,
+	}
+}
+
+// Log configures logging
+type Log struct {
+	Level  string `yaml:"level"`  // debug, info, warn or error
+	Format string `yaml:"format"` // json or text
+}
+
+// Metrics configures access to the Prometheus metrics, which reveal traffic
+// and usage to anyone who can read them
+type Metrics struct {
+	// Addr is a separate address to serve /metrics on, e.g. one reachable
+	// only from inside the cluster. If empty, /metrics is served with the API.
+	Addr string `yaml:"addr"`
+	// Token, if set, must be sent as "Authorization: Bearer <token>".
+	Token string `yaml:"token"`
+}
+
+// Auth configures the keys that sign session tokens, voter cookies and
+// invites
+type Auth struct {
+	// JWTKeys lists the keys as "id:base64-secret" pairs separated by
+	// commas; see auth.ParseKeySet. It is required with BackendMongo. With
+	// BackendMemory it may be empty, and a random key is used: sessions,
+	// voter cookies and invites stop working on restart, along with the data.
+	JWTKeys string `yaml:"jwt_keys"`
+}
+
+// Slack configures the Slack integration, which is enabled when both the
+// signing secret and bot token are set
+type Slack struct {
+	SigningSecret string `yaml:"signing_secret"`
+	BotToken      string `yaml:"bot_token"`
+	APIURL        string `yaml:"api_url"` // Empty for Slack's own Web API
+}
+
+// Enabled reports whether the Slack integration is configured
+func (s Slack) Enabled() bool {
+	return s.SigningSecret != "" && s.BotToken != ""
+}
+
+// Default returns the settings used when nothing else is configured
+func Default() *Config {
+	limits := models.DefaultLimits
+	return &Config{
+		Server: Server{Addr: ":8080"},
+		Storage: Storage{
+			Backend: BackendMongo,
+			Mongo: Mongo{
+				URI:      "mongodb://localhost:27017",
+				Database: "instapoll",
+				Collections: Collections{
+					Polls:         "polls",
+					Ballots:       "ballots",
+					Users:         "users",
+					RevokedTokens: "revoked_tokens",
+					Leases:        "leases",
+					Webhooks:      "webhooks",
+					Deliveries:    "webhook_deliveries",
+				},
+			},
+		},
+		Timeouts: Timeouts{
+			Database: 10 * time.Second,
+			Request:  handlers.DefaultTimeouts.Request,
+			List:     handlers.DefaultTimeouts.List,
+			Ballots:  handlers.DefaultTimeouts.Ballots,
+		},
+		Limits: Limits{
+			MaxTitleLength:       limits.MaxTitleLength,
+			MaxDescriptionLength: limits.MaxDescriptionLength,
+			MaxOptions:           limits.MaxOptions,
+			MaxOptionLength:      limits.MaxOptionLength,
+		},
+		Log: Log{Level: "info", Format: "json"},
+	}
+}
+
+// Validate reports every setting that is missing or invalid
+func (c *Config) Validate() error {
+	var errs []error
+	problem := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Server.Addr == "" {
+		problem("server.addr is required")
+	}
+	if tls := c.Server.TLS; tls.Enabled() {
+		if tls.CertFile == "" || tls.KeyFile == "" {
+			problem("server.tls needs both cert_file and key_file")
+		}
+		for _, file := range []string{tls.CertFile, tls.KeyFile} {
+			if file == "" {
+				continue
+			}
+			if _, err := os.Stat(file); err != nil {
+				problem("server.tls: %v", err)
+			}
+		}
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				problem("server.trusted_proxies: %q is not an IP address or CIDR range", proxy)
+			}
+		}
+	}
+
+	switch c.Storage.Backend {
+	case BackendMongo:
+		mongo := c.Storage.Mongo
+		if mongo.URI == "" {
+			problem("storage.mongo.uri is required")
+		}
+		if mongo.Database == "" {
+			problem("storage.mongo.database is required")
+		}
+		collections := mongo.Collections
+		used := make(map[string]string)
+		for _, collection := range []struct{ key, name string }{
+			{"polls", collections.Polls},
+			{"ballots", collections.Ballots},
+			{"users", collections.Users},
+			{"revoked_tokens", collections.RevokedTokens},
+			{"leases", collections.Leases},
+			{"webhooks", collections.Webhooks},
+			{"webhook_deliveries", collections.Deliveries},
+		} {
+			if collection.name == "" {
+				problem("storage.mongo.collections.%s is required", collection.key)
+				continue
+			}
+			if other, ok := used[collection.name]; ok {
+				problem("storage.mongo.collections.%s and %s are both %q", other, collection.key, collection.name)
+			}
+			used[collection.name] = collection.key
+		}
+		// Data outlives the process, so what is signed must too: a random
+		// key would let every browser vote again after a restart, or on
+		// another replica, and void every invite.
+		if c.Auth.JWTKeys == "" {
+			problem("auth.jwt_keys is required with the mongo storage backend")
+		}
+	case BackendMemory:
+	default:
+		problem("storage.backend must be %q or %q, not %q", BackendMongo, BackendMemory, c.Storage.Backend)
+	}
+
+	for _, t := range []struct {
+		key     string
+		timeout time.Duration
+	}{
+		{"database", c.Timeouts.Database},
+		{"request", c.Timeouts.Request},
+		{"list", c.Timeouts.List},
+		{"ballots", c.Timeouts.Ballots},
+	} {
+		if t.timeout <= 0 {
+			problem("timeouts.%s must be positive", t.key)
+		}
+	}
+
+	if err := c.Limits.Poll().Validate(); err != nil {
+		problem("limits: %v", err)
+	}
+
+	if c.Metrics.Addr != "" && c.Metrics.Addr == c.Server.Addr {
+		problem("metrics.addr must differ from server.addr")
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		problem("log.level must be debug, info, warn or error, not %q", c.Log.Level)
+	}
+	switch strings.ToLower(c.Log.Format) {
+	case "json", "text":
+	default:
+		problem("log.format must be json or text, not %q", c.Log.Format)
+	}
+
+	return errors.Join(errs...)
+}
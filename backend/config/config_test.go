@@ -0,0 +1,211 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"instapoll/backend/handlers"
+	"instapoll/backend/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// env returns a getenv reading from vars
+func env(vars map[string]string) func(string) string {
+	return func(key string) string { return vars[key] }
+}
+
+// writeFile writes a configuration file and returns its path
+func writeFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "instapoll.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// testKeys is a valid auth.jwt_keys value
+const testKeys = "k1:c2VjcmV0LXNlY3JldC1zZWNyZXQtc2VjcmV0LXNlY3JldA=="
+
+// withKeys returns the defaults with testKeys, the one setting MongoDB
+// needs that has no default
+func withKeys() *Config {
+	cfg := Default()
+	cfg.Auth.JWTKeys = testKeys
+	return cfg
+}
+
+func TestLoadDefaults(t *testing.T) {
+	keys := env(map[string]string{"JWT_KEYS": testKeys})
+	cfg, err := Load(nil, keys, io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, withKeys(), cfg)
+	assert.Equal(t, ":8080", cfg.Server.Addr)
+	assert.False(t, cfg.Server.TLS.Enabled())
+	assert.Equal(t, handlers.DefaultTimeouts, cfg.Timeouts.Handlers())
+	assert.Equal(t, models.DefaultLimits, cfg.Limits.Poll())
+	assert.False(t, cfg.Slack.Enabled())
+
+	// The example file documents the defaults.
+	cfg, err = Load([]string{"-config", "../config.example.yaml"}, keys, io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, withKeys(), cfg)
+
+	// The keys have no default with MongoDB.
+	_, err = Load(nil, env(nil), io.Discard)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "auth.jwt_keys is required")
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, `
+server:
+  addr: ":9000"
+storage:
+  backend: memory
+  mongo:
+    database: from-file
+    collections:
+      polls: file_polls
+timeouts:
+  request: 2s
+  list: 20s
+limits:
+  max_options: 20
+log:
+  level: warn
+`)
+
+	// The file overrides the defaults, the environment the file, and
+	// flags the environment.
+	cfg, err := Load(
+		[]string{"-config", path, "-list-timeout", "30s", "-log-level", "debug"},
+		env(map[string]string{
+			"MONGODB_DATABASE": "from-env",
+			"LIST_TIMEOUT":     "25s",
+			"POLL_MAX_OPTIONS": "15",
+			"LOG_LEVEL":        "error",
+			"JWT_KEYS":         "k1:secret",
+			"METRICS_TOKEN":    "scrape",
+			"TRUSTED_PROXIES":  "10.0.0.0/8, 192.0.2.1",
+		}),
+		io.Discard,
+	)
+	require.NoError(t, err)
+	assert.Equal(t, ":9000", cfg.Server.Addr)
+	assert.Equal(t, BackendMemory, cfg.Storage.Backend)
+	assert.Equal(t, "from-env", cfg.Storage.Mongo.Database)
+	assert.Equal(t, "file_polls", cfg.Storage.Mongo.Collections.Polls)
+	assert.Equal(t, "ballots", cfg.Storage.Mongo.Collections.Ballots, "Settings missing from the file keep their defaults")
+	assert.Equal(t, 2*time.Second, cfg.Timeouts.Request)
+	assert.Equal(t, 30*time.Second, cfg.Timeouts.List)
+	assert.Equal(t, 15, cfg.Limits.MaxOptions)
+	assert.Equal(t, "debug", cfg.Log.Level)
+	assert.Equal(t, "k1:secret", cfg.Auth.JWTKeys)
+	assert.Equal(t, "scrape", cfg.Metrics.Token)
+	assert.Equal(t, []string{"10.0.0.0/8", "192.0.2.1"}, cfg.Server.TrustedProxies)
+
+	// The file can also be named by the environment.
+	cfg, err = Load(nil, env(map[string]string{FileEnv: path, "JWT_KEYS": testKeys}), io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, ":9000", cfg.Server.Addr)
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		file string
+	}{
+		{name: "unknown flag", args: []string{"-port", "80"}},
+		{name: "extra argument", args: []string{"serve"}},
+		{name: "bad duration flag", args: []string{"-request-timeout", "5"}},
+		{name: "bad number env", env: map[string]string{"POLL_MAX_OPTIONS": "many"}},
+		{name: "missing file", args: []string{"-config", "/nonexistent/instapoll.yaml"}},
+		{name: "unknown key in file", file: "server:\n  port: 80\n"},
+		{name: "malformed file", file: "server: [\n"},
+		{name: "invalid setting", env: map[string]string{"STORAGE_BACKEND": "postgres"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				args = append(args, "-config", writeFile(t, tt.file))
+			}
+			// The keys are set so that only the setting tested is wrong.
+			vars := map[string]string{"JWT_KEYS": testKeys}
+			for key, value := range tt.env {
+				vars[key] = value
+			}
+			_, err := Load(args, env(vars), io.Discard)
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := Load([]string{"-help"}, env(nil), io.Discard)
+	assert.True(t, errors.Is(err, flag.ErrHelp))
+}
+
+func TestValidate(t *testing.T) {
+	cert := writeFile(t, "certificate")
+	tests := []struct {
+		name    string
+		change  func(*Config)
+		wantErr bool
+	}{
+		{name: "defaults", change: func(*Config) {}},
+		{name: "memory needs no mongo settings", change: func(c *Config) {
+			c.Storage.Backend = BackendMemory
+			c.Storage.Mongo = Mongo{}
+		}},
+		{name: "tls", change: func(c *Config) { c.Server.TLS = TLS{CertFile: cert, KeyFile: cert} }},
+		{name: "tls without key", change: func(c *Config) { c.Server.TLS.CertFile = cert }, wantErr: true},
+		{name: "tls file missing", change: func(c *Config) {
+			c.Server.TLS = TLS{CertFile: cert, KeyFile: "/nonexistent/key.pem"}
+		}, wantErr: true},
+		{name: "trusted proxies", change: func(c *Config) { c.Server.TrustedProxies = []string{"10.0.0.0/8", "::1"} }},
+		{name: "bad trusted proxy", change: func(c *Config) { c.Server.TrustedProxies = []string{"proxy.internal"} }, wantErr: true},
+		{name: "no address", change: func(c *Config) { c.Server.Addr = "" }, wantErr: true},
+		{name: "unknown backend", change: func(c *Config) { c.Storage.Backend = "postgres" }, wantErr: true},
+		{name: "no mongo uri", change: func(c *Config) { c.Storage.Mongo.URI = "" }, wantErr: true},
+		{name: "no database", change: func(c *Config) { c.Storage.Mongo.Database = "" }, wantErr: true},
+		{name: "no collection", change: func(c *Config) { c.Storage.Mongo.Collections.Leases = "" }, wantErr: true},
+		{name: "shared collection", change: func(c *Config) { c.Storage.Mongo.Collections.Ballots = "polls" }, wantErr: true},
+		{name: "zero timeout", change: func(c *Config) { c.Timeouts.Request = 0 }, wantErr: true},
+		{name: "one option", change: func(c *Config) { c.Limits.MaxOptions = 1 }, wantErr: true},
+		{name: "no title", change: func(c *Config) { c.Limits.MaxTitleLength = 0 }, wantErr: true},
+		{name: "metrics address", change: func(c *Config) { c.Metrics.Addr = "127.0.0.1:9090" }},
+		{name: "metrics on the api address", change: func(c *Config) { c.Metrics.Addr = c.Server.Addr }, wantErr: true},
+		{name: "mongo without keys", change: func(c *Config) { c.Auth.JWTKeys = "" }, wantErr: true},
+		{name: "memory without keys", change: func(c *Config) {
+			c.Storage.Backend = BackendMemory
+			c.Auth.JWTKeys = ""
+		}},
+		{name: "unknown log level", change: func(c *Config) { c.Log.Level = "loud" }, wantErr: true},
+		{name: "unknown log format", change: func(c *Config) { c.Log.Format = "xml" }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := withKeys()
+			tt.change(cfg)
+			err := cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Every problem is reported at once.
+	cfg := withKeys()
+	cfg.Server.Addr = ""
+	cfg.Timeouts.List = -time.Second
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "server.addr is required")
+	assert.Contains(t, err.Error(), "timeouts.list must be positive")
+}
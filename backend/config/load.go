@@ -0,0 +1,200 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the environment variable holding the path of the
+// configuration file, if the -config flag does not give one
+const FileEnv = "CONFIG_FILE"
+
+// setting is a value that environment variables or flags can set, besides
+// the configuration file
+type setting struct {
+	env   string // Environment variable, or empty
+	flag  string // Command-line flag, or empty
+	usage string // Shown by -help
+	value any    // *string, *[]string, *int or *time.Duration pointing into a Config
+}
+
+// settings lists c's values that environment variables or flags can set.
+// Secrets have no flags, as other users of the machine can see a
+// process's arguments.
+func (c *Config) settings() []setting {
+	collections := &c.Storage.Mongo.Collections
+	return []setting{
+		{"LISTEN_ADDR", "addr", "address and port to listen on", &c.Server.Addr},
+		{"TLS_CERT_FILE", "tls-cert", "PEM certificate `file` for HTTPS", &c.Server.TLS.CertFile},
+		{"TLS_KEY_FILE", "tls-key", "PEM private key `file` for HTTPS", &c.Server.TLS.KeyFile},
+		{"TRUSTED_PROXIES", "trusted-proxies", "comma-separated addresses or CIDR ranges of trusted reverse proxies", &c.Server.TrustedProxies},
+		{"STORAGE_BACKEND", "storage", "where data is kept: mongo or memory", &c.Storage.Backend},
+		{"MONGODB_URI", "mongo-uri", "MongoDB connection string", &c.Storage.Mongo.URI},
+		{"MONGODB_DATABASE", "mongo-database", "MongoDB database", &c.Storage.Mongo.Database},
+		{"MONGODB_POLLS_COLLECTION", "", "", &collections.Polls},
+		{"MONGODB_BALLOTS_COLLECTION", "", "", &collections.Ballots},
+		{"MONGODB_USERS_COLLECTION", "", "", &collections.Users},
+		{"MONGODB_REVOKED_TOKENS_COLLECTION", "", "", &collections.RevokedTokens},
+		{"MONGODB_LEASES_COLLECTION", "", "", &collections.Leases},
+		{"MONGODB_WEBHOOKS_COLLECTION", "", "", &collections.Webhooks},
+		{"MONGODB_WEBHOOK_DELIVERIES_COLLECTION", "", "", &collections.Deliveries},
+		{"DB_TIMEOUT", "db-timeout", "timeout connecting to MongoDB and creating indexes", &c.Timeouts.Database},
+		{"REQUEST_TIMEOUT", "request-timeout", "timeout of a request's store operations", &c.Timeouts.Request},
+		{"LIST_TIMEOUT", "list-timeout", "timeout listing polls", &c.Timeouts.List},
+		{"BALLOTS_TIMEOUT", "ballots-timeout", "timeout reading all of a poll's ballots", &c.Timeouts.Ballots},
+		{"POLL_MAX_TITLE_LENGTH", "max-title-length", "longest poll title, in bytes", &c.Limits.MaxTitleLength},
+		{"POLL_MAX_DESCRIPTION_LENGTH", "max-description-length", "longest poll description, in bytes", &c.Limits.MaxDescriptionLength},
+		{"POLL_MAX_OPTIONS", "max-options", "most options in a poll", &c.Limits.MaxOptions},
+		{"POLL_MAX_OPTION_LENGTH", "max-option-length", "longest option text, in bytes", &c.Limits.MaxOptionLength},
+		{"LOG_LEVEL", "log-level", "debug, info, warn or error", &c.Log.Level},
+		{"LOG_FORMAT", "log-format", "json or text", &c.Log.Format},
+		{"METRICS_ADDR", "metrics-addr", "separate address to serve /metrics on", &c.Metrics.Addr},
+		{"METRICS_TOKEN", "", "", &c.Metrics.Token},
+		{"JWT_KEYS", "", "", &c.Auth.JWTKeys},
+		{"SLACK_SIGNING_SECRET", "", "", &c.Slack.SigningSecret},
+		{"SLACK_BOT_TOKEN", "", "", &c.Slack.BotToken},
+		{"SLACK_API_URL", "", "", &c.Slack.APIURL},
+	}
+}
+
+// set parses raw into the setting's value
+func (s setting) set(raw string) error {
+	switch v := s.value.(type) {
+	case *string:
+		*v = raw
+	case *[]string:
+		*v = nil
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*v = append(*v, item)
+			}
+		}
+	case *int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", raw)
+		}
+		*v = n
+	case *time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 5s", raw)
+		}
+		*v = d
+	}
+	return nil
+}
+
+// String formats the setting's value
+func (s setting) String() string {
+	switch v := s.value.(type) {
+	case *string:
+		return *v
+	case *[]string:
+		return strings.Join(*v, ",")
+	case *int:
+		return strconv.Itoa(*v)
+	case *time.Duration:
+		return v.String()
+	}
+	return ""
+}
+
+// rawFlag is a flag's value as given, parsed once the file and environment
+// have been read so that flags take precedence over both
+type rawFlag string
+
+func (f *rawFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return string(*f)
+}
+
+func (f *rawFlag) Set(value string) error {
+	*f = rawFlag(value)
+	return nil
+}
+
+// Load returns the configuration given by the defaults, overridden by the
+// YAML file named by the -config flag or CONFIG_FILE, overridden by the
+// environment read with getenv, overridden by the command-line arguments
+// args (without the program name). Empty environment variables are
+// ignored. The result is validated; with -help, flag.ErrHelp is returned.
+func Load(args []string, getenv func(string) string, output io.Writer) (*Config, error) {
+	cfg := Default()
+	settings := cfg.settings()
+
+	flags := flag.NewFlagSet("instapoll", flag.ContinueOnError)
+	flags.SetOutput(output)
+	path := flags.String("config", getenv(FileEnv), "YAML configuration `file`")
+	byFlag := make(map[string]setting)
+	for _, s := range settings {
+		if s.flag == "" {
+			continue
+		}
+		value := rawFlag(s.String())
+		flags.Var(&value, s.flag, s.usage+" (env "+s.env+")")
+		byFlag[s.flag] = s
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %v", flags.Args())
+	}
+
+	if *path != "" {
+		if err := cfg.loadFile(*path); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range settings {
+		if raw := getenv(s.env); raw != "" {
+			if err := s.set(raw); err != nil {
+				return nil, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+	var err error
+	flags.Visit(func(f *flag.Flag) {
+		if s, ok := byFlag[f.Name]; ok && err == nil {
+			if setErr := s.set(f.Value.String()); setErr != nil {
+				err = fmt.Errorf("-%s: %w", f.Name, setErr)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// loadFile overrides c with the settings in the YAML file at path. Unknown
+// keys are refused, so that a misspelt setting is not silently ignored.
+func (c *Config) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("reading configuration file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parsing configuration file %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"instapoll/backend/auth"
+	"instapoll/backend/logging"
+	"instapoll/backend/models"
+	"instapoll/backend/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AuthHandler holds the storage used for user accounts, the manager
+// that issues their session tokens, and how long requests wait on them
+type AuthHandler struct {
+	users    store.UserStore
+	tokens   *auth.Manager
+	timeouts Timeouts
+}
+
+// NewAuthHandler creates a new handler with the given user store, token
+// manager and timeouts
+func NewAuthHandler(users store.UserStore, tokens *auth.Manager, timeouts Timeouts) *AuthHandler {
+	return &AuthHandler{users: users, tokens: tokens, timeouts: timeouts}
+}
+
+// Session is the response body of Login and Refresh: the signed-in user
+// and their new tokens
+type Session struct {
+	User *models.User `json:"user"`
+	auth.Tokens
+}
+
+// refreshRequest is the request body of Refresh and Logout
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// RegisterRoutes sets up the account routes under /api/auth
+func (h *AuthHandler) RegisterRoutes(r *gin.Engine) {
+	authRoutes := r.Group("/api/auth")
+	{
+		authRoutes.POST("/register", h.Register) // Create an account
+		authRoutes.POST("/login", h.Login)       // Start a session
+		authRoutes.POST("/refresh", h.Refresh)   // Exchange a refresh token for new tokens
+		authRoutes.POST("/logout", h.Logout)     // End a session
+	}
+}
+
+// Register creates a new account from an email, password and optional name.
+// The password is stored as a bcrypt hash and never returned.
+func (h *AuthHandler) Register(c *gin.Context) {
+	var creds models.Credentials
+	if err := c.ShouldBindJSON(&creds); err != nil {
+		logging.FromContext(c.Request.Context()).Info("Invalid request body", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	if err := creds.ValidateRegistration(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return
+	}
+
+	hash, err := auth.HashPassword(creds.Password)
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("Failed to hash password", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create account"})
+		return
+	}
+	user := models.User{
+		ID:           uuid.New().String(),
+		Email:        models.NormalizeEmail(creds.Email),
+		Name:         creds.Name,
+		PasswordHash: hash,
+		CreatedAt:    time.Now(),
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeouts.Request)
+	defer cancel()
+
+	if err := h.users.CreateUser(ctx, &user); err != nil {
+		if errors.Is(err, store.ErrEmailTaken) {
+			c.JSON(http.StatusConflict, gin.H{"error": "An account with this email already exists"})
+			return
+		}
+		logging.FromContext(ctx).Error("Failed to create user", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create account"})
+		return
+	}
+	logging.FromContext(ctx).Info("Registered user", "user_id", user.ID)
+
+	c.JSON(http.StatusCreated, user)
+}
+
+// Login checks an email and password and starts a session, returning the
+// account with an access and refresh token.
+// Unknown emails and wrong passwords get the same response, so the
+// endpoint cannot be used to find out who has an account.
+func (h *AuthHandler) Login(c *gin.Context) {
+	var creds models.Credentials
+	if err := c.ShouldBindJSON(&creds); err != nil {
+		logging.FromContext(c.Request.Context()).Info("Invalid request body", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeouts.Request)
+	defer cancel()
+
+	user, err := h.users.GetUserByEmail(ctx, models.NormalizeEmail(creds.Email))
+	if err != nil && !errors.Is(err, store.ErrUserNotFound) {
+		logging.FromContext(ctx).Error("Failed to retrieve user", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+		return
+	}
+	hash := ""
+	if user != nil {
+		hash = user.PasswordHash
+	}
+	// Always check a password, even for unknown emails, so both cases take as long.
+	if !auth.CheckPassword(hash, creds.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		return
+	}
+
+	tokens, err := h.tokens.Issue(user.ID)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to issue tokens", "user_id", user.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Session{User: user, Tokens: *tokens})
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token.
+// Each refresh token can only be exchanged once; reusing one ends the
+// session, since it means someone else holds a copy.
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req refreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeouts.Request)
+	defer cancel()
+
+	tokens, claims, err := h.tokens.Refresh(ctx, req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, auth.ErrRevokedToken) || errors.Is(err, auth.ErrInvalidToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+			return
+		}
+		logging.FromContext(ctx).Error("Failed to refresh session", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		return
+	}
+
+	user, err := h.users.GetUser(ctx, claims.UserID())
+	if err != nil {
+		if errors.Is(err, store.ErrUserNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+			return
+		}
+		logging.FromContext(ctx).Error("Failed to retrieve user", "user_id", claims.UserID(), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Session{User: user, Tokens: *tokens})
+}
+
+// Logout ends the session a refresh token belongs to. Every access and
+// refresh token issued in the session stops working immediately.
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req refreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeouts.Request)
+	defer cancel()
+
+	if err := h.tokens.Revoke(ctx, req.RefreshToken); err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+			return
+		}
+		logging.FromContext(ctx).Error("Failed to revoke session", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
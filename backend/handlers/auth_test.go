@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"instapoll/backend/auth"
+	"instapoll/backend/models"
+	"instapoll/backend/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupAuthRouter creates a Gin router with the account routes and an in-memory user store
+func setupAuthRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	NewAuthHandler(store.NewMemoryUserStore(), testTokens, DefaultTimeouts).RegisterRoutes(r)
+	return r
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	router := setupAuthRouter()
+
+	w := sendJSON(router, "POST", "/api/auth/register", `{"email": " Ada@Example.com", "password": "correct horse", "name": "Ada"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.NotContains(t, w.Body.String(), "password", "the password hash must never be returned")
+	var user models.User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+	assert.NotEmpty(t, user.ID)
+	assert.Equal(t, "ada@example.com", user.Email)
+	assert.Equal(t, "Ada", user.Name)
+
+	// Emails are compared after normalizing.
+	w = sendJSON(router, "POST", "/api/auth/register", `{"email": "ada@example.COM", "password": "another password"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = sendJSON(router, "POST", "/api/auth/login", `{"email": "ADA@example.com", "password": "correct horse"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var session Session
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &session))
+	assert.Equal(t, user.ID, session.User.ID)
+	assert.Equal(t, "Bearer", session.TokenType)
+	claims, err := testTokens.Verify(context.Background(), session.AccessToken, auth.TypeAccess)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, claims.UserID())
+}
+
+// login signs in and returns the new session
+func login(t *testing.T, router *gin.Engine, email, password string) Session {
+	w := sendJSON(router, "POST", "/api/auth/login", `{"email": "`+email+`", "password": "`+password+`"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var session Session
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &session))
+	return session
+}
+
+func TestRefreshAndLogout(t *testing.T) {
+	router := setupAuthRouter()
+	w := sendJSON(router, "POST", "/api/auth/register", `{"email": "ada@example.com", "password": "correct horse"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	session := login(t, router, "ada@example.com", "correct horse")
+
+	// A refresh token can be exchanged once for a new pair.
+	w = sendJSON(router, "POST", "/api/auth/refresh", `{"refresh_token": "`+session.RefreshToken+`"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var refreshed Session
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &refreshed))
+	assert.Equal(t, session.User.ID, refreshed.User.ID)
+	assert.NotEqual(t, session.RefreshToken, refreshed.RefreshToken)
+
+	w = sendJSON(router, "POST", "/api/auth/refresh", `{"refresh_token": "`+session.RefreshToken+`"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "an exchanged refresh token should not work again")
+
+	// Reuse ended the session, so a fresh login is needed to test logout.
+	session = login(t, router, "ada@example.com", "correct horse")
+	w = sendJSON(router, "POST", "/api/auth/logout", `{"refresh_token": "`+session.RefreshToken+`"}`)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	_, err := testTokens.Verify(context.Background(), session.AccessToken, auth.TypeAccess)
+	assert.ErrorIs(t, err, auth.ErrRevokedToken, "logging out should revoke the access token")
+	w = sendJSON(router, "POST", "/api/auth/refresh", `{"refresh_token": "`+session.RefreshToken+`"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = sendJSON(router, "POST", "/api/auth/logout", `{"refresh_token": "nonsense"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = sendJSON(router, "POST", "/api/auth/refresh", `{}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestLogin_Rejected(t *testing.T) {
+	router := setupAuthRouter()
+	w := sendJSON(router, "POST", "/api/auth/register", `{"email": "ada@example.com", "password": "correct horse"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	// A wrong password and an unknown email look the same to the caller.
+	wrongPassword := sendJSON(router, "POST", "/api/auth/login", `{"email": "ada@example.com", "password": "battery staple"}`)
+	unknownEmail := sendJSON(router, "POST", "/api/auth/login", `{"email": "bob@example.com", "password": "correct horse"}`)
+	assert.Equal(t, http.StatusUnauthorized, wrongPassword.Code)
+	assert.Equal(t, http.StatusUnauthorized, unknownEmail.Code)
+	assert.Equal(t, wrongPassword.Body.String(), unknownEmail.Body.String())
+}
+
+func TestRegister_Invalid(t *testing.T) {
+	router := setupAuthRouter()
+	tests := []struct {
+		name    string
+		payload string
+	}{
+		{name: "malformed JSON", payload: `{"email": `},
+		{name: "missing email", payload: `{"password": "correct horse"}`},
+		{name: "invalid email", payload: `{"email": "ada", "password": "correct horse"}`},
+		{name: "short password", payload: `{"email": "ada@example.com", "password": "short"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := sendJSON(router, "POST", "/api/auth/register", tt.payload)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
+
+func TestCreatePoll_CreatorID(t *testing.T) {
+	router := setupRouter(newTestStore())
+
+	poll := createPoll(t, router, `{"title": "Mine", "creator_id": "someone-else", "options": [{"text": "A"}, {"text": "B"}]}`)
+	assert.Equal(t, testUserID, poll.CreatorID, "creator comes from the authenticated user, not the request body")
+
+	w := sendJSONAs(router, "", "POST", "/api/polls", `{"title": "Anyone's", "options": [{"text": "A"}, {"text": "B"}]}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "creating a poll requires a signed-in user")
+}
+
+func TestPollOwnership(t *testing.T) {
+	router := setupRouter(newTestStore())
+	poll := createPoll(t, router, `{"title": "Mine", "options": [{"text": "A"}, {"text": "B"}]}`)
+	update := `{"title": "Taken over", "version": 1, "options": [{"id": "` + poll.Options[0].ID + `", "text": "A"}, {"id": "` + poll.Options[1].ID + `", "text": "B"}]}`
+
+	// Anyone may view and vote.
+	assert.Equal(t, http.StatusOK, sendJSONAs(router, "", "GET", "/api/polls/"+poll.ID, "").Code)
+	assert.Equal(t, http.StatusOK, sendJSONAs(router, "", "POST", "/api/polls/"+poll.ID+"/votes", `{"option_id": "`+poll.Options[0].ID+`"}`).Code)
+
+	// Only the creator may change the poll.
+	for _, userID := range []string{"", "someone-else"} {
+		want := http.StatusForbidden
+		if userID == "" {
+			want = http.StatusUnauthorized
+		}
+		assert.Equal(t, want, sendJSONAs(router, userID, "PUT", "/api/polls/"+poll.ID, update).Code)
+		assert.Equal(t, want, sendJSONAs(router, userID, "DELETE", "/api/polls/"+poll.ID, "").Code)
+	}
+
+	require.Equal(t, http.StatusNoContent, sendJSON(router, "DELETE", "/api/polls/"+poll.ID, "").Code)
+	assert.Equal(t, http.StatusForbidden, sendJSONAs(router, "someone-else", "POST", "/api/polls/"+poll.ID+"/restore", "").Code)
+	assert.Equal(t, http.StatusOK, sendJSON(router, "POST", "/api/polls/"+poll.ID+"/restore", "").Code)
+}
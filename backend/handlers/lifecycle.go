@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"instapoll/backend/auth"
+	"instapoll/backend/logging"
+	"instapoll/backend/models"
+	"instapoll/backend/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// lifecycleRequest is the optional body of the lifecycle endpoints
+type lifecycleRequest struct {
+	OpensAt   time.Time `json:"opens_at"`   // OpenPoll: schedule the poll to open then
+	ExpiresAt time.Time `json:"expires_at"` // ReopenPoll: new expiration date
+}
+
+// requireVisible responds with 404 Not Found and returns false if the poll
+// is a draft and the caller did not create it; drafts are private until
+// they are opened or scheduled.
+func requireVisible(c *gin.Context, poll *models.Poll) bool {
+	userID, _ := auth.UserID(c)
+	if poll.Lifecycle() == models.StateDraft && (poll.CreatorID == "" || poll.CreatorID != userID) {
+		logging.FromContext(c.Request.Context()).Info("Draft poll hidden from caller", "poll_id", poll.ID, "user_id", userID)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Poll not found"})
+		return false
+	}
+	return true
+}
+
+// OpenPoll handles the creator opening a draft or scheduled poll. With an
+// "opens_at" time in the future the poll is scheduled to open then instead,
+// which also moves the opening time of a poll that is already scheduled.
+func (h *PollHandler) OpenPoll(c *gin.Context) {
+	var req lifecycleRequest
+	if !bindLifecycleRequest(c, &req) {
+		return
+	}
+	h.transition(c, func(poll *models.Poll, now time.Time) (int, string) {
+		switch poll.Lifecycle() {
+		case models.StateDraft, models.StateScheduled:
+		default:
+			return http.StatusConflict, "Only draft and scheduled polls can be opened"
+		}
+		if poll.IsExpired(now) {
+			return http.StatusConflict, "Poll has expired; set a new expiration date before opening it"
+		}
+		if req.OpensAt.After(now) {
+			if !poll.ExpiresAt.IsZero() && !req.OpensAt.Before(poll.ExpiresAt) {
+				return http.StatusBadRequest, "Opening time must be before the expiration date"
+			}
+			poll.State = models.StateScheduled
+			poll.OpensAt = req.OpensAt
+			return 0, ""
+		}
+		poll.State = models.StateOpen
+		poll.OpensAt = now
+		return 0, ""
+	})
+}
+
+// ClosePoll handles the creator closing an open poll before it expires.
+// Its results are final from then on, unless the poll is reopened.
+func (h *PollHandler) ClosePoll(c *gin.Context) {
+	h.transition(c, func(poll *models.Poll, now time.Time) (int, string) {
+		if !poll.CanTransition(models.StateClosed) {
+			return http.StatusConflict, "Only open polls can be closed"
+		}
+		poll.State = models.StateClosed
+		poll.ClosedAt = now
+		if poll.IsExpired(now) {
+			poll.ClosedAt = poll.ExpiresAt // It stopped taking votes then
+		}
+		poll.Outbox = append(poll.Outbox, models.NewEvent(models.EventPollClosed, now))
+		return 0, ""
+	})
+}
+
+// ReopenPoll handles the creator reopening a closed poll. An "expires_at"
+// time sets a new expiration date; without one, a poll that closed because
+// it expired stays open until it is closed again.
+func (h *PollHandler) ReopenPoll(c *gin.Context) {
+	var req lifecycleRequest
+	if !bindLifecycleRequest(c, &req) {
+		return
+	}
+	h.transition(c, func(poll *models.Poll, now time.Time) (int, string) {
+		if poll.Lifecycle() != models.StateClosed {
+			return http.StatusConflict, "Only closed polls can be reopened"
+		}
+		switch {
+		case !req.ExpiresAt.IsZero():
+			if !req.ExpiresAt.After(now) {
+				return http.StatusBadRequest, "Expiration date must be in the future"
+			}
+			poll.ExpiresAt = req.ExpiresAt
+		case poll.IsExpired(now):
+			poll.ExpiresAt = time.Time{}
+		}
+		poll.State = models.StateOpen
+		poll.ClosedAt = time.Time{}
+		return 0, ""
+	})
+}
+
+// ArchivePoll handles the creator archiving a closed poll, which can then
+// never be reopened.
+func (h *PollHandler) ArchivePoll(c *gin.Context) {
+	h.transition(c, func(poll *models.Poll, now time.Time) (int, string) {
+		if !poll.CanTransition(models.StateArchived) {
+			return http.StatusConflict, "Only closed polls can be archived"
+		}
+		poll.State = models.StateArchived
+		return 0, ""
+	})
+}
+
+// bindLifecycleRequest reads the optional body of a lifecycle endpoint.
+// If it is malformed it responds with 400 Bad Request and returns false.
+func bindLifecycleRequest(c *gin.Context, req *lifecycleRequest) bool {
+	if err := c.ShouldBindJSON(req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+// transition loads the poll named in the request and, if the caller created
+// it, moves it to a new state with apply, which changes the poll or returns
+// the status and message to refuse the change with. The updated poll is
+// published to live subscribers and returned.
+func (h *PollHandler) transition(c *gin.Context, apply func(poll *models.Poll, now time.Time) (int, string)) {
+	pollID := c.Param("id")
+	if pollID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Poll ID parameter is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeouts.Request)
+	defer cancel()
+
+	poll, err := h.store.Get(ctx, pollID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			logging.FromContext(ctx).Info("Poll not found", "poll_id", pollID)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Poll not found"})
+		} else {
+			logging.FromContext(ctx).Error("Failed to retrieve poll", "poll_id", pollID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve poll"})
+		}
+		return
+	}
+	if !requireCreator(c, poll) {
+		return
+	}
+
+	now := time.Now()
+	updated := *poll
+	if status, message := apply(&updated, now); status != 0 {
+		logging.FromContext(ctx).Info("State change rejected", "poll_id", pollID, "state", poll.Lifecycle(), "reason", message)
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+	updated.UpdatedAt = now
+	updated.Version = poll.Version + 1
+
+	if err := h.store.Update(ctx, &updated, poll.Version); err != nil {
+		switch {
+		case errors.Is(err, store.ErrVersionConflict):
+			c.JSON(http.StatusConflict, gin.H{"error": "Poll has been modified since it was read; reload and try again"})
+		case errors.Is(err, store.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Poll not found"})
+		default:
+			logging.FromContext(ctx).Error("Failed to change poll state", "poll_id", pollID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update poll"})
+		}
+		return
+	}
+	logging.FromContext(ctx).Info("Changed poll state", "poll_id", pollID, "state", updated.State)
+
+	// Tell live subscribers the poll opened or closed.
+	h.hub.Publish(&updated)
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// notAcceptingVotes explains why a poll that does not accept votes at now
+// refuses them
+func notAcceptingVotes(poll *models.Poll, now time.Time) string {
+	switch poll.Lifecycle() {
+	case models.StateClosed, models.StateArchived:
+		return "Poll is closed"
+	}
+	if poll.IsExpired(now) {
+		return "Poll has expired"
+	}
+	return "Poll is not open yet"
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"instapoll/backend/live"
+	"instapoll/backend/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lifecyclePoll is a poll creation payload in the given state
+func lifecyclePoll(state string) string {
+	return `{"title": "Lunch?", "state": "` + state + `", "options": [{"text": "Pizza"}, {"text": "Sushi"}]}`
+}
+
+// assertError checks a response's status and error message
+func assertError(t *testing.T, w *httptest.ResponseRecorder, status int, message string) {
+	t.Helper()
+	require.Equal(t, status, w.Code, w.Body.String())
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, message, body["error"])
+}
+
+func TestPollLifecycle(t *testing.T) {
+	router := setupRouter(newTestStore())
+	poll := createPoll(t, router, lifecyclePoll(models.StateDraft))
+	assert.Equal(t, models.StateDraft, poll.State)
+	path := "/api/polls/" + poll.ID
+	vote := `{"option_id": "` + poll.Options[0].ID + `"}`
+
+	// Drafts are private to their creator and take no votes.
+	assert.Equal(t, http.StatusNotFound, getAs(router, "", path).Code)
+	assert.Equal(t, http.StatusNotFound, getAs(router, "someone-else", path+"/results").Code)
+	assert.Equal(t, models.StateDraft, decodePoll(t, getAs(router, testUserID, path)).State)
+	assertError(t, postVote(router, poll.ID, vote), http.StatusForbidden, "Poll is not open yet")
+	assert.Empty(t, listPolls(t, router, "").Items, "Drafts are not listed by default")
+	var drafts PollList
+	require.NoError(t, json.Unmarshal(getAs(router, testUserID, "/api/polls?state=draft").Body.Bytes(), &drafts))
+	assert.Len(t, drafts.Items, 1, "Creators list their own drafts")
+	assert.Equal(t, http.StatusUnauthorized, getAs(router, "", "/api/polls?state=draft").Code)
+
+	// Only the creator can open it.
+	assert.Equal(t, http.StatusForbidden, sendJSONAs(router, "someone-else", "POST", path+"/open", "").Code)
+	opened := decodePoll(t, sendJSON(router, "POST", path+"/open", ""))
+	assert.Equal(t, models.StateOpen, opened.State)
+	assert.False(t, opened.OpensAt.IsZero())
+	assert.Equal(t, 2, opened.Version)
+	require.Equal(t, http.StatusOK, postVote(router, poll.ID, vote).Code)
+
+	closed := decodePoll(t, sendJSON(router, "POST", path+"/close", ""))
+	assert.Equal(t, models.StateClosed, closed.State)
+	assert.False(t, closed.ClosedAt.IsZero())
+	assert.Equal(t, 1, closed.TotalVotes, "Closing keeps the votes")
+	assertError(t, postVote(router, poll.ID, vote), http.StatusForbidden, "Poll is closed")
+	assertError(t, sendJSON(router, "POST", path+"/close", ""), http.StatusConflict, "Only open polls can be closed")
+
+	reopened := decodePoll(t, sendJSON(router, "POST", path+"/reopen", ""))
+	assert.Equal(t, models.StateOpen, reopened.State)
+	assert.True(t, reopened.ClosedAt.IsZero())
+	require.Equal(t, http.StatusOK, postVote(router, poll.ID, vote).Code)
+
+	assertError(t, sendJSON(router, "POST", path+"/archive", ""), http.StatusConflict, "Only closed polls can be archived")
+	decodePoll(t, sendJSON(router, "POST", path+"/close", ""))
+	archived := decodePoll(t, sendJSON(router, "POST", path+"/archive", ""))
+	assert.Equal(t, models.StateArchived, archived.State)
+	assertError(t, sendJSON(router, "POST", path+"/reopen", ""), http.StatusConflict, "Only closed polls can be reopened")
+	assert.Len(t, listPolls(t, router, "?state=archived").Items, 1)
+}
+
+func TestPollLifecycle_Scheduled(t *testing.T) {
+	router := setupRouter(newTestStore())
+	opensAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	poll := createPoll(t, router, `{"title": "Lunch?", "opens_at": "`+opensAt+`", "options": [{"text": "Pizza"}, {"text": "Sushi"}]}`)
+	assert.Equal(t, models.StateScheduled, poll.State, "Polls opening later are scheduled")
+	path := "/api/polls/" + poll.ID
+
+	// Scheduled polls are public but take no votes yet.
+	assert.Equal(t, models.StateScheduled, decodePoll(t, getAs(router, "", path)).State)
+	assertError(t, postVote(router, poll.ID, `{"option_id": "`+poll.Options[0].ID+`"}`), http.StatusForbidden, "Poll is not open yet")
+
+	// Opening again with a new time reschedules; without one, opens now.
+	later := time.Now().Add(2 * time.Hour).UTC().Truncate(time.Second)
+	rescheduled := decodePoll(t, sendJSON(router, "POST", path+"/open", `{"opens_at": "`+later.Format(time.RFC3339)+`"}`))
+	assert.Equal(t, models.StateScheduled, rescheduled.State)
+	assert.True(t, later.Equal(rescheduled.OpensAt))
+	assert.Equal(t, models.StateOpen, decodePoll(t, sendJSON(router, "POST", path+"/open", "")).State)
+	assertError(t, sendJSON(router, "POST", path+"/open", ""), http.StatusConflict, "Only draft and scheduled polls can be opened")
+}
+
+func TestPollLifecycle_Reopen(t *testing.T) {
+	s := newTestStore()
+	router := setupRouter(s)
+	poll := insertTestPoll(t, s, "plurality", time.Now().Add(-time.Second))
+	path := "/api/polls/" + poll.ID
+
+	// Closing an expired poll records when it expired.
+	closed := decodePoll(t, sendJSON(router, "POST", path+"/close", ""))
+	assert.True(t, poll.ExpiresAt.Equal(closed.ClosedAt))
+
+	assertError(t, sendJSON(router, "POST", path+"/reopen", `{"expires_at": "2000-01-01T00:00:00Z"}`),
+		http.StatusBadRequest, "Expiration date must be in the future")
+
+	// Without a new expiration date the old one is dropped.
+	reopened := decodePoll(t, sendJSON(router, "POST", path+"/reopen", ""))
+	assert.Equal(t, models.StateOpen, reopened.State)
+	assert.True(t, reopened.ExpiresAt.IsZero())
+	require.Equal(t, http.StatusOK, postVote(router, poll.ID, `{"option_id": "`+poll.Options[0].ID+`"}`).Code)
+}
+
+func TestCreatePoll_InvalidState(t *testing.T) {
+	router := setupRouter(newTestStore())
+	for _, state := range []string{models.StateClosed, models.StateArchived, "paused"} {
+		w := sendJSON(router, "POST", "/api/polls", lifecyclePoll(state))
+		assert.Equal(t, http.StatusBadRequest, w.Code, "state %q", state)
+	}
+	assert.Equal(t, http.StatusBadRequest, getAs(router, "", "/api/polls?state=paused").Code)
+}
+
+func TestClosePoll_Streams(t *testing.T) {
+	s := newTestStore()
+	router := setupRouter(s)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close) // Runs after the event streams are closed
+	poll := insertTestPoll(t, s, "plurality", time.Time{})
+
+	conn, _, err := dialPoll(t, server, poll.ID)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, live.TypeSnapshot, readMessage(t, conn).Type)
+	_, events := openEvents(t, server, poll.ID, "")
+	readEvent(t, events)
+
+	require.Equal(t, http.StatusOK, postVote(router, poll.ID, `{"option_id": "`+poll.Options[0].ID+`"}`).Code)
+	assert.Equal(t, live.TypeDiff, readMessage(t, conn).Type)
+	assert.Equal(t, "poll", readEvent(t, events).Name)
+
+	decodePoll(t, sendJSON(router, "POST", "/api/polls/"+poll.ID+"/close", ""))
+
+	// Both streams announce the final counts.
+	msg := readMessage(t, conn)
+	assert.Equal(t, live.TypeClosed, msg.Type)
+	assert.Equal(t, models.StateClosed, msg.State)
+	assert.Equal(t, 1, msg.TotalVotes)
+	assert.Equal(t, 1, msg.Counts[poll.Options[0].ID])
+
+	event := readEvent(t, events)
+	assert.Equal(t, "closed", event.Name)
+	assert.Equal(t, "1", event.ID)
+	assert.Equal(t, models.StateClosed, decodeEvent(t, event).State)
+}
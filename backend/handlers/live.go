@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"instapoll/backend/live"
+	"instapoll/backend/logging"
+	"instapoll/backend/models"
+	"instapoll/backend/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Time allowed to write a message to a WebSocket client
+	wsWriteTimeout = 10 * time.Second
+	// Time allowed between pongs before a WebSocket client is considered gone
+	wsPongTimeout = 60 * time.Second
+	// How often to ping WebSocket clients; must be less than wsPongTimeout
+	wsPingInterval = wsPongTimeout * 9 / 10
+
+	// How often to send a comment on an idle event stream, well below the
+	// idle timeout of common load balancers and proxies
+	sseHeartbeatInterval = 15 * time.Second
+	// How long EventSource clients should wait before reconnecting
+	sseRetry = 3 * time.Second
+)
+
+// upgrader turns HTTP requests into WebSocket connections.
+// The frontend is served from a different origin than the API, so any
+// origin is allowed; the stream only exposes data GetPoll already returns.
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+// StreamResults handles a WebSocket connection that follows a poll's votes.
+// The client first receives a snapshot of every option's count, then a diff
+// each time a vote is recorded and an "opened" or "closed" message when the
+// poll opens or closes. Clients that cannot keep up are disconnected
+// and should reconnect to get a fresh snapshot, as are clients who may no
+// longer see the results after the poll's results visibility changed.
+func (h *PollHandler) StreamResults(c *gin.Context) {
+	pollID := c.Param("id")
+	if pollID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Poll ID parameter is required"})
+		return
+	}
+
+	// Subscribe before reading the snapshot so no vote recorded in between is missed.
+	sub := h.hub.Subscribe(pollID)
+	defer h.hub.Unsubscribe(sub)
+
+	poll, err := h.getPoll(c.Request.Context(), pollID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			logging.FromContext(c.Request.Context()).Info("Poll not found", "poll_id", pollID)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Poll not found"})
+		} else {
+			logging.FromContext(c.Request.Context()).Error("Failed to retrieve poll", "poll_id", pollID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve poll"})
+		}
+		return
+	}
+	// The stream only carries counts, so it is refused while they are hidden.
+	if !requireVisible(c, poll) || !h.checkStreamResults(c, poll) {
+		return
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already written an HTTP error response.
+		logging.FromContext(c.Request.Context()).Info("WebSocket upgrade failed", "poll_id", pollID, "error", err)
+		return
+	}
+	defer conn.Close()
+
+	// Seed the hub with the snapshot so the first diff is relative to it.
+	h.hub.Publish(poll)
+	snapshot := live.Snapshot(poll)
+	checked := poll.Version
+	if err := writeWS(conn, snapshot); err != nil {
+		return
+	}
+
+	// Read in the background so pongs and close frames are processed;
+	// clients are not expected to send anything else.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadLimit(512)
+		conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case msg, ok := <-sub.C:
+			if !ok {
+				logging.FromContext(c.Request.Context()).Info("Dropping slow WebSocket client", "poll_id", pollID)
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "client too slow"),
+					time.Now().Add(wsWriteTimeout))
+				return
+			}
+			if msg.Type == live.TypeDiff && msg.TotalVotes <= snapshot.TotalVotes {
+				continue // Already included in the snapshot
+			}
+			if visible, err := h.checkVisible(c, msg.Poll, &checked); err != nil || !visible {
+				if err == nil {
+					conn.WriteControl(websocket.CloseMessage,
+						websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "results are hidden"),
+						time.Now().Add(wsWriteTimeout))
+				}
+				return
+			}
+			if err := writeWS(conn, msg); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// writeWS sends a JSON message to a WebSocket client with a write deadline
+func writeWS(conn *websocket.Conn, msg live.Message) error {
+	conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return conn.WriteJSON(msg)
+}
+
+// StreamEvents serves a poll as a Server-Sent Events stream, for clients
+// that cannot open a WebSocket. Each "poll" event carries the same payload
+// as GetPoll and is sent whenever the counts change; "opened" and "closed"
+// events carry the same payload when the poll opens or closes. Each event's
+// ID is the poll's total vote count. A client reconnecting with a
+// Last-Event-ID header only receives the poll again if votes were cast while
+// it was away. The stream ends once the caller may no longer see the results.
+func (h *PollHandler) StreamEvents(c *gin.Context) {
+	pollID := c.Param("id")
+	if pollID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Poll ID parameter is required"})
+		return
+	}
+
+	// Subscribe before reading the poll so no vote recorded in between is missed.
+	sub := h.hub.Subscribe(pollID)
+	defer h.hub.Unsubscribe(sub)
+
+	poll, err := h.getPoll(c.Request.Context(), pollID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			logging.FromContext(c.Request.Context()).Info("Poll not found", "poll_id", pollID)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Poll not found"})
+		} else {
+			logging.FromContext(c.Request.Context()).Error("Failed to retrieve poll", "poll_id", pollID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve poll"})
+		}
+		return
+	}
+	// Each event reveals that votes were cast, so the stream is refused
+	// while the counts are hidden.
+	if !requireVisible(c, poll) || !h.checkStreamResults(c, poll) {
+		return
+	}
+	h.hub.Publish(poll)
+	checked := poll.Version
+
+	// lastSent is the total vote count the client has already seen, or -1.
+	// An unparseable Last-Event-ID is treated as no ID at all.
+	lastSent := -1
+	if id, err := strconv.Atoi(c.GetHeader("Last-Event-ID")); err == nil {
+		lastSent = id
+	}
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no") // Stop nginx from buffering the stream
+	c.Status(http.StatusOK)
+	fmt.Fprintf(c.Writer, "retry: %d\n\n", sseRetry.Milliseconds())
+
+	if poll.TotalVotes > lastSent {
+		if err := writeSSE(c.Writer, "poll", poll); err != nil {
+			return
+		}
+		lastSent = poll.TotalVotes
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case msg, ok := <-sub.C:
+			if !ok {
+				// Dropped for being too slow; EventSource reconnects with the
+				// last ID it received and catches up from there.
+				logging.FromContext(c.Request.Context()).Info("Dropping slow event stream client", "poll_id", pollID)
+				return
+			}
+			// Opening and closing are always sent; vote counts only if new.
+			event := "poll"
+			switch msg.Type {
+			case live.TypeOpened, live.TypeClosed:
+				event = msg.Type
+			default:
+				if msg.TotalVotes <= lastSent {
+					continue
+				}
+			}
+			// The hub sends the whole poll along with the diff, so it is
+			// not read again for every client.
+			poll := msg.Poll
+			if poll.IsDeleted() {
+				return
+			}
+			if visible, err := h.checkVisible(c, poll, &checked); err != nil || !visible {
+				return
+			}
+			if err := writeSSE(c.Writer, event, poll); err != nil {
+				return
+			}
+			lastSent = poll.TotalVotes
+			c.Writer.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// getPoll reads a poll with the usual request timeout
+func (h *PollHandler) getPoll(ctx context.Context, pollID string) (*models.Poll, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.timeouts.Request)
+	defer cancel()
+	return h.store.Get(ctx, pollID)
+}
+
+// checkStreamResults is requireResults with the usual request timeout, for
+// streams whose own context has none
+func (h *PollHandler) checkStreamResults(c *gin.Context, poll *models.Poll) bool {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeouts.Request)
+	defer cancel()
+	return h.requireResults(ctx, c, poll)
+}
+
+// checkVisible reports whether the caller may still see the results of a
+// streamed poll whose visibility was last decided at version *checked.
+// Visibility only changes along with the poll's version, so it is decided
+// again only for a newer version, which is then recorded in *checked; the
+// poll is the one the hub sent, so this needs no read unless the caller's
+// vote must be looked up.
+func (h *PollHandler) checkVisible(c *gin.Context, poll *models.Poll, checked *int) (bool, error) {
+	if poll.Version <= *checked {
+		return true, nil
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeouts.Request)
+	defer cancel()
+	visible, err := h.resultsVisible(ctx, c, poll)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to check whether the caller voted", "poll_id", poll.ID, "error", err)
+		return false, err
+	}
+	if !visible {
+		logging.FromContext(ctx).Info("Ending stream, results are now hidden", "poll_id", poll.ID)
+		return false, nil
+	}
+	*checked = poll.Version
+	return true, nil
+}
+
+// writeSSE writes a poll as an event whose ID is its total vote count
+func writeSSE(w gin.ResponseWriter, event string, poll *models.Poll) error {
+	data, err := json.Marshal(poll)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", poll.TotalVotes, event, data)
+	return err
+}
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"instapoll/backend/live"
+	"instapoll/backend/models"
+	"instapoll/backend/store"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dialPoll opens a WebSocket connection to a poll's live stream
+func dialPoll(t *testing.T, server *httptest.Server, pollID string) (*websocket.Conn, *http.Response, error) {
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/polls/" + pollID + "/ws"
+	return websocket.DefaultDialer.Dial(url, nil)
+}
+
+// readMessage reads the next live message, failing the test if none arrives
+func readMessage(t *testing.T, conn *websocket.Conn) live.Message {
+	var msg live.Message
+	conn.SetReadDeadline(time.Now().Add(defaultTimeout))
+	require.NoError(t, conn.ReadJSON(&msg))
+	return msg
+}
+
+func TestStreamResults(t *testing.T) {
+	s := newTestStore()
+	router := setupRouter(s)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	poll := insertTestPoll(t, s, "plurality", time.Now().Add(time.Hour))
+	yes, no := poll.Options[0].ID, poll.Options[1].ID
+
+	conn, _, err := dialPoll(t, server, poll.ID)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// The first message is a snapshot of every option.
+	msg := readMessage(t, conn)
+	assert.Equal(t, live.TypeSnapshot, msg.Type)
+	assert.Equal(t, poll.ID, msg.PollID)
+	assert.Equal(t, 0, msg.TotalVotes)
+	assert.Equal(t, map[string]int{yes: 0, no: 0}, msg.Counts)
+
+	// Each vote is followed by a diff carrying only the changed option.
+	w := postVote(router, poll.ID, `{"option_id": "`+yes+`"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	msg = readMessage(t, conn)
+	assert.Equal(t, live.TypeDiff, msg.Type)
+	assert.Equal(t, 1, msg.TotalVotes)
+	assert.Equal(t, map[string]int{yes: 1}, msg.Counts)
+
+	w = postVote(router, poll.ID, `{"option_id": "`+no+`"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	msg = readMessage(t, conn)
+	assert.Equal(t, 2, msg.TotalVotes)
+	assert.Equal(t, map[string]int{no: 1}, msg.Counts)
+}
+
+// countingStore counts the polls read from a store
+type countingStore struct {
+	store.PollStore
+	gets atomic.Int64
+}
+
+func (s *countingStore) Get(ctx context.Context, id string) (*models.Poll, error) {
+	s.gets.Add(1)
+	return s.PollStore.Get(ctx, id)
+}
+
+func TestStreamResults_NoReadPerClient(t *testing.T) {
+	s := &countingStore{PollStore: newTestStore()}
+	router := setupRouter(s)
+	server := httptest.NewServer(router)
+	defer server.Close()
+	poll := insertTestPoll(t, s, "plurality", time.Now().Add(time.Hour))
+	vote := `{"option_id": "` + poll.Options[0].ID + `"}`
+
+	// Measure what a vote reads with nobody watching.
+	before := s.gets.Load()
+	require.Equal(t, http.StatusOK, postVote(router, poll.ID, vote).Code)
+	perVote := s.gets.Load() - before
+
+	var conns []*websocket.Conn
+	for i := 0; i < 3; i++ {
+		conn, _, err := dialPoll(t, server, poll.ID)
+		require.NoError(t, err)
+		defer conn.Close()
+		readMessage(t, conn) // Snapshot
+		conns = append(conns, conn)
+	}
+
+	// A vote reaches every client without each of them reading the poll.
+	before = s.gets.Load()
+	require.Equal(t, http.StatusOK, postVote(router, poll.ID, vote).Code)
+	for _, conn := range conns {
+		assert.Equal(t, 2, readMessage(t, conn).TotalVotes)
+	}
+	assert.Equal(t, perVote, s.gets.Load()-before, "streams should not read the poll for each client")
+}
+
+func TestStreamResults_NotFound(t *testing.T) {
+	server := httptest.NewServer(setupRouter(newTestStore()))
+	defer server.Close()
+
+	_, resp, err := dialPoll(t, server, "missing")
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+// sseEvent is one event read from a Server-Sent Events stream
+type sseEvent struct {
+	ID   string
+	Name string
+	Data string
+}
+
+// openEvents connects to a poll's event stream. The stream is closed when
+// the test ends.
+func openEvents(t *testing.T, server *httptest.Server, pollID, lastEventID string) (*http.Response, *bufio.Reader) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/api/polls/"+pollID+"/events", nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp, bufio.NewReader(resp.Body)
+}
+
+// readEvent reads the next event with data, skipping retry hints and comments
+func readEvent(t *testing.T, r *bufio.Reader) sseEvent {
+	var event sseEvent
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			if event.Data != "" {
+				return event
+			}
+		case strings.HasPrefix(line, "id: "):
+			event.ID = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event.Name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			event.Data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+// decodeEvent parses an event's data as a poll
+func decodeEvent(t *testing.T, event sseEvent) models.Poll {
+	var poll models.Poll
+	require.NoError(t, json.Unmarshal([]byte(event.Data), &poll))
+	return poll
+}
+
+func TestStreamEvents(t *testing.T) {
+	s := newTestStore()
+	router := setupRouter(s)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close) // Runs after the event streams are closed
+
+	poll := insertTestPoll(t, s, "plurality", time.Now().Add(time.Hour))
+
+	resp, events := openEvents(t, server, poll.ID, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	// The current state is sent straight away.
+	event := readEvent(t, events)
+	assert.Equal(t, "poll", event.Name)
+	assert.Equal(t, "0", event.ID)
+	got := decodeEvent(t, event)
+	assert.Equal(t, poll.ID, got.ID)
+	assert.Equal(t, poll.Title, got.Title)
+
+	// A vote sends the full poll again with the new counts.
+	w := postVote(router, poll.ID, `{"option_id": "`+poll.Options[1].ID+`"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	event = readEvent(t, events)
+	assert.Equal(t, "1", event.ID)
+	got = decodeEvent(t, event)
+	assert.Equal(t, 1, got.TotalVotes)
+	assert.Equal(t, 1, got.Options[1].VoteCount)
+}
+
+func TestStreamEvents_Resume(t *testing.T) {
+	s := newTestStore()
+	router := setupRouter(s)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close) // Runs after the event streams are closed
+
+	poll := insertTestPoll(t, s, "plurality", time.Now().Add(time.Hour))
+	for i := 0; i < 2; i++ {
+		w := postVote(router, poll.ID, `{"option_id": "`+poll.Options[0].ID+`"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	// A client that missed a vote gets the current state on reconnect.
+	_, events := openEvents(t, server, poll.ID, "1")
+	event := readEvent(t, events)
+	assert.Equal(t, "2", event.ID)
+	assert.Equal(t, 2, decodeEvent(t, event).Options[0].VoteCount)
+
+	// A client that is up to date only hears about the next vote.
+	_, events = openEvents(t, server, poll.ID, "2")
+	w := postVote(router, poll.ID, `{"option_id": "`+poll.Options[1].ID+`"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	event = readEvent(t, events)
+	assert.Equal(t, "3", event.ID)
+}
+
+func TestStreamEvents_NoReadPerClient(t *testing.T) {
+	s := &countingStore{PollStore: newTestStore()}
+	router := setupRouter(s)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close) // Runs after the event streams are closed
+	poll := insertTestPoll(t, s, "plurality", time.Now().Add(time.Hour))
+	vote := `{"option_id": "` + poll.Options[0].ID + `"}`
+
+	before := s.gets.Load()
+	require.Equal(t, http.StatusOK, postVote(router, poll.ID, vote).Code)
+	perVote := s.gets.Load() - before
+
+	var streams []*bufio.Reader
+	for i := 0; i < 3; i++ {
+		_, events := openEvents(t, server, poll.ID, "")
+		readEvent(t, events) // Current state
+		streams = append(streams, events)
+	}
+
+	// Every stream gets the full poll without reading it again.
+	before = s.gets.Load()
+	require.Equal(t, http.StatusOK, postVote(router, poll.ID, vote).Code)
+	for _, events := range streams {
+		got := decodeEvent(t, readEvent(t, events))
+		assert.Equal(t, 2, got.TotalVotes)
+		assert.Equal(t, poll.Title, got.Title)
+	}
+	assert.Equal(t, perVote, s.gets.Load()-before, "streams should not read the poll for each client")
+}
+
+func TestStreamEvents_NotFound(t *testing.T) {
+	server := httptest.NewServer(setupRouter(newTestStore()))
+	t.Cleanup(server.Close) // Runs after the event streams are closed
+
+	resp, _ := openEvents(t, server, "missing", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -3,62 +3,137 @@ package handlers
 import (
 	"context"
 	"errors"
-	"log"
 	"net/http"
+	"net/netip"
+	"strconv"
 	"time"
 
+	"instapoll/backend/auth"
+	"instapoll/backend/live"
+	"instapoll/backend/logging"
+	"instapoll/backend/metrics"
 	"instapoll/backend/models" // Import the Poll model
+	"instapoll/backend/tabulation"
+
+	"instapoll/backend/store"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo" // Import mongo driver types
-	// "go.mongodb.org/mongo-driver/bson/primitive" // May be needed if using default MongoDB ObjectID
 )
 
-// PollHandler holds the database collection for polls
+// PollHandler holds the storage used for polls and their ballots,
+// the hub that streams vote counts to live subscribers, the token
+// manager that identifies callers, and how long requests wait on the store
 type PollHandler struct {
-	collection *mongo.Collection // Pointer to the MongoDB collection
+	store    store.PollStore // MongoDB in production, in-memory for tests and local development
+	hub      *live.Hub       // Notified after every recorded vote
+	tokens   *auth.Manager   // Verifies the access tokens sent by signed-in users
+	timeouts Timeouts        // Bound every store operation
+	proxies  []netip.Prefix  // Reverse proxies trusted to say how clients connected
 }
 
-// NewPollHandler creates a new handler with the given MongoDB collection.
+// NewPollHandler creates a new handler with the given poll store, live hub,
+// token manager and timeouts.
 // This acts as a constructor for PollHandler.
-func NewPollHandler(collection *mongo.Collection) *PollHandler {
+func NewPollHandler(s store.PollStore, hub *live.Hub, tokens *auth.Manager, timeouts Timeouts) *PollHandler {
 	// Return a pointer to a new PollHandler instance,
-	// initializing its collection field with the provided argument.
+	// initializing its fields with the provided arguments.
 	return &PollHandler{
-		collection: collection,
+		store:    s,
+		hub:      hub,
+		tokens:   tokens,
+		timeouts: timeouts,
 	}
 }
 
 // RegisterRoutes sets up the poll-related routes for the Gin engine.
 // It accepts the gin.Engine directly to register the route group.
+// Viewing and voting are public, though each poll's results visibility
+// decides who sees its counts and drafts are only shown to their creator;
+// creating and changing polls requires a
+// signed-in user, and only a poll's creator may change it.
 func (h *PollHandler) RegisterRoutes(r *gin.Engine) {
-	// Create a route group for API endpoints prefixed with /api/polls
-	polls := r.Group("/api/polls")
+	// Create a route group for API endpoints prefixed with /api/polls.
+	// Every route identifies the caller if they send an access token.
+	polls := r.Group("/api/polls", h.tokens.Identify)
+	{
+		// Public routes: anyone can view polls and vote.
+		polls.GET("", h.ListPolls)                          // Handle GET requests to /api/polls
+		polls.GET("/:id", h.GetPoll)                        // Handle GET requests to /api/polls/:id (with path parameter)
+		polls.POST("/:id/votes", h.CastVote)                // Handle POST requests to /api/polls/:id/votes
+		polls.GET("/:id/results", h.GetResults)             // Handle GET requests to /api/polls/:id/results
+		polls.GET("/:id/results/explain", h.ExplainResults) // Step-by-step account of /api/polls/:id/results
+		polls.GET("/:id/ws", h.StreamResults)               // WebSocket stream of live vote counts for /api/polls/:id
+		polls.GET("/:id/events", h.StreamEvents)            // Server-Sent Events stream of /api/polls/:id
+	}
+	authenticated := polls.Group("", auth.RequireUser)
 	{
-		// Assign handler methods (which now belong to PollHandler) to specific
-		// HTTP methods and paths within the group.
-		polls.POST("", h.CreatePoll) // Handle POST requests to /api/polls
-		polls.GET("", h.ListPolls)   // Handle GET requests to /api/polls
-		polls.GET("/:id", h.GetPoll) // Handle GET requests to /api/polls/:id (with path parameter)
-		// TODO: Add routes for PUT /:id (UpdatePoll) and DELETE /:id (DeletePoll) later
+		// Authenticated routes: a signed-in user creates polls and manages their own.
+		authenticated.POST("", h.CreatePoll)                          // Handle POST requests to /api/polls
+		authenticated.PUT("/:id", h.UpdatePoll)                       // Handle PUT requests to /api/polls/:id
+		authenticated.DELETE("/:id", h.DeletePoll)                    // Handle DELETE requests to /api/polls/:id (soft delete)
+		authenticated.POST("/:id/restore", h.RestorePoll)             // Handle POST requests to /api/polls/:id/restore
+		authenticated.POST("/:id/invites", h.CreateInvites)           // Issue single-use invites for /api/polls/:id
+		authenticated.PUT("/:id/tie-break-order", h.SetTieBreakOrder) // Creator's order for breaking ties on /api/polls/:id
+		authenticated.POST("/:id/open", h.OpenPoll)                   // Open or schedule a draft /api/polls/:id
+		authenticated.POST("/:id/close", h.ClosePoll)                 // Close /api/polls/:id before it expires
+		authenticated.POST("/:id/reopen", h.ReopenPoll)               // Reopen a closed /api/polls/:id
+		authenticated.POST("/:id/archive", h.ArchivePoll)             // Archive a closed /api/polls/:id for good
 	}
 }
 
+// requireCreator responds with 403 Forbidden and returns false unless the
+// caller created the poll. Polls created anonymously cannot be changed.
+func requireCreator(c *gin.Context, poll *models.Poll) bool {
+	userID, _ := auth.UserID(c)
+	if poll.CreatorID == "" || poll.CreatorID != userID {
+		logging.FromContext(c.Request.Context()).Info("Only the creator may modify the poll", "poll_id", poll.ID, "user_id", userID, "creator_id", poll.CreatorID)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the poll's creator can modify it"})
+		return false
+	}
+	return true
+}
+
 // CreatePoll handles the creation of a new poll.
-// It's now a method on PollHandler, allowing access to h.collection.
+// It's now a method on PollHandler, allowing access to h.store.
 func (h *PollHandler) CreatePoll(c *gin.Context) {
 	var poll models.Poll // Declare a variable to hold the incoming poll data
 
 	// Attempt to bind the incoming JSON request body to the poll struct.
 	if err := c.ShouldBindJSON(&poll); err != nil {
 		// If binding fails (e.g., malformed JSON), return a Bad Request error.
-		log.Printf("Error binding JSON: %v", err)
+		logging.FromContext(c.Request.Context()).Info("Invalid request body", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
 		return
 	}
 
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeouts.Request) // Use request context with timeout
+	defer cancel()
+
+	// The poll belongs to the signed-in user creating it.
+	creatorID, _ := auth.UserID(c)
+	if err := h.Create(ctx, &poll, creatorID); err != nil {
+		var invalid models.ErrInvalidPoll
+		if errors.As(err, &invalid) {
+			// If validation fails, return a Bad Request error with the validation message.
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create poll"})
+		}
+		return
+	}
+
+	// Return the newly created poll object (including generated IDs and timestamps)
+	// with an HTTP 201 Created status.
+	c.JSON(http.StatusCreated, poll)
+}
+
+// Create fills in a new poll from the settings a client chose, validates it
+// and stores it. It is how every poll is created, whether through the API
+// or an integration such as Slack. creatorID is the user creating the poll,
+// or empty for anonymous polls. A poll that fails validation returns a
+// models.ErrInvalidPoll.
+func (h *PollHandler) Create(ctx context.Context, poll *models.Poll, creatorID string) error {
 	// --- Prepare Poll Data ---
 	// Generate unique IDs for the poll and its options.
 	// Note: MongoDB often uses ObjectID as the default _id. If you prefer that,
@@ -69,34 +144,67 @@ func (h *PollHandler) CreatePoll(c *gin.Context) {
 		poll.Options[i].ID = uuid.New().String() // Assign a new UUID string to each option's ID
 		poll.Options[i].VoteCount = 0            // Initialize vote count to zero
 	}
+	poll.TotalVotes = 0
+	poll.CreatorID = creatorID
+	// Polls that don't choose a voting method, voter policy or results
+	// visibility use the defaults.
+	if poll.VotingMethod == "" {
+		poll.VotingMethod = models.DefaultVotingMethod
+	}
+	if poll.VoterPolicy == "" {
+		poll.VoterPolicy = models.DefaultVoterPolicy
+	}
+	if poll.ResultsVisibility == "" {
+		poll.ResultsVisibility = models.DefaultResultsVisibility
+	}
+	poll.ResultsHidden = false
+	// Every poll gets its tie-break seed now, before anyone votes, so a
+	// random tie-break cannot be rerolled once the result is known. The
+	// creator's tie-break order refers to option IDs, which only exist now.
+	seed, err := newTieBreakSeed()
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to generate tie-break seed", "error", err)
+		return err
+	}
+	poll.TieBreakSeed = seed
+	poll.TieBreakOrder = nil
 	// Set creation and update timestamps to the current time.
 	now := time.Now()
 	poll.CreatedAt = now
 	poll.UpdatedAt = now
+	poll.DeletedAt = time.Time{}
+	poll.Version = 1 // Updates must send this version back
+	// New polls open straight away unless they are drafts or open later.
+	switch poll.State {
+	case models.StateDraft:
+	case "", models.StateScheduled, models.StateOpen:
+		if poll.OpensAt.After(now) {
+			poll.State = models.StateScheduled
+		} else if poll.State != models.StateScheduled {
+			poll.State = models.StateOpen
+			poll.OpensAt = now
+		}
+	default:
+		return models.ErrInvalidPoll("new polls must be draft, scheduled or open")
+	}
+	poll.ClosedAt = time.Time{}
+	// Queued with the poll itself for webhooks to be told about it.
+	poll.Outbox = []models.Event{models.NewEvent(models.EventPollCreated, now)}
 
 	// --- Validate Poll Data ---
 	// Perform business logic validation using the method defined on the model.
 	if err := poll.Validate(); err != nil {
-		// If validation fails, return a Bad Request error with the validation message.
-		log.Printf("Validation failed for poll '%s': %v", poll.Title, err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
-		return
+		logging.FromContext(ctx).Info("Poll failed validation", "error", err)
+		return err
 	}
 
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second) // Use request context with timeout
-	defer cancel()
-
-	_, err := h.collection.InsertOne(ctx, poll) // Insert the poll document
-	if err != nil {
-		log.Printf("Error inserting poll into database: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create poll"})
-		return
+	if err := h.store.Create(ctx, poll); err != nil { // Insert the poll document
+		logging.FromContext(ctx).Error("Failed to create poll", "poll_id", poll.ID, "error", err)
+		return err
 	}
-	log.Printf("Successfully inserted poll with ID: %s", poll.ID)
-
-	// Return the newly created poll object (including generated IDs and timestamps)
-	// with an HTTP 201 Created status.
-	c.JSON(http.StatusCreated, poll)
+	logging.FromContext(ctx).Info("Created poll", "poll_id", poll.ID)
+	metrics.PollsCreated.Inc()
+	return nil
 }
 
 // GetPoll handles retrieving a single poll by its ID.
@@ -112,82 +220,682 @@ func (h *PollHandler) GetPoll(c *gin.Context) {
 		return
 	}
 
-	var result models.Poll
-	// Create a filter to find the document where the 'id' field matches pollID.
-	// Note: If using MongoDB's default _id, the field name is "_id".
-	// If you stored your UUID string in the 'id' field, use "id". Adjust accordingly.
-	filter := bson.M{"_id": pollID} // Assuming you store your UUID in the _id field
-
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeouts.Request)
 	defer cancel()
 
-	// Attempt to find one document matching the filter.
-	err := h.collection.FindOne(ctx, filter).Decode(&result)
+	// Attempt to find the poll with the given ID.
+	result, err := h.store.Get(ctx, pollID)
 
 	if err != nil {
-		// Check if the error is because no document was found.
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			log.Printf("Poll not found with ID: %s", pollID)
+		// Check if the error is because no poll was found.
+		if errors.Is(err, store.ErrNotFound) {
+			logging.FromContext(ctx).Info("Poll not found", "poll_id", pollID)
 			c.JSON(http.StatusNotFound, gin.H{"error": "Poll not found"})
 		} else {
 			// Handle other potential database errors.
-			log.Printf("Error retrieving poll with ID %s: %v", pollID, err)
+			logging.FromContext(ctx).Error("Failed to retrieve poll", "poll_id", pollID, "error", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve poll"})
 		}
 		return
 	}
 
+	// Drafts are only shown to their creator, and vote counts are left out
+	// if the poll does not show them to the caller yet.
+	if !requireVisible(c, result) || !h.hideResultsFrom(ctx, c, result) {
+		return
+	}
+
 	// If found, return the poll data with HTTP 200 OK.
 	c.JSON(http.StatusOK, result)
-	return // Important to return here after successful handling
+}
 
-	// Return a 404 Not Found status as the database logic isn't implemented yet.
-	c.JSON(http.StatusNotFound, gin.H{"error": "Poll not found (DB fetch not implemented)"})
+// maxListLimit caps the page size clients may request from ListPolls
+const maxListLimit = 100
+
+// PollList is the response body of ListPolls
+type PollList struct {
+	Items   []models.Poll `json:"items"`
+	Limit   int           `json:"limit"`          // Page size actually used
+	HasMore bool          `json:"has_more"`       // Whether another page follows
+	Next    string        `json:"next,omitempty"` // Pass as ?cursor= to fetch the next page
 }
 
-// ListPolls handles retrieving a list of all polls.
-// It's now a method on PollHandler. Pagination should be added later.
+// ListPolls handles retrieving a page of polls.
+// Query parameters (all optional):
+//   - limit: page size, default 20 and capped at 100
+//   - cursor: the "next" token of the previous page
+//   - sort: created (default), updated, expires or votes
+//   - order: desc (default, newest or most first) or asc
+//   - status: active or expired
+//   - state: only polls in this state; drafts are only listed this way, and
+//     only the caller's own
+//   - q: only polls whose title contains this text, ignoring case
+//
+// A cursor is only valid with the sort and order of the page it came from.
 func (h *PollHandler) ListPolls(c *gin.Context) {
-	var results []models.Poll // Slice to hold the results
-
-	// Create an empty filter {} to match all documents.
-	filter := bson.M{}
+	query := store.ListQuery{
+		Sort:   c.DefaultQuery("sort", store.SortCreated),
+		Status: c.Query("status"),
+		State:  c.Query("state"),
+		Title:  c.Query("q"),
+		Now:    time.Now(),
+		Limit:  store.DefaultListLimit,
+	}
 
-	// Add query parameters for pagination later (e.g., limit, skip/offset)
-	// findOptions := options.Find()
-	// findOptions.SetLimit(10) // Example limit
+	switch query.Sort {
+	case store.SortCreated, store.SortUpdated, store.SortExpires, store.SortVotes:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be one of created, updated, expires or votes"})
+		return
+	}
+	switch c.DefaultQuery("order", "desc") {
+	case "desc":
+		query.Desc = true
+	case "asc":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order must be asc or desc"})
+		return
+	}
+	switch query.Status {
+	case store.StatusAll, store.StatusActive, store.StatusExpired:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be active or expired"})
+		return
+	}
+	switch query.State {
+	case "", models.StateScheduled, models.StateOpen, models.StateClosed, models.StateArchived:
+	case models.StateDraft:
+		userID, ok := auth.UserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Sign in to list your draft polls"})
+			return
+		}
+		query.CreatorID = userID
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "state must be one of draft, scheduled, open, closed or archived"})
+		return
+	}
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		query.Limit = min(limit, maxListLimit)
+	}
+	if token := c.Query("cursor"); token != "" {
+		cursor, err := store.DecodeCursor(token)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		query.After = cursor
+	}
 
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second) // Longer timeout for potentially larger lists
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeouts.List) // Longer timeout for potentially larger lists
 	defer cancel()
 
-	// Find documents matching the filter.
-	cursor, err := h.collection.Find(ctx, filter) // Add findOptions here if using pagination
+	page, err := h.store.List(ctx, query)
 	if err != nil {
-		log.Printf("Error finding polls: %v", err)
+		if errors.Is(err, store.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cursor does not match the requested sort order"})
+			return
+		}
+		logging.FromContext(ctx).Error("Failed to list polls", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve polls"})
 		return
 	}
-	// Ensure the cursor is closed when the function returns.
-	defer cursor.Close(ctx)
 
-	// Decode all documents found by the cursor into the results slice.
-	if err = cursor.All(ctx, &results); err != nil {
-		log.Printf("Error decoding polls from cursor: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode polls"})
+	// Whether the caller voted is not looked up for every poll, so polls
+	// showing results after a vote only show them here once they close.
+	userID, _ := auth.UserID(c)
+	for i := range page.Polls {
+		if !page.Polls[i].ResultsVisibleTo(userID, query.Now) {
+			page.Polls[i].HideResults()
+		}
+	}
+
+	response := PollList{Items: page.Polls, Limit: query.Limit}
+	if page.Next != nil {
+		response.HasMore = true
+		response.Next = page.Next.Encode()
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// UpdatePoll handles editing an existing poll.
+// The request body has the same shape as CreatePoll plus the "version" the
+// client last read; if the poll has been updated since, the request fails
+// with 409 Conflict so the client can reload instead of overwriting someone
+// else's changes. Options are matched by ID: options without an ID are added,
+// and options that already have votes can be neither changed nor removed.
+// The voting method, its settings, the voter policy and the results
+// visibility keep their current values when omitted.
+func (h *PollHandler) UpdatePoll(c *gin.Context) {
+	pollID := c.Param("id")
+	if pollID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Poll ID parameter is required"})
+		return
+	}
+
+	var input struct {
+		models.Poll
+		// Settings is nil when omitted, as an empty object clears them.
+		Settings *tabulation.Settings `json:"settings"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		logging.FromContext(c.Request.Context()).Info("Invalid request body", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	if input.Version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Version of the poll being updated is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeouts.Ballots) // Longer timeout as ballots are read
+	defer cancel()
+
+	current, err := h.store.Get(ctx, pollID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			logging.FromContext(ctx).Info("Poll not found", "poll_id", pollID)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Poll not found"})
+		} else {
+			logging.FromContext(ctx).Error("Failed to retrieve poll", "poll_id", pollID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve poll"})
+		}
+		return
+	}
+	if !requireCreator(c, current) {
+		return
+	}
+	if current.Version != input.Version {
+		logging.FromContext(ctx).Info("Update rejected, poll has been modified", "poll_id", pollID, "version", current.Version, "client_version", input.Version)
+		c.JSON(http.StatusConflict, gin.H{"error": "Poll has been modified since it was read; reload and try again"})
+		return
+	}
+
+	// An option has votes if its counter is non-zero or any ballot mentions
+	// it (ranked ballots only count their first preference).
+	ballots, err := h.store.Ballots(ctx, pollID)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to retrieve ballots", "poll_id", pollID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve ballots"})
+		return
+	}
+	voted := make(map[string]bool)
+	for _, option := range current.Options {
+		if option.VoteCount > 0 {
+			voted[option.ID] = true
+		}
+	}
+	for _, ballot := range ballots {
+		for _, optionID := range ballot.Options() {
+			voted[optionID] = true
+		}
+	}
+
+	// --- Merge the requested options with the stored ones ---
+	kept := make(map[string]bool, len(input.Options))
+	for i, option := range input.Options {
+		if option.ID == "" {
+			input.Options[i].ID = uuid.New().String() // New option
+			input.Options[i].VoteCount = 0
+			continue
+		}
+		existing := current.FindOption(option.ID)
+		if existing == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown option ID: " + option.ID})
+			return
+		}
+		if kept[option.ID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Duplicate option ID: " + option.ID})
+			return
+		}
+		kept[option.ID] = true
+		if voted[option.ID] && option.Text != existing.Text {
+			c.JSON(http.StatusConflict, gin.H{"error": "Cannot change option '" + existing.Text + "' because it already has votes"})
+			return
+		}
+		input.Options[i].VoteCount = existing.VoteCount
+	}
+	for _, option := range current.Options {
+		if !kept[option.ID] && voted[option.ID] {
+			c.JSON(http.StatusConflict, gin.H{"error": "Cannot remove option '" + option.Text + "' because it already has votes"})
+			return
+		}
+	}
+
+	if input.VotingMethod == "" {
+		input.VotingMethod = current.Method()
+	}
+	if input.Settings == nil {
+		input.Settings = &current.Settings
+	}
+	if len(ballots) > 0 && (input.VotingMethod != current.Method() || *input.Settings != current.Settings) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot change the voting method or its settings after votes have been cast"})
+		return
+	}
+	// Voter keys depend on the policy, so changing it would let everyone vote again.
+	if input.VoterPolicy == "" {
+		input.VoterPolicy = current.Policy()
+	}
+	if len(ballots) > 0 && input.VoterPolicy != current.Policy() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot change the voter policy after votes have been cast"})
+		return
+	}
+	// Results visibility can change at any time, e.g. to reveal results
+	// early or once the poll has closed.
+	if input.ResultsVisibility == "" {
+		input.ResultsVisibility = current.Visibility()
+	}
+
+	// --- Build the updated poll ---
+	updated := *current
+	updated.Title = input.Title
+	updated.Description = input.Description
+	updated.Options = input.Options
+	updated.VotingMethod = input.VotingMethod
+	updated.Settings = *input.Settings
+	updated.VoterPolicy = input.VoterPolicy
+	updated.ResultsVisibility = input.ResultsVisibility
+	updated.ExpiresAt = input.ExpiresAt
+	// The state only changes through the lifecycle endpoints, but polls
+	// that have not opened yet can be given a new opening time.
+	switch current.Lifecycle() {
+	case models.StateDraft, models.StateScheduled:
+		updated.OpensAt = input.OpensAt
+	}
+	// The creator's tie-break order only keeps the options that remain.
+	updated.TieBreakOrder = nil
+	for _, id := range current.TieBreakOrder {
+		if updated.FindOption(id) != nil {
+			updated.TieBreakOrder = append(updated.TieBreakOrder, id)
+		}
+	}
+	if updated.TieBreakSeed == "" {
+		// Polls created before tie-break seeds existed get one now.
+		if updated.TieBreakSeed, err = newTieBreakSeed(); err != nil {
+			logging.FromContext(ctx).Error("Failed to generate tie-break seed", "poll_id", pollID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update poll"})
+			return
+		}
+	}
+	updated.UpdatedAt = time.Now()
+	updated.Version = current.Version + 1
+
+	// A change of visibility alone is only checked itself, as the rest of a
+	// closed poll would no longer validate, e.g. its expiry is in the past.
+	validate := updated.Validate
+	if onlyVisibilityChanged(current, &updated) {
+		validate = updated.ValidateResultsVisibility
+	}
+	if err := validate(); err != nil {
+		logging.FromContext(ctx).Info("Poll failed validation", "poll_id", pollID, "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return
+	}
+
+	if err := h.store.Update(ctx, &updated, current.Version); err != nil {
+		switch {
+		case errors.Is(err, store.ErrVersionConflict):
+			logging.FromContext(ctx).Info("Update rejected, poll changed concurrently", "poll_id", pollID)
+			c.JSON(http.StatusConflict, gin.H{"error": "Poll has been modified since it was read; reload and try again"})
+		case errors.Is(err, store.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Poll not found"})
+		default:
+			logging.FromContext(ctx).Error("Failed to update poll", "poll_id", pollID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update poll"})
+		}
+		return
+	}
+	logging.FromContext(ctx).Info("Updated poll", "poll_id", pollID, "version", updated.Version)
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// onlyVisibilityChanged reports whether an update leaves everything the
+// creator can edit as it was, except perhaps the results visibility
+func onlyVisibilityChanged(current, updated *models.Poll) bool {
+	if updated.Title != current.Title ||
+		updated.Description != current.Description ||
+		updated.VotingMethod != current.Method() ||
+		updated.Settings != current.Settings ||
+		updated.VoterPolicy != current.Policy() ||
+		!updated.ExpiresAt.Equal(current.ExpiresAt) ||
+		!updated.OpensAt.Equal(current.OpensAt) ||
+		len(updated.Options) != len(current.Options) {
+		return false
+	}
+	for i, option := range updated.Options {
+		if option.ID != current.Options[i].ID || option.Text != current.Options[i].Text {
+			return false
+		}
+	}
+	return true
+}
+
+// DeletePoll handles soft-deleting a poll.
+// The poll disappears from every endpoint but is kept, together with its
+// ballots, so it can be brought back with RestorePoll.
+func (h *PollHandler) DeletePoll(c *gin.Context) {
+	pollID := c.Param("id")
+	if pollID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Poll ID parameter is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeouts.Request)
+	defer cancel()
+
+	poll, err := h.store.Get(ctx, pollID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			logging.FromContext(ctx).Info("Poll not found", "poll_id", pollID)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Poll not found"})
+		} else {
+			logging.FromContext(ctx).Error("Failed to retrieve poll", "poll_id", pollID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve poll"})
+		}
+		return
+	}
+	if !requireCreator(c, poll) {
 		return
 	}
 
-	// Important: If no documents are found, cursor.All returns an empty slice and no error.
-	// However, if the results slice was initially nil, encoding it to JSON might result in 'null'.
-	// Ensure we return an empty JSON array '[]' instead of 'null' if no polls exist.
-	if results == nil {
-		results = []models.Poll{}
+	if err := h.store.Delete(ctx, pollID, time.Now()); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			logging.FromContext(ctx).Info("Poll not found", "poll_id", pollID)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Poll not found"})
+		} else {
+			logging.FromContext(ctx).Error("Failed to delete poll", "poll_id", pollID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete poll"})
+		}
+		return
+	}
+	logging.FromContext(ctx).Info("Soft-deleted poll", "poll_id", pollID)
+
+	c.Status(http.StatusNoContent)
+}
+
+// RestorePoll handles undoing a soft delete.
+// Restoring a poll that is not deleted simply returns it.
+func (h *PollHandler) RestorePoll(c *gin.Context) {
+	pollID := c.Param("id")
+	if pollID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Poll ID parameter is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeouts.Request)
+	defer cancel()
+
+	// Deleted polls are hidden from Get, so look the poll up including them.
+	poll, err := h.store.GetIncludingDeleted(ctx, pollID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			logging.FromContext(ctx).Info("Poll not found", "poll_id", pollID)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Poll not found"})
+		} else {
+			logging.FromContext(ctx).Error("Failed to retrieve poll", "poll_id", pollID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve poll"})
+		}
+		return
+	}
+	if !requireCreator(c, poll) {
+		return
+	}
+
+	poll, err = h.store.Restore(ctx, pollID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			logging.FromContext(ctx).Info("Poll not found", "poll_id", pollID)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Poll not found"})
+		} else {
+			logging.FromContext(ctx).Error("Failed to restore poll", "poll_id", pollID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore poll"})
+		}
+		return
+	}
+	logging.FromContext(ctx).Info("Restored poll", "poll_id", pollID)
+
+	c.JSON(http.StatusOK, poll)
+}
+
+// CastVote handles casting a ballot on a poll.
+// The ballot's shape depends on the poll's voting method (a single choice,
+// a ranking, a set of approvals or a set of scores). The store keeps
+// the full ballot and atomically increments the vote counts of the options
+// it chooses, so concurrent voters never overwrite each other's increments.
+func (h *PollHandler) CastVote(c *gin.Context) {
+	pollID := c.Param("id")
+	if pollID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Poll ID parameter is required"})
+		return
+	}
+
+	var vote models.VoteRequest
+	if err := c.ShouldBindJSON(&vote); err != nil {
+		logging.FromContext(c.Request.Context()).Info("Invalid request body", "poll_id", pollID, "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeouts.Request)
+	defer cancel()
+
+	// Load the poll first so we can give the caller a precise error
+	// (unknown poll, expired poll, invalid ballot) before writing anything.
+	poll, err := h.store.Get(ctx, pollID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			logging.FromContext(ctx).Info("Vote rejected, poll not found", "poll_id", pollID)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Poll not found"})
+		} else {
+			logging.FromContext(ctx).Error("Failed to retrieve poll", "poll_id", pollID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve poll"})
+		}
+		return
 	}
 
-	// Return the list of polls with HTTP 200 OK.
-	c.JSON(http.StatusOK, results)
-	return // Important to return here after successful handling
+	now := time.Now()
+	if !poll.AcceptsVotes(now) {
+		logging.FromContext(ctx).Info("Vote rejected, poll does not accept votes", "poll_id", pollID, "state", poll.Lifecycle(), "expires_at", poll.ExpiresAt)
+		c.JSON(http.StatusForbidden, gin.H{"error": notAcceptingVotes(poll, now)})
+		return
+	}
+
+	// Work out who is voting; the store allows one ballot per voter key.
+	voterKey, ok := h.voterKey(c, poll, &vote)
+	if !ok {
+		return
+	}
+
+	updated, status, message := h.castBallot(ctx, poll, voterKey, &vote, now)
+	if status != 0 {
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	// Return the poll with its updated vote counts, if the voter may see
+	// them: having just voted, they can on polls showing results after a vote.
+	userID, _ := auth.UserID(c)
+	if !updated.ResultsVisibleTo(userID, now) && updated.Visibility() != models.ResultsAfterVote {
+		updated.HideResults()
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// Vote records a vote on a poll from outside the HTTP API, such as an
+// integration relaying a Slack user's choice. identity identifies the voter
+// in place of the poll's voter policy and must be unique to them, e.g.
+// prefixed with the integration's name; each identity votes once. A vote
+// that is refused returns an ErrVoteRejected saying why.
+func (h *PollHandler) Vote(ctx context.Context, pollID, identity string, vote models.VoteRequest) (*models.Poll, error) {
+	poll, err := h.store.Get(ctx, pollID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrVoteRejected("Poll not found")
+		}
+		return nil, err
+	}
+	now := time.Now()
+	if !poll.AcceptsVotes(now) {
+		return nil, ErrVoteRejected(notAcceptingVotes(poll, now))
+	}
+
+	updated, status, message := h.castBallot(ctx, poll, hashVoterKey(poll, identity), &vote, now)
+	switch {
+	case status >= http.StatusInternalServerError:
+		return nil, errors.New(message)
+	case status != 0:
+		return nil, ErrVoteRejected(message)
+	}
+	return updated, nil
+}
+
+// ErrVoteRejected explains why Vote refused a vote
+type ErrVoteRejected string
+
+func (e ErrVoteRejected) Error() string {
+	return string(e)
+}
+
+// castBallot records the vote of the voter with the given key on a poll
+// that accepts votes and pushes the new counts to live subscribers. If the
+// vote is refused it returns the status and message to respond with.
+func (h *PollHandler) castBallot(ctx context.Context, poll *models.Poll, voterKey string, vote *models.VoteRequest, now time.Time) (*models.Poll, int, string) {
+	ballot := models.Ballot{
+		ID:        uuid.New().String(),
+		PollID:    poll.ID,
+		Ballot:    vote.Ballot(),
+		CreatedAt: now,
+		VoterKey:  voterKey,
+	}
+	if err := ballot.Validate(poll); err != nil {
+		logging.FromContext(ctx).Info("Vote rejected, invalid ballot", "poll_id", poll.ID, "error", err)
+		return nil, http.StatusBadRequest, "Invalid ballot: " + err.Error()
+	}
+
+	// The store checks the state and expiry again as part of the write, so a
+	// poll that closes between the read above and this write cannot receive
+	// the vote.
+	updated, err := h.store.RecordVote(ctx, &ballot)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrPollClosed):
+			logging.FromContext(ctx).Info("Vote rejected, poll stopped accepting votes", "poll_id", poll.ID)
+			return nil, http.StatusForbidden, "Poll is closed"
+		case errors.Is(err, store.ErrAlreadyVoted):
+			logging.FromContext(ctx).Info("Vote rejected, voter already voted", "poll_id", poll.ID)
+			return nil, http.StatusConflict, "You have already voted on this poll"
+		case errors.Is(err, store.ErrNotFound):
+			logging.FromContext(ctx).Info("Vote rejected, poll was removed", "poll_id", poll.ID)
+			return nil, http.StatusNotFound, "Poll not found"
+		default:
+			logging.FromContext(ctx).Error("Failed to record vote", "poll_id", poll.ID, "error", err)
+			return nil, http.StatusInternalServerError, "Failed to record vote"
+		}
+	}
+	logging.FromContext(ctx).Info("Recorded ballot", "poll_id", poll.ID, "ballot_id", ballot.ID)
+	metrics.VotesCast.WithLabelValues(poll.Method()).Inc()
+
+	// Push the new counts to anyone watching this poll live.
+	h.hub.Publish(updated)
+	return updated, 0, ""
+}
+
+// GetResults handles tabulating a poll's ballots with the poll's voting method.
+// The structured result depends on the method, e.g. instant-runoff results
+// contain every counting round so clients can show how eliminated options'
+// ballots were transferred.
+func (h *PollHandler) GetResults(c *gin.Context) {
+	poll, result, ok := h.tabulate(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"poll_id": poll.ID,
+		"results": result,
+	})
+}
+
+// ExplainResults handles describing, step by step, how a poll's result was
+// reached: each counting round, who was eliminated or elected and why,
+// where their ballots went and any tie-breaks. The explanation is built
+// from the same result GetResults returns.
+// It responds with JSON, or with just the Markdown document if the client
+// asks for format=markdown or accepts text/markdown.
+func (h *PollHandler) ExplainResults(c *gin.Context) {
+	poll, result, ok := h.tabulate(c)
+	if !ok {
+		return
+	}
+
+	names := make(map[string]string, len(poll.Options))
+	for _, option := range poll.Options {
+		names[option.ID] = option.Text
+	}
+	explanation := tabulation.Explain(result, names)
+
+	if c.Query("format") == "markdown" || c.NegotiateFormat(gin.MIMEJSON, "text/markdown") == "text/markdown" {
+		c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(explanation.Markdown))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"poll_id":     poll.ID,
+		"results":     result,
+		"explanation": explanation,
+	})
+}
+
+// tabulate loads the poll named in the request and counts its ballots.
+// If that fails, or the caller may not see the poll's results, it responds
+// with an error and returns false.
+func (h *PollHandler) tabulate(c *gin.Context) (*models.Poll, tabulation.Result, bool) {
+	pollID := c.Param("id")
+	if pollID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Poll ID parameter is required"})
+		return nil, tabulation.Result{}, false
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeouts.Ballots) // Longer timeout as all ballots are read
+	defer cancel()
+
+	poll, err := h.store.Get(ctx, pollID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			logging.FromContext(ctx).Info("Poll not found", "poll_id", pollID)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Poll not found"})
+		} else {
+			logging.FromContext(ctx).Error("Failed to retrieve poll", "poll_id", pollID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve poll"})
+		}
+		return nil, tabulation.Result{}, false
+	}
+	if !requireVisible(c, poll) || !h.requireResults(ctx, c, poll) {
+		return nil, tabulation.Result{}, false
+	}
+
+	ballots, err := h.store.Ballots(ctx, pollID)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to retrieve ballots", "poll_id", pollID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve ballots"})
+		return nil, tabulation.Result{}, false
+	}
+
+	method, ok := tabulation.Lookup(poll.Method())
+	if !ok {
+		logging.FromContext(ctx).Error("Poll uses an unknown voting method", "poll_id", pollID, "voting_method", poll.VotingMethod)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Poll uses an unknown voting method"})
+		return nil, tabulation.Result{}, false
+	}
+	contents := make([]tabulation.Ballot, len(ballots))
+	for i, ballot := range ballots {
+		contents[i] = ballot.Ballot
+	}
 
-	// Return an empty list with HTTP 200 OK as the DB logic isn't implemented yet.
-	c.JSON(http.StatusOK, []models.Poll{})
+	return poll, method.Tabulate(poll.Contest(), contents), true
 }
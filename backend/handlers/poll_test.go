@@ -4,118 +4,72 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
-	"os"
+	"strconv"
 	"testing"
 	"time"
 
+	"instapoll/backend/auth"
+	"instapoll/backend/live"
+	"instapoll/backend/logging"
+	"instapoll/backend/metrics"
 	"instapoll/backend/models" // Import models
+	"instapoll/backend/store"
+	"instapoll/backend/tabulation"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require" // Use require for fatal assertions in setup
-	"go.mongodb.org/mongo-driver/bson"    // Import bson
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
-const (
-	testMongoURI    = "mongodb://localhost:27017" // Default for local Docker
-	testDatabase    = "instapoll_test"            // Separate DB for tests
-	testCollection  = "polls"
-	defaultTimeout  = 10 * time.Second
-)
-
-var testPollCollection *mongo.Collection // Make collection accessible to tests
-
-// setupTestDB connects to the test database and returns the client and collection
-func setupTestDB(t *testing.T) (*mongo.Client, *mongo.Collection) {
-	// Use require for setup steps, as failure here means tests can't run
-	mongoURI := os.Getenv("TEST_MONGODB_URI") // Allow overriding via env var
-	if mongoURI == "" {
-		mongoURI = testMongoURI
-	}
+const defaultTimeout = 10 * time.Second
 
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+// testUserID is the user sendJSON and createPoll act as
+const testUserID = "test-user"
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoURI))
-	require.NoError(t, err, "Failed to connect to test MongoDB")
+// testTokens issues and verifies the access tokens used by the tests
+var testTokens = auth.NewManager(auth.RandomKeySet(), store.NewMemoryRevocationStore())
 
-	err = client.Ping(ctx, readpref.Primary())
-	require.NoError(t, err, "Failed to ping test MongoDB")
-
-	collection := client.Database(testDatabase).Collection(testCollection)
-	testPollCollection = collection // Assign to global var for test access
-	log.Println("Connected to test database:", testDatabase)
-	return client, collection
-}
-
-// teardownTestDB disconnects from the database and cleans up
-func teardownTestDB(t *testing.T, client *mongo.Client) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
-
-	// Clean up the test database
-	err := client.Database(testDatabase).Drop(ctx)
-	// Use assert here instead of require, so teardown continues even if drop fails
-	assert.NoError(t, err, "Failed to drop test database")
-	log.Println("Dropped test database:", testDatabase)
-
-
-	err = client.Disconnect(ctx)
-	assert.NoError(t, err, "Failed to disconnect from test MongoDB")
-	log.Println("Disconnected from test database.")
-}
-
-// setupRouter creates a Gin router with the test handler and DB collection
-func setupRouter(collection *mongo.Collection) *gin.Engine {
+// setupRouter creates a Gin router with the test handler and poll store
+func setupRouter(s store.PollStore) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	r := gin.Default()
-	// *** This line (84) causes 'undefined: NewPollHandler' if poll.go is incorrect ***
-	pollHandler := NewPollHandler(collection) // Create handler with test collection
-	pollHandler.RegisterRoutes(r)             // Register routes
+	// Like main without configured proxies, ignore forwarding headers
+	r.SetTrustedProxies(nil)
+	pollHandler := NewPollHandler(s, live.NewHub(live.DefaultBuffer), testTokens, DefaultTimeouts) // Create handler with test store
+	pollHandler.RegisterRoutes(r)                                                                  // Register routes
 	return r
 }
 
-// TestMain runs setup and teardown for the entire test suite
-func TestMain(m *testing.M) {
-	// Setup: Connect to DB
-	// *** FIX: Use blank identifier '_' for the collection returned here ***
-	// *** as the local variable 'collection' was unused in this scope.  ***
-	// *** The global 'testPollCollection' is assigned inside setupTestDB. ***
-	client, _ := setupTestDB(&testing.T{}) // Use dummy T for setup
-
-	// Run tests
-	exitCode := m.Run()
-
-	// Teardown: Disconnect and clean DB
-	teardownTestDB(&testing.T{}, client) // Use dummy T for teardown
+// bearer returns an Authorization header value for the given user
+func bearer(t *testing.T, userID string) string {
+	tokens, err := testTokens.Issue(userID)
+	require.NoError(t, err)
+	return "Bearer " + tokens.AccessToken
+}
 
-	os.Exit(exitCode)
+// newTestStore returns an empty in-memory store so tests need no database
+func newTestStore() *store.MemoryStore {
+	return store.NewMemoryStore()
 }
 
-// clearTestCollection removes all documents from the test collection
-func clearTestCollection(t *testing.T) {
+// insertPoll stores a poll directly, bypassing the API
+func insertPoll(t *testing.T, s store.PollStore, poll models.Poll) {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
 	defer cancel()
-	_, err := testPollCollection.DeleteMany(ctx, bson.M{})
-	require.NoError(t, err, "Failed to clear test collection")
+	require.NoError(t, s.Create(ctx, &poll), "Failed to insert test poll directly into store")
 }
 
-
 // --- Test Cases ---
 
 func TestCreatePoll(t *testing.T) {
-	// Ensure collection is clean before test
-	clearTestCollection(t)
-
-	// Setup router with the globally available testPollCollection
-	router := setupRouter(testPollCollection)
+	// Each test gets its own empty store
+	pollStore := newTestStore()
+	router := setupRouter(pollStore)
 
 	validPollPayload := models.Poll{
 		Title:       "Favorite Color?",
@@ -133,6 +87,7 @@ func TestCreatePoll(t *testing.T) {
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("POST", "/api/polls", bytes.NewBuffer(jsonData))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", bearer(t, testUserID))
 
 	router.ServeHTTP(w, req)
 
@@ -151,17 +106,15 @@ func TestCreatePoll(t *testing.T) {
 	assert.NotZero(t, responsePoll.CreatedAt, "Response CreatedAt should be set")
 	assert.NotZero(t, responsePoll.UpdatedAt, "Response UpdatedAt should be set")
 
-	// --- TDD Failing Assertion ---
-	// Verify the poll was actually saved to the database
+	assert.Equal(t, models.DefaultVotingMethod, responsePoll.VotingMethod, "Voting method should default to plurality")
+
+	// Verify the poll was actually saved to the store
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
 	defer cancel()
-	var dbPoll models.Poll
-	// Use the ID returned in the response to find the document in the DB
-	filter := bson.M{"_id": responsePoll.ID} // Assuming ID is used as _id
-	err = testPollCollection.FindOne(ctx, filter).Decode(&dbPoll)
+	// Use the ID returned in the response to find the poll in the store
+	dbPoll, err := pollStore.Get(ctx, responsePoll.ID)
 
-	// This assertion WILL FAIL until InsertOne is implemented in the handler
-	assert.NoError(t, err, "Poll should be found in the database")
+	assert.NoError(t, err, "Poll should be found in the store")
 	if err == nil { // Only compare if found
 		assert.Equal(t, responsePoll.Title, dbPoll.Title, "Database poll title mismatch")
 		assert.Equal(t, len(responsePoll.Options), len(dbPoll.Options), "Database poll options count mismatch")
@@ -169,13 +122,11 @@ func TestCreatePoll(t *testing.T) {
 }
 
 func TestGetPoll(t *testing.T) {
-	// Ensure collection is clean before test
-	clearTestCollection(t)
+	// Each test gets its own empty store
+	pollStore := newTestStore()
+	router := setupRouter(pollStore)
 
-	// Setup router with the globally available testPollCollection
-	router := setupRouter(testPollCollection)
-
-	// --- TDD Setup: Insert a poll directly into the DB ---
+	// --- Setup: Insert a poll directly into the store ---
 	pollID := uuid.New().String()
 	testPoll := models.Poll{
 		ID:          pollID,
@@ -188,23 +139,18 @@ func TestGetPoll(t *testing.T) {
 		CreatedAt: time.Now().Add(-time.Hour), // Set explicit times
 		UpdatedAt: time.Now().Add(-time.Minute),
 	}
-	ctxInsert, cancelInsert := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancelInsert()
-	_, err := testPollCollection.InsertOne(ctxInsert, testPoll)
-	require.NoError(t, err, "Failed to insert test poll directly into DB")
+	insertPoll(t, pollStore, testPoll)
 
 	// --- Make API Request ---
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/api/polls/"+pollID, nil)
 	router.ServeHTTP(w, req)
 
-	// --- TDD Failing Assertions ---
-	// This assertion WILL FAIL until FindOne is implemented in the handler
 	assert.Equal(t, http.StatusOK, w.Code, "Expected status code 200 OK")
 
 	if w.Code == http.StatusOK {
 		var responsePoll models.Poll
-		err = json.Unmarshal(w.Body.Bytes(), &responsePoll)
+		err := json.Unmarshal(w.Body.Bytes(), &responsePoll)
 		require.NoError(t, err, "Failed to unmarshal response body")
 		assert.Equal(t, testPoll.ID, responsePoll.ID, "Response ID mismatch")
 		assert.Equal(t, testPoll.Title, responsePoll.Title, "Response title mismatch")
@@ -215,76 +161,146 @@ func TestGetPoll(t *testing.T) {
 }
 
 func TestGetPoll_NotFound(t *testing.T) {
-	// Ensure collection is clean
-	clearTestCollection(t)
-	router := setupRouter(testPollCollection)
+	pollStore := newTestStore()
+	router := setupRouter(pollStore)
 
 	nonExistentID := uuid.New().String()
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/api/polls/"+nonExistentID, nil)
 	router.ServeHTTP(w, req)
 
-	// This assertion SHOULD PASS even without DB logic IF the handler correctly
-	// returns 404 when the (future) DB lookup fails.
-	// However, the current stub returns 404 always, so it passes for the wrong reason initially.
 	assert.Equal(t, http.StatusNotFound, w.Code, "Expected status code 404 Not Found")
 }
 
-
 func TestListPolls(t *testing.T) {
-	// Ensure collection is clean
-	clearTestCollection(t)
-	router := setupRouter(testPollCollection)
+	pollStore := newTestStore()
+	router := setupRouter(pollStore)
 
-	// --- TDD Setup: Insert multiple polls directly into the DB ---
+	// --- Setup: Insert multiple polls directly into the store ---
 	poll1 := models.Poll{ID: uuid.New().String(), Title: "Poll 1", Options: []models.Option{{Text: "A"}, {Text: "B"}}, CreatedAt: time.Now()}
 	poll2 := models.Poll{ID: uuid.New().String(), Title: "Poll 2", Options: []models.Option{{Text: "C"}, {Text: "D"}}, CreatedAt: time.Now()}
 
-	ctxInsert, cancelInsert := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancelInsert()
-	_, err := testPollCollection.InsertMany(ctxInsert, []interface{}{poll1, poll2})
-	require.NoError(t, err, "Failed to insert multiple test polls")
+	insertPoll(t, pollStore, poll1)
+	insertPoll(t, pollStore, poll2)
 
 	// --- Make API Request ---
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/api/polls", nil)
 	router.ServeHTTP(w, req)
 
-	// --- TDD Failing Assertions ---
-	// These assertions WILL FAIL until Find is implemented in the handler
 	assert.Equal(t, http.StatusOK, w.Code, "Expected status code 200 OK")
 
 	if w.Code == http.StatusOK {
-		var responsePolls []models.Poll
-		err = json.Unmarshal(w.Body.Bytes(), &responsePolls)
+		var response PollList
+		err := json.Unmarshal(w.Body.Bytes(), &response)
 		require.NoError(t, err, "Failed to unmarshal response body")
-		assert.Len(t, responsePolls, 2, "Expected 2 polls in the response list")
-		// Optionally, check if the returned polls match the inserted ones
-		// This might require sorting or checking IDs if order isn't guaranteed
+		assert.Len(t, response.Items, 2, "Expected 2 polls in the response list")
+		assert.False(t, response.HasMore)
+		assert.Empty(t, response.Next)
 	}
 }
 
 func TestListPolls_Empty(t *testing.T) {
-	// Ensure collection is clean
-	clearTestCollection(t)
-	router := setupRouter(testPollCollection)
+	pollStore := newTestStore()
+	router := setupRouter(pollStore)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/api/polls", nil)
 	router.ServeHTTP(w, req)
 
-	// This assertion SHOULD PASS even without DB logic IF the handler correctly
-	// returns an empty array `[]` when the (future) DB lookup finds nothing.
-	// The current stub returns `[]`, so it passes for the wrong reason initially.
 	assert.Equal(t, http.StatusOK, w.Code, "Expected status code 200 OK")
-	assert.Equal(t, "[]", w.Body.String(), "Expected empty JSON array '[]'")
+	assert.JSONEq(t, `{"items": [], "limit": 20, "has_more": false}`, w.Body.String(), "Expected an empty items array")
+}
+
+// listPolls fetches a page of polls with the given query string
+func listPolls(t *testing.T, router *gin.Engine, query string) PollList {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/polls?"+query, nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response PollList
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response
+}
+
+func TestListPolls_Pagination(t *testing.T) {
+	pollStore := newTestStore()
+	router := setupRouter(pollStore)
+
+	// Five polls, the newest first; the last two have expired.
+	now := time.Now()
+	var want []string
+	for i := 0; i < 5; i++ {
+		poll := models.Poll{
+			ID:        uuid.New().String(),
+			Title:     "Poll " + strconv.Itoa(i),
+			Options:   []models.Option{{Text: "A"}, {Text: "B"}},
+			CreatedAt: now.Add(-time.Duration(i) * time.Minute),
+		}
+		if i >= 3 {
+			poll.ExpiresAt = now.Add(-time.Second)
+		}
+		insertPoll(t, pollStore, poll)
+		want = append(want, poll.ID)
+	}
+
+	// Follow the cursors two at a time.
+	var got []string
+	page := listPolls(t, router, "limit=2")
+	for {
+		assert.Equal(t, 2, page.Limit)
+		for _, poll := range page.Items {
+			got = append(got, poll.ID)
+		}
+		if !page.HasMore {
+			break
+		}
+		require.NotEmpty(t, page.Next)
+		page = listPolls(t, router, "limit=2&cursor="+page.Next)
+	}
+	assert.Equal(t, want, got, "pages should list every poll once, newest first")
+
+	page = listPolls(t, router, "order=asc&limit=1")
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, want[4], page.Items[0].ID)
+
+	page = listPolls(t, router, "status=expired")
+	assert.Len(t, page.Items, 2)
+	page = listPolls(t, router, "status=active&q=poll%201")
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, want[1], page.Items[0].ID)
+
+	// Requests above the cap are clamped rather than rejected.
+	page = listPolls(t, router, "limit=1000")
+	assert.Equal(t, maxListLimit, page.Limit)
 }
 
+func TestListPolls_InvalidQuery(t *testing.T) {
+	router := setupRouter(newTestStore())
+	cursor := (&store.Cursor{Sort: store.SortVotes, ID: "x"}).Encode()
+
+	for _, query := range []string{
+		"limit=0",
+		"limit=ten",
+		"sort=title",
+		"order=up",
+		"status=open",
+		"cursor=not-a-cursor",
+		"cursor=" + cursor, // Issued for a different sort order
+	} {
+		t.Run(query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/api/polls?"+query, nil)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
 
-// Add tests for invalid CreatePoll payloads (these should still work as they test validation before DB)
+// Add tests for invalid CreatePoll payloads (these test validation before the store is touched)
 func TestCreatePoll_InvalidPayload(t *testing.T) {
-	clearTestCollection(t)
-	router := setupRouter(testPollCollection)
+	pollStore := newTestStore()
+	router := setupRouter(pollStore)
 
 	tests := []struct {
 		name       string
@@ -306,6 +322,11 @@ func TestCreatePoll_InvalidPayload(t *testing.T) {
 			payload:    `{"title": "Test", "options": [{"text":"A"}]}`,
 			wantStatus: http.StatusBadRequest,
 		},
+		{
+			name:       "unknown voting method",
+			payload:    `{"title": "Test", "voting_method": "coin-toss", "options": [{"text":"A"},{"text":"B"}]}`,
+			wantStatus: http.StatusBadRequest,
+		},
 	}
 
 	for _, tt := range tests {
@@ -313,17 +334,579 @@ func TestCreatePoll_InvalidPayload(t *testing.T) {
 			w := httptest.NewRecorder()
 			req, _ := http.NewRequest("POST", "/api/polls", bytes.NewBufferString(tt.payload))
 			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", bearer(t, testUserID))
 
 			router.ServeHTTP(w, req)
 			assert.Equal(t, tt.wantStatus, w.Code)
 
-			// Verify DB was NOT touched for bad requests
+			// Verify the store was NOT touched for bad requests
 			ctxCount, cancelCount := context.WithTimeout(context.Background(), defaultTimeout)
 			defer cancelCount()
-			count, err := testPollCollection.CountDocuments(ctxCount, bson.M{})
+			page, err := pollStore.List(ctxCount, store.ListQuery{})
+			require.NoError(t, err)
+			assert.Empty(t, page.Polls, "Store should be empty after invalid request")
+		})
+	}
+}
+
+// insertTestPoll stores a poll with two options directly in the store and returns it
+func insertTestPoll(t *testing.T, s store.PollStore, method string, expiresAt time.Time) models.Poll {
+	poll := models.Poll{
+		ID:           uuid.New().String(),
+		Title:        "Vote Target",
+		VotingMethod: method,
+		Options: []models.Option{
+			{ID: uuid.New().String(), Text: "Yes"},
+			{ID: uuid.New().String(), Text: "No"},
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+		CreatorID: testUserID,
+	}
+	insertPoll(t, s, poll)
+	return poll
+}
+
+// postVote sends a vote request for the given poll and returns the recorder
+func postVote(router *gin.Engine, pollID, payload string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/polls/"+pollID+"/votes", bytes.NewBufferString(payload))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCastVote(t *testing.T) {
+	pollStore := newTestStore()
+	router := setupRouter(pollStore)
+	poll := insertTestPoll(t, pollStore, models.DefaultVotingMethod, time.Time{})
+
+	w := postVote(router, poll.ID, `{"option_id": "`+poll.Options[0].ID+`"}`)
+	assert.Equal(t, http.StatusOK, w.Code, "Expected status code 200 OK")
+
+	var responsePoll models.Poll
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &responsePoll))
+	assert.Equal(t, 1, responsePoll.Options[0].VoteCount, "Voted option should have 1 vote")
+	assert.Equal(t, 0, responsePoll.Options[1].VoteCount, "Other option should have no votes")
+}
+
+func TestPollMetrics(t *testing.T) {
+	router := setupRouter(newTestStore())
+	created := testutil.ToFloat64(metrics.PollsCreated)
+	votes := testutil.ToFloat64(metrics.VotesCast.WithLabelValues(models.DefaultVotingMethod))
+
+	poll := createPoll(t, router, `{"title": "Counted", "options": [{"text": "A"}, {"text": "B"}]}`)
+	postVote(router, poll.ID, `{"option_id": "`+poll.Options[0].ID+`"}`)
+	postVote(router, poll.ID, `{"option_id": "nope"}`) // Rejected
+
+	assert.Equal(t, created+1, testutil.ToFloat64(metrics.PollsCreated))
+	assert.Equal(t, votes+1, testutil.ToFloat64(metrics.VotesCast.WithLabelValues(models.DefaultVotingMethod)))
+}
+
+func TestPollLogging(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+	r := gin.New()
+	r.Use(logging.Middleware(slog.New(slog.NewJSONHandler(&buf, nil))))
+	NewPollHandler(newTestStore(), live.NewHub(live.DefaultBuffer), testTokens, DefaultTimeouts).RegisterRoutes(r)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/polls/missing", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	// The handler's lines carry the request's ID and route as well as the poll.
+	var line map[string]any
+	require.NoError(t, json.NewDecoder(&buf).Decode(&line))
+	assert.Equal(t, "Poll not found", line["msg"])
+	assert.Equal(t, w.Header().Get(logging.RequestIDHeader), line["request_id"])
+	assert.Equal(t, "/api/polls/:id", line["route"])
+	assert.Equal(t, "missing", line["poll_id"])
+}
+
+func TestCastVote_Concurrent(t *testing.T) {
+	pollStore := newTestStore()
+	router := setupRouter(pollStore)
+	poll := insertTestPoll(t, pollStore, models.DefaultVotingMethod, time.Time{})
+
+	const voters = 20
+	done := make(chan int, voters)
+	for i := 0; i < voters; i++ {
+		go func() {
+			done <- postVote(router, poll.ID, `{"option_id": "`+poll.Options[1].ID+`"}`).Code
+		}()
+	}
+	for i := 0; i < voters; i++ {
+		assert.Equal(t, http.StatusOK, <-done)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	dbPoll, err := pollStore.Get(ctx, poll.ID)
+	require.NoError(t, err)
+	assert.Equal(t, voters, dbPoll.Options[1].VoteCount, "No increments should be lost")
+}
+
+func TestCastVote_Ranked(t *testing.T) {
+	pollStore := newTestStore()
+	router := setupRouter(pollStore)
+	poll := insertTestPoll(t, pollStore, "irv", time.Time{})
+
+	ranking := `["` + poll.Options[1].ID + `", "` + poll.Options[0].ID + `"]`
+	w := postVote(router, poll.ID, `{"ranking": `+ranking+`}`)
+	assert.Equal(t, http.StatusOK, w.Code, "Expected status code 200 OK")
+
+	// The first preference is counted on the poll...
+	var responsePoll models.Poll
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &responsePoll))
+	assert.Equal(t, 1, responsePoll.Options[1].VoteCount, "First preference should be counted")
+
+	// ...and the full ranking is stored as a ballot.
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	ballots, err := pollStore.Ballots(ctx, poll.ID)
+	require.NoError(t, err)
+	require.Len(t, ballots, 1)
+	assert.Equal(t, []string{poll.Options[1].ID, poll.Options[0].ID}, ballots[0].Ranking)
+}
+
+func TestCastVote_Rejected(t *testing.T) {
+	pollStore := newTestStore()
+	router := setupRouter(pollStore)
+	open := insertTestPoll(t, pollStore, models.DefaultVotingMethod, time.Time{})
+	expired := insertTestPoll(t, pollStore, models.DefaultVotingMethod, time.Now().Add(-time.Minute))
+
+	tests := []struct {
+		name       string
+		pollID     string
+		payload    string
+		wantStatus int
+	}{
+		{
+			name:       "empty ballot",
+			pollID:     open.ID,
+			payload:    `{}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown option",
+			pollID:     open.ID,
+			payload:    `{"option_id": "not-an-option"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "ranking on a single-choice poll",
+			pollID:     open.ID,
+			payload:    `{"ranking": ["` + open.Options[0].ID + `", "` + open.Options[1].ID + `"]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "duplicate ranking",
+			pollID:     open.ID,
+			payload:    `{"ranking": ["` + open.Options[0].ID + `", "` + open.Options[0].ID + `"]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown poll",
+			pollID:     uuid.New().String(),
+			payload:    `{"option_id": "` + open.Options[0].ID + `"}`,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "expired poll",
+			pollID:     expired.ID,
+			payload:    `{"option_id": "` + expired.Options[0].ID + `"}`,
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postVote(router, tt.pollID, tt.payload)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
+
+func TestGetResults(t *testing.T) {
+	pollStore := newTestStore()
+	router := setupRouter(pollStore)
+	poll := insertTestPoll(t, pollStore, "irv", time.Time{})
+	yes, no := poll.Options[0].ID, poll.Options[1].ID
+
+	postVote(router, poll.ID, `{"ranking": ["`+yes+`", "`+no+`"]}`)
+	postVote(router, poll.ID, `{"ranking": ["`+yes+`"]}`)
+	postVote(router, poll.ID, `{"option_id": "`+no+`"}`)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/polls/"+poll.ID+"/results", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "Expected status code 200 OK")
+
+	var response struct {
+		PollID  string            `json:"poll_id"`
+		Results tabulation.Result `json:"results"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, poll.ID, response.PollID)
+	assert.Equal(t, 3, response.Results.TotalBallots)
+	assert.Equal(t, "irv", response.Results.Method)
+	assert.Equal(t, []string{yes}, response.Results.Winners)
+}
+
+func TestExplainResults(t *testing.T) {
+	pollStore := newTestStore()
+	router := setupRouter(pollStore)
+	poll := insertTestPoll(t, pollStore, "irv", time.Time{})
+	yes, no := poll.Options[0].ID, poll.Options[1].ID
+
+	postVote(router, poll.ID, `{"ranking": ["`+yes+`", "`+no+`"]}`)
+	postVote(router, poll.ID, `{"ranking": ["`+no+`", "`+yes+`"]}`)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/polls/"+poll.ID+"/results/explain", nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		PollID      string                 `json:"poll_id"`
+		Results     tabulation.Result      `json:"results"`
+		Explanation tabulation.Explanation `json:"explanation"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, poll.ID, response.PollID)
+	// The explanation describes the same result the results endpoint returns.
+	assert.Equal(t, []string{yes}, response.Results.Winners)
+	assert.Equal(t, `"Yes" wins with 2 ballots counted.`, response.Explanation.Summary)
+	assert.NotEmpty(t, response.Explanation.Steps)
+	assert.Contains(t, response.Explanation.Markdown, "### Round 1")
+
+	// The Markdown document can also be requested on its own.
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/api/polls/"+poll.ID+"/results/explain?format=markdown", nil),
+		httptest.NewRequest("GET", "/api/polls/"+poll.ID+"/results/explain", nil),
+	} {
+		if req.URL.RawQuery == "" {
+			req.Header.Set("Accept", "text/markdown")
+		}
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/markdown; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, response.Explanation.Markdown, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/polls/missing/results/explain", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetResults_Condorcet(t *testing.T) {
+	for _, method := range []string{"schulze", "ranked_pairs"} {
+		t.Run(method, func(t *testing.T) {
+			pollStore := newTestStore()
+			router := setupRouter(pollStore)
+			poll := insertTestPoll(t, pollStore, method, time.Time{})
+			yes, no := poll.Options[0].ID, poll.Options[1].ID
+
+			postVote(router, poll.ID, `{"ranking": ["`+no+`", "`+yes+`"]}`)
+			postVote(router, poll.ID, `{"ranking": ["`+no+`"]}`)
+			postVote(router, poll.ID, `{"ranking": ["`+yes+`", "`+no+`"]}`)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/api/polls/"+poll.ID+"/results", nil)
+			router.ServeHTTP(w, req)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var response struct {
+				Results tabulation.Result `json:"results"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, []string{no}, response.Results.Winners)
+			// The head-to-head table is included for the frontend.
+			assert.Equal(t, map[string]map[string]int{yes: {no: 1}, no: {yes: 2}}, response.Results.Pairwise)
+		})
+	}
+}
+
+func TestCastVote_Tiers(t *testing.T) {
+	router := setupRouter(newTestStore())
+	poll := createPoll(t, router, `{"title": "Backlog", "voting_method": "borda",`+
+		`"settings": {"allow_ties": true, "truncation": "reject"},`+
+		`"options": [{"text": "Search"}, {"text": "Export"}, {"text": "Dark mode"}]}`)
+	search, export, dark := poll.Options[0].ID, poll.Options[1].ID, poll.Options[2].ID
+
+	// Truncated ballots break the poll's rule.
+	w := postVote(router, poll.ID, `{"ranking": ["`+search+`", "`+export+`"]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+	w = postVote(router, poll.ID, `{"tiers": [["`+search+`", "`+export+`"], ["`+dark+`"]]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Both options tied for first count as first preferences.
+	var responsePoll models.Poll
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &responsePoll))
+	assert.Equal(t, 1, responsePoll.Options[0].VoteCount)
+	assert.Equal(t, 1, responsePoll.Options[1].VoteCount)
+	assert.Equal(t, 0, responsePoll.Options[2].VoteCount)
+}
+
+func TestCastVote_Approval(t *testing.T) {
+	pollStore := newTestStore()
+	router := setupRouter(pollStore)
+	poll := insertTestPoll(t, pollStore, "approval", time.Time{})
+
+	approvals := `["` + poll.Options[0].ID + `", "` + poll.Options[1].ID + `"]`
+	w := postVote(router, poll.ID, `{"approvals": `+approvals+`}`)
+	assert.Equal(t, http.StatusOK, w.Code, "Expected status code 200 OK")
+
+	// Every approved option is counted.
+	var responsePoll models.Poll
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &responsePoll))
+	assert.Equal(t, 1, responsePoll.Options[0].VoteCount)
+	assert.Equal(t, 1, responsePoll.Options[1].VoteCount)
+}
+
+func TestGetResults_Approval(t *testing.T) {
+	pollStore := newTestStore()
+	router := setupRouter(pollStore)
+	poll := createPoll(t, router, `{"title": "Which days work?", "voting_method": "approval",`+
+		`"settings": {"min_selections": 1, "max_selections": 2},`+
+		`"options": [{"text": "Mon"}, {"text": "Tue"}, {"text": "Wed"}]}`)
+	mon, tue, wed := poll.Options[0].ID, poll.Options[1].ID, poll.Options[2].ID
+	assert.Equal(t, 2, poll.Settings.MaxSelections)
+
+	w := postVote(router, poll.ID, `{"approvals": ["`+mon+`", "`+tue+`", "`+wed+`"]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Approving more than max_selections should be rejected")
+
+	for _, approvals := range []string{`["` + mon + `", "` + tue + `"]`, `["` + mon + `"]`} {
+		w = postVote(router, poll.ID, `{"approvals": `+approvals+`}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/polls/"+poll.ID+"/results", nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Results tabulation.Result `json:"results"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, map[string]float64{mon: 2, tue: 1, wed: 0}, response.Results.Tallies)
+	assert.Equal(t, map[string]float64{mon: 100, tue: 50, wed: 0}, response.Results.Percentages)
+	assert.Equal(t, []string{mon}, response.Results.Winners)
+}
+
+func TestGetResults_NotFound(t *testing.T) {
+	pollStore := newTestStore()
+	router := setupRouter(pollStore)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/polls/"+uuid.New().String()+"/results", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code, "Expected status code 404 Not Found")
+}
+
+// sendJSON sends a request with an optional JSON body as testUserID and returns the recorder
+func sendJSON(router *gin.Engine, method, path, payload string) *httptest.ResponseRecorder {
+	return sendJSONAs(router, testUserID, method, path, payload)
+}
+
+// sendJSONAs is sendJSON signed in as the given user, or anonymous if userID is empty
+func sendJSONAs(router *gin.Engine, userID, method, path, payload string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, bytes.NewBufferString(payload))
+	req.Header.Set("Content-Type", "application/json")
+	if userID != "" {
+		tokens, _ := testTokens.Issue(userID)
+		req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// createPoll creates a poll through the API and returns the response
+func createPoll(t *testing.T, router *gin.Engine, payload string) models.Poll {
+	w := sendJSON(router, "POST", "/api/polls", payload)
+	require.Equal(t, http.StatusCreated, w.Code, "Failed to create poll: %s", w.Body.String())
+	var poll models.Poll
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &poll))
+	return poll
+}
+
+func TestUpdatePoll(t *testing.T) {
+	pollStore := newTestStore()
+	router := setupRouter(pollStore)
+	poll := createPoll(t, router, `{"title": "Lunch?", "options": [{"text": "Pizza"}, {"text": "Sushi"}]}`)
+	assert.Equal(t, 1, poll.Version, "New polls start at version 1")
+
+	payload := `{"title": "Lunch today?", "version": 1, "options": [` +
+		`{"id": "` + poll.Options[0].ID + `", "text": "Pizza"},` +
+		`{"id": "` + poll.Options[1].ID + `", "text": "Ramen"},` +
+		`{"text": "Tacos"}]}`
+	w := sendJSON(router, "PUT", "/api/polls/"+poll.ID, payload)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var updated models.Poll
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.Equal(t, "Lunch today?", updated.Title)
+	assert.Equal(t, 2, updated.Version, "Version should be bumped")
+	assert.True(t, updated.UpdatedAt.After(poll.UpdatedAt), "UpdatedAt should be bumped")
+	require.Len(t, updated.Options, 3)
+	assert.Equal(t, "Ramen", updated.Options[1].Text)
+	assert.NotEmpty(t, updated.Options[2].ID, "New option should get an ID")
+
+	// Sending the old version again is a conflict.
+	w = sendJSON(router, "PUT", "/api/polls/"+poll.ID, payload)
+	assert.Equal(t, http.StatusConflict, w.Code, "Stale version should be rejected")
+}
+
+func TestUpdatePoll_OptionsWithVotes(t *testing.T) {
+	pollStore := newTestStore()
+	router := setupRouter(pollStore)
+	poll := createPoll(t, router, `{"title": "Lunch?", "options": [{"text": "Pizza"}, {"text": "Sushi"}, {"text": "Salad"}]}`)
+	pizza, sushi, salad := poll.Options[0].ID, poll.Options[1].ID, poll.Options[2].ID
+	require.Equal(t, http.StatusOK, postVote(router, poll.ID, `{"option_id": "`+pizza+`"}`).Code)
+
+	tests := []struct {
+		name       string
+		options    string
+		wantStatus int
+	}{
+		{
+			name:       "rename voted option",
+			options:    `{"id": "` + pizza + `", "text": "Calzone"}, {"id": "` + sushi + `", "text": "Sushi"}`,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "remove voted option",
+			options:    `{"id": "` + sushi + `", "text": "Sushi"}, {"id": "` + salad + `", "text": "Salad"}`,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "unknown option id",
+			options:    `{"id": "` + pizza + `", "text": "Pizza"}, {"id": "nope", "text": "Nope"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "rename and remove options without votes",
+			options:    `{"id": "` + pizza + `", "text": "Pizza"}, {"id": "` + sushi + `", "text": "Nigiri"}`,
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current, err := pollStore.Get(context.Background(), poll.ID)
 			require.NoError(t, err)
-			assert.Zero(t, count, "Database should be empty after invalid request")
+			payload := `{"title": "Lunch?", "version": ` + strconv.Itoa(current.Version) + `, "options": [` + tt.options + `]}`
+			w := sendJSON(router, "PUT", "/api/polls/"+poll.ID, payload)
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
 		})
 	}
+
+	// The vote survived the successful update.
+	current, err := pollStore.Get(context.Background(), poll.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, current.FindOption(pizza).VoteCount)
+}
+
+func TestUpdatePoll_Settings(t *testing.T) {
+	router := setupRouter(newTestStore())
+	poll := createPoll(t, router, `{"title": "Which days work?", "voting_method": "approval",`+
+		`"settings": {"max_selections": 2},`+
+		`"options": [{"text": "Mon"}, {"text": "Tue"}, {"text": "Wed"}]}`)
+	options := `"options": [` +
+		`{"id": "` + poll.Options[0].ID + `", "text": "Mon"},` +
+		`{"id": "` + poll.Options[1].ID + `", "text": "Tue"},` +
+		`{"id": "` + poll.Options[2].ID + `", "text": "Wed"}]`
+
+	// Leaving the settings out keeps the current ones.
+	w := sendJSON(router, "PUT", "/api/polls/"+poll.ID, `{"title": "Renamed", "version": 1, `+options+`}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var updated models.Poll
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.Equal(t, 2, updated.Settings.MaxSelections)
+
+	// An empty object clears them.
+	w = sendJSON(router, "PUT", "/api/polls/"+poll.ID, `{"title": "Renamed", "version": 2, "settings": {}, `+options+`}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var cleared models.Poll
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &cleared))
+	assert.Equal(t, tabulation.Settings{}, cleared.Settings)
+
+	w = postVote(router, poll.ID, `{"approvals": ["`+poll.Options[0].ID+`"]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Once votes are in, updates without settings still go through...
+	w = sendJSON(router, "PUT", "/api/polls/"+poll.ID, `{"title": "Renamed again", "version": 3, `+options+`}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// ...but the settings cannot change.
+	w = sendJSON(router, "PUT", "/api/polls/"+poll.ID, `{"title": "Renamed", "version": 4, "settings": {"max_selections": 1}, `+options+`}`)
+	assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
 }
 
+func TestUpdatePoll_Invalid(t *testing.T) {
+	pollStore := newTestStore()
+	router := setupRouter(pollStore)
+	poll := createPoll(t, router, `{"title": "Lunch?", "options": [{"text": "Pizza"}, {"text": "Sushi"}]}`)
+	options := `[{"id": "` + poll.Options[0].ID + `", "text": "Pizza"}, {"id": "` + poll.Options[1].ID + `", "text": "Sushi"}]`
+
+	tests := []struct {
+		name       string
+		pollID     string
+		payload    string
+		wantStatus int
+	}{
+		{
+			name:       "missing version",
+			pollID:     poll.ID,
+			payload:    `{"title": "Lunch?", "options": ` + options + `}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "fails validation",
+			pollID:     poll.ID,
+			payload:    `{"title": "", "version": 1, "options": ` + options + `}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown poll",
+			pollID:     uuid.New().String(),
+			payload:    `{"title": "Lunch?", "version": 1, "options": ` + options + `}`,
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := sendJSON(router, "PUT", "/api/polls/"+tt.pollID, tt.payload)
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestDeleteAndRestorePoll(t *testing.T) {
+	pollStore := newTestStore()
+	router := setupRouter(pollStore)
+	poll := createPoll(t, router, `{"title": "Lunch?", "options": [{"text": "Pizza"}, {"text": "Sushi"}]}`)
+
+	w := sendJSON(router, "DELETE", "/api/polls/"+poll.ID, "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	// The deleted poll is hidden everywhere.
+	assert.Equal(t, http.StatusNotFound, sendJSON(router, "GET", "/api/polls/"+poll.ID, "").Code)
+	assert.Equal(t, http.StatusNotFound, postVote(router, poll.ID, `{"option_id": "`+poll.Options[0].ID+`"}`).Code)
+	assert.Empty(t, listPolls(t, router, "").Items)
+	assert.Equal(t, http.StatusNotFound, sendJSON(router, "DELETE", "/api/polls/"+poll.ID, "").Code)
+
+	// Restoring brings it back.
+	w = sendJSON(router, "POST", "/api/polls/"+poll.ID+"/restore", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusOK, sendJSON(router, "GET", "/api/polls/"+poll.ID, "").Code)
+
+	assert.Equal(t, http.StatusNotFound, sendJSON(router, "POST", "/api/polls/"+uuid.New().String()+"/restore", "").Code)
+}
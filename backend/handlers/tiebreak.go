@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"instapoll/backend/logging"
+	"instapoll/backend/models"
+	"instapoll/backend/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTieBreakSeed returns a random seed for tabulation.TieBreakRandom
+func newTieBreakSeed() (string, error) {
+	seed := make([]byte, 16)
+	if _, err := rand.Read(seed); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(seed), nil
+}
+
+// SetTieBreakOrder handles the poll creator deciding ties: it stores the
+// order, most preferred first, used to break ties on polls with the
+// tabulation.TieBreakCreator policy. Unlike the poll's settings it can be
+// set after votes have been cast, as creators decide once they see a tie;
+// the order is published with the poll and its results. As those results
+// are final once the poll closes, a closed poll's order can only be set if
+// it has none, and an archived poll's not at all.
+func (h *PollHandler) SetTieBreakOrder(c *gin.Context) {
+	pollID := c.Param("id")
+	if pollID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Poll ID parameter is required"})
+		return
+	}
+
+	var req struct {
+		Order   []string `json:"order" binding:"required"`
+		Version int      `json:"version"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	if req.Version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Version of the poll being updated is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeouts.Request)
+	defer cancel()
+
+	poll, err := h.store.Get(ctx, pollID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			logging.FromContext(ctx).Info("Poll not found", "poll_id", pollID)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Poll not found"})
+		} else {
+			logging.FromContext(ctx).Error("Failed to retrieve poll", "poll_id", pollID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve poll"})
+		}
+		return
+	}
+	if !requireCreator(c, poll) {
+		return
+	}
+	if poll.Version != req.Version {
+		c.JSON(http.StatusConflict, gin.H{"error": "Poll has been modified since it was read; reload and try again"})
+		return
+	}
+	switch poll.Lifecycle() {
+	case models.StateArchived:
+		c.JSON(http.StatusConflict, gin.H{"error": "Archived polls cannot be changed"})
+		return
+	case models.StateClosed:
+		if len(poll.TieBreakOrder) > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "The tie-break order of a closed poll cannot be changed once set"})
+			return
+		}
+	}
+
+	// Only the order is checked: ties are usually decided after the poll
+	// has closed, when the rest of the poll would no longer validate.
+	updated := *poll
+	updated.TieBreakOrder = req.Order
+	updated.UpdatedAt = time.Now()
+	updated.Version = poll.Version + 1
+	if err := updated.ValidateTieBreakOrder(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return
+	}
+
+	if err := h.store.Update(ctx, &updated, poll.Version); err != nil {
+		switch {
+		case errors.Is(err, store.ErrVersionConflict):
+			c.JSON(http.StatusConflict, gin.H{"error": "Poll has been modified since it was read; reload and try again"})
+		case errors.Is(err, store.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Poll not found"})
+		default:
+			logging.FromContext(ctx).Error("Failed to update tie-break order", "poll_id", pollID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update poll"})
+		}
+		return
+	}
+	logging.FromContext(ctx).Info("Set tie-break order", "poll_id", pollID, "version", updated.Version)
+
+	c.JSON(http.StatusOK, updated)
+}
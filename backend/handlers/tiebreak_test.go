@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"instapoll/backend/models"
+	"instapoll/backend/tabulation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreatePoll_TieBreakSeed(t *testing.T) {
+	router := setupRouter(newTestStore())
+	first := createPoll(t, router, `{"title": "Lunch?", "options": [{"text": "Pizza"}, {"text": "Sushi"}]}`)
+	second := createPoll(t, router, `{"title": "Lunch?", "options": [{"text": "Pizza"}, {"text": "Sushi"}], "tie_break_seed": "chosen"}`)
+
+	assert.NotEmpty(t, first.TieBreakSeed, "Every poll should get a seed")
+	assert.NotEqual(t, "chosen", second.TieBreakSeed, "Clients should not pick the seed")
+	assert.NotEqual(t, first.TieBreakSeed, second.TieBreakSeed)
+
+	w := sendJSON(router, "POST", "/api/polls", `{"title": "Lunch?", "options": [{"text": "Pizza"}, {"text": "Sushi"}], "settings": {"tie_break": "coin_flip"}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Unknown tie-break policy should be rejected")
+}
+
+func TestSetTieBreakOrder(t *testing.T) {
+	pollStore := newTestStore()
+	router := setupRouter(pollStore)
+	poll := createPoll(t, router, `{"title": "Lunch?", "options": [{"text": "Pizza"}, {"text": "Sushi"}], "settings": {"tie_break": "creator"}}`)
+	pizza, sushi := poll.Options[0].ID, poll.Options[1].ID
+
+	postVote(router, poll.ID, `{"option_id": "`+pizza+`"}`)
+	postVote(router, poll.ID, `{"option_id": "`+sushi+`"}`)
+
+	// Another user cannot decide the tie.
+	w := sendJSONAs(router, "someone-else", "PUT", "/api/polls/"+poll.ID+"/tie-break-order", `{"version": 1, "order": ["`+sushi+`"]}`)
+	assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+
+	w = sendJSON(router, "PUT", "/api/polls/"+poll.ID+"/tie-break-order", `{"version": 1, "order": ["unknown"]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Unknown options should be rejected")
+
+	// The creator can, even after votes have been cast.
+	w = sendJSON(router, "PUT", "/api/polls/"+poll.ID+"/tie-break-order", `{"version": 1, "order": ["`+sushi+`", "`+pizza+`"]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var updated models.Poll
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.Equal(t, []string{sushi, pizza}, updated.TieBreakOrder)
+	assert.Equal(t, 2, updated.Version)
+
+	w = sendJSON(router, "PUT", "/api/polls/"+poll.ID+"/tie-break-order", `{"version": 1, "order": ["`+pizza+`"]}`)
+	assert.Equal(t, http.StatusConflict, w.Code, "Stale version should be rejected")
+
+	// The results record the tie and the order that broke it.
+	w = sendJSON(router, "GET", "/api/polls/"+poll.ID+"/results", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Results tabulation.Result `json:"results"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []string{sushi}, response.Results.Winners)
+	assert.Equal(t, tabulation.TieBreakCreator, response.Results.TieBreakPolicy)
+	assert.Equal(t, []string{sushi, pizza}, response.Results.TieBreakOrder)
+	require.Len(t, response.Results.TieBreaks, 1)
+	assert.Equal(t, tabulation.TieBreakCreator, response.Results.TieBreaks[0].Rule)
+}
+
+func TestSetTieBreakOrder_ClosedPoll(t *testing.T) {
+	router := setupRouter(newTestStore())
+	poll := createPoll(t, router, `{"title": "Lunch?", "options": [{"text": "Pizza"}, {"text": "Sushi"}], "settings": {"tie_break": "creator"}}`)
+	pizza, sushi := poll.Options[0].ID, poll.Options[1].ID
+	path := "/api/polls/" + poll.ID
+	require.Equal(t, http.StatusOK, sendJSON(router, "POST", path+"/close", "").Code)
+
+	// A closed poll's tie can still be decided once...
+	w := sendJSON(router, "PUT", path+"/tie-break-order", `{"version": 2, "order": ["`+sushi+`", "`+pizza+`"]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// ...but its final results cannot be changed afterwards.
+	w = sendJSON(router, "PUT", path+"/tie-break-order", `{"version": 3, "order": ["`+pizza+`", "`+sushi+`"]}`)
+	assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+
+	require.Equal(t, http.StatusOK, sendJSON(router, "POST", path+"/archive", "").Code)
+	w = sendJSON(router, "PUT", path+"/tie-break-order", `{"version": 4, "order": ["`+pizza+`", "`+sushi+`"]}`)
+	assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "Archived")
+}
@@ -0,0 +1,17 @@
+package handlers
+
+import "time"
+
+// Timeouts bound how long a request waits on the stores
+type Timeouts struct {
+	Request time.Duration // Reading or writing a single poll, account or webhook
+	List    time.Duration // Listing polls
+	Ballots time.Duration // Reading all of a poll's ballots, e.g. to count them
+}
+
+// DefaultTimeouts are the timeouts used unless configured otherwise
+var DefaultTimeouts = Timeouts{
+	Request: 5 * time.Second,
+	List:    10 * time.Second,
+	Ballots: 10 * time.Second,
+}
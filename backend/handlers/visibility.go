@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"instapoll/backend/auth"
+	"instapoll/backend/logging"
+	"instapoll/backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// resultsVisible reports whether the caller may see the poll's vote counts
+// and results under its results visibility. For ResultsAfterVote polls it
+// looks up whether the caller has voted.
+func (h *PollHandler) resultsVisible(ctx context.Context, c *gin.Context, poll *models.Poll) (bool, error) {
+	userID, _ := auth.UserID(c)
+	if poll.ResultsVisibleTo(userID, time.Now()) {
+		return true, nil
+	}
+	if poll.Visibility() != models.ResultsAfterVote {
+		return false, nil
+	}
+	key := h.existingVoterKey(c, poll)
+	if key == "" {
+		return false, nil
+	}
+	return h.store.HasVoted(ctx, poll.ID, key)
+}
+
+// hideResultsFrom removes the poll's vote counts if the caller may not see
+// them. If that cannot be decided it responds with an error and returns false.
+func (h *PollHandler) hideResultsFrom(ctx context.Context, c *gin.Context, poll *models.Poll) bool {
+	visible, err := h.resultsVisible(ctx, c, poll)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to check whether the caller voted", "poll_id", poll.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve poll"})
+		return false
+	}
+	if !visible {
+		poll.HideResults()
+	}
+	return true
+}
+
+// requireResults responds with 403 Forbidden and returns false unless the
+// caller may see the poll's results. It is used by the endpoints that
+// exist only to show results.
+func (h *PollHandler) requireResults(ctx context.Context, c *gin.Context, poll *models.Poll) bool {
+	visible, err := h.resultsVisible(ctx, c, poll)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to check whether the caller voted", "poll_id", poll.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve poll"})
+		return false
+	}
+	if visible {
+		return true
+	}
+
+	var message string
+	switch poll.Visibility() {
+	case models.ResultsAfterVote:
+		message = "Results of this poll are shown once you have voted"
+	case models.ResultsAfterClose:
+		message = "Results of this poll are shown once it closes"
+	default: // models.ResultsCreatorOnly
+		message = "Only the poll's creator can see its results"
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": message})
+	return false
+}
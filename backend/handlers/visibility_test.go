@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"instapoll/backend/models"
+	"instapoll/backend/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// insertVisibilityPoll stores a plurality poll with the given results
+// visibility and one vote for its first option, recorded before the poll
+// is given its expiry
+func insertVisibilityPoll(t *testing.T, s store.PollStore, visibility string, expiresAt time.Time) models.Poll {
+	poll := insertTestPoll(t, s, "plurality", time.Time{})
+	_, err := s.RecordVote(context.Background(), &models.Ballot{
+		ID:        "seed-ballot",
+		PollID:    poll.ID,
+		Ballot:    models.VoteRequest{OptionID: poll.Options[0].ID}.Ballot(),
+		CreatedAt: time.Now(),
+	})
+	require.NoError(t, err)
+
+	poll.ResultsVisibility = visibility
+	poll.ExpiresAt = expiresAt
+	require.NoError(t, s.Update(context.Background(), &poll, poll.Version))
+	return poll
+}
+
+// getAs fetches a path as the given user (anonymous if empty) with cookies
+func getAs(router *gin.Engine, userID, path string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	if userID != "" {
+		tokens, _ := testTokens.Issue(userID)
+		req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// decodePoll decodes a poll response body
+func decodePoll(t *testing.T, w *httptest.ResponseRecorder) models.Poll {
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var poll models.Poll
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &poll))
+	return poll
+}
+
+func TestResultsVisibility(t *testing.T) {
+	tests := []struct {
+		name        string
+		visibility  string
+		expiresAt   time.Time
+		userID      string
+		wantVisible bool
+	}{
+		{name: "always", visibility: models.ResultsAlways, wantVisible: true},
+		{name: "after vote before voting", visibility: models.ResultsAfterVote, wantVisible: false},
+		{name: "after close while open", visibility: models.ResultsAfterClose, expiresAt: time.Now().Add(time.Hour), wantVisible: false},
+		{name: "after close once closed", visibility: models.ResultsAfterClose, expiresAt: time.Now().Add(-time.Second), wantVisible: true},
+		{name: "creator only", visibility: models.ResultsCreatorOnly, userID: "someone-else", wantVisible: false},
+		{name: "creator only to the creator", visibility: models.ResultsCreatorOnly, userID: testUserID, wantVisible: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore()
+			router := setupRouter(s)
+			poll := insertVisibilityPoll(t, s, tt.visibility, tt.expiresAt)
+
+			got := decodePoll(t, getAs(router, tt.userID, "/api/polls/"+poll.ID))
+			assert.Equal(t, !tt.wantVisible, got.ResultsHidden)
+			assert.Equal(t, 1, got.TotalVotes, "The number of ballots stays visible")
+			if tt.wantVisible {
+				assert.Equal(t, 1, got.Options[0].VoteCount)
+			} else {
+				assert.Equal(t, 0, got.Options[0].VoteCount, "Vote counts should be stripped")
+			}
+
+			var list PollList
+			w := getAs(router, tt.userID, "/api/polls")
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+			require.Len(t, list.Items, 1)
+			assert.Equal(t, !tt.wantVisible, list.Items[0].ResultsHidden)
+
+			wantStatus := http.StatusOK
+			if !tt.wantVisible {
+				wantStatus = http.StatusForbidden
+			}
+			assert.Equal(t, wantStatus, getAs(router, tt.userID, "/api/polls/"+poll.ID+"/results").Code)
+			assert.Equal(t, wantStatus, getAs(router, tt.userID, "/api/polls/"+poll.ID+"/results/explain").Code)
+		})
+	}
+}
+
+func TestResultsVisibility_AfterVote(t *testing.T) {
+	s := newTestStore()
+	router := setupRouter(s)
+	poll := insertVisibilityPoll(t, s, models.ResultsAfterVote, time.Time{})
+	payload := `{"option_id": "` + poll.Options[1].ID + `"}`
+
+	// The vote response already shows the counts.
+	w := voteWith(router, poll.ID, payload, "", "")
+	voted := decodePoll(t, w)
+	assert.False(t, voted.ResultsHidden)
+	assert.Equal(t, 1, voted.Options[1].VoteCount)
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+
+	// The voter's cookie shows they voted; other browsers still cannot see.
+	got := decodePoll(t, getAs(router, "", "/api/polls/"+poll.ID, cookies[0]))
+	assert.False(t, got.ResultsHidden)
+	assert.Equal(t, 1, got.Options[0].VoteCount)
+	assert.Equal(t, http.StatusOK, getAs(router, "", "/api/polls/"+poll.ID+"/results", cookies[0]).Code)
+
+	got = decodePoll(t, getAs(router, "", "/api/polls/"+poll.ID))
+	assert.True(t, got.ResultsHidden)
+	assert.Equal(t, http.StatusForbidden, getAs(router, "", "/api/polls/"+poll.ID+"/results").Code)
+}
+
+func TestResultsVisibility_Streams(t *testing.T) {
+	s := newTestStore()
+	server := httptest.NewServer(setupRouter(s))
+	defer server.Close()
+	poll := insertVisibilityPoll(t, s, models.ResultsCreatorOnly, time.Time{})
+
+	_, resp, err := dialPoll(t, server, poll.ID)
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp, err = http.Get(server.URL + "/api/polls/" + poll.ID + "/events")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestResultsVisibility_StreamsHiddenLater(t *testing.T) {
+	s := newTestStore()
+	router := setupRouter(s)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close) // Runs after the event streams are closed
+	poll := insertVisibilityPoll(t, s, models.ResultsAlways, time.Time{})
+
+	conn, _, err := dialPoll(t, server, poll.ID)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, 1, readMessage(t, conn).TotalVotes)
+	resp, events := openEvents(t, server, poll.ID, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "1", readEvent(t, events).ID)
+
+	// The creator hides the results while both streams are open.
+	current, err := s.Get(context.Background(), poll.ID)
+	require.NoError(t, err)
+	hidden := *current
+	hidden.ResultsVisibility = models.ResultsCreatorOnly
+	hidden.Version = current.Version + 1
+	require.NoError(t, s.Update(context.Background(), &hidden, current.Version))
+
+	// The next vote ends both streams instead of revealing the new counts.
+	require.Equal(t, http.StatusOK, postVote(router, poll.ID, `{"option_id": "`+poll.Options[1].ID+`"}`).Code)
+
+	conn.SetReadDeadline(time.Now().Add(defaultTimeout))
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation), "got %v", err)
+
+	line, err := events.ReadString('\n')
+	for err == nil && !strings.HasPrefix(line, "id: ") {
+		line, err = events.ReadString('\n')
+	}
+	assert.ErrorIs(t, err, io.EOF, "the event stream sent %q", line)
+}
+
+func TestCastVote_HidesResults(t *testing.T) {
+	s := newTestStore()
+	router := setupRouter(s)
+	poll := insertVisibilityPoll(t, s, models.ResultsAfterClose, time.Now().Add(time.Hour))
+
+	got := decodePoll(t, postVote(router, poll.ID, `{"option_id": "`+poll.Options[0].ID+`"}`))
+	assert.True(t, got.ResultsHidden)
+	assert.Equal(t, 0, got.Options[0].VoteCount)
+	assert.Equal(t, 2, got.TotalVotes)
+}
+
+func TestUpdatePoll_ResultsVisibility(t *testing.T) {
+	router := setupRouter(newTestStore())
+	poll := createPoll(t, router, `{"title": "Lunch?", "results_visibility": "creator_only", "options": [{"text": "Pizza"}, {"text": "Sushi"}]}`)
+	assert.Equal(t, models.ResultsCreatorOnly, poll.ResultsVisibility)
+	require.Equal(t, http.StatusOK, postVote(router, poll.ID, `{"option_id": "`+poll.Options[0].ID+`"}`).Code)
+
+	// Results can be revealed after votes have been cast.
+	payload := `{"title": "Lunch?", "version": 1, "results_visibility": "always", "options": [` +
+		`{"id": "` + poll.Options[0].ID + `", "text": "Pizza"},` +
+		`{"id": "` + poll.Options[1].ID + `", "text": "Sushi"}]}`
+	updated := decodePoll(t, sendJSON(router, "PUT", "/api/polls/"+poll.ID, payload))
+	assert.Equal(t, models.ResultsAlways, updated.ResultsVisibility)
+
+	got := decodePoll(t, getAs(router, "", "/api/polls/"+poll.ID))
+	assert.Equal(t, 1, got.Options[0].VoteCount)
+
+	w := sendJSON(router, "POST", "/api/polls", `{"title": "Lunch?", "results_visibility": "sometimes", "options": [{"text": "Pizza"}, {"text": "Sushi"}]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestUpdatePoll_RevealResultsOfClosedPoll(t *testing.T) {
+	s := newTestStore()
+	router := setupRouter(s)
+	poll := createPoll(t, router, `{"title": "Lunch?", "results_visibility": "creator_only", "expires_at": "`+
+		time.Now().Add(time.Hour).Format(time.RFC3339)+`", "options": [{"text": "Pizza"}, {"text": "Sushi"}]}`)
+	require.Equal(t, http.StatusOK, postVote(router, poll.ID, `{"option_id": "`+poll.Options[0].ID+`"}`).Code)
+
+	// The poll expires.
+	current, err := s.Get(context.Background(), poll.ID)
+	require.NoError(t, err)
+	expired := *current
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	expired.Version = current.Version + 1
+	require.NoError(t, s.Update(context.Background(), &expired, current.Version))
+
+	// The creator sends the poll back as read, with only the visibility
+	// changed; the past expiry does not stop the results being revealed.
+	read := decodePoll(t, getAs(router, testUserID, "/api/polls/"+poll.ID))
+	read.ResultsVisibility = models.ResultsAlways
+	payload, err := json.Marshal(read)
+	require.NoError(t, err)
+	updated := decodePoll(t, sendJSON(router, "PUT", "/api/polls/"+poll.ID, string(payload)))
+	assert.Equal(t, models.ResultsAlways, updated.ResultsVisibility)
+
+	got := decodePoll(t, getAs(router, "", "/api/polls/"+poll.ID))
+	assert.False(t, got.ResultsHidden)
+	assert.Equal(t, 1, got.Options[0].VoteCount)
+
+	// Other changes to the closed poll are still validated in full.
+	read = updated
+	read.Title = "Dinner?"
+	payload, err = json.Marshal(read)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, sendJSON(router, "PUT", "/api/polls/"+poll.ID, string(payload)).Code)
+}
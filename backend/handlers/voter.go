@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"instapoll/backend/auth"
+	"instapoll/backend/logging"
+	"instapoll/backend/models"
+	"instapoll/backend/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// voterCookie holds a signed random ID identifying a browser for
+	// polls using models.VoterPolicyCookie
+	voterCookie = "instapoll_voter"
+	// voterCookieMaxAge is how long browsers keep the voter cookie, in seconds
+	voterCookieMaxAge = 365 * 24 * 60 * 60
+	// maxInvites is the most invite tokens CreateInvites issues at once
+	maxInvites = 500
+)
+
+// voterKey works out who is voting on a poll under its voter policy and
+// returns a key identifying them. The store allows one ballot per key, so
+// this is what stops people voting twice. Keys are hashed with the poll ID,
+// so ballots do not store user IDs or IP addresses and a voter's keys on
+// different polls cannot be linked.
+// If the caller cannot vote, voterKey responds with an error and returns false.
+func (h *PollHandler) voterKey(c *gin.Context, poll *models.Poll, vote *models.VoteRequest) (string, bool) {
+	var identity string
+	switch poll.Policy() {
+	case models.VoterPolicyUser:
+		userID, ok := auth.UserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Sign in to vote on this poll"})
+			return "", false
+		}
+		identity = userID
+	case models.VoterPolicyIP:
+		identity = c.ClientIP()
+	case models.VoterPolicyInvite:
+		if !h.validInvite(poll.ID, vote.Invite) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "A valid invite is required to vote on this poll"})
+			return "", false
+		}
+		identity = vote.Invite
+	default: // models.VoterPolicyCookie
+		id, ok := h.browserID(c)
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Your voter cookie could not be verified"})
+			return "", false
+		}
+		identity = id
+	}
+
+	return hashVoterKey(poll, identity), true
+}
+
+// existingVoterKey returns the key the caller would vote with, or "" if
+// they have no identity under the poll's voter policy yet. Unlike voterKey
+// it never sets a cookie or responds with an error. Invites are taken from
+// the invite query parameter.
+func (h *PollHandler) existingVoterKey(c *gin.Context, poll *models.Poll) string {
+	var identity string
+	switch poll.Policy() {
+	case models.VoterPolicyUser:
+		identity, _ = auth.UserID(c)
+	case models.VoterPolicyIP:
+		identity = c.ClientIP()
+	case models.VoterPolicyInvite:
+		if invite := c.Query("invite"); h.validInvite(poll.ID, invite) {
+			identity = invite
+		}
+	default: // models.VoterPolicyCookie
+		identity, _ = h.cookieBrowserID(c)
+	}
+	if identity == "" {
+		return ""
+	}
+	return hashVoterKey(poll, identity)
+}
+
+// hashVoterKey derives a poll's voter key from the voter's identity
+func hashVoterKey(poll *models.Poll, identity string) string {
+	sum := sha256.Sum256([]byte(poll.ID + "\x00" + poll.Policy() + "\x00" + identity))
+	return hex.EncodeToString(sum[:])
+}
+
+// cookieBrowserID returns the voter ID from the caller's signed voter
+// cookie, if they sent a valid one
+func (h *PollHandler) cookieBrowserID(c *gin.Context) (string, bool) {
+	cookie, err := c.Cookie(voterCookie)
+	if err != nil {
+		return "", false
+	}
+	id, signature, ok := strings.Cut(cookie, ".")
+	if !ok || !h.tokens.Keys().Verify(id, signature) {
+		return "", false
+	}
+	return id, true
+}
+
+// browserID returns the voter ID from the caller's signed voter cookie,
+// setting a new cookie if there is none. A cookie that fails verification,
+// e.g. because it was tampered with or signed with a key no longer listed,
+// is not replaced, as that would let its browser vote again; false is
+// returned instead.
+func (h *PollHandler) browserID(c *gin.Context) (string, bool) {
+	if _, err := c.Cookie(voterCookie); err == nil {
+		return h.cookieBrowserID(c)
+	}
+
+	keys := h.tokens.Keys()
+	id := uuid.New().String()
+	secure := h.overHTTPS(c)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(voterCookie, id+"."+keys.Sign(id), voterCookieMaxAge, "/", "", secure, true)
+	return id, true
+}
+
+// TrustProxies sets the reverse proxies, as addresses or CIDR ranges, whose
+// X-Forwarded-Proto header tells whether the client connected over HTTPS.
+// The header is ignored from anyone else, as any client could send it.
+func (h *PollHandler) TrustProxies(proxies []string) error {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+	for _, proxy := range proxies {
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(proxy)
+			if addrErr != nil {
+				return fmt.Errorf("trusted proxy %q is not an IP address or CIDR range", proxy)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	h.proxies = prefixes
+	return nil
+}
+
+// overHTTPS reports whether the client connected over HTTPS, either to
+// this server or to a trusted proxy in front of it
+func (h *PollHandler) overHTTPS(c *gin.Context) bool {
+	if c.Request.TLS != nil {
+		return true
+	}
+	if c.GetHeader("X-Forwarded-Proto") != "https" {
+		return false
+	}
+	remote, err := netip.ParseAddr(c.RemoteIP())
+	if err != nil {
+		return false
+	}
+	for _, proxy := range h.proxies {
+		if proxy.Contains(remote.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+// inviteSubject is the value an invite token's signature covers
+func inviteSubject(pollID, nonce string) string {
+	return "invite:" + pollID + ":" + nonce
+}
+
+// validInvite reports whether token is an invite issued for the poll.
+// Invites are signed rather than stored; that each is used only once is
+// enforced by the voter key derived from it.
+func (h *PollHandler) validInvite(pollID, token string) bool {
+	nonce, signature, ok := strings.Cut(token, ".")
+	return ok && nonce != "" && h.tokens.Keys().Verify(inviteSubject(pollID, nonce), signature)
+}
+
+// CreateInvites handles issuing single-use invite tokens for a poll that
+// uses the invite voter policy. Only the poll's creator can issue them.
+// The request body is {"count": n}; one invite is issued if it is omitted.
+func (h *PollHandler) CreateInvites(c *gin.Context) {
+	pollID := c.Param("id")
+	if pollID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Poll ID parameter is required"})
+		return
+	}
+
+	var req struct {
+		Count int `json:"count"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+			return
+		}
+	}
+	if req.Count == 0 {
+		req.Count = 1
+	}
+	if req.Count < 0 || req.Count > maxInvites {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "count must be between 1 and 500"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeouts.Request)
+	defer cancel()
+
+	poll, err := h.store.Get(ctx, pollID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			logging.FromContext(ctx).Info("Poll not found", "poll_id", pollID)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Poll not found"})
+		} else {
+			logging.FromContext(ctx).Error("Failed to retrieve poll", "poll_id", pollID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve poll"})
+		}
+		return
+	}
+	if !requireCreator(c, poll) {
+		return
+	}
+	if poll.Policy() != models.VoterPolicyInvite {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Poll does not use invite voting"})
+		return
+	}
+
+	keys := h.tokens.Keys()
+	invites := make([]string, req.Count)
+	nonce := make([]byte, 16)
+	for i := range invites {
+		if _, err := rand.Read(nonce); err != nil {
+			logging.FromContext(ctx).Error("Failed to generate invite", "poll_id", pollID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invites"})
+			return
+		}
+		encoded := base64.RawURLEncoding.EncodeToString(nonce)
+		invites[i] = encoded + "." + keys.Sign(inviteSubject(pollID, encoded))
+	}
+	logging.FromContext(ctx).Info("Issued invites", "poll_id", pollID, "count", len(invites))
+
+	c.JSON(http.StatusCreated, gin.H{"poll_id": pollID, "invites": invites})
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"instapoll/backend/live"
+	"instapoll/backend/models"
+	"instapoll/backend/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// insertPolicyPoll stores an open plurality poll using the given voter policy
+func insertPolicyPoll(t *testing.T, s store.PollStore, policy string) models.Poll {
+	poll := insertTestPoll(t, s, "plurality", time.Now().Add(time.Hour))
+	poll.VoterPolicy = policy
+	require.NoError(t, s.Update(context.Background(), &poll, poll.Version))
+	return poll
+}
+
+// voteWith sends a vote with the given cookies and remote address,
+// signed in as userID unless it is empty
+func voteWith(router *gin.Engine, pollID, payload, userID, remoteAddr string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", "/api/polls/"+pollID+"/votes", bytes.NewBufferString(payload))
+	return sendVote(router, req, userID, remoteAddr, cookies...)
+}
+
+// sendVote sends the vote request req like voteWith, so that callers can
+// add headers of their own
+func sendVote(router *gin.Engine, req *http.Request, userID, remoteAddr string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req.Header.Set("Content-Type", "application/json")
+	if userID != "" {
+		tokens, _ := testTokens.Issue(userID)
+		req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	}
+	if remoteAddr != "" {
+		req.RemoteAddr = remoteAddr
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCastVote_CookiePolicy(t *testing.T) {
+	s := newTestStore()
+	router := setupRouter(s)
+	poll := insertPolicyPoll(t, s, models.VoterPolicyCookie)
+	payload := `{"option_id": "` + poll.Options[0].ID + `"}`
+
+	// The first vote hands the browser a voter cookie.
+	w := voteWith(router, poll.ID, payload, "", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, voterCookie, cookies[0].Name)
+	assert.True(t, cookies[0].HttpOnly)
+
+	// Voting again from the same browser is rejected.
+	w = voteWith(router, poll.ID, payload, "", "", cookies[0])
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// A cookie that fails verification is refused rather than replaced, so
+	// that a browser cannot vote again with a tampered cookie, or one signed
+	// by a key the server no longer has.
+	forged := &http.Cookie{Name: voterCookie, Value: "someone-else." + cookies[0].Value}
+	w = voteWith(router, poll.ID, payload, "", "", forged)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, w.Result().Cookies())
+	stale := &http.Cookie{Name: voterCookie, Value: "browser-id.signed-by-an-old-key"}
+	w = voteWith(router, poll.ID, payload, "", "", stale)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// A browser without a cookie is a new voter.
+	w = voteWith(router, poll.ID, payload, "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	got, err := s.Get(context.Background(), poll.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, got.TotalVotes)
+}
+
+func TestCastVote_SecureCookieBehindProxy(t *testing.T) {
+	s := newTestStore()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler := NewPollHandler(s, live.NewHub(live.DefaultBuffer), testTokens, DefaultTimeouts)
+	handler.RegisterRoutes(router)
+	poll := insertPolicyPoll(t, s, models.VoterPolicyCookie)
+	payload := `{"option_id": "` + poll.Options[0].ID + `"}`
+
+	voteCookie := func(remoteAddr string) *http.Cookie {
+		req, _ := http.NewRequest("POST", "/api/polls/"+poll.ID+"/votes", bytes.NewBufferString(payload))
+		req.Header.Set("X-Forwarded-Proto", "https")
+		w := sendVote(router, req, "", remoteAddr)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		cookies := w.Result().Cookies()
+		require.Len(t, cookies, 1)
+		return cookies[0]
+	}
+
+	// Any client can claim to have connected over HTTPS.
+	assert.False(t, voteCookie("203.0.113.7:1234").Secure)
+
+	// A trusted proxy is believed.
+	require.Error(t, handler.TrustProxies([]string{"proxy.internal"}))
+	require.NoError(t, handler.TrustProxies([]string{"10.0.0.0/8", "192.0.2.1"}))
+	assert.True(t, voteCookie("10.1.2.3:1234").Secure)
+	assert.True(t, voteCookie("192.0.2.1:1234").Secure)
+	assert.False(t, voteCookie("192.0.2.2:1234").Secure)
+}
+
+func TestCastVote_IPPolicy(t *testing.T) {
+	s := newTestStore()
+	router := setupRouter(s)
+	poll := insertPolicyPoll(t, s, models.VoterPolicyIP)
+	payload := `{"option_id": "` + poll.Options[0].ID + `"}`
+
+	w := voteWith(router, poll.ID, payload, "", "203.0.113.7:1234")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Empty(t, w.Result().Cookies(), "IP polls should not set a voter cookie")
+
+	// The port changes between connections; the address is what counts.
+	w = voteWith(router, poll.ID, payload, "", "203.0.113.7:5678")
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = voteWith(router, poll.ID, payload, "", "203.0.113.8:1234")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestCastVote_IPPolicySpoofedHeaders(t *testing.T) {
+	s := newTestStore()
+	router := setupRouter(s)
+	poll := insertPolicyPoll(t, s, models.VoterPolicyIP)
+	payload := `{"option_id": "` + poll.Options[0].ID + `"}`
+
+	// Forwarding headers from a client that is not a trusted proxy are
+	// ignored, so they cannot be used to vote again.
+	for i, forwarded := range []string{"198.51.100.1", "198.51.100.2"} {
+		req, _ := http.NewRequest("POST", "/api/polls/"+poll.ID+"/votes", bytes.NewBufferString(payload))
+		req.Header.Set("X-Forwarded-For", forwarded)
+		req.Header.Set("X-Real-IP", forwarded)
+		w := sendVote(router, req, "", "203.0.113.7:1234")
+		if i == 0 {
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		} else {
+			assert.Equal(t, http.StatusConflict, w.Code, "a spoofed X-Forwarded-For should not count as a new voter")
+		}
+	}
+
+	// Behind a trusted proxy the forwarded address is the voter's.
+	require.NoError(t, router.SetTrustedProxies([]string{"10.0.0.0/8"}))
+	for _, forwarded := range []string{"198.51.100.1", "198.51.100.2"} {
+		req, _ := http.NewRequest("POST", "/api/polls/"+poll.ID+"/votes", bytes.NewBufferString(payload))
+		req.Header.Set("X-Forwarded-For", forwarded)
+		w := sendVote(router, req, "", "10.0.0.1:1234")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	got, err := s.Get(context.Background(), poll.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, got.TotalVotes)
+}
+
+func TestCastVote_UserPolicy(t *testing.T) {
+	s := newTestStore()
+	router := setupRouter(s)
+	poll := insertPolicyPoll(t, s, models.VoterPolicyUser)
+	payload := `{"option_id": "` + poll.Options[0].ID + `"}`
+
+	w := voteWith(router, poll.ID, payload, "", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = voteWith(router, poll.ID, payload, "alice", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Signing in from another address does not allow a second vote.
+	w = voteWith(router, poll.ID, payload, "alice", "198.51.100.1:1234")
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = voteWith(router, poll.ID, payload, "bob", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+// createInvites issues invites for a poll as its creator
+func createInvites(t *testing.T, router *gin.Engine, pollID string, count int) []string {
+	payload, _ := json.Marshal(map[string]int{"count": count})
+	w := sendJSON(router, "POST", "/api/polls/"+pollID+"/invites", string(payload))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var response struct {
+		PollID  string   `json:"poll_id"`
+		Invites []string `json:"invites"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, pollID, response.PollID)
+	return response.Invites
+}
+
+func TestCastVote_InvitePolicy(t *testing.T) {
+	s := newTestStore()
+	router := setupRouter(s)
+	poll := insertPolicyPoll(t, s, models.VoterPolicyInvite)
+	other := insertPolicyPoll(t, s, models.VoterPolicyInvite)
+
+	invites := createInvites(t, router, poll.ID, 2)
+	require.Len(t, invites, 2)
+	assert.NotEqual(t, invites[0], invites[1])
+
+	vote := func(pollID, invite string) int {
+		payload := `{"option_id": "` + poll.Options[0].ID + `", "invite": "` + invite + `"}`
+		return voteWith(router, pollID, payload, "", "").Code
+	}
+
+	assert.Equal(t, http.StatusForbidden, vote(poll.ID, ""), "Missing invite")
+	assert.Equal(t, http.StatusForbidden, vote(poll.ID, "not-an-invite"), "Malformed invite")
+	assert.Equal(t, http.StatusForbidden, vote(poll.ID, invites[0]+"x"), "Tampered invite")
+	assert.Equal(t, http.StatusForbidden, vote(other.ID, invites[0]), "Invite for another poll")
+
+	assert.Equal(t, http.StatusOK, vote(poll.ID, invites[0]))
+	assert.Equal(t, http.StatusConflict, vote(poll.ID, invites[0]), "Invites are single-use")
+	assert.Equal(t, http.StatusOK, vote(poll.ID, invites[1]))
+}
+
+func TestCreateInvites_Rejected(t *testing.T) {
+	s := newTestStore()
+	router := setupRouter(s)
+	invitePoll := insertPolicyPoll(t, s, models.VoterPolicyInvite)
+	cookiePoll := insertPolicyPoll(t, s, models.VoterPolicyCookie)
+
+	tests := []struct {
+		name         string
+		userID       string
+		pollID       string
+		payload      string
+		expectedCode int
+	}{
+		{"Anonymous", "", invitePoll.ID, `{}`, http.StatusUnauthorized},
+		{"Not the creator", "someone-else", invitePoll.ID, `{}`, http.StatusForbidden},
+		{"Poll does not use invites", testUserID, cookiePoll.ID, `{}`, http.StatusBadRequest},
+		{"Too many invites", testUserID, invitePoll.ID, `{"count": 501}`, http.StatusBadRequest},
+		{"Negative count", testUserID, invitePoll.ID, `{"count": -1}`, http.StatusBadRequest},
+		{"Poll not found", testUserID, "missing", `{}`, http.StatusNotFound},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := sendJSONAs(router, tc.userID, "POST", "/api/polls/"+tc.pollID+"/invites", tc.payload)
+			assert.Equal(t, tc.expectedCode, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestUpdatePoll_VoterPolicy(t *testing.T) {
+	router := setupRouter(newTestStore())
+	poll := createPoll(t, router, `{"title": "Policy", "options": [{"text": "A"}, {"text": "B"}]}`)
+	assert.Equal(t, models.DefaultVoterPolicy, poll.VoterPolicy)
+	options := `"options": [` +
+		`{"id": "` + poll.Options[0].ID + `", "text": "A"},` +
+		`{"id": "` + poll.Options[1].ID + `", "text": "B"}]`
+
+	// Leaving the policy out keeps the current one.
+	w := sendJSON(router, "PUT", "/api/polls/"+poll.ID, `{"title": "Renamed", "version": 1, `+options+`}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var updated models.Poll
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.Equal(t, models.DefaultVoterPolicy, updated.VoterPolicy)
+
+	w = postVote(router, poll.ID, `{"option_id": "`+poll.Options[0].ID+`"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Once votes are in, the policy cannot change.
+	w = sendJSON(router, "PUT", "/api/polls/"+poll.ID, `{"title": "Renamed", "version": 2, "voter_policy": "ip", `+options+`}`)
+	assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+}
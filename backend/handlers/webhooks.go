@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"instapoll/backend/auth"
+	"instapoll/backend/logging"
+	"instapoll/backend/models"
+	"instapoll/backend/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultDeliveryLimit is how many deliveries are listed without ?limit=
+	defaultDeliveryLimit = 50
+	// maxDeliveryLimit caps ?limit= on the delivery log
+	maxDeliveryLimit = 100
+)
+
+// WebhookHandler holds the storage used for webhooks and the polls they
+// subscribe to, the token manager identifying their owners, and how long
+// requests wait on the stores
+type WebhookHandler struct {
+	hooks    store.WebhookStore
+	polls    store.PollStore
+	tokens   *auth.Manager
+	timeouts Timeouts
+}
+
+// NewWebhookHandler creates a new handler with the given stores, token
+// manager and timeouts
+func NewWebhookHandler(hooks store.WebhookStore, polls store.PollStore, tokens *auth.Manager, timeouts Timeouts) *WebhookHandler {
+	return &WebhookHandler{hooks: hooks, polls: polls, tokens: tokens, timeouts: timeouts}
+}
+
+// webhookRequest is the request body of CreateWebhook
+type webhookRequest struct {
+	URL    string   `json:"url" binding:"required"`
+	PollID string   `json:"poll_id"` // Empty to receive events of every poll the caller creates
+	Events []string `json:"events"`  // Empty to receive every event type
+}
+
+// RegisterRoutes sets up the webhook routes under /api/webhooks. Every route
+// requires a signed-in user, who only sees their own webhooks.
+func (h *WebhookHandler) RegisterRoutes(r *gin.Engine) {
+	webhooks := r.Group("/api/webhooks", h.tokens.Identify, auth.RequireUser)
+	{
+		webhooks.POST("", h.CreateWebhook)                                        // Subscribe a URL to poll events
+		webhooks.GET("", h.ListWebhooks)                                          // The caller's webhooks
+		webhooks.GET("/:id", h.GetWebhook)                                        // One of the caller's webhooks
+		webhooks.DELETE("/:id", h.DeleteWebhook)                                  // Unsubscribe
+		webhooks.GET("/:id/deliveries", h.ListDeliveries)                         // Delivery log of /api/webhooks/:id
+		webhooks.POST("/:id/deliveries/:delivery/redeliver", h.RedeliverDelivery) // Send a delivery again
+	}
+}
+
+// CreateWebhook subscribes a URL to the events of the caller's polls, or of
+// one of them if "poll_id" is given. The response includes the secret that
+// signs deliveries; it is not shown again.
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req webhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logging.FromContext(c.Request.Context()).Info("Invalid request body", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	userID, _ := auth.UserID(c)
+	webhook := models.Webhook{
+		ID:        uuid.New().String(),
+		OwnerID:   userID,
+		PollID:    req.PollID,
+		URL:       req.URL,
+		Events:    req.Events,
+		CreatedAt: time.Now(),
+	}
+	if err := webhook.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeouts.Request)
+	defer cancel()
+
+	if webhook.PollID != "" {
+		poll, err := h.polls.Get(ctx, webhook.PollID)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Poll not found"})
+			} else {
+				logging.FromContext(ctx).Error("Failed to retrieve poll", "poll_id", webhook.PollID, "error", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve poll"})
+			}
+			return
+		}
+		if poll.CreatorID != userID {
+			logging.FromContext(ctx).Info("Only the creator may subscribe to the poll", "poll_id", poll.ID, "user_id", userID, "creator_id", poll.CreatorID)
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the poll's creator can subscribe to its events"})
+			return
+		}
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to generate webhook secret", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+	webhook.Secret = secret
+	if err := h.hooks.CreateWebhook(ctx, &webhook); err != nil {
+		logging.FromContext(ctx).Error("Failed to create webhook", "webhook_id", webhook.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+	logging.FromContext(ctx).Info("Created webhook", "webhook_id", webhook.ID, "poll_id", webhook.PollID, "user_id", userID)
+	c.JSON(http.StatusCreated, webhook)
+}
+
+// ListWebhooks returns the caller's webhooks, oldest first, without their secrets
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	userID, _ := auth.UserID(c)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeouts.Request)
+	defer cancel()
+
+	webhooks, err := h.hooks.ListWebhooks(ctx, userID)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to list webhooks", "user_id", userID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve webhooks"})
+		return
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	c.JSON(http.StatusOK, webhooks)
+}
+
+// GetWebhook returns one of the caller's webhooks without its secret
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeouts.Request)
+	defer cancel()
+
+	webhook, ok := h.loadWebhook(ctx, c)
+	if !ok {
+		return
+	}
+	webhook.Secret = ""
+	c.JSON(http.StatusOK, webhook)
+}
+
+// DeleteWebhook unsubscribes one of the caller's webhooks. Deliveries still
+// pending are not sent; the delivery log is kept.
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeouts.Request)
+	defer cancel()
+
+	webhook, ok := h.loadWebhook(ctx, c)
+	if !ok {
+		return
+	}
+	if err := h.hooks.DeleteWebhook(ctx, webhook.ID); err != nil && !errors.Is(err, store.ErrWebhookNotFound) {
+		logging.FromContext(ctx).Error("Failed to delete webhook", "webhook_id", webhook.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+	logging.FromContext(ctx).Info("Deleted webhook", "webhook_id", webhook.ID)
+	c.Status(http.StatusNoContent)
+}
+
+// ListDeliveries returns the delivery log of one of the caller's webhooks,
+// newest first, with every attempt's outcome.
+// Query parameters (optional):
+//   - limit: how many deliveries, default 50 and capped at 100
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	limit := defaultDeliveryLimit
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = n
+		if limit > maxDeliveryLimit {
+			limit = maxDeliveryLimit
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeouts.Request)
+	defer cancel()
+
+	webhook, ok := h.loadWebhook(ctx, c)
+	if !ok {
+		return
+	}
+	deliveries, err := h.hooks.ListDeliveries(ctx, webhook.ID, limit)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to list deliveries", "webhook_id", webhook.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve deliveries"})
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// RedeliverDelivery queues a finished delivery of one of the caller's
+// webhooks to be sent again straight away, e.g. once a dead receiver is
+// fixed. A dead delivery gets a single further attempt.
+func (h *WebhookHandler) RedeliverDelivery(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeouts.Request)
+	defer cancel()
+
+	webhook, ok := h.loadWebhook(ctx, c)
+	if !ok {
+		return
+	}
+	delivery, err := h.hooks.GetDelivery(ctx, c.Param("delivery"))
+	if err == nil && delivery.WebhookID != webhook.ID {
+		err = store.ErrDeliveryNotFound
+	}
+	if err != nil {
+		if errors.Is(err, store.ErrDeliveryNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		} else {
+			logging.FromContext(ctx).Error("Failed to retrieve delivery", "webhook_id", webhook.ID, "delivery_id", c.Param("delivery"), "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve delivery"})
+		}
+		return
+	}
+	if delivery.Status == models.DeliveryPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Delivery is already waiting to be sent"})
+		return
+	}
+
+	delivery.Status = models.DeliveryPending
+	delivery.NextAttemptAt = time.Now()
+	if err := h.hooks.SaveDelivery(ctx, delivery); err != nil {
+		logging.FromContext(ctx).Error("Failed to save delivery", "webhook_id", webhook.ID, "delivery_id", delivery.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to redeliver"})
+		return
+	}
+	logging.FromContext(ctx).Info("Queued delivery to be sent again", "webhook_id", webhook.ID, "delivery_id", delivery.ID)
+	c.JSON(http.StatusOK, delivery)
+}
+
+// loadWebhook returns the webhook named in the request if the caller owns
+// it. Otherwise it responds with 404 Not Found, so other users' webhooks
+// cannot be told from missing ones, and returns false.
+func (h *WebhookHandler) loadWebhook(ctx context.Context, c *gin.Context) (*models.Webhook, bool) {
+	userID, _ := auth.UserID(c)
+	webhook, err := h.hooks.GetWebhook(ctx, c.Param("id"))
+	if err == nil && webhook.OwnerID != userID {
+		err = store.ErrWebhookNotFound
+	}
+	if err != nil {
+		if errors.Is(err, store.ErrWebhookNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		} else {
+			logging.FromContext(ctx).Error("Failed to retrieve webhook", "webhook_id", c.Param("id"), "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve webhook"})
+		}
+		return nil, false
+	}
+	return webhook, true
+}
+
+// newWebhookSecret returns a random secret for signing deliveries
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
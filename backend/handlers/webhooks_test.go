@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"instapoll/backend/models"
+	"instapoll/backend/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupWebhookRouter creates a Gin router with the poll and webhook routes
+// sharing the given in-memory stores
+func setupWebhookRouter(polls store.PollStore, hooks store.WebhookStore) *gin.Engine {
+	r := setupRouter(polls)
+	NewWebhookHandler(hooks, polls, testTokens, DefaultTimeouts).RegisterRoutes(r)
+	return r
+}
+
+// createWebhook creates a webhook as testUserID and returns the response
+func createWebhook(t *testing.T, router *gin.Engine, payload string) models.Webhook {
+	w := sendJSON(router, "POST", "/api/webhooks", payload)
+	require.Equal(t, http.StatusCreated, w.Code, "Failed to create webhook: %s", w.Body.String())
+	var webhook models.Webhook
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &webhook))
+	return webhook
+}
+
+func TestCreateWebhook(t *testing.T) {
+	polls := newTestStore()
+	router := setupWebhookRouter(polls, store.NewMemoryWebhookStore())
+	poll := createPoll(t, router, `{"title": "Hooked", "options": [{"text": "A"}, {"text": "B"}]}`)
+
+	account := createWebhook(t, router, `{"url": "https://example.com/all"}`)
+	assert.Equal(t, testUserID, account.OwnerID)
+	assert.Empty(t, account.PollID)
+	assert.Len(t, account.Secret, 64, "The secret is returned once, on creation")
+
+	one := createWebhook(t, router, `{"url": "https://example.com/one", "poll_id": "`+poll.ID+`", "events": ["vote.cast"]}`)
+	assert.Equal(t, poll.ID, one.PollID)
+	assert.Equal(t, []string{models.EventVoteCast}, one.Events)
+
+	// Secrets are not shown again.
+	w := sendJSON(router, "GET", "/api/webhooks", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var list []models.Webhook
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list, 2)
+	assert.Equal(t, account.ID, list[0].ID)
+	assert.NotContains(t, w.Body.String(), account.Secret)
+	w = sendJSON(router, "GET", "/api/webhooks/"+one.ID, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "secret")
+
+	// Other users cannot see it.
+	w = sendJSONAs(router, "someone-else", "GET", "/api/webhooks/"+one.ID, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = sendJSONAs(router, "someone-else", "GET", "/api/webhooks", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[]`, w.Body.String())
+
+	w = sendJSONAs(router, "", "GET", "/api/webhooks", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = sendJSONAs(router, "someone-else", "DELETE", "/api/webhooks/"+one.ID, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = sendJSON(router, "DELETE", "/api/webhooks/"+one.ID, "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = sendJSON(router, "GET", "/api/webhooks/"+one.ID, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCreateWebhook_Invalid(t *testing.T) {
+	polls := newTestStore()
+	router := setupWebhookRouter(polls, store.NewMemoryWebhookStore())
+	poll := createPoll(t, router, `{"title": "Hooked", "options": [{"text": "A"}, {"text": "B"}]}`)
+
+	cases := []struct {
+		name    string
+		userID  string
+		payload string
+		status  int
+	}{
+		{"missing url", testUserID, `{}`, http.StatusBadRequest},
+		{"relative url", testUserID, `{"url": "/hooks"}`, http.StatusBadRequest},
+		{"other scheme", testUserID, `{"url": "ftp://example.com/hooks"}`, http.StatusBadRequest},
+		{"unknown event", testUserID, `{"url": "https://example.com", "events": ["poll.exploded"]}`, http.StatusBadRequest},
+		{"missing poll", testUserID, `{"url": "https://example.com", "poll_id": "nope"}`, http.StatusNotFound},
+		{"someone else's poll", "someone-else", `{"url": "https://example.com", "poll_id": "` + poll.ID + `"}`, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := sendJSONAs(router, tc.userID, "POST", "/api/webhooks", tc.payload)
+			assert.Equal(t, tc.status, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	ctx := context.Background()
+	hooks := store.NewMemoryWebhookStore()
+	router := setupWebhookRouter(newTestStore(), hooks)
+	webhook := createWebhook(t, router, `{"url": "https://example.com/hooks"}`)
+
+	now := time.Now().UTC()
+	deliveries := []models.Delivery{
+		{ID: "event-1:" + webhook.ID, WebhookID: webhook.ID, EventID: "event-1", EventType: models.EventPollCreated, Payload: json.RawMessage(`{}`), Status: models.DeliveryDead, CreatedAt: now.Add(-time.Hour)},
+		{ID: "event-2:" + webhook.ID, WebhookID: webhook.ID, EventID: "event-2", EventType: models.EventVoteCast, Payload: json.RawMessage(`{}`), Status: models.DeliveryPending, NextAttemptAt: now.Add(time.Hour), CreatedAt: now},
+		{ID: "event-2:other", WebhookID: "other", EventID: "event-2", EventType: models.EventVoteCast, Payload: json.RawMessage(`{}`), Status: models.DeliveryDead, CreatedAt: now},
+	}
+	require.NoError(t, hooks.Enqueue(ctx, deliveries))
+
+	w := sendJSON(router, "GET", "/api/webhooks/"+webhook.ID+"/deliveries", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var log []models.Delivery
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &log))
+	require.Len(t, log, 2)
+	assert.Equal(t, deliveries[1].ID, log[0].ID, "Newest first")
+
+	w = sendJSON(router, "GET", "/api/webhooks/"+webhook.ID+"/deliveries?limit=1", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &log))
+	assert.Len(t, log, 1)
+	w = sendJSON(router, "GET", "/api/webhooks/"+webhook.ID+"/deliveries?limit=0", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = sendJSONAs(router, "someone-else", "GET", "/api/webhooks/"+webhook.ID+"/deliveries", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Dead deliveries can be sent again; pending ones and other webhooks' cannot.
+	w = sendJSON(router, "POST", "/api/webhooks/"+webhook.ID+"/deliveries/"+deliveries[0].ID+"/redeliver", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	redelivered, err := hooks.GetDelivery(ctx, deliveries[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.DeliveryPending, redelivered.Status)
+	assert.False(t, redelivered.NextAttemptAt.After(time.Now()))
+
+	w = sendJSON(router, "POST", "/api/webhooks/"+webhook.ID+"/deliveries/"+deliveries[1].ID+"/redeliver", "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = sendJSON(router, "POST", "/api/webhooks/"+webhook.ID+"/deliveries/"+deliveries[2].ID+"/redeliver", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestPollEventsQueued(t *testing.T) {
+	ctx := context.Background()
+	polls := newTestStore()
+	router := setupRouter(polls)
+	poll := createPoll(t, router, `{"title": "Queued", "options": [{"text": "A"}, {"text": "B"}]}`)
+	assert.NotContains(t, sendJSON(router, "GET", "/api/polls/"+poll.ID, "").Body.String(), "outbox")
+
+	w := postVote(router, poll.ID, `{"option_id": "`+poll.Options[0].ID+`"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = sendJSON(router, "POST", "/api/polls/"+poll.ID+"/close", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	pending, err := polls.PendingEvents(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	var types []string
+	for _, event := range pending[0].Outbox {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []string{models.EventPollCreated, models.EventVoteCast, models.EventPollClosed}, types)
+}
@@ -0,0 +1,239 @@
+// Package live fans out vote count updates to clients watching a poll.
+package live
+
+import (
+	"sync"
+
+	"instapoll/backend/metrics"
+	"instapoll/backend/models"
+)
+
+// Message types sent to subscribers
+const (
+	// TypeSnapshot carries the count of every option
+	TypeSnapshot = "snapshot"
+	// TypeDiff carries only the options whose count changed
+	TypeDiff = "diff"
+	// TypeOpened is sent when a poll opens, or is reopened, and carries
+	// the count of every option
+	TypeOpened = "opened"
+	// TypeClosed is sent when a poll closes, or is archived, and carries
+	// the final count of every option
+	TypeClosed = "closed"
+)
+
+// DefaultBuffer is the number of messages a subscriber may fall behind
+// before it is considered too slow and dropped
+const DefaultBuffer = 16
+
+// Message is a vote count update for a single poll.
+// Counts are absolute values, so applying a message twice is harmless.
+type Message struct {
+	Type       string         `json:"type"`
+	PollID     string         `json:"poll_id"`
+	State      string         `json:"state"` // The poll's state, see models.Poll.Lifecycle
+	TotalVotes int            `json:"total_votes"`
+	Counts     map[string]int `json:"counts"` // Option ID -> vote count
+
+	// Version is the newest poll version the hub has seen, and Poll is that
+	// version with every count as of this message, for subscribers that
+	// send whole polls or check who may see them. Neither is sent to
+	// clients. Poll is shared by every subscriber and must not be modified.
+	Version int          `json:"-"`
+	Poll    *models.Poll `json:"-"`
+}
+
+// Snapshot builds a message carrying the current count of every option
+func Snapshot(poll *models.Poll) Message {
+	counts := make(map[string]int, len(poll.Options))
+	for _, option := range poll.Options {
+		counts[option.ID] = option.VoteCount
+	}
+	return Message{Type: TypeSnapshot, PollID: poll.ID, State: poll.Lifecycle(), TotalVotes: poll.TotalVotes, Counts: counts}
+}
+
+// Subscription receives the updates for one poll.
+// C is closed when the subscription ends, either because Unsubscribe was
+// called or because the subscriber fell too far behind.
+type Subscription struct {
+	PollID string
+	C      <-chan Message
+	c      chan Message
+}
+
+// pollState is what the hub knows about a poll that has subscribers
+type pollState struct {
+	subscribers map[*Subscription]struct{}
+	last        *Message     // Latest counts and state seen, nil until the first publish
+	version     int          // Latest poll version seen
+	poll        *models.Poll // The poll at that version, counts aside
+}
+
+// current returns a copy of the latest poll version with the latest counts
+func (state *pollState) current() *models.Poll {
+	poll := *state.poll
+	poll.Options = append([]models.Option(nil), state.poll.Options...)
+	for i, option := range poll.Options {
+		if count, ok := state.last.Counts[option.ID]; ok {
+			poll.Options[i].VoteCount = count
+		}
+	}
+	poll.TotalVotes = state.last.TotalVotes
+	return &poll
+}
+
+// Hub tracks subscribers per poll and sends them a diff every time a poll's
+// counts change. Updates can come from this process (after recording a
+// vote) or from other replicas (via a MongoDB change stream); updates with a
+// TotalVotes no higher than what the hub has already seen are ignored, so
+// the same vote arriving from both sources is only sent once. Likewise a
+// change of state is only announced when it comes with a newer poll version.
+type Hub struct {
+	mu     sync.Mutex
+	polls  map[string]*pollState
+	buffer int
+}
+
+// NewHub creates a hub whose subscribers may fall buffer messages behind
+func NewHub(buffer int) *Hub {
+	if buffer < 1 {
+		buffer = DefaultBuffer
+	}
+	return &Hub{
+		polls:  make(map[string]*pollState),
+		buffer: buffer,
+	}
+}
+
+// Subscribe starts receiving updates for the given poll
+func (h *Hub) Subscribe(pollID string) *Subscription {
+	c := make(chan Message, h.buffer)
+	sub := &Subscription{PollID: pollID, C: c, c: c}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	state, ok := h.polls[pollID]
+	if !ok {
+		state = &pollState{subscribers: make(map[*Subscription]struct{})}
+		h.polls[pollID] = state
+	}
+	state.subscribers[sub] = struct{}{}
+	metrics.LiveSubscribers.Inc()
+	return sub
+}
+
+// Unsubscribe stops a subscription and closes its channel.
+// It is safe to call more than once, and after the hub dropped it.
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(sub)
+}
+
+// remove deletes a subscription and closes its channel; h.mu must be held
+func (h *Hub) remove(sub *Subscription) {
+	state, ok := h.polls[sub.PollID]
+	if !ok {
+		return
+	}
+	if _, ok := state.subscribers[sub]; !ok {
+		return
+	}
+	delete(state.subscribers, sub)
+	close(sub.c)
+	metrics.LiveSubscribers.Dec()
+	if len(state.subscribers) == 0 {
+		delete(h.polls, sub.PollID)
+	}
+}
+
+// Subscribers returns the number of active subscriptions for a poll
+func (h *Hub) Subscribers(pollID string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if state, ok := h.polls[pollID]; ok {
+		return len(state.subscribers)
+	}
+	return 0
+}
+
+// Publish tells the hub about a poll's current counts and state.
+// Subscribers receive a diff of the options that changed since the last
+// publish, or an "opened" or "closed" message with every count when the poll
+// opened or closed. Subscribers whose buffer is full are dropped rather than
+// slowing everyone down.
+func (h *Hub) Publish(poll *models.Poll) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	state, ok := h.polls[poll.ID]
+	if !ok {
+		return // Nobody is watching this poll
+	}
+	current := Snapshot(poll)
+	if state.poll == nil || poll.Version >= state.version {
+		latest := *poll
+		latest.Options = append([]models.Option(nil), poll.Options...)
+		latest.Outbox = nil
+		state.poll = &latest
+	}
+	if state.last != nil && poll.Version > state.version && current.State != state.last.State {
+		state.version = poll.Version
+		h.publishState(state, current)
+		return
+	}
+	state.version = max(state.version, poll.Version)
+	if state.last != nil && current.TotalVotes <= state.last.TotalVotes {
+		return // Already seen these counts or newer ones
+	}
+	if state.last != nil {
+		current.State = state.last.State // Only a newer version changes the state
+	}
+
+	diff := Message{Type: TypeDiff, PollID: poll.ID, State: current.State, TotalVotes: current.TotalVotes, Counts: current.Counts}
+	if state.last != nil {
+		diff.Counts = make(map[string]int)
+		for id, count := range current.Counts {
+			if previous, ok := state.last.Counts[id]; !ok || previous != count {
+				diff.Counts[id] = count
+			}
+		}
+	}
+	state.last = &current
+	h.send(state, diff)
+}
+
+// publishState announces a poll's new state; h.mu must be held. Counts
+// older than the ones already sent are not sent again.
+func (h *Hub) publishState(state *pollState, current Message) {
+	if current.TotalVotes < state.last.TotalVotes {
+		current.TotalVotes = state.last.TotalVotes
+		current.Counts = state.last.Counts
+	}
+	last := current
+	state.last = &last
+
+	switch current.State {
+	case models.StateOpen:
+		current.Type = TypeOpened
+	case models.StateClosed, models.StateArchived:
+		current.Type = TypeClosed
+	default:
+		return // Drafts and scheduled polls have nothing to announce
+	}
+	h.send(state, current)
+}
+
+// send delivers a message to every subscriber of a poll, along with the
+// latest version of the poll; h.mu must be held
+func (h *Hub) send(state *pollState, msg Message) {
+	msg.Version = state.version
+	msg.Poll = state.current()
+	for sub := range state.subscribers {
+		select {
+		case sub.c <- msg:
+		default:
+			h.remove(sub) // Too slow; the client can reconnect for a fresh snapshot
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package live
+
+import (
+	"testing"
+
+	"instapoll/backend/metrics"
+	"instapoll/backend/models"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPoll returns a poll with two options and the given counts
+func testPoll(total, a, b int) *models.Poll {
+	return &models.Poll{
+		ID:         "poll",
+		TotalVotes: total,
+		Options: []models.Option{
+			{ID: "a", VoteCount: a},
+			{ID: "b", VoteCount: b},
+		},
+	}
+}
+
+func TestHubPublishesDiffs(t *testing.T) {
+	hub := NewHub(4)
+	sub := hub.Subscribe("poll")
+	defer hub.Unsubscribe(sub)
+
+	// The first publish has no baseline, so every count is sent.
+	hub.Publish(testPoll(1, 1, 0))
+	msg := <-sub.C
+	assert.Equal(t, TypeDiff, msg.Type)
+	assert.Equal(t, map[string]int{"a": 1, "b": 0}, msg.Counts)
+
+	// Later publishes only carry what changed.
+	hub.Publish(testPoll(2, 1, 1))
+	msg = <-sub.C
+	assert.Equal(t, 2, msg.TotalVotes)
+	assert.Equal(t, map[string]int{"b": 1}, msg.Counts)
+}
+
+func TestHubIgnoresStaleUpdates(t *testing.T) {
+	hub := NewHub(4)
+	sub := hub.Subscribe("poll")
+	defer hub.Unsubscribe(sub)
+
+	hub.Publish(testPoll(3, 2, 1))
+	<-sub.C
+
+	// The same state arriving again (e.g. from a change stream) or an older
+	// one arriving late must not produce a message.
+	hub.Publish(testPoll(3, 2, 1))
+	hub.Publish(testPoll(2, 1, 1))
+	select {
+	case msg := <-sub.C:
+		t.Fatalf("unexpected message for stale update: %+v", msg)
+	default:
+	}
+}
+
+func TestHubIgnoresOtherPolls(t *testing.T) {
+	hub := NewHub(4)
+	sub := hub.Subscribe("other")
+	defer hub.Unsubscribe(sub)
+
+	hub.Publish(testPoll(1, 1, 0))
+	assert.Len(t, sub.C, 0, "subscriber of another poll should not receive updates")
+}
+
+func TestHubDropsSlowSubscribers(t *testing.T) {
+	hub := NewHub(1)
+	slow := hub.Subscribe("poll")
+	fast := hub.Subscribe("poll")
+
+	hub.Publish(testPoll(1, 1, 0))
+	<-fast.C
+	hub.Publish(testPoll(2, 2, 0)) // slow's buffer is now full
+
+	_, ok := <-slow.C
+	require.True(t, ok, "buffered message should still be delivered")
+	_, ok = <-slow.C
+	assert.False(t, ok, "slow subscriber's channel should be closed")
+
+	msg, ok := <-fast.C
+	assert.True(t, ok, "fast subscriber should stay subscribed")
+	assert.Equal(t, 2, msg.TotalVotes)
+	assert.Equal(t, 1, hub.Subscribers("poll"))
+
+	// Unsubscribing a dropped subscriber is a no-op.
+	hub.Unsubscribe(slow)
+	hub.Unsubscribe(fast)
+	assert.Equal(t, 0, hub.Subscribers("poll"))
+}
+
+func TestHubCountsSubscribers(t *testing.T) {
+	hub := NewHub(1)
+	before := testutil.ToFloat64(metrics.LiveSubscribers)
+	slow := hub.Subscribe("poll")
+	other := hub.Subscribe("other")
+	assert.Equal(t, before+2, testutil.ToFloat64(metrics.LiveSubscribers))
+
+	// Dropped subscribers stop counting once, however they leave.
+	hub.Publish(testPoll(1, 1, 0))
+	hub.Publish(testPoll(2, 2, 0))
+	hub.Unsubscribe(slow)
+	hub.Unsubscribe(other)
+	assert.Equal(t, before, testutil.ToFloat64(metrics.LiveSubscribers))
+}
+
+func TestSnapshot(t *testing.T) {
+	msg := Snapshot(testPoll(5, 3, 2))
+	assert.Equal(t, TypeSnapshot, msg.Type)
+	assert.Equal(t, "poll", msg.PollID)
+	assert.Equal(t, 5, msg.TotalVotes)
+	assert.Equal(t, map[string]int{"a": 3, "b": 2}, msg.Counts)
+}
+
+func TestHubSendsLatestPoll(t *testing.T) {
+	hub := NewHub(4)
+	sub := hub.Subscribe("poll")
+	defer hub.Unsubscribe(sub)
+
+	poll := testPoll(1, 1, 0)
+	poll.Version = 1
+	poll.ResultsVisibility = models.ResultsAlways
+	hub.Publish(poll)
+	msg := <-sub.C
+	assert.Equal(t, 1, msg.Version)
+	require.NotNil(t, msg.Poll)
+	assert.Equal(t, models.ResultsAlways, msg.Poll.ResultsVisibility)
+
+	// A newer version's details come with the counts of whichever update
+	// carried the most votes, even if it was read before that version.
+	hidden := testPoll(1, 1, 0)
+	hidden.Version = 2
+	hidden.ResultsVisibility = models.ResultsCreatorOnly
+	hub.Publish(hidden)
+	stale := testPoll(2, 1, 1)
+	stale.Version = 1
+	hub.Publish(stale)
+	msg = <-sub.C
+	assert.Equal(t, 2, msg.Version)
+	assert.Equal(t, 2, msg.Poll.Version)
+	assert.Equal(t, models.ResultsCreatorOnly, msg.Poll.ResultsVisibility)
+	assert.Equal(t, 2, msg.Poll.TotalVotes)
+	assert.Equal(t, []models.Option{{ID: "a", VoteCount: 1}, {ID: "b", VoteCount: 1}}, msg.Poll.Options)
+
+	// Subscribers get a copy, not the poll that was published.
+	assert.Equal(t, 0, hidden.Options[1].VoteCount)
+}
+
+func TestHubPublishesStateChanges(t *testing.T) {
+	hub := NewHub(4)
+	sub := hub.Subscribe("poll")
+	defer hub.Unsubscribe(sub)
+
+	poll := testPoll(2, 1, 1)
+	poll.Version = 1
+	hub.Publish(poll)
+	msg := <-sub.C
+	assert.Equal(t, models.StateOpen, msg.State)
+
+	// Closing carries every count, even though none changed.
+	closed := testPoll(2, 1, 1)
+	closed.Version = 2
+	closed.State = models.StateClosed
+	hub.Publish(closed)
+	msg = <-sub.C
+	assert.Equal(t, TypeClosed, msg.Type)
+	assert.Equal(t, models.StateClosed, msg.State)
+	assert.Equal(t, map[string]int{"a": 1, "b": 1}, msg.Counts)
+
+	// The same change arriving again, or an older version, is not announced.
+	hub.Publish(closed)
+	hub.Publish(poll)
+	assert.Len(t, sub.C, 0)
+
+	reopened := testPoll(2, 1, 1)
+	reopened.Version = 3
+	reopened.State = models.StateOpen
+	hub.Publish(reopened)
+	msg = <-sub.C
+	assert.Equal(t, TypeOpened, msg.Type)
+}
@@ -0,0 +1,68 @@
+// Package logging sets up the service's structured logger and carries a
+// logger through each request's context, so every line logged while
+// handling a request includes its request ID and route.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Log formats
+const (
+	// FormatJSON writes one JSON object per line, for log collectors
+	FormatJSON = "json"
+	// FormatText writes key=value pairs, which are easier to read locally
+	FormatText = "text"
+)
+
+// Field names used consistently across log lines
+const (
+	KeyRequestID = "request_id"
+	KeyPollID    = "poll_id"
+	KeyRoute     = "route"
+	KeyStatus    = "status"
+	KeyLatency   = "latency"
+	KeyError     = "error"
+)
+
+// New creates a logger writing to w at the given level (debug, info, warn
+// or error; info if empty) in the given format (json or text; json if empty)
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("unknown log level %q (expected debug, info, warn or error)", level)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	switch strings.ToLower(format) {
+	case "", FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q (expected json or text)", format)
+	}
+}
+
+// loggerKey is the context key of the request's logger
+type loggerKey struct{}
+
+// WithLogger returns a context carrying logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or the default logger if
+// it carries none, e.g. in background jobs and tests
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
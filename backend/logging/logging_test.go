@@ -0,0 +1,55 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "", "")
+	require.NoError(t, err)
+	logger.Debug("Hidden")
+	logger.Info("Shown", KeyPollID, "p1")
+
+	// JSON at info level by default.
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "Shown", line["msg"])
+	assert.Equal(t, "p1", line[KeyPollID])
+
+	buf.Reset()
+	logger, err = New(&buf, "DEBUG", "text")
+	require.NoError(t, err)
+	logger.Debug("Shown", KeyPollID, "p1")
+	assert.Contains(t, buf.String(), "level=DEBUG")
+	assert.Contains(t, buf.String(), "poll_id=p1")
+
+	buf.Reset()
+	logger, err = New(&buf, "warn", "json")
+	require.NoError(t, err)
+	logger.Info("Hidden")
+	assert.Empty(t, buf.String())
+
+	_, err = New(&buf, "loud", "json")
+	assert.Error(t, err)
+	_, err = New(&buf, "info", "xml")
+	assert.Error(t, err)
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Same(t, slog.Default(), FromContext(context.Background()), "Falls back to the default logger")
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil)).With(KeyRequestID, "r1")
+	ctx := WithLogger(context.Background(), logger)
+	FromContext(ctx).Info("Hello")
+	assert.True(t, strings.Contains(buf.String(), "request_id=r1"))
+}
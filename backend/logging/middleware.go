@@ -0,0 +1,81 @@
+package logging
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the request ID. A client or proxy may send one to
+// follow a request across services; otherwise one is generated. Either way
+// it is returned in the response.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength caps the length of request IDs taken from clients
+const maxRequestIDLength = 128
+
+// pollRoutes prefixes the routes whose :id parameter is a poll ID
+const pollRoutes = "/api/polls/"
+
+// Middleware assigns each request an ID and a logger carrying it and the
+// route, available to handlers through FromContext, then logs the request
+// once it has been handled.
+func Middleware(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.New().String()
+		}
+		c.Header(RequestIDHeader, id)
+
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path // No route matched; it will be a 404
+		}
+		reqLogger := logger.With(KeyRequestID, id, KeyRoute, route)
+		c.Request = c.Request.WithContext(WithLogger(c.Request.Context(), reqLogger))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []any{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			KeyStatus, status,
+			KeyLatency, time.Since(start),
+			"client_ip", c.ClientIP(),
+		}
+		if pollID := c.Param("id"); pollID != "" && strings.HasPrefix(route, pollRoutes) {
+			attrs = append(attrs, KeyPollID, pollID)
+		}
+		if err := c.Errors.Last(); err != nil {
+			attrs = append(attrs, KeyError, err.Error())
+		}
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		reqLogger.Log(c.Request.Context(), level, "Handled request", attrs...)
+	}
+}
+
+// validRequestID reports whether a client-sent request ID can be used:
+// short, and made only of visible ASCII so it cannot forge log lines
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
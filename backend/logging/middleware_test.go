@@ -0,0 +1,86 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// logLines decodes the JSON lines written to buf
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var lines []map[string]any
+	for _, raw := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var line map[string]any
+		require.NoError(t, json.Unmarshal([]byte(raw), &line))
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+	r := gin.New()
+	r.Use(Middleware(slog.New(slog.NewJSONHandler(&buf, nil))))
+	r.GET("/api/polls/:id", func(c *gin.Context) {
+		FromContext(c.Request.Context()).Info("Handling", KeyPollID, c.Param("id"))
+		c.Status(http.StatusNoContent)
+	})
+	r.GET("/fail", func(c *gin.Context) {
+		c.Error(errors.New("boom"))
+		c.Status(http.StatusInternalServerError)
+	})
+
+	// A request ID is generated and returned, and every line carries it.
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/polls/p1", nil))
+	id := w.Header().Get(RequestIDHeader)
+	require.NotEmpty(t, id)
+	lines := logLines(t, &buf)
+	require.Len(t, lines, 2)
+	assert.Equal(t, "Handling", lines[0]["msg"])
+	for _, line := range lines {
+		assert.Equal(t, id, line[KeyRequestID])
+		assert.Equal(t, "/api/polls/:id", line[KeyRoute])
+		assert.Equal(t, "p1", line[KeyPollID])
+	}
+	assert.Equal(t, "INFO", lines[1]["level"])
+	assert.EqualValues(t, http.StatusNoContent, lines[1][KeyStatus])
+	assert.Contains(t, lines[1], KeyLatency)
+
+	// A request ID sent by the client is kept.
+	buf.Reset()
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/polls/p1", nil)
+	req.Header.Set(RequestIDHeader, "upstream-42")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, "upstream-42", w.Header().Get(RequestIDHeader))
+	assert.Equal(t, "upstream-42", logLines(t, &buf)[0][KeyRequestID])
+
+	// Unusable ones are replaced.
+	for _, bad := range []string{"has spaces", strings.Repeat("x", maxRequestIDLength+1)} {
+		w = httptest.NewRecorder()
+		req = httptest.NewRequest("GET", "/api/polls/p1", nil)
+		req.Header.Set(RequestIDHeader, bad)
+		r.ServeHTTP(w, req)
+		assert.NotEqual(t, bad, w.Header().Get(RequestIDHeader))
+		assert.NotEmpty(t, w.Header().Get(RequestIDHeader))
+	}
+
+	// Failures are logged as errors with the error attached.
+	buf.Reset()
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/fail", nil))
+	line := logLines(t, &buf)[0]
+	assert.Equal(t, "ERROR", line["level"])
+	assert.Equal(t, "boom", line[KeyError])
+	assert.NotContains(t, line, KeyPollID)
+}
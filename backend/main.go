@@ -1,103 +1,245 @@
 package main
 
 import (
-	"context" // Required for database operations
-	"log"     // For logging messages
+	"context"  // Required for database operations
+	"errors"   // To tell -help apart from configuration errors
+	"flag"     // For flag.ErrHelp
+	"log/slog" // For structured logging
 	"net/http"
-	"os"      // To read environment variables
-	"time"    // For setting timeouts
+	"os"        // To read environment variables and arguments
+	"os/signal" // To shut down on SIGINT and SIGTERM
+	"sync"      // To wait for the background jobs to stop
+	"syscall"   // For SIGTERM
+	"time"      // For setting timeouts
 
-	// Import the handlers package from the current module
+	// Import the handlers and store packages from the current module
+	"instapoll/backend/auth"
+	"instapoll/backend/config"
 	"instapoll/backend/handlers"
+	"instapoll/backend/live"
+	"instapoll/backend/logging"
+	"instapoll/backend/metrics"
+	"instapoll/backend/models"
+	"instapoll/backend/scheduler"
+	"instapoll/backend/slack"
+	"instapoll/backend/store"
+	"instapoll/backend/webhooks"
 
-	"github.com/gin-gonic/gin"                // Gin web framework
-	"go.mongodb.org/mongo-driver/mongo"       // MongoDB Go Driver
-	"go.mongodb.org/mongo-driver/mongo/options" // MongoDB Driver options
-	"go.mongodb.org/mongo-driver/mongo/readpref"  // For pinging the database
+	"github.com/gin-gonic/gin"                   // Gin web framework
+	"github.com/google/uuid"                     // For identifying this replica
+	"go.mongodb.org/mongo-driver/mongo"          // MongoDB Go Driver
+	"go.mongodb.org/mongo-driver/mongo/options"  // MongoDB Driver options
+	"go.mongodb.org/mongo-driver/mongo/readpref" // For pinging the database
 )
 
-// Constants for database configuration
-const (
-	// Default MongoDB connection string (used if MONGODB_URI env var is not set)
-	defaultMongoURI = "mongodb://localhost:27017"
-	// Name of the database to use within MongoDB
-	databaseName = "instapoll"
-	// Name of the collection to store poll documents
-	collectionName = "polls"
-	// Timeout duration for database operations like connect/ping
-	dbTimeout = 10 * time.Second
-)
+// shutdownTimeout is how long requests in flight get to finish once the
+// service is asked to stop
+const shutdownTimeout = 10 * time.Second
 
 func main() {
-	log.Println("Starting InstaPoll backend service...")
-
-	// --- Database Connection Setup ---
-	log.Println("Attempting to connect to MongoDB...")
-
-	// Get MongoDB connection URI from environment variable MONGODB_URI.
-	// Fallback to the default URI if the environment variable is not set.
-	mongoURI := os.Getenv("MONGODB_URI")
-	if mongoURI == "" {
-		mongoURI = defaultMongoURI
-		log.Printf("MONGODB_URI environment variable not set, using default: %s", mongoURI)
+	// --- Configuration ---
+	// Settings come from the defaults, overridden by the YAML file named by
+	// -config or CONFIG_FILE, then environment variables, then flags. Any
+	// invalid setting stops the service here.
+	cfg, err := config.Load(os.Args[1:], os.Getenv, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		slog.Error("Failed to load configuration", "error", err)
+		os.Exit(2)
 	}
 
-	// Create a context with a timeout for the database connection attempt.
-	// This prevents the application from hanging indefinitely if the DB is unavailable.
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
-	// Ensure the context resources are released when main() exits.
-	defer cancel()
-
-	// Configure the MongoDB client options using the URI.
-	clientOptions := options.Client().ApplyURI(mongoURI)
-	// Connect to the MongoDB server.
-	client, err := mongo.Connect(ctx, clientOptions)
+	// --- Logging Setup ---
+	// Logs are written to stderr as JSON, one object per line, unless the
+	// log format is "text". At debug level every poll store operation is
+	// logged too.
+	logger, err := logging.New(os.Stderr, cfg.Log.Level, cfg.Log.Format)
 	if err != nil {
-		// If connection fails, log the error and exit fatally.
-		log.Fatalf("FATAL: Failed to connect to MongoDB at %s: %v", mongoURI, err)
+		fatal("Invalid logging configuration", "error", err)
 	}
+	// The default logger is used outside requests, and by the standard
+	// library's log package.
+	slog.SetDefault(logger)
+	logger.Info("Starting InstaPoll backend service...")
 
-	// Ping the primary node of the MongoDB cluster to verify the connection is active.
-	if err := client.Ping(ctx, readpref.Primary()); err != nil {
-		// If ping fails, log the error and exit fatally.
-		log.Fatalf("FATAL: Failed to ping MongoDB: %v", err)
+	// ctx is cancelled on SIGINT or SIGTERM, which stops the background jobs
+	// and the HTTP server.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// The jobs are waited for before exiting, so they can release their
+	// leases to another replica.
+	var jobs sync.WaitGroup
+
+	// Polls are validated against the configured size limits.
+	if err := models.SetLimits(cfg.Limits.Poll()); err != nil {
+		fatal("Invalid poll limits", "error", err)
 	}
-	log.Println("Successfully connected and pinged MongoDB.")
-
-	// Set up a deferred function to disconnect from MongoDB when the main function exits.
-	// This ensures graceful shutdown.
-	defer func() {
-		log.Println("Disconnecting from MongoDB...")
-		// Use a background context for disconnection as the original context might have expired.
-		disconnectCtx, disconnectCancel := context.WithTimeout(context.Background(), dbTimeout)
-		defer disconnectCancel()
-		if err = client.Disconnect(disconnectCtx); err != nil {
-			// Log any errors during disconnection.
-			log.Printf("Error disconnecting from MongoDB: %v", err)
-		} else {
-			log.Println("Successfully disconnected from MongoDB.")
+
+	// The hub pushes vote count updates to clients watching a poll live.
+	hub := live.NewHub(live.DefaultBuffer)
+
+	var pollStore store.PollStore
+	var userStore store.UserStore
+	var revocationStore store.RevocationStore
+	var leaseStore store.LeaseStore
+	var webhookStore store.WebhookStore
+	switch cfg.Storage.Backend {
+	case config.BackendMongo:
+		mongoCfg := cfg.Storage.Mongo
+		collections := mongoCfg.Collections
+		client := connectMongo(mongoCfg.URI, cfg.Timeouts.Database)
+		// Set up a deferred function to disconnect from MongoDB when the main function exits.
+		// This ensures graceful shutdown.
+		defer disconnectMongo(client, cfg.Timeouts.Database)
+
+		// Get a handle for the configured database.
+		db := client.Database(mongoCfg.Database)
+		// Get handles for the poll and ballot collections within the database.
+		mongoStore := store.NewMongoStore(db.Collection(collections.Polls), db.Collection(collections.Ballots))
+		logger.Info("Using MongoDB storage", "database", mongoCfg.Database, "polls", collections.Polls, "ballots", collections.Ballots)
+
+		indexCtx, cancel := context.WithTimeout(ctx, cfg.Timeouts.Database)
+		if err := mongoStore.EnsureIndexes(indexCtx); err != nil {
+			fatal("Failed to create MongoDB indexes", "error", err)
+		}
+		pollStore = mongoStore
+
+		mongoUserStore := store.NewMongoUserStore(db.Collection(collections.Users))
+		if err := mongoUserStore.EnsureIndexes(indexCtx); err != nil {
+			fatal("Failed to create MongoDB user indexes", "error", err)
+		}
+		userStore = mongoUserStore
+
+		mongoRevocationStore := store.NewMongoRevocationStore(db.Collection(collections.RevokedTokens))
+		if err := mongoRevocationStore.EnsureIndexes(indexCtx); err != nil {
+			fatal("Failed to create MongoDB revocation indexes", "error", err)
+		}
+		revocationStore = mongoRevocationStore
+
+		mongoWebhookStore := store.NewMongoWebhookStore(db.Collection(collections.Webhooks), db.Collection(collections.Deliveries))
+		if err := mongoWebhookStore.EnsureIndexes(indexCtx); err != nil {
+			fatal("Failed to create MongoDB webhook indexes", "error", err)
 		}
+		cancel()
+		webhookStore = mongoWebhookStore
+		leaseStore = store.NewMongoLeaseStore(db.Collection(collections.Leases))
+
+		// Follow the polls collection so live clients also see votes recorded
+		// by other replicas of this service.
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			err := mongoStore.WatchPolls(ctx, func(poll *models.Poll) {
+				hub.Publish(poll)
+			})
+			if err != nil && ctx.Err() == nil {
+				logger.Warn("Cannot watch poll changes (MongoDB must run as a replica set); live updates will only include votes cast on this instance", "error", err)
+			}
+		}()
+	case config.BackendMemory:
+		logger.Info("Using in-memory storage; all polls are lost when the server stops")
+		pollStore = store.NewMemoryStore()
+		userStore = store.NewMemoryUserStore()
+		revocationStore = store.NewMemoryRevocationStore()
+		leaseStore = store.NewMemoryLeaseStore()
+		webhookStore = store.NewMemoryWebhookStore()
+	}
+	// Time every poll store operation, and count and log its failures.
+	pollStore = metrics.InstrumentPollStore(pollStore)
+
+	// --- Poll Lifecycle ---
+	// Open scheduled polls and close expired ones in the background. Every
+	// replica runs the scheduler, but the lease lets only one of them work
+	// at a time. Transitions are announced to live clients on this replica;
+	// other replicas see them through their change streams.
+	holder, _ := os.Hostname()
+	holder += "-" + uuid.New().String()
+	lifecycle := scheduler.New(pollStore, leaseStore, holder, scheduler.DefaultInterval, hub.Publish)
+	jobs.Add(1)
+	go func() {
+		defer jobs.Done()
+		lifecycle.Run(logging.WithLogger(ctx, logger.With("job", scheduler.LeaseName)))
 	}()
+	logger.Info("Started poll lifecycle scheduler", "holder", holder)
 
-	// Get a handle for the specific database ("instapoll").
-	db := client.Database(databaseName)
-	// Get a handle for the specific collection ("polls") within the database.
-	pollCollection := db.Collection(collectionName)
-	log.Printf("Using database '%s' and collection '%s'", databaseName, collectionName)
+	// --- Webhooks ---
+	// Hand poll events queued in the polls' outboxes on to the webhooks
+	// subscribed to them and send the deliveries, retrying failures. Like
+	// the scheduler, only the replica holding the lease does this.
+	deliveries := webhooks.New(pollStore, webhookStore, leaseStore, holder, webhooks.DefaultInterval)
+	jobs.Add(1)
+	go func() {
+		defer jobs.Done()
+		deliveries.Run(logging.WithLogger(ctx, logger.With("job", webhooks.LeaseName)))
+	}()
+	logger.Info("Started webhook delivery worker", "holder", holder)
+
+	// --- Session Tokens ---
+	// The JWT keys are listed as "id:base64-secret" pairs separated by
+	// commas. The first key signs new tokens; the others only verify tokens
+	// issued before a key rotation.
+	var keys *auth.KeySet
+	if spec := cfg.Auth.JWTKeys; spec != "" {
+		keys, err = auth.ParseKeySet(spec)
+		if err != nil {
+			fatal("Invalid JWT keys", "error", err)
+		}
+	} else {
+		// Only allowed with the memory store, whose data goes with the key.
+		logger.Warn("JWT keys not configured, using a random signing key; sessions, voter cookies and invites end when the server restarts")
+		keys = auth.RandomKeySet()
+	}
+	tokens := auth.NewManager(keys, revocationStore)
 
 	// --- Gin Router and Handler Setup ---
-	log.Println("Setting up Gin router and routes...")
-	// Create a new Gin engine with default middleware (logger, recovery).
-	r := gin.Default()
+	logger.Info("Setting up Gin router and routes...")
+	// Create a new Gin engine that recovers from panics, gives every request
+	// an ID and a logger carrying it, logs it once handled, and counts and
+	// times it.
+	r := gin.New()
+	r.Use(gin.Recovery(), logging.Middleware(logger), metrics.Middleware())
+	// Client addresses, which the ip voter policy and the logs rely on, are
+	// only taken from forwarding headers set by a configured proxy; anyone
+	// else could forge them.
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		fatal("Invalid trusted proxies", "error", err)
+	}
 
-	// Create an instance of PollHandler, passing the database collection handle.
-	// This injects the database dependency into the handler.
-	pollHandler := handlers.NewPollHandler(pollCollection)
+	// Create an instance of PollHandler, passing the poll store, live hub,
+	// token manager and store timeouts.
+	// This injects the storage dependency into the handler.
+	timeouts := cfg.Timeouts.Handlers()
+	pollHandler := handlers.NewPollHandler(pollStore, hub, tokens, timeouts)
+	// Like the router, the handler only believes the proxies configured
+	// about how clients connected, which decides whether cookies are Secure.
+	if err := pollHandler.TrustProxies(cfg.Server.TrustedProxies); err != nil {
+		fatal("Invalid trusted proxies", "error", err)
+	}
 
 	// Register the API routes defined in the PollHandler.
 	// This calls the RegisterRoutes method on the pollHandler instance.
 	pollHandler.RegisterRoutes(r)
-	log.Println("Registered poll routes under /api/polls")
+	logger.Info("Registered poll routes under /api/polls")
+
+	authHandler := handlers.NewAuthHandler(userStore, tokens, timeouts)
+	authHandler.RegisterRoutes(r)
+	logger.Info("Registered account routes under /api/auth")
+
+	webhookHandler := handlers.NewWebhookHandler(webhookStore, pollStore, tokens, timeouts)
+	webhookHandler.RegisterRoutes(r)
+	logger.Info("Registered webhook routes under /api/webhooks")
+
+	// The Slack integration is enabled by the app's signing secret, which
+	// verifies Slack's requests, and bot token, which posts the messages.
+	// The API URL points the client at another server, e.g. a fake Slack.
+	if cfg.Slack.Enabled() {
+		slackClient := slack.NewClient(cfg.Slack.BotToken, cfg.Slack.APIURL)
+		slack.NewHandler(pollHandler, slackClient, cfg.Slack.SigningSecret, timeouts.Request).RegisterRoutes(r)
+		logger.Info("Registered Slack routes under /api/slack")
+	} else {
+		logger.Info("SLACK_SIGNING_SECRET or SLACK_BOT_TOKEN not set; the Slack integration is disabled")
+	}
 
 	// Define a simple root endpoint for health checks or basic info.
 	r.GET("/", func(c *gin.Context) {
@@ -107,15 +249,107 @@ func main() {
 		})
 	})
 
+	// Prometheus scrapes request, store and poll metrics here. They reveal
+	// traffic and usage, so they are kept on an address of their own, behind
+	// a token, or both, when configured.
+	metricsHandler := metrics.Handler(cfg.Metrics.Token)
+	if cfg.Metrics.Addr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metricsHandler)
+		go func() {
+			logger.Info("Starting metrics server", "addr", cfg.Metrics.Addr, "token", cfg.Metrics.Token != "")
+			if err := http.ListenAndServe(cfg.Metrics.Addr, mux); err != nil {
+				fatal("Metrics server failed", "addr", cfg.Metrics.Addr, "error", err)
+			}
+		}()
+	} else {
+		if cfg.Metrics.Token == "" {
+			logger.Warn("/metrics is public; set metrics.addr or METRICS_TOKEN to protect it")
+		}
+		r.GET("/metrics", gin.WrapH(metricsHandler))
+	}
+
 	// --- Start HTTP Server ---
-	serverAddr := ":8080" // Address and port to listen on
-	log.Printf("Starting HTTP server, listening on %s", serverAddr)
-	// Start the Gin server and listen for incoming requests.
-	// r.Run() blocks until the server is shut down or an error occurs.
-	if err := r.Run(serverAddr); err != nil && err != http.ErrServerClosed {
+	// Serve the Gin router, over HTTPS if a certificate is configured, until
+	// ctx is cancelled. Requests in flight then get shutdownTimeout to
+	// finish; streams still open after that are cut off.
+	server := cfg.Server
+	httpServer := &http.Server{Addr: server.Addr, Handler: r}
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		<-ctx.Done()
+		logger.Info("Shutting down HTTP server...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			logger.Warn("Requests did not finish in time; closing their connections", "error", err)
+			httpServer.Close()
+		}
+	}()
+	logger.Info("Starting HTTP server", "addr", server.Addr, "tls", server.TLS.Enabled())
+	if server.TLS.Enabled() {
+		err = httpServer.ListenAndServeTLS(server.TLS.CertFile, server.TLS.KeyFile)
+	} else {
+		err = httpServer.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		// Log fatal error if the server fails to start (excluding graceful shutdown).
-		log.Fatalf("FATAL: Failed to run server: %v", err)
+		fatal("Failed to run server", "error", err)
+	}
+	<-shutdown
+	jobs.Wait()
+
+	logger.Info("Server shut down gracefully.")
+}
+
+// fatal logs an error that keeps the service from running and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// connectMongo connects to MongoDB at mongoURI and verifies the connection
+// with a ping, waiting at most timeout.
+// It exits the process if the database cannot be reached.
+func connectMongo(mongoURI string, timeout time.Duration) *mongo.Client {
+	slog.Info("Attempting to connect to MongoDB...")
+
+	// Create a context with a timeout for the database connection attempt.
+	// This prevents the application from hanging indefinitely if the DB is unavailable.
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	// Ensure the context resources are released when connectMongo() returns.
+	defer cancel()
+
+	// Configure the MongoDB client options using the URI.
+	clientOptions := options.Client().ApplyURI(mongoURI)
+	// Connect to the MongoDB server.
+	client, err := mongo.Connect(ctx, clientOptions)
+	if err != nil {
+		// If connection fails, log the error and exit fatally.
+		fatal("Failed to connect to MongoDB", "error", err)
 	}
 
-	log.Println("Server shut down gracefully.")
+	// Ping the primary node of the MongoDB cluster to verify the connection is active.
+	if err := client.Ping(ctx, readpref.Primary()); err != nil {
+		// If ping fails, log the error and exit fatally.
+		fatal("Failed to ping MongoDB", "error", err)
+	}
+	slog.Info("Successfully connected and pinged MongoDB.")
+
+	return client
+}
+
+// disconnectMongo closes the MongoDB client, logging any error.
+func disconnectMongo(client *mongo.Client, timeout time.Duration) {
+	slog.Info("Disconnecting from MongoDB...")
+	// Use a background context for disconnection as the original context might have expired.
+	disconnectCtx, disconnectCancel := context.WithTimeout(context.Background(), timeout)
+	defer disconnectCancel()
+	if err := client.Disconnect(disconnectCtx); err != nil {
+		// Log any errors during disconnection.
+		slog.Error("Failed to disconnect from MongoDB", "error", err)
+	} else {
+		slog.Info("Successfully disconnected from MongoDB.")
+	}
 }
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/test/polls/:id", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	r.GET("/metrics", gin.WrapH(Handler("")))
+
+	before := testutil.ToFloat64(HTTPRequests.WithLabelValues("GET", "/test/polls/:id", "204"))
+	unmatched := testutil.ToFloat64(HTTPRequests.WithLabelValues("GET", unmatchedRoute, "404"))
+	for _, path := range []string{"/test/polls/a", "/test/polls/b", "/nowhere"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	// Requests are labelled by route pattern, not path.
+	assert.Equal(t, before+2, testutil.ToFloat64(HTTPRequests.WithLabelValues("GET", "/test/polls/:id", "204")))
+	assert.Equal(t, unmatched+1, testutil.ToFloat64(HTTPRequests.WithLabelValues("GET", unmatchedRoute, "404")))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, `instapoll_http_request_duration_seconds_count{method="GET",route="/test/polls/:id"}`)
+	assert.False(t, strings.Contains(body, `route="/test/polls/a"`))
+}
+
+func TestHandlerToken(t *testing.T) {
+	handler := Handler("s3cret")
+	for _, tt := range []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{name: "no token", want: http.StatusUnauthorized},
+		{name: "wrong token", authorization: "Bearer guess", want: http.StatusUnauthorized},
+		{name: "not a bearer token", authorization: "s3cret", want: http.StatusUnauthorized},
+		{name: "token", authorization: "Bearer s3cret", want: http.StatusOK},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/metrics", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
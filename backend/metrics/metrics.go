@@ -0,0 +1,92 @@
+// Package metrics defines the Prometheus metrics the service exports on
+// /metrics: HTTP requests per route, poll store operations, and domain
+// events such as polls created and votes cast.
+package metrics
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes every metric name
+const namespace = "instapoll"
+
+var (
+	// HTTPRequests counts handled requests by method, route pattern and status code
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPDuration observes how long requests take by method and route
+	// pattern. Streaming routes last as long as the client stays connected.
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to handle HTTP requests, by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// StoreDuration observes how long poll store operations take, e.g.
+	// record_vote for the vote write
+	StoreDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "store_operation_duration_seconds",
+		Help:      "Time taken by poll store operations, by operation.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14), // 0.5ms to about 4s
+	}, []string{"operation"})
+
+	// StoreErrors counts poll store operations that failed unexpectedly,
+	// e.g. because the database could not be reached. Expected outcomes
+	// such as a poll not being found are not errors here.
+	StoreErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "store_errors_total",
+		Help:      "Poll store operations that failed unexpectedly, by operation.",
+	}, []string{"operation"})
+
+	// PollsCreated counts polls created, through the API or an integration
+	PollsCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "polls_created_total",
+		Help:      "Polls created.",
+	})
+
+	// VotesCast counts recorded ballots by the poll's voting method
+	VotesCast = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "votes_cast_total",
+		Help:      "Ballots recorded, by voting method.",
+	}, []string{"method"})
+
+	// LiveSubscribers is how many clients are following polls live
+	LiveSubscribers = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "live_subscribers",
+		Help:      "Clients currently subscribed to live poll updates.",
+	})
+)
+
+// Handler serves every registered metric in the Prometheus text format.
+// Unless token is empty, only requests presenting it as a bearer token are
+// served; others get 401 Unauthorized.
+func Handler(token string) http.Handler {
+	handler := promhttp.Handler()
+	if token == "" {
+		return handler
+	}
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"instapoll/backend/logging"
+	"instapoll/backend/models"
+	"instapoll/backend/store"
+)
+
+// pollStore is a store.PollStore timing every operation of the store it wraps
+type pollStore struct {
+	next store.PollStore
+}
+
+// InstrumentPollStore returns a poll store that passes every operation on
+// to s, observing its duration in StoreDuration, counting unexpected
+// failures in StoreErrors and logging them
+func InstrumentPollStore(s store.PollStore) store.PollStore {
+	return &pollStore{next: s}
+}
+
+// observe records an operation on the poll with the given ID, if it
+// concerns one, that started at start and returned err. Every operation is
+// logged at debug level, and unexpected failures as errors, with the
+// logger of the request the operation is part of.
+func observe(ctx context.Context, operation, pollID string, start time.Time, err error) {
+	latency := time.Since(start)
+	StoreDuration.WithLabelValues(operation).Observe(latency.Seconds())
+
+	attrs := []any{"operation", operation, "latency", latency}
+	if pollID != "" {
+		attrs = append(attrs, "poll_id", pollID)
+	}
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+	if unexpected(err) {
+		StoreErrors.WithLabelValues(operation).Inc()
+		logging.FromContext(ctx).Error("Store operation failed", attrs...)
+		return
+	}
+	logging.FromContext(ctx).Debug("Store operation", attrs...)
+}
+
+// unexpected reports whether err is a failure rather than one of the
+// outcomes the store reports by design, or a request given up by its client
+func unexpected(err error) bool {
+	switch {
+	case err == nil,
+		errors.Is(err, store.ErrNotFound),
+		errors.Is(err, store.ErrPollClosed),
+		errors.Is(err, store.ErrAlreadyVoted),
+		errors.Is(err, store.ErrVersionConflict),
+		errors.Is(err, store.ErrInvalidCursor),
+		errors.Is(err, context.Canceled):
+		return false
+	}
+	return true
+}
+
+func (s *pollStore) Create(ctx context.Context, poll *models.Poll) (err error) {
+	defer func(start time.Time) { observe(ctx, "create", poll.ID, start, err) }(time.Now())
+	return s.next.Create(ctx, poll)
+}
+
+func (s *pollStore) Get(ctx context.Context, id string) (_ *models.Poll, err error) {
+	defer func(start time.Time) { observe(ctx, "get", id, start, err) }(time.Now())
+	return s.next.Get(ctx, id)
+}
+
+func (s *pollStore) GetIncludingDeleted(ctx context.Context, id string) (_ *models.Poll, err error) {
+	defer func(start time.Time) { observe(ctx, "get_including_deleted", id, start, err) }(time.Now())
+	return s.next.GetIncludingDeleted(ctx, id)
+}
+
+func (s *pollStore) List(ctx context.Context, q store.ListQuery) (_ *store.ListPage, err error) {
+	defer func(start time.Time) { observe(ctx, "list", "", start, err) }(time.Now())
+	return s.next.List(ctx, q)
+}
+
+func (s *pollStore) Update(ctx context.Context, poll *models.Poll, expectedVersion int) (err error) {
+	defer func(start time.Time) { observe(ctx, "update", poll.ID, start, err) }(time.Now())
+	return s.next.Update(ctx, poll, expectedVersion)
+}
+
+func (s *pollStore) Delete(ctx context.Context, id string, at time.Time) (err error) {
+	defer func(start time.Time) { observe(ctx, "delete", id, start, err) }(time.Now())
+	return s.next.Delete(ctx, id, at)
+}
+
+func (s *pollStore) Restore(ctx context.Context, id string) (_ *models.Poll, err error) {
+	defer func(start time.Time) { observe(ctx, "restore", id, start, err) }(time.Now())
+	return s.next.Restore(ctx, id)
+}
+
+func (s *pollStore) RecordVote(ctx context.Context, ballot *models.Ballot) (_ *models.Poll, err error) {
+	defer func(start time.Time) { observe(ctx, "record_vote", ballot.PollID, start, err) }(time.Now())
+	return s.next.RecordVote(ctx, ballot)
+}
+
+func (s *pollStore) Ballots(ctx context.Context, pollID string) (_ []models.Ballot, err error) {
+	defer func(start time.Time) { observe(ctx, "ballots", pollID, start, err) }(time.Now())
+	return s.next.Ballots(ctx, pollID)
+}
+
+func (s *pollStore) Due(ctx context.Context, now time.Time, limit int) (_ []models.Poll, err error) {
+	defer func(start time.Time) { observe(ctx, "due", "", start, err) }(time.Now())
+	return s.next.Due(ctx, now, limit)
+}
+
+func (s *pollStore) HasVoted(ctx context.Context, pollID, voterKey string) (_ bool, err error) {
+	defer func(start time.Time) { observe(ctx, "has_voted", pollID, start, err) }(time.Now())
+	return s.next.HasVoted(ctx, pollID, voterKey)
+}
+
+func (s *pollStore) PendingEvents(ctx context.Context, limit int) (_ []models.Poll, err error) {
+	defer func(start time.Time) { observe(ctx, "pending_events", "", start, err) }(time.Now())
+	return s.next.PendingEvents(ctx, limit)
+}
+
+func (s *pollStore) AckEvents(ctx context.Context, pollID string, eventIDs []string) (err error) {
+	defer func(start time.Time) { observe(ctx, "ack_events", pollID, start, err) }(time.Now())
+	return s.next.AckEvents(ctx, pollID, eventIDs)
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"instapoll/backend/logging"
+	"instapoll/backend/models"
+	"instapoll/backend/store"
+	"instapoll/backend/tabulation"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingStore is a poll store whose Get fails as if the database were down
+type failingStore struct {
+	store.PollStore
+}
+
+func (failingStore) Get(ctx context.Context, id string) (*models.Poll, error) {
+	return nil, errors.New("connection refused")
+}
+
+// histogramCount returns how many durations were observed for an operation
+func histogramCount(t *testing.T, operation string) uint64 {
+	var m dto.Metric
+	require.NoError(t, StoreDuration.WithLabelValues(operation).(prometheus.Histogram).Write(&m))
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestInstrumentPollStore(t *testing.T) {
+	ctx := context.Background()
+	s := InstrumentPollStore(store.NewMemoryStore())
+
+	poll := &models.Poll{
+		ID:      uuid.New().String(),
+		Title:   "Instrumented",
+		Options: []models.Option{{ID: "a", Text: "A"}, {ID: "b", Text: "B"}},
+		State:   models.StateOpen,
+		Version: 1,
+	}
+	require.NoError(t, s.Create(ctx, poll))
+
+	votes := histogramCount(t, "record_vote")
+	errs := testutil.ToFloat64(StoreErrors.WithLabelValues("record_vote"))
+	ballot := &models.Ballot{
+		ID:        uuid.New().String(),
+		PollID:    poll.ID,
+		Ballot:    tabulation.Ballot{Ranking: []string{"a"}},
+		CreatedAt: time.Now(),
+		VoterKey:  "voter",
+	}
+	updated, err := s.RecordVote(ctx, ballot)
+	require.NoError(t, err)
+	assert.Equal(t, 1, updated.TotalVotes, "Operations are passed on")
+
+	// Refusals the store makes by design are timed but are not errors.
+	ballot.ID = uuid.New().String()
+	_, err = s.RecordVote(ctx, ballot)
+	assert.ErrorIs(t, err, store.ErrAlreadyVoted)
+	assert.Equal(t, votes+2, histogramCount(t, "record_vote"))
+	assert.Equal(t, errs, testutil.ToFloat64(StoreErrors.WithLabelValues("record_vote")))
+
+	// Failures are counted, and logged with the request's logger.
+	var buf bytes.Buffer
+	ctx = logging.WithLogger(ctx, slog.New(slog.NewTextHandler(&buf, nil)).With("request_id", "r1"))
+	getErrs := testutil.ToFloat64(StoreErrors.WithLabelValues("get"))
+	_, err = InstrumentPollStore(failingStore{}).Get(ctx, poll.ID)
+	assert.Error(t, err)
+	assert.Equal(t, getErrs+1, testutil.ToFloat64(StoreErrors.WithLabelValues("get")))
+	assert.Contains(t, buf.String(), "level=ERROR")
+	assert.Contains(t, buf.String(), "request_id=r1")
+	assert.Contains(t, buf.String(), "operation=get")
+	assert.Contains(t, buf.String(), "poll_id="+poll.ID)
+	assert.Contains(t, buf.String(), `error="connection refused"`)
+}
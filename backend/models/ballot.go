@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"instapoll/backend/tabulation"
+)
+
+// Ballot represents a single voter's ballot for a poll.
+// Ballots are stored in their own collection so the full ballot is kept,
+// not just the per-option counters on the poll. The embedded
+// tabulation.Ballot holds the ranking, approvals or scores depending on
+// the poll's voting method.
+type Ballot struct {
+	ID                string `json:"id" bson:"_id"`
+	PollID            string `json:"poll_id" bson:"poll_id"`
+	tabulation.Ballot `bson:",inline"`
+	CreatedAt         time.Time `json:"created_at" bson:"created_at"`
+	// VoterKey identifies the voter under the poll's voter policy. The
+	// store keeps it unique per poll, so each voter has one ballot.
+	VoterKey string `json:"-" bson:"voter_key,omitempty"`
+}
+
+// Validate checks the ballot against the voting method and options of the given poll
+func (b *Ballot) Validate(p *Poll) error {
+	method, ok := tabulation.Lookup(p.Method())
+	if !ok {
+		return ErrInvalidBallot("poll uses an unknown voting method: " + p.VotingMethod)
+	}
+	if err := method.ValidateBallot(p.Contest(), b.Ballot); err != nil {
+		return ErrInvalidBallot(err.Error())
+	}
+	return nil
+}
+
+// ErrInvalidBallot represents an error in ballot validation
+type ErrInvalidBallot string
+
+func (e ErrInvalidBallot) Error() string {
+	return string(e)
+}
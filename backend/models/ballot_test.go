@@ -0,0 +1,106 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+
+	"instapoll/backend/tabulation"
+)
+
+func TestBallotValidation(t *testing.T) {
+	poll := Poll{
+		Title:        "Test Poll",
+		VotingMethod: "irv",
+		Options: []Option{
+			{ID: "a", Text: "Option A"},
+			{ID: "b", Text: "Option B"},
+			{ID: "c", Text: "Option C"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		ranking []string
+		wantErr bool
+	}{
+		{name: "full ranking", ranking: []string{"c", "a", "b"}, wantErr: false},
+		{name: "partial ranking", ranking: []string{"b"}, wantErr: false},
+		{name: "empty ranking", ranking: nil, wantErr: true},
+		{name: "unknown option", ranking: []string{"a", "z"}, wantErr: true},
+		{name: "duplicate option", ranking: []string{"a", "b", "a"}, wantErr: true},
+		{name: "too many entries", ranking: []string{"a", "b", "c", "a"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ballot := Ballot{PollID: poll.ID, Ballot: tabulation.Ballot{Ranking: tt.ranking}}
+			err := ballot.Validate(&poll)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Ballot.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBallotValidationUsesPollMethod(t *testing.T) {
+	poll := Poll{
+		Title: "Test Poll",
+		Options: []Option{
+			{ID: "a", Text: "Option A"},
+			{ID: "b", Text: "Option B"},
+		},
+	}
+	ranked := Ballot{Ballot: tabulation.Ballot{Ranking: []string{"a", "b"}}}
+
+	// Plurality is the default and only accepts a single choice.
+	if err := ranked.Validate(&poll); err == nil {
+		t.Errorf("Ballot.Validate() accepted a ranking on a plurality poll")
+	}
+
+	poll.VotingMethod = "borda"
+	if err := ranked.Validate(&poll); err != nil {
+		t.Errorf("Ballot.Validate() on a borda poll error = %v", err)
+	}
+
+	poll.VotingMethod = "no-such-method"
+	if err := ranked.Validate(&poll); err == nil {
+		t.Errorf("Ballot.Validate() accepted a ballot for an unknown method")
+	}
+}
+
+func TestVoteRequestBallot(t *testing.T) {
+	tests := []struct {
+		name string
+		req  VoteRequest
+		want tabulation.Ballot
+	}{
+		{
+			name: "single choice",
+			req:  VoteRequest{OptionID: "a"},
+			want: tabulation.Ballot{Ranking: []string{"a"}},
+		},
+		{
+			name: "ranking wins over option id",
+			req:  VoteRequest{OptionID: "a", Ranking: []string{"b", "a"}},
+			want: tabulation.Ballot{Ranking: []string{"b", "a"}},
+		},
+		{
+			name: "approvals",
+			req:  VoteRequest{Approvals: []string{"a", "b"}},
+			want: tabulation.Ballot{Approvals: []string{"a", "b"}},
+		},
+		{
+			name: "scores",
+			req:  VoteRequest{Scores: map[string]int{"a": 3}},
+			want: tabulation.Ballot{Scores: map[string]int{"a": 3}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.req.Ballot(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("VoteRequest.Ballot() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
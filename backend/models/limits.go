@@ -0,0 +1,55 @@
+package models
+
+import "errors"
+
+// Limits bound the size of the polls Validate accepts
+type Limits struct {
+	MaxTitleLength       int // Bytes in a poll's title
+	MaxDescriptionLength int // Bytes in a poll's description
+	MaxOptions           int // Options in a poll; at least 2
+	MaxOptionLength      int // Bytes in an option's text
+}
+
+// DefaultLimits are used unless SetLimits is called
+var DefaultLimits = Limits{
+	MaxTitleLength:       200,
+	MaxDescriptionLength: 1000,
+	MaxOptions:           10,
+	MaxOptionLength:      200,
+}
+
+// MinOptions is the fewest options a poll can have
+const MinOptions = 2
+
+// limits are the limits in force
+var limits = DefaultLimits
+
+// Validate reports whether the limits are usable
+func (l Limits) Validate() error {
+	switch {
+	case l.MaxTitleLength < 1:
+		return errors.New("max title length must be positive")
+	case l.MaxDescriptionLength < 0:
+		return errors.New("max description length cannot be negative")
+	case l.MaxOptions < MinOptions:
+		return errors.New("max options must be at least 2")
+	case l.MaxOptionLength < 1:
+		return errors.New("max option length must be positive")
+	}
+	return nil
+}
+
+// SetLimits changes the limits polls are validated against. It is meant to
+// be called once at startup, before any poll is validated.
+func SetLimits(l Limits) error {
+	if err := l.Validate(); err != nil {
+		return err
+	}
+	limits = l
+	return nil
+}
+
+// CurrentLimits returns the limits polls are validated against
+func CurrentLimits() Limits {
+	return limits
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSetLimits(t *testing.T) {
+	defer SetLimits(DefaultLimits)
+
+	poll := Poll{
+		Title:   strings.Repeat("t", 250),
+		Options: []Option{{ID: "a", Text: "A"}, {ID: "b", Text: "B"}, {ID: "c", Text: "C"}},
+	}
+	if err := poll.Validate(); err == nil {
+		t.Fatal("Validate() accepted a title over the default limit")
+	}
+
+	if err := SetLimits(Limits{MaxTitleLength: 300, MaxDescriptionLength: 1000, MaxOptions: 2, MaxOptionLength: 200}); err != nil {
+		t.Fatalf("SetLimits() error = %v", err)
+	}
+	err := poll.Validate()
+	if err == nil || err.Error() != "poll cannot have more than 2 options" {
+		t.Errorf("Validate() error = %v, want the options limit", err)
+	}
+	poll.Options = poll.Options[:2]
+	if err := poll.Validate(); err != nil {
+		t.Errorf("Validate() error = %v within the limits", err)
+	}
+
+	// Unusable limits are refused and leave the current ones in force.
+	if err := SetLimits(Limits{MaxTitleLength: 100, MaxDescriptionLength: 1000, MaxOptions: 1, MaxOptionLength: 200}); err == nil {
+		t.Error("SetLimits() accepted fewer than 2 options")
+	}
+	if got := CurrentLimits().MaxTitleLength; got != 300 {
+		t.Errorf("CurrentLimits().MaxTitleLength = %d, want 300", got)
+	}
+}
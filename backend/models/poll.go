@@ -1,48 +1,147 @@
 package models
 
 import (
+	"fmt"
 	"time"
+
+	"instapoll/backend/tabulation"
+)
+
+// DefaultVotingMethod is used for polls that do not choose a voting method
+const DefaultVotingMethod = "plurality"
+
+// Voter policies decide what counts as one voter, and so how hard it is to
+// vote twice. From least to most strict:
+const (
+	// VoterPolicyCookie allows one vote per browser, tracked with a signed
+	// cookie. Clearing cookies allows voting again; fine for casual polls.
+	VoterPolicyCookie = "cookie"
+	// VoterPolicyIP allows one vote per client IP address. People sharing
+	// a network (an office, a NAT) can only vote once between them.
+	VoterPolicyIP = "ip"
+	// VoterPolicyUser allows one vote per signed-in user.
+	VoterPolicyUser = "user"
+	// VoterPolicyInvite allows one vote per single-use invite token handed
+	// out by the poll's creator; suited to binding decisions.
+	VoterPolicyInvite = "invite"
+)
+
+// DefaultVoterPolicy is used for polls that do not choose a voter policy
+const DefaultVoterPolicy = VoterPolicyCookie
+
+// Results visibility decides who can see a poll's vote counts and results.
+// A poll's creator can always see them.
+const (
+	// ResultsAlways shows results to anyone at any time.
+	ResultsAlways = "always"
+	// ResultsAfterVote shows results to voters once they have voted, and to
+	// everyone once the poll closes.
+	ResultsAfterVote = "after_vote"
+	// ResultsAfterClose shows results to everyone once the poll closes.
+	ResultsAfterClose = "after_close"
+	// ResultsCreatorOnly shows results to the poll's creator alone.
+	ResultsCreatorOnly = "creator_only"
+)
+
+// DefaultResultsVisibility is used for polls that do not choose a results visibility
+const DefaultResultsVisibility = ResultsAlways
+
+// Poll states. A poll moves through them in order, except that a closed
+// poll can be reopened and a scheduled poll put back into draft.
+const (
+	// StateDraft polls are being prepared: only their creator can see them
+	// and they take no votes.
+	StateDraft = "draft"
+	// StateScheduled polls open by themselves at OpensAt.
+	StateScheduled = "scheduled"
+	// StateOpen polls take votes until they are closed or expire.
+	StateOpen = "open"
+	// StateClosed polls take no more votes, so their results are final
+	// unless the creator reopens them.
+	StateClosed = "closed"
+	// StateArchived polls are closed for good.
+	StateArchived = "archived"
 )
 
+// transitions lists the states each state can move to
+var transitions = map[string][]string{
+	StateDraft:     {StateScheduled, StateOpen},
+	StateScheduled: {StateDraft, StateOpen},
+	StateOpen:      {StateClosed},
+	StateClosed:    {StateOpen, StateArchived},
+	StateArchived:  {},
+}
+
 // Poll represents a single poll in the system
 type Poll struct {
-	ID          string    `json:"id" bson:"_id"`
-	Title       string    `json:"title" bson:"title"`
-	Description string    `json:"description,omitempty" bson:"description,omitempty"`
-	Options     []Option  `json:"options" bson:"options"`
-	CreatedAt   time.Time `json:"created_at" bson:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" bson:"updated_at"`
-	ExpiresAt   time.Time `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+	ID           string              `json:"id" bson:"_id"`
+	Title        string              `json:"title" bson:"title"`
+	Description  string              `json:"description,omitempty" bson:"description,omitempty"`
+	Options      []Option            `json:"options" bson:"options"`
+	TotalVotes   int                 `json:"total_votes" bson:"total_votes"` // Number of ballots cast; only ever increases
+	VotingMethod string              `json:"voting_method" bson:"voting_method"`
+	Settings     tabulation.Settings `json:"settings" bson:"settings"`                         // Method-specific settings
+	VoterPolicy  string              `json:"voter_policy" bson:"voter_policy"`                 // One of the VoterPolicy constants
+	CreatorID    string              `json:"creator_id,omitempty" bson:"creator_id,omitempty"` // ID of the user who created the poll, empty for anonymous polls
+	CreatedAt    time.Time           `json:"created_at" bson:"created_at"`
+	UpdatedAt    time.Time           `json:"updated_at" bson:"updated_at"`
+	ExpiresAt    time.Time           `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+	DeletedAt    time.Time           `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"` // Set while the poll is soft-deleted
+	Version      int                 `json:"version" bson:"version"`                           // Incremented on every update, for optimistic concurrency
+	// TieBreakSeed is generated when the poll is created and published with
+	// it, so random tie-breaks can be reproduced but not rerolled.
+	// TieBreakOrder ranks option IDs, most preferred first, for the creator
+	// tie-break policy.
+	TieBreakSeed  string   `json:"tie_break_seed,omitempty" bson:"tie_break_seed,omitempty"`
+	TieBreakOrder []string `json:"tie_break_order,omitempty" bson:"tie_break_order,omitempty"`
+	// ResultsVisibility is one of the Results constants. ResultsHidden is
+	// set on responses whose vote counts were removed because the caller
+	// may not see them yet; it is never stored.
+	ResultsVisibility string `json:"results_visibility" bson:"results_visibility"`
+	ResultsHidden     bool   `json:"results_hidden,omitempty" bson:"-"`
+	// State is one of the State constants; polls created before states
+	// existed have none and are open. OpensAt is when a scheduled poll
+	// opens and ClosedAt when the poll last closed.
+	State    string    `json:"state" bson:"state,omitempty"`
+	OpensAt  time.Time `json:"opens_at,omitempty" bson:"opens_at,omitempty"`
+	ClosedAt time.Time `json:"closed_at,omitempty" bson:"closed_at,omitempty"`
+	// Outbox holds the events to send to webhooks. Stores append a poll's
+	// events in the same write as the change they describe and only
+	// return them from PollStore.PendingEvents.
+	Outbox []Event `json:"-" bson:"outbox,omitempty"`
 }
 
-// Option represents a single choice in a poll
+// Option represents a single choice in a poll.
+// VoteCount is a live counter of ballots choosing this option: the first
+// preference on ranked ballots, or each approved option on approval ballots.
 type Option struct {
 	ID        string `json:"id" bson:"_id"`
 	Text      string `json:"text" bson:"text"`
 	VoteCount int    `json:"vote_count" bson:"vote_count"`
 }
 
-// Validate performs validation on the poll structure
+// Validate performs validation on the poll structure, within the limits
+// set with SetLimits
 func (p *Poll) Validate() error {
 	// Title validation
 	if p.Title == "" {
 		return ErrInvalidPoll("title is required")
 	}
-	if len(p.Title) > 200 {
-		return ErrInvalidPoll("title must be less than 200 characters")
+	if len(p.Title) > limits.MaxTitleLength {
+		return ErrInvalidPoll(fmt.Sprintf("title must be less than %d characters", limits.MaxTitleLength))
 	}
 
 	// Description validation
-	if len(p.Description) > 1000 {
-		return ErrInvalidPoll("description must be less than 1000 characters")
+	if len(p.Description) > limits.MaxDescriptionLength {
+		return ErrInvalidPoll(fmt.Sprintf("description must be less than %d characters", limits.MaxDescriptionLength))
 	}
 
 	// Options validation
-	if len(p.Options) < 2 {
-		return ErrInvalidPoll("poll must have at least 2 options")
+	if len(p.Options) < MinOptions {
+		return ErrInvalidPoll(fmt.Sprintf("poll must have at least %d options", MinOptions))
 	}
-	if len(p.Options) > 10 {
-		return ErrInvalidPoll("poll cannot have more than 10 options")
+	if len(p.Options) > limits.MaxOptions {
+		return ErrInvalidPoll(fmt.Sprintf("poll cannot have more than %d options", limits.MaxOptions))
 	}
 
 	// Validate each option
@@ -50,19 +149,223 @@ func (p *Poll) Validate() error {
 		if option.Text == "" {
 			return ErrInvalidPoll("option text cannot be empty")
 		}
-		if len(option.Text) > 200 {
-			return ErrInvalidPoll("option text must be less than 200 characters")
+		if len(option.Text) > limits.MaxOptionLength {
+			return ErrInvalidPoll(fmt.Sprintf("option text must be less than %d characters", limits.MaxOptionLength))
 		}
 	}
 
+	// Voting method validation
+	method, ok := tabulation.Lookup(p.Method())
+	if !ok {
+		return ErrInvalidPoll("unknown voting method: " + p.VotingMethod)
+	}
+	if err := method.ValidateSettings(p.Settings, len(p.Options)); err != nil {
+		return ErrInvalidPoll(err.Error())
+	}
+	if err := tabulation.ValidateTieBreak(p.Settings); err != nil {
+		return ErrInvalidPoll(err.Error())
+	}
+	if err := p.ValidateTieBreakOrder(); err != nil {
+		return err
+	}
+
+	// Voter policy validation
+	switch p.Policy() {
+	case VoterPolicyCookie, VoterPolicyIP, VoterPolicyUser, VoterPolicyInvite:
+	default:
+		return ErrInvalidPoll("unknown voter policy: " + p.VoterPolicy)
+	}
+
+	// Results visibility validation
+	if err := p.ValidateResultsVisibility(); err != nil {
+		return err
+	}
+
 	// Expiration validation
 	if !p.ExpiresAt.IsZero() && p.ExpiresAt.Before(time.Now()) {
 		return ErrInvalidPoll("expiration date must be in the future")
 	}
 
+	// State validation
+	if _, ok := transitions[p.Lifecycle()]; !ok {
+		return ErrInvalidPoll("unknown state: " + p.State)
+	}
+	if p.Lifecycle() == StateScheduled && p.OpensAt.IsZero() {
+		return ErrInvalidPoll("scheduled polls need an opening time")
+	}
+	if !p.OpensAt.IsZero() && !p.ExpiresAt.IsZero() && !p.OpensAt.Before(p.ExpiresAt) {
+		return ErrInvalidPoll("opening time must be before the expiration date")
+	}
+
 	return nil
 }
 
+// ValidateTieBreakOrder checks that the creator's tie-break order only
+// lists the poll's options, each at most once
+func (p *Poll) ValidateTieBreakOrder() error {
+	seen := make(map[string]bool, len(p.TieBreakOrder))
+	for _, id := range p.TieBreakOrder {
+		if p.FindOption(id) == nil {
+			return ErrInvalidPoll("tie_break_order lists an option that does not belong to the poll")
+		}
+		if seen[id] {
+			return ErrInvalidPoll("tie_break_order lists the same option more than once")
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// ValidateResultsVisibility checks that the poll's results visibility is
+// known
+func (p *Poll) ValidateResultsVisibility() error {
+	switch p.Visibility() {
+	case ResultsAlways, ResultsAfterVote, ResultsAfterClose, ResultsCreatorOnly:
+		return nil
+	}
+	return ErrInvalidPoll("unknown results visibility: " + p.ResultsVisibility)
+}
+
+// Method returns the poll's voting method name, falling back to
+// DefaultVotingMethod for polls that did not choose one.
+func (p *Poll) Method() string {
+	if p.VotingMethod == "" {
+		return DefaultVotingMethod
+	}
+	return p.VotingMethod
+}
+
+// Policy returns the poll's voter policy, falling back to
+// DefaultVoterPolicy for polls that did not choose one.
+func (p *Poll) Policy() string {
+	if p.VoterPolicy == "" {
+		return DefaultVoterPolicy
+	}
+	return p.VoterPolicy
+}
+
+// Lifecycle returns the poll's state, treating polls created before states
+// existed as open.
+func (p *Poll) Lifecycle() string {
+	if p.State == "" {
+		return StateOpen
+	}
+	return p.State
+}
+
+// CanTransition reports whether the poll can move from its current state
+// to the given one
+func (p *Poll) CanTransition(to string) bool {
+	for _, next := range transitions[p.Lifecycle()] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// AcceptsVotes reports whether the poll takes votes at the given time: it
+// is open, or scheduled to have opened by then, and has not expired.
+func (p *Poll) AcceptsVotes(now time.Time) bool {
+	switch p.Lifecycle() {
+	case StateOpen:
+	case StateScheduled:
+		if p.OpensAt.After(now) {
+			return false
+		}
+	default:
+		return false
+	}
+	return !p.IsExpired(now)
+}
+
+// IsClosed reports whether the poll has finished taking votes at the given
+// time: it has been closed or archived, or it has expired.
+func (p *Poll) IsClosed(now time.Time) bool {
+	switch p.Lifecycle() {
+	case StateClosed, StateArchived:
+		return true
+	case StateOpen:
+		return p.IsExpired(now)
+	default:
+		return false
+	}
+}
+
+// Visibility returns the poll's results visibility, falling back to
+// DefaultResultsVisibility for polls that did not choose one.
+func (p *Poll) Visibility() string {
+	if p.ResultsVisibility == "" {
+		return DefaultResultsVisibility
+	}
+	return p.ResultsVisibility
+}
+
+// ResultsVisibleTo reports whether the signed-in user userID (empty for
+// anonymous callers) may see the poll's results at the given time. For
+// ResultsAfterVote polls that are still open it returns false, as it does
+// not know whether the caller has voted.
+func (p *Poll) ResultsVisibleTo(userID string, now time.Time) bool {
+	if p.CreatorID != "" && p.CreatorID == userID {
+		return true
+	}
+	switch p.Visibility() {
+	case ResultsAlways:
+		return true
+	case ResultsAfterVote, ResultsAfterClose:
+		return p.IsClosed(now)
+	default: // ResultsCreatorOnly
+		return false
+	}
+}
+
+// HideResults removes the option vote counts, for callers who may not see
+// them yet, and marks the poll as having its results hidden. The total
+// number of ballots stays visible.
+func (p *Poll) HideResults() {
+	for i := range p.Options {
+		p.Options[i].VoteCount = 0
+	}
+	p.ResultsHidden = true
+}
+
+// Contest describes the poll's options, settings and tie-break inputs for
+// tabulation
+func (p *Poll) Contest() tabulation.Contest {
+	ids := make([]string, len(p.Options))
+	for i, option := range p.Options {
+		ids[i] = option.ID
+	}
+	return tabulation.Contest{
+		Options:       ids,
+		Settings:      p.Settings,
+		TieBreakSeed:  p.TieBreakSeed,
+		TieBreakOrder: p.TieBreakOrder,
+	}
+}
+
+// FindOption returns a pointer to the option with the given ID,
+// or nil if the poll has no such option.
+func (p *Poll) FindOption(optionID string) *Option {
+	for i := range p.Options {
+		if p.Options[i].ID == optionID {
+			return &p.Options[i]
+		}
+	}
+	return nil
+}
+
+// IsExpired reports whether the poll has an expiration date at or before now.
+// Polls without an expiration date never expire.
+func (p *Poll) IsExpired(now time.Time) bool {
+	return !p.ExpiresAt.IsZero() && !p.ExpiresAt.After(now)
+}
+
+// IsDeleted reports whether the poll has been soft-deleted
+func (p *Poll) IsDeleted() bool {
+	return !p.DeletedAt.IsZero()
+}
+
 // ErrInvalidPoll represents an error in poll validation
 type ErrInvalidPoll string
 
@@ -3,6 +3,8 @@ package models
 import (
 	"testing"
 	"time"
+
+	"instapoll/backend/tabulation"
 )
 
 func TestPollValidation(t *testing.T) {
@@ -78,6 +80,183 @@ func TestPollValidation(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "explicit voting method",
+			poll: Poll{
+				Title:        "Test Poll",
+				VotingMethod: "schulze",
+				Options: []Option{
+					{Text: "Option 1"},
+					{Text: "Option 2"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown voting method",
+			poll: Poll{
+				Title:        "Test Poll",
+				VotingMethod: "coin-toss",
+				Options: []Option{
+					{Text: "Option 1"},
+					{Text: "Option 2"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invite voter policy",
+			poll: Poll{
+				Title:       "Test Poll",
+				VoterPolicy: VoterPolicyInvite,
+				Options: []Option{
+					{Text: "Option 1"},
+					{Text: "Option 2"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown voter policy",
+			poll: Poll{
+				Title:       "Test Poll",
+				VoterPolicy: "honor-system",
+				Options: []Option{
+					{Text: "Option 1"},
+					{Text: "Option 2"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "score voting with max score",
+			poll: Poll{
+				Title:        "Test Poll",
+				VotingMethod: "score",
+				Settings:     tabulation.Settings{MaxScore: 5},
+				Options: []Option{
+					{Text: "Option 1"},
+					{Text: "Option 2"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "score voting without max score",
+			poll: Poll{
+				Title:        "Test Poll",
+				VotingMethod: "score",
+				Options: []Option{
+					{Text: "Option 1"},
+					{Text: "Option 2"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "approval voting with selection limits",
+			poll: Poll{
+				Title:        "Test Poll",
+				VotingMethod: "approval",
+				Settings:     tabulation.Settings{MinSelections: 1, MaxSelections: 2},
+				Options: []Option{
+					{Text: "Option 1"},
+					{Text: "Option 2"},
+					{Text: "Option 3"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "approval voting with more required selections than options",
+			poll: Poll{
+				Title:        "Test Poll",
+				VotingMethod: "approval",
+				Settings:     tabulation.Settings{MinSelections: 3},
+				Options: []Option{
+					{Text: "Option 1"},
+					{Text: "Option 2"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "stv with fewer seats than options",
+			poll: Poll{
+				Title:        "Test Poll",
+				VotingMethod: "stv",
+				Settings:     tabulation.Settings{Seats: 2, SurplusTransfer: tabulation.TransferMeek},
+				Options: []Option{
+					{Text: "Option 1"},
+					{Text: "Option 2"},
+					{Text: "Option 3"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "stv with a seat for every option",
+			poll: Poll{
+				Title:        "Test Poll",
+				VotingMethod: "stv",
+				Settings:     tabulation.Settings{Seats: 2},
+				Options: []Option{
+					{Text: "Option 1"},
+					{Text: "Option 2"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "majority judgment on a five grade scale",
+			poll: Poll{
+				Title:        "Test Poll",
+				VotingMethod: "majority_judgment",
+				Settings:     tabulation.Settings{MinScore: 1, MaxScore: 5},
+				Options: []Option{
+					{Text: "Option 1"},
+					{Text: "Option 2"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "star voting with an empty scale",
+			poll: Poll{
+				Title:        "Test Poll",
+				VotingMethod: "star",
+				Settings:     tabulation.Settings{MinScore: 5, MaxScore: 5},
+				Options: []Option{
+					{Text: "Option 1"},
+					{Text: "Option 2"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "results shown after voting",
+			poll: Poll{
+				Title:             "Test Poll",
+				ResultsVisibility: ResultsAfterVote,
+				Options: []Option{
+					{Text: "Option 1"},
+					{Text: "Option 2"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown results visibility",
+			poll: Poll{
+				Title:             "Test Poll",
+				ResultsVisibility: "sometimes",
+				Options: []Option{
+					{Text: "Option 1"},
+					{Text: "Option 2"},
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "expired poll",
 			poll: Poll{
@@ -90,6 +269,57 @@ func TestPollValidation(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "scheduled poll",
+			poll: Poll{
+				Title:   "Test Poll",
+				State:   StateScheduled,
+				OpensAt: time.Now().Add(time.Hour),
+				Options: []Option{
+					{Text: "Option 1"},
+					{Text: "Option 2"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "scheduled poll without opening time",
+			poll: Poll{
+				Title: "Test Poll",
+				State: StateScheduled,
+				Options: []Option{
+					{Text: "Option 1"},
+					{Text: "Option 2"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "opening after expiry",
+			poll: Poll{
+				Title:     "Test Poll",
+				State:     StateScheduled,
+				OpensAt:   time.Now().Add(2 * time.Hour),
+				ExpiresAt: time.Now().Add(time.Hour),
+				Options: []Option{
+					{Text: "Option 1"},
+					{Text: "Option 2"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown state",
+			poll: Poll{
+				Title: "Test Poll",
+				State: "paused",
+				Options: []Option{
+					{Text: "Option 1"},
+					{Text: "Option 2"},
+				},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -101,3 +331,154 @@ func TestPollValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestPollFindOption(t *testing.T) {
+	poll := Poll{
+		Options: []Option{
+			{ID: "a", Text: "Option A"},
+			{ID: "b", Text: "Option B"},
+		},
+	}
+
+	if opt := poll.FindOption("b"); opt == nil || opt.Text != "Option B" {
+		t.Errorf("Poll.FindOption(\"b\") = %v, want Option B", opt)
+	}
+	if opt := poll.FindOption("missing"); opt != nil {
+		t.Errorf("Poll.FindOption(\"missing\") = %v, want nil", opt)
+	}
+}
+
+func TestPollIsExpired(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		want      bool
+	}{
+		{name: "no expiration", expiresAt: time.Time{}, want: false},
+		{name: "expires in future", expiresAt: now.Add(time.Hour), want: false},
+		{name: "expired in past", expiresAt: now.Add(-time.Hour), want: true},
+		{name: "expires exactly now", expiresAt: now, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			poll := Poll{ExpiresAt: tt.expiresAt}
+			if got := poll.IsExpired(now); got != tt.want {
+				t.Errorf("Poll.IsExpired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPollIsDeleted(t *testing.T) {
+	if (&Poll{}).IsDeleted() {
+		t.Errorf("Poll.IsDeleted() = true for a poll without DeletedAt")
+	}
+	if !(&Poll{DeletedAt: time.Now()}).IsDeleted() {
+		t.Errorf("Poll.IsDeleted() = false for a poll with DeletedAt")
+	}
+}
+
+func TestPollResultsVisibleTo(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name       string
+		visibility string
+		userID     string
+		expiresAt  time.Time
+		want       bool
+	}{
+		{name: "default", want: true},
+		{name: "always", visibility: ResultsAlways, want: true},
+		{name: "after vote while open", visibility: ResultsAfterVote, want: false},
+		{name: "after vote once closed", visibility: ResultsAfterVote, expiresAt: now.Add(-time.Hour), want: true},
+		{name: "after close while open", visibility: ResultsAfterClose, expiresAt: now.Add(time.Hour), want: false},
+		{name: "after close once closed", visibility: ResultsAfterClose, expiresAt: now.Add(-time.Hour), want: true},
+		{name: "creator only", visibility: ResultsCreatorOnly, userID: "someone", want: false},
+		{name: "creator only to the creator", visibility: ResultsCreatorOnly, userID: "creator", want: true},
+		{name: "creator before close", visibility: ResultsAfterClose, userID: "creator", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			poll := Poll{CreatorID: "creator", ResultsVisibility: tt.visibility, ExpiresAt: tt.expiresAt}
+			if got := poll.ResultsVisibleTo(tt.userID, now); got != tt.want {
+				t.Errorf("Poll.ResultsVisibleTo(%q) = %v, want %v", tt.userID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPollLifecycle(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name         string
+		state        string
+		opensAt      time.Time
+		expiresAt    time.Time
+		acceptsVotes bool
+		closed       bool
+	}{
+		{name: "created before states", state: "", acceptsVotes: true},
+		{name: "draft", state: StateDraft},
+		{name: "scheduled", state: StateScheduled, opensAt: now.Add(time.Hour)},
+		{name: "scheduled and due", state: StateScheduled, opensAt: now.Add(-time.Hour), acceptsVotes: true},
+		{name: "open", state: StateOpen, expiresAt: now.Add(time.Hour), acceptsVotes: true},
+		{name: "open and expired", state: StateOpen, expiresAt: now.Add(-time.Hour), closed: true},
+		{name: "closed", state: StateClosed, closed: true},
+		{name: "archived", state: StateArchived, closed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			poll := Poll{State: tt.state, OpensAt: tt.opensAt, ExpiresAt: tt.expiresAt}
+			if got := poll.AcceptsVotes(now); got != tt.acceptsVotes {
+				t.Errorf("Poll.AcceptsVotes() = %v, want %v", got, tt.acceptsVotes)
+			}
+			if got := poll.IsClosed(now); got != tt.closed {
+				t.Errorf("Poll.IsClosed() = %v, want %v", got, tt.closed)
+			}
+		})
+	}
+}
+
+func TestPollCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{from: StateDraft, to: StateOpen, want: true},
+		{from: StateDraft, to: StateClosed, want: false},
+		{from: StateScheduled, to: StateDraft, want: true},
+		{from: "", to: StateClosed, want: true},
+		{from: StateOpen, to: StateArchived, want: false},
+		{from: StateClosed, to: StateOpen, want: true},
+		{from: StateClosed, to: StateArchived, want: true},
+		{from: StateArchived, to: StateOpen, want: false},
+	}
+
+	for _, tt := range tests {
+		poll := Poll{State: tt.from}
+		if got := poll.CanTransition(tt.to); got != tt.want {
+			t.Errorf("Poll{State: %q}.CanTransition(%q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestPollHideResults(t *testing.T) {
+	poll := Poll{TotalVotes: 3, Options: []Option{{ID: "a", VoteCount: 2}, {ID: "b", VoteCount: 1}}}
+	poll.HideResults()
+
+	if !poll.ResultsHidden {
+		t.Error("Poll.HideResults() should mark the results as hidden")
+	}
+	for _, option := range poll.Options {
+		if option.VoteCount != 0 {
+			t.Errorf("option %s vote count = %d after HideResults, want 0", option.ID, option.VoteCount)
+		}
+	}
+	if poll.TotalVotes != 3 {
+		t.Errorf("Poll.TotalVotes = %d after HideResults, want 3", poll.TotalVotes)
+	}
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// Password length limits. bcrypt ignores everything past 72 bytes, so longer
+// passwords are refused rather than silently truncated.
+const (
+	MinPasswordLength = 8
+	MaxPasswordLength = 72
+)
+
+// User is a registered account. Polls created while signed in record the
+// user's ID as their CreatorID.
+type User struct {
+	ID           string    `json:"id" bson:"_id"`
+	Email        string    `json:"email" bson:"email"` // Normalized with NormalizeEmail; unique
+	Name         string    `json:"name" bson:"name"`
+	PasswordHash string    `json:"-" bson:"password_hash"` // Never sent to clients
+	CreatedAt    time.Time `json:"created_at" bson:"created_at"`
+}
+
+// Credentials is the request body for registering and logging in.
+// Name is only used when registering.
+type Credentials struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Name     string `json:"name,omitempty"`
+}
+
+// NormalizeEmail trims and lower-cases an email address so the same address
+// always maps to the same account
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// ValidateRegistration checks the credentials a new account is created with
+func (c *Credentials) ValidateRegistration() error {
+	email := NormalizeEmail(c.Email)
+	if email == "" {
+		return ErrInvalidUser("email is required")
+	}
+	if len(email) > 254 {
+		return ErrInvalidUser("email must be at most 254 characters")
+	}
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		return ErrInvalidUser("email is not a valid address")
+	}
+	if len(c.Password) < MinPasswordLength {
+		return ErrInvalidUser("password must be at least 8 characters")
+	}
+	if len(c.Password) > MaxPasswordLength {
+		return ErrInvalidUser("password must be at most 72 bytes")
+	}
+	if len(c.Name) > 100 {
+		return ErrInvalidUser("name must be at most 100 characters")
+	}
+	return nil
+}
+
+// ErrInvalidUser represents an error in account validation
+type ErrInvalidUser string
+
+func (e ErrInvalidUser) Error() string {
+	return string(e)
+}
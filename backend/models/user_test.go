@@ -0,0 +1,32 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCredentialsValidateRegistration(t *testing.T) {
+	tests := []struct {
+		name    string
+		creds   Credentials
+		wantErr bool
+	}{
+		{name: "valid", creds: Credentials{Email: "ada@example.com", Password: "correct horse"}, wantErr: false},
+		{name: "email is normalized first", creds: Credentials{Email: "  Ada@Example.COM ", Password: "correct horse"}, wantErr: false},
+		{name: "missing email", creds: Credentials{Password: "correct horse"}, wantErr: true},
+		{name: "invalid email", creds: Credentials{Email: "not an email", Password: "correct horse"}, wantErr: true},
+		{name: "email with display name", creds: Credentials{Email: "Ada <ada@example.com>", Password: "correct horse"}, wantErr: true},
+		{name: "short password", creds: Credentials{Email: "ada@example.com", Password: "short"}, wantErr: true},
+		{name: "long password", creds: Credentials{Email: "ada@example.com", Password: strings.Repeat("x", 73)}, wantErr: true},
+		{name: "long name", creds: Credentials{Email: "ada@example.com", Password: "correct horse", Name: strings.Repeat("x", 101)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.creds.ValidateRegistration()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Credentials.ValidateRegistration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"instapoll/backend/tabulation"
+)
+
+// VoteRequest represents the payload sent when casting a vote on a poll.
+// Which fields are used depends on the poll's voting method: OptionID for
+// single-choice polls, Ranking (or Tiers, where ties are allowed) for ranked
+// methods, Approvals for approval voting and Scores for score voting.
+type VoteRequest struct {
+	OptionID  string         `json:"option_id,omitempty"`
+	Ranking   []string       `json:"ranking,omitempty"`   // Option IDs, most preferred first
+	Tiers     [][]string     `json:"tiers,omitempty"`     // Groups of equally preferred option IDs, most preferred first
+	Approvals []string       `json:"approvals,omitempty"` // Option IDs the voter approves of
+	Scores    map[string]int `json:"scores,omitempty"`    // Option ID -> score
+	// Invite is the single-use invite token required by polls using
+	// VoterPolicyInvite
+	Invite string `json:"invite,omitempty"`
+}
+
+// Ballot converts the request into ballot content. A single-choice vote is
+// treated as a ranking containing only that option.
+func (v VoteRequest) Ballot() tabulation.Ballot {
+	ballot := tabulation.Ballot{
+		Ranking:   v.Ranking,
+		Tiers:     v.Tiers,
+		Approvals: v.Approvals,
+		Scores:    v.Scores,
+	}
+	if len(ballot.Ranking) == 0 && len(ballot.Tiers) == 0 && v.OptionID != "" {
+		ballot.Ranking = []string{v.OptionID}
+	}
+	return ballot
+}
@@ -0,0 +1,160 @@
+package models
+
+import (
+	"encoding/json"
+	"net/netip"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event types webhooks can subscribe to
+const (
+	// EventPollCreated is queued when a poll is created
+	EventPollCreated = "poll.created"
+	// EventVoteCast is queued when a ballot is recorded on a poll
+	EventVoteCast = "vote.cast"
+	// EventPollClosed is queued when a poll closes, whether its creator
+	// closed it or it expired
+	EventPollClosed = "poll.closed"
+)
+
+// EventTypes lists every event type
+var EventTypes = []string{EventPollCreated, EventVoteCast, EventPollClosed}
+
+// Event is something that happened to a poll, queued in the poll's outbox
+// by the same write that made the change so it cannot be lost or sent for
+// a change that did not happen.
+type Event struct {
+	ID        string    `json:"id" bson:"id"`
+	Type      string    `json:"type" bson:"type"`                               // One of the Event constants
+	BallotID  string    `json:"ballot_id,omitempty" bson:"ballot_id,omitempty"` // For EventVoteCast
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+// NewEvent returns an event of the given type with a fresh ID
+func NewEvent(eventType string, at time.Time) Event {
+	return Event{ID: uuid.New().String(), Type: eventType, CreatedAt: at}
+}
+
+// Webhook is a subscription to a user's poll events. Deliveries are POSTed
+// to URL and signed with Secret.
+type Webhook struct {
+	ID      string `json:"id" bson:"_id"`
+	OwnerID string `json:"owner_id" bson:"owner_id"`
+	// PollID limits the webhook to one of the owner's polls; if empty it
+	// receives the events of every poll the owner creates.
+	PollID string   `json:"poll_id,omitempty" bson:"poll_id,omitempty"`
+	URL    string   `json:"url" bson:"url"`
+	Events []string `json:"events,omitempty" bson:"events,omitempty"` // Event types to send; every type if empty
+	// Secret signs deliveries. It is only sent to clients when the
+	// webhook is created.
+	Secret    string    `json:"secret,omitempty" bson:"secret"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+// Validate checks the webhook's URL and event types
+func (w *Webhook) Validate() error {
+	target, err := url.Parse(w.URL)
+	if err != nil || !target.IsAbs() || target.Host == "" {
+		return ErrInvalidWebhook("url must be an absolute URL")
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return ErrInvalidWebhook("url must use http or https")
+	}
+	if len(w.URL) > 2000 {
+		return ErrInvalidWebhook("url must be at most 2000 characters")
+	}
+	// Host names are checked when deliveries connect, as they can resolve
+	// to anything; addresses can be refused straight away.
+	if ip, err := netip.ParseAddr(target.Hostname()); err == nil && !IsPublicAddr(ip) {
+		return ErrInvalidWebhook("url must not point at a loopback, private or link-local address")
+	}
+	for _, eventType := range w.Events {
+		known := false
+		for _, t := range EventTypes {
+			known = known || t == eventType
+		}
+		if !known {
+			return ErrInvalidWebhook("unknown event type: " + eventType)
+		}
+	}
+	return nil
+}
+
+// IsPublicAddr reports whether deliveries may be sent to ip. Loopback,
+// private, link-local, multicast and unspecified addresses would reach the
+// server itself or its own network rather than a receiver elsewhere, e.g.
+// its database or a cloud metadata service.
+func IsPublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsValid() &&
+		!ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() &&
+		!ip.IsUnspecified()
+}
+
+// Wants reports whether the webhook receives events of the given type
+func (w *Webhook) Wants(eventType string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, t := range w.Events {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery states
+const (
+	// DeliveryPending deliveries are waiting for their next attempt
+	DeliveryPending = "pending"
+	// DeliverySucceeded deliveries were accepted by the receiver
+	DeliverySucceeded = "succeeded"
+	// DeliveryDead deliveries failed too many times and are no longer
+	// retried unless redelivered by hand
+	DeliveryDead = "dead"
+)
+
+// Delivery is one event sent to one webhook, with a log of every attempt
+type Delivery struct {
+	ID        string `json:"id" bson:"_id"` // DeliveryID of the event and webhook
+	WebhookID string `json:"webhook_id" bson:"webhook_id"`
+	EventID   string `json:"event_id" bson:"event_id"`
+	EventType string `json:"event_type" bson:"event_type"`
+	PollID    string `json:"poll_id" bson:"poll_id"`
+	// Payload is the exact request body, so every attempt sends the same bytes
+	Payload       json.RawMessage   `json:"payload" bson:"payload"`
+	Status        string            `json:"status" bson:"status"` // One of the Delivery constants
+	Attempts      []DeliveryAttempt `json:"attempts" bson:"attempts"`
+	NextAttemptAt time.Time         `json:"next_attempt_at,omitempty" bson:"next_attempt_at,omitempty"` // For pending deliveries
+	CreatedAt     time.Time         `json:"created_at" bson:"created_at"`
+}
+
+// DeliveryAttempt records one attempt to send a delivery.
+// StatusCode is 0 if no response was received.
+type DeliveryAttempt struct {
+	At         time.Time `json:"at" bson:"at"`
+	StatusCode int       `json:"status_code,omitempty" bson:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty" bson:"error,omitempty"`
+}
+
+// DeliveryID identifies the delivery of an event to a webhook. Deriving it
+// from both makes queueing the same delivery twice detectable.
+func DeliveryID(eventID, webhookID string) string {
+	return eventID + ":" + webhookID
+}
+
+// ErrInvalidWebhook describes why a webhook is invalid
+type ErrInvalidWebhook string
+
+func (e ErrInvalidWebhook) Error() string {
+	return string(e)
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWebhookValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		events  []string
+		wantErr bool
+	}{
+		{name: "https", url: "https://example.com/hooks", wantErr: false},
+		{name: "http with port", url: "http://localhost:9000/hooks", wantErr: false},
+		{name: "some events", url: "https://example.com", events: []string{EventVoteCast, EventPollClosed}, wantErr: false},
+		{name: "empty url", url: "", wantErr: true},
+		{name: "relative url", url: "/hooks", wantErr: true},
+		{name: "other scheme", url: "ftp://example.com/hooks", wantErr: true},
+		{name: "too long", url: "https://example.com/" + strings.Repeat("a", 2000), wantErr: true},
+		{name: "unknown event", url: "https://example.com", events: []string{"poll.deleted"}, wantErr: true},
+		{name: "public address", url: "https://203.0.113.10/hooks", wantErr: false},
+		{name: "loopback address", url: "http://127.0.0.1:27017", wantErr: true},
+		{name: "private address", url: "http://10.1.2.3/hooks", wantErr: true},
+		{name: "metadata address", url: "http://169.254.169.254/latest/meta-data", wantErr: true},
+		{name: "unspecified address", url: "http://0.0.0.0:8080", wantErr: true},
+		{name: "ipv6 loopback", url: "http://[::1]:8080", wantErr: true},
+		{name: "ipv4-mapped loopback", url: "http://[::ffff:127.0.0.1]:8080", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webhook := Webhook{URL: tt.url, Events: tt.events}
+			err := webhook.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebhookWants(t *testing.T) {
+	all := Webhook{}
+	for _, eventType := range EventTypes {
+		if !all.Wants(eventType) {
+			t.Errorf("webhook without events should want %s", eventType)
+		}
+	}
+
+	votes := Webhook{Events: []string{EventVoteCast}}
+	if !votes.Wants(EventVoteCast) || votes.Wants(EventPollCreated) {
+		t.Errorf("webhook should only want the events it lists")
+	}
+}
@@ -0,0 +1,159 @@
+// Package scheduler moves polls through their lifecycle at the right time:
+// it opens scheduled polls once their opening time passes and closes open
+// polls once they expire.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"instapoll/backend/logging"
+	"instapoll/backend/models"
+	"instapoll/backend/store"
+)
+
+// LeaseName is the lease a replica must hold to run transitions
+const LeaseName = "poll-lifecycle"
+
+const (
+	// DefaultInterval is how often due polls are looked for
+	DefaultInterval = 5 * time.Second
+	// batchSize is how many due polls are transitioned per store query
+	batchSize = 100
+	// opTimeout bounds each store operation of a tick
+	opTimeout = 10 * time.Second
+)
+
+// Scheduler transitions due polls. Every replica of the service runs one,
+// but only the replica holding the lease does any work, so each transition
+// happens once; if that replica goes away another takes over when the lease
+// expires.
+type Scheduler struct {
+	polls    store.PollStore
+	leases   store.LeaseStore
+	holder   string             // Identifies this replica to the lease store
+	interval time.Duration      // Time between ticks; the lease lasts three ticks
+	onChange func(*models.Poll) // Called with every poll that changed state
+}
+
+// New creates a scheduler that ticks every interval, or DefaultInterval if
+// interval is not positive. holder must be unique to this replica. onChange,
+// which may be nil, is told about every poll the scheduler opens or closes,
+// e.g. to announce it to live subscribers.
+func New(polls store.PollStore, leases store.LeaseStore, holder string, interval time.Duration, onChange func(*models.Poll)) *Scheduler {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	if onChange == nil {
+		onChange = func(*models.Poll) {}
+	}
+	return &Scheduler{
+		polls:    polls,
+		leases:   leases,
+		holder:   holder,
+		interval: interval,
+		onChange: onChange,
+	}
+}
+
+// Run ticks until ctx is cancelled, then gives up the lease
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	defer func() {
+		releaseCtx, cancel := context.WithTimeout(context.Background(), opTimeout)
+		defer cancel()
+		if err := s.leases.Release(releaseCtx, LeaseName, s.holder); err != nil {
+			logging.FromContext(ctx).Error("Failed to release lease", "lease", LeaseName, "error", err)
+		}
+	}()
+
+	for {
+		if err := s.Tick(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			logging.FromContext(ctx).Error("Failed to run poll lifecycle transitions", "error", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Tick takes or renews the lease and, if this replica holds it, transitions
+// every poll due at now.
+func (s *Scheduler) Tick(ctx context.Context, now time.Time) error {
+	leaseCtx, cancel := context.WithTimeout(ctx, opTimeout)
+	held, err := s.leases.Acquire(leaseCtx, LeaseName, s.holder, now, 3*s.interval)
+	cancel()
+	if err != nil {
+		return err
+	}
+	if !held {
+		return nil // Another replica runs the transitions
+	}
+
+	for {
+		dueCtx, cancel := context.WithTimeout(ctx, opTimeout)
+		due, err := s.polls.Due(dueCtx, now, batchSize)
+		cancel()
+		if err != nil {
+			return err
+		}
+
+		changed := 0
+		for i := range due {
+			ok, err := s.transition(ctx, &due[i], now)
+			if err != nil {
+				return err
+			}
+			if ok {
+				changed++
+			}
+		}
+		// A short batch was the last one. If nothing in a full batch could
+		// be changed, it was all changed concurrently; try again next tick.
+		if len(due) < batchSize || changed == 0 {
+			return nil
+		}
+	}
+}
+
+// transition opens or closes a single due poll, reporting whether it did.
+// Polls changed concurrently, e.g. closed by their creator, are skipped;
+// the next tick sees their new state.
+func (s *Scheduler) transition(ctx context.Context, poll *models.Poll, now time.Time) (bool, error) {
+	updated := *poll
+	switch poll.Lifecycle() {
+	case models.StateScheduled:
+		updated.State = models.StateOpen
+		if poll.IsExpired(now) {
+			// It opened and expired while nobody was running transitions.
+			updated.State = models.StateClosed
+			updated.ClosedAt = poll.ExpiresAt
+		}
+	case models.StateOpen:
+		updated.State = models.StateClosed
+		updated.ClosedAt = poll.ExpiresAt
+	default:
+		return false, nil
+	}
+	if updated.State == models.StateClosed {
+		updated.Outbox = append(updated.Outbox, models.NewEvent(models.EventPollClosed, now))
+	}
+	updated.UpdatedAt = now
+	updated.Version = poll.Version + 1
+
+	ctx, cancel := context.WithTimeout(ctx, opTimeout)
+	defer cancel()
+	if err := s.polls.Update(ctx, &updated, poll.Version); err != nil {
+		if errors.Is(err, store.ErrVersionConflict) || errors.Is(err, store.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	logging.FromContext(ctx).Info("Changed poll state", "poll_id", poll.ID, "state", updated.State)
+	s.onChange(&updated)
+	return true, nil
+}
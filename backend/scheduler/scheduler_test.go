@@ -0,0 +1,136 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"instapoll/backend/models"
+	"instapoll/backend/store"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// insertPoll stores a two-option poll in the given state
+func insertPoll(t *testing.T, s store.PollStore, state string, opensAt, expiresAt time.Time) *models.Poll {
+	poll := &models.Poll{
+		ID:    uuid.New().String(),
+		Title: "Scheduler Test",
+		Options: []models.Option{
+			{ID: uuid.New().String(), Text: "A"},
+			{ID: uuid.New().String(), Text: "B"},
+		},
+		State:     state,
+		OpensAt:   opensAt,
+		ExpiresAt: expiresAt,
+		Version:   1,
+	}
+	require.NoError(t, s.Create(context.Background(), poll))
+	return poll
+}
+
+// stateOf reads a poll back from the store
+func stateOf(t *testing.T, s store.PollStore, id string) *models.Poll {
+	poll, err := s.Get(context.Background(), id)
+	require.NoError(t, err)
+	return poll
+}
+
+func TestSchedulerTick(t *testing.T) {
+	ctx := context.Background()
+	polls := store.NewMemoryStore()
+	now := time.Now()
+
+	due := insertPoll(t, polls, models.StateScheduled, now.Add(-time.Minute), time.Time{})
+	later := insertPoll(t, polls, models.StateScheduled, now.Add(time.Hour), time.Time{})
+	expired := insertPoll(t, polls, models.StateOpen, time.Time{}, now.Add(-time.Second))
+	legacy := insertPoll(t, polls, "", time.Time{}, now.Add(-time.Second))
+	missed := insertPoll(t, polls, models.StateScheduled, now.Add(-time.Hour), now.Add(-time.Minute))
+	open := insertPoll(t, polls, models.StateOpen, time.Time{}, now.Add(time.Hour))
+
+	var changed []string
+	s := New(polls, store.NewMemoryLeaseStore(), "replica-1", time.Second, func(poll *models.Poll) {
+		changed = append(changed, poll.ID)
+	})
+	require.NoError(t, s.Tick(ctx, now))
+
+	assert.Equal(t, models.StateOpen, stateOf(t, polls, due.ID).State)
+	assert.Equal(t, models.StateScheduled, stateOf(t, polls, later.ID).State)
+	for _, poll := range []*models.Poll{expired, legacy, missed} {
+		got := stateOf(t, polls, poll.ID)
+		assert.Equal(t, models.StateClosed, got.State)
+		assert.True(t, got.ClosedAt.Equal(poll.ExpiresAt), "Polls close when they expired, not when the scheduler noticed")
+		assert.Equal(t, 2, got.Version)
+	}
+	assert.Equal(t, models.StateOpen, stateOf(t, polls, open.ID).State)
+	assert.ElementsMatch(t, []string{due.ID, expired.ID, legacy.ID, missed.ID}, changed)
+
+	// Closing a poll queues an event for its webhooks; opening one does not.
+	pending, err := polls.PendingEvents(ctx, 100)
+	require.NoError(t, err)
+	closedEvents := map[string]int{}
+	for _, poll := range pending {
+		for _, event := range poll.Outbox {
+			if event.Type == models.EventPollClosed {
+				closedEvents[poll.ID]++
+			}
+		}
+	}
+	assert.Equal(t, map[string]int{expired.ID: 1, legacy.ID: 1, missed.ID: 1}, closedEvents)
+
+	// Nothing is due any more.
+	changed = nil
+	require.NoError(t, s.Tick(ctx, now))
+	assert.Empty(t, changed)
+}
+
+func TestSchedulerLease(t *testing.T) {
+	ctx := context.Background()
+	polls := store.NewMemoryStore()
+	leases := store.NewMemoryLeaseStore()
+	now := time.Now()
+	first := New(polls, leases, "replica-1", time.Second, nil)
+	second := New(polls, leases, "replica-2", time.Second, nil)
+
+	require.NoError(t, first.Tick(ctx, now))
+	poll := insertPoll(t, polls, models.StateOpen, time.Time{}, now.Add(-time.Second))
+
+	// The first replica holds the lease, so the second one does nothing.
+	require.NoError(t, second.Tick(ctx, now))
+	assert.Equal(t, models.StateOpen, stateOf(t, polls, poll.ID).State)
+
+	// Once the lease expires without being renewed, the second takes over.
+	require.NoError(t, second.Tick(ctx, now.Add(time.Minute)))
+	assert.Equal(t, models.StateClosed, stateOf(t, polls, poll.ID).State)
+}
+
+func TestSchedulerRunReleasesLease(t *testing.T) {
+	polls := store.NewMemoryStore()
+	leases := store.NewMemoryLeaseStore()
+	poll := insertPoll(t, polls, models.StateOpen, time.Time{}, time.Now().Add(-time.Second))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	closed := make(chan struct{}, 1)
+	s := New(polls, leases, "replica-1", time.Hour, func(*models.Poll) { closed <- struct{}{} })
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	// Run ticks straight away rather than after the first interval.
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not close the expired poll")
+	}
+	assert.Equal(t, models.StateClosed, stateOf(t, polls, poll.ID).State)
+	cancel()
+	<-done
+
+	held, err := leases.Acquire(context.Background(), LeaseName, "replica-2", time.Now(), time.Minute)
+	require.NoError(t, err)
+	assert.True(t, held, "Run should release its lease when it stops")
+}
@@ -0,0 +1,126 @@
+package slack
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"instapoll/backend/models"
+)
+
+// VoteAction is the action ID of the vote buttons
+const VoteAction = "instapoll_vote"
+
+// barWidth is how many characters the vote count bars are wide
+const barWidth = 10
+
+// Block is a Block Kit layout block. Only the fields the poll message uses
+// are included.
+type Block struct {
+	Type      string  `json:"type"`
+	BlockID   string  `json:"block_id,omitempty"`
+	Text      *Text   `json:"text,omitempty"`
+	Accessory *Button `json:"accessory,omitempty"`
+	Elements  []*Text `json:"elements,omitempty"` // For context blocks
+}
+
+// Text is a Block Kit text object
+type Text struct {
+	Type string `json:"type"` // "mrkdwn" or "plain_text"
+	Text string `json:"text"`
+}
+
+// Button is a Block Kit button element
+type Button struct {
+	Type     string `json:"type"` // Always "button"
+	ActionID string `json:"action_id"`
+	Text     *Text  `json:"text"`
+	Value    string `json:"value"`
+}
+
+// voteValue is the value of the button voting for an option of a poll
+func voteValue(pollID, optionID string) string {
+	return pollID + ":" + optionID
+}
+
+// parseVoteValue splits a vote button's value into the poll and option IDs
+func parseVoteValue(value string) (pollID, optionID string, ok bool) {
+	pollID, optionID, ok = strings.Cut(value, ":")
+	return pollID, optionID, ok && pollID != "" && optionID != ""
+}
+
+// PollBlocks lays out the message showing a poll in a channel: the
+// question, each option with a vote button while the poll takes votes, and
+// the counts if everyone may see them.
+func PollBlocks(poll *models.Poll, now time.Time) []Block {
+	showCounts := poll.ResultsVisibleTo("", now)
+	acceptsVotes := poll.AcceptsVotes(now)
+
+	blocks := []Block{{
+		Type:    "section",
+		BlockID: "question",
+		Text:    &Text{Type: "mrkdwn", Text: "*" + escape(poll.Title) + "*"},
+	}}
+	for _, option := range poll.Options {
+		text := "*" + escape(option.Text) + "*"
+		if showCounts {
+			text += "\n" + bar(option.VoteCount, poll.TotalVotes) + " " + votes(option.VoteCount)
+		}
+		block := Block{
+			Type:    "section",
+			BlockID: "option:" + option.ID,
+			Text:    &Text{Type: "mrkdwn", Text: text},
+		}
+		if acceptsVotes {
+			block.Accessory = &Button{
+				Type:     "button",
+				ActionID: VoteAction,
+				Text:     &Text{Type: "plain_text", Text: "Vote"},
+				Value:    voteValue(poll.ID, option.ID),
+			}
+		}
+		blocks = append(blocks, block)
+	}
+
+	status := votes(poll.TotalVotes) + " in total"
+	if !acceptsVotes {
+		status += " · Voting is closed"
+	}
+	if !showCounts {
+		status += " · Results are hidden for now"
+	}
+	blocks = append(blocks, Block{
+		Type:     "context",
+		BlockID:  "status",
+		Elements: []*Text{{Type: "mrkdwn", Text: status}},
+	})
+	return blocks
+}
+
+// PollText is the plain-text fallback of the poll message, shown in
+// notifications and by clients that cannot show blocks
+func PollText(poll *models.Poll) string {
+	return "Poll: " + poll.Title
+}
+
+// bar draws count's share of total as a row of filled and empty squares
+func bar(count, total int) string {
+	filled := 0
+	if total > 0 {
+		filled = (count*barWidth + total/2) / total
+	}
+	return strings.Repeat("█", filled) + strings.Repeat("░", barWidth-filled)
+}
+
+// votes formats a vote count
+func votes(n int) string {
+	if n == 1 {
+		return "1 vote"
+	}
+	return fmt.Sprintf("%d votes", n)
+}
+
+// escape escapes the characters Slack's mrkdwn treats as markup
+func escape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
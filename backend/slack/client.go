@@ -0,0 +1,112 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultAPIURL is the base URL of the Slack Web API
+const DefaultAPIURL = "https://slack.com/api/"
+
+// requestTimeout bounds each Web API call
+const requestTimeout = 10 * time.Second
+
+// Client calls the Slack Web API methods the integration needs with a bot token
+type Client struct {
+	baseURL string // Ends with a slash; method names are appended
+	token   string
+	http    *http.Client
+}
+
+// NewClient creates a client authenticating with the bot token. baseURL is
+// DefaultAPIURL unless the client talks to a fake Slack server in tests.
+func NewClient(token, baseURL string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultAPIURL
+	}
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	return &Client{baseURL: baseURL, token: token, http: &http.Client{Timeout: requestTimeout}}
+}
+
+// Message is a message posted to, or updated in, a channel
+type Message struct {
+	Channel string  `json:"channel"`
+	TS      string  `json:"ts,omitempty"` // Identifies the message to update
+	Text    string  `json:"text"`         // Fallback for notifications
+	Blocks  []Block `json:"blocks,omitempty"`
+}
+
+// ephemeral is a message only one user in a channel sees
+type ephemeral struct {
+	Channel string `json:"channel"`
+	User    string `json:"user"`
+	Text    string `json:"text"`
+}
+
+// response is the envelope of every Web API response
+type response struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+	TS    string `json:"ts,omitempty"`
+}
+
+// PostMessage posts a message to its channel and returns the new message's timestamp
+func (c *Client) PostMessage(ctx context.Context, msg Message) (string, error) {
+	msg.TS = ""
+	res, err := c.call(ctx, "chat.postMessage", msg)
+	if err != nil {
+		return "", err
+	}
+	return res.TS, nil
+}
+
+// UpdateMessage replaces the text and blocks of the message msg.TS in msg.Channel
+func (c *Client) UpdateMessage(ctx context.Context, msg Message) error {
+	_, err := c.call(ctx, "chat.update", msg)
+	return err
+}
+
+// PostEphemeral shows a message to one user in a channel
+func (c *Client) PostEphemeral(ctx context.Context, channel, user, text string) error {
+	_, err := c.call(ctx, "chat.postEphemeral", ephemeral{Channel: channel, User: user, Text: text})
+	return err
+}
+
+// call sends a JSON request to a Web API method. Slack reports most
+// failures with a 200 response whose "ok" is false.
+func (c *Client) call(ctx context.Context, method string, body interface{}) (*response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+method, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("slack %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("slack %s: unexpected status %s", method, resp.Status)
+	}
+	var res response
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("slack %s: decoding response: %w", method, err)
+	}
+	if !res.OK {
+		return nil, fmt.Errorf("slack %s: %s", method, res.Error)
+	}
+	return &res, nil
+}
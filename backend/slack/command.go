@@ -0,0 +1,66 @@
+package slack
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// ErrUsage is returned by ParseCommand for text that is not a question
+// followed by options
+var ErrUsage = errors.New(`usage: /instapoll "Question" "Option A" "Option B"`)
+
+// ParseCommand splits the text of a slash command into the question and
+// options. Arguments containing spaces are quoted; Slack clients often turn
+// straight quotes into curly ones, so both are accepted.
+func ParseCommand(text string) (string, []string, error) {
+	var args []string
+	var current strings.Builder
+	inQuotes, started := false, false
+	for _, r := range text {
+		switch {
+		case isQuote(r):
+			if inQuotes {
+				args = append(args, current.String())
+				current.Reset()
+				started = false
+			}
+			inQuotes = !inQuotes
+		case unicode.IsSpace(r) && !inQuotes:
+			if started {
+				args = append(args, current.String())
+				current.Reset()
+				started = false
+			}
+		default:
+			current.WriteRune(r)
+			started = true
+		}
+	}
+	if inQuotes {
+		return "", nil, ErrUsage // Unterminated quote
+	}
+	if started {
+		args = append(args, current.String())
+	}
+
+	for i := range args {
+		args[i] = strings.TrimSpace(args[i])
+		if args[i] == "" {
+			return "", nil, ErrUsage
+		}
+	}
+	if len(args) < 3 {
+		return "", nil, ErrUsage
+	}
+	return args[0], args[1:], nil
+}
+
+// isQuote reports whether r opens or closes a quoted argument
+func isQuote(r rune) bool {
+	switch r {
+	case '"', '“', '”':
+		return true
+	}
+	return false
+}
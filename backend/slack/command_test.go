@@ -0,0 +1,35 @@
+package slack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		question string
+		options  []string
+	}{
+		{name: "quoted", text: `"Where to lunch?" "Tacos" "Noodle bar"`, question: "Where to lunch?", options: []string{"Tacos", "Noodle bar"}},
+		{name: "curly quotes", text: `“Where to lunch?” “Tacos” “Noodle bar”`, question: "Where to lunch?", options: []string{"Tacos", "Noodle bar"}},
+		{name: "bare words", text: `"Best day?"   Monday Friday`, question: "Best day?", options: []string{"Monday", "Friday"}},
+		{name: "no spaces between", text: `"Q""A""B"`, question: "Q", options: []string{"A", "B"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			question, options, err := ParseCommand(tt.text)
+			require.NoError(t, err)
+			assert.Equal(t, tt.question, question)
+			assert.Equal(t, tt.options, options)
+		})
+	}
+
+	for _, text := range []string{"", `"Only a question"`, `"Q" "A"`, `"Q" "A" "B`, `"Q" "" "B"`} {
+		_, _, err := ParseCommand(text)
+		assert.ErrorIs(t, err, ErrUsage, "text %q", text)
+	}
+}
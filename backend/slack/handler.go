@@ -0,0 +1,183 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"instapoll/backend/handlers"
+	"instapoll/backend/logging"
+	"instapoll/backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxRequestBody caps the size of the requests Slack sends
+const maxRequestBody = 1 << 20
+
+// Handler serves the slash command and interaction endpoints configured in
+// the Slack app. Polls are created and votes recorded by the poll handler,
+// exactly as through the API.
+type Handler struct {
+	polls         *handlers.PollHandler
+	client        *Client
+	signingSecret string        // Verifies that requests come from Slack
+	timeout       time.Duration // Bounds the store and Slack calls made for each request
+}
+
+// NewHandler creates a handler posting messages with client and verifying
+// requests with the Slack app's signing secret. Each request's store and
+// Slack calls must finish within timeout.
+func NewHandler(polls *handlers.PollHandler, client *Client, signingSecret string, timeout time.Duration) *Handler {
+	return &Handler{polls: polls, client: client, signingSecret: signingSecret, timeout: timeout}
+}
+
+// RegisterRoutes sets up the Slack routes under /api/slack. Every route
+// requires a request signed by Slack.
+func (h *Handler) RegisterRoutes(r *gin.Engine) {
+	routes := r.Group("/api/slack", h.verify)
+	{
+		routes.POST("/commands", h.Command)         // Slash command request URL
+		routes.POST("/interactions", h.Interaction) // Interactivity request URL
+	}
+}
+
+// verify rejects requests without a valid Slack signature with 401
+// Unauthorized. The body is read to check it, then restored for the handler.
+func (h *Handler) verify(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxRequestBody))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := Verify(h.signingSecret, c.Request.Header, body, time.Now()); err != nil {
+		logging.FromContext(c.Request.Context()).Info("Rejected Slack request", "error", err)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid Slack signature"})
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	c.Next()
+}
+
+// reply answers a slash command with a message only its user sees
+func reply(c *gin.Context, text string) {
+	c.JSON(http.StatusOK, gin.H{"response_type": "ephemeral", "text": text})
+}
+
+// Command handles `/instapoll "Question" "A" "B"`: it creates an anonymous
+// poll with those options and posts it to the channel the command was
+// typed in. Problems are answered to the user alone.
+func (h *Handler) Command(c *gin.Context) {
+	question, options, err := ParseCommand(c.PostForm("text"))
+	if err != nil {
+		reply(c, "Usage: "+c.PostForm("command")+` "Question" "Option A" "Option B"`)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	defer cancel()
+
+	poll := models.Poll{Title: question}
+	for _, text := range options {
+		poll.Options = append(poll.Options, models.Option{Text: text})
+	}
+	if err := h.polls.Create(ctx, &poll, ""); err != nil {
+		var invalid models.ErrInvalidPoll
+		if errors.As(err, &invalid) {
+			reply(c, "Could not create the poll: "+err.Error())
+		} else {
+			reply(c, "Could not create the poll, please try again")
+		}
+		return
+	}
+
+	channel := c.PostForm("channel_id")
+	_, err = h.client.PostMessage(ctx, Message{
+		Channel: channel,
+		Text:    PollText(&poll),
+		Blocks:  PollBlocks(&poll, time.Now()),
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to post poll to Slack", "poll_id", poll.ID, "channel", channel, "error", err)
+		reply(c, "The poll was created but could not be posted here; make sure the InstaPoll app is in this channel")
+		return
+	}
+	logging.FromContext(ctx).Info("Posted poll to Slack", "poll_id", poll.ID, "channel", channel, "slack_user", c.PostForm("user_id"))
+	c.Status(http.StatusOK)
+}
+
+// interaction is the part of a Slack interaction payload the handler uses
+type interaction struct {
+	Type string `json:"type"`
+	User struct {
+		ID string `json:"id"`
+	} `json:"user"`
+	Team struct {
+		ID string `json:"id"`
+	} `json:"team"`
+	Container struct {
+		ChannelID string `json:"channel_id"`
+		MessageTS string `json:"message_ts"`
+	} `json:"container"`
+	Actions []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+}
+
+// Interaction handles a user pressing a vote button: the vote is recorded
+// as that Slack user's and the poll message updated with the new counts.
+// Refused votes, e.g. a second vote by the same user, are explained to the
+// user alone.
+func (h *Handler) Interaction(c *gin.Context) {
+	var payload interaction
+	if err := json.Unmarshal([]byte(c.PostForm("payload")), &payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid interaction payload"})
+		return
+	}
+	if payload.Type != "block_actions" {
+		c.Status(http.StatusOK) // Nothing else is sent to the app
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	defer cancel()
+
+	channel, user := payload.Container.ChannelID, payload.User.ID
+	for _, action := range payload.Actions {
+		pollID, optionID, ok := parseVoteValue(action.Value)
+		if action.ActionID != VoteAction || !ok {
+			continue
+		}
+
+		// Every Slack user votes once, whatever the poll's voter policy.
+		identity := "slack:" + payload.Team.ID + ":" + user
+		updated, err := h.polls.Vote(ctx, pollID, identity, models.VoteRequest{OptionID: optionID})
+		if err != nil {
+			text := "Your vote could not be recorded, please try again"
+			var rejected handlers.ErrVoteRejected
+			if errors.As(err, &rejected) {
+				text = err.Error()
+			}
+			if err := h.client.PostEphemeral(ctx, channel, user, text); err != nil {
+				logging.FromContext(ctx).Error("Failed to tell Slack user their vote failed", "poll_id", pollID, "slack_user", user, "error", err)
+			}
+			continue
+		}
+
+		err = h.client.UpdateMessage(ctx, Message{
+			Channel: channel,
+			TS:      payload.Container.MessageTS,
+			Text:    PollText(updated),
+			Blocks:  PollBlocks(updated, time.Now()),
+		})
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to update Slack message", "poll_id", pollID, "error", err)
+		}
+	}
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,247 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"instapoll/backend/auth"
+	"instapoll/backend/handlers"
+	"instapoll/backend/live"
+	"instapoll/backend/models"
+	"instapoll/backend/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSigningSecret = "signing-secret"
+	testBotToken      = "xoxb-test"
+)
+
+// apiCall is a Web API request the fake Slack server received
+type apiCall struct {
+	method string
+	body   map[string]interface{}
+}
+
+// fakeSlack is a local stand-in for the Slack Web API recording every call
+type fakeSlack struct {
+	*httptest.Server
+	mu    sync.Mutex
+	calls []apiCall
+}
+
+func newFakeSlack(t *testing.T) *fakeSlack {
+	f := &fakeSlack{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+testBotToken {
+			json.NewEncoder(w).Encode(map[string]interface{}{"ok": false, "error": "invalid_auth"})
+			return
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			json.NewEncoder(w).Encode(map[string]interface{}{"ok": false, "error": "invalid_json"})
+			return
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.calls = append(f.calls, apiCall{method: strings.TrimPrefix(r.URL.Path, "/"), body: body})
+		if body["channel"] == "C-not-a-member" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"ok": false, "error": "not_in_channel"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "channel": body["channel"], "ts": "1700000000.000100"})
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// received returns the calls made so far
+func (f *fakeSlack) received() []apiCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]apiCall(nil), f.calls...)
+}
+
+// setup returns a router serving the poll and Slack routes over an
+// in-memory store, talking to a fake Slack server
+func setup(t *testing.T) (*gin.Engine, *store.MemoryStore, *fakeSlack) {
+	gin.SetMode(gin.TestMode)
+	polls := store.NewMemoryStore()
+	fake := newFakeSlack(t)
+	pollHandler := handlers.NewPollHandler(polls, live.NewHub(live.DefaultBuffer), auth.NewManager(auth.RandomKeySet(), store.NewMemoryRevocationStore()), handlers.DefaultTimeouts)
+	r := gin.New()
+	pollHandler.RegisterRoutes(r)
+	NewHandler(pollHandler, NewClient(testBotToken, fake.URL), testSigningSecret, handlers.DefaultTimeouts.Request).RegisterRoutes(r)
+	return r, polls, fake
+}
+
+// postSigned sends a form to a Slack route signed as Slack would
+func postSigned(router *gin.Engine, path string, form url.Values) *httptest.ResponseRecorder {
+	body := form.Encode()
+	now := time.Now().Unix()
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set(TimestampHeader, strconv.FormatInt(now, 10))
+	req.Header.Set(SignatureHeader, Sign(testSigningSecret, now, []byte(body)))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// command sends the /instapoll slash command typed by user U1 in channel
+func command(router *gin.Engine, channel, text string) *httptest.ResponseRecorder {
+	return postSigned(router, "/api/slack/commands", url.Values{
+		"command":    {"/instapoll"},
+		"text":       {text},
+		"team_id":    {"T1"},
+		"user_id":    {"U1"},
+		"channel_id": {channel},
+	})
+}
+
+// pressVote sends the interaction of a user pressing a vote button
+func pressVote(t *testing.T, router *gin.Engine, user, value string) *httptest.ResponseRecorder {
+	payload, err := json.Marshal(map[string]interface{}{
+		"type":      "block_actions",
+		"user":      map[string]string{"id": user},
+		"team":      map[string]string{"id": "T1"},
+		"container": map[string]string{"channel_id": "C1", "message_ts": "1700000000.000100"},
+		"actions":   []map[string]string{{"action_id": VoteAction, "value": value}},
+	})
+	require.NoError(t, err)
+	return postSigned(router, "/api/slack/interactions", url.Values{"payload": {string(payload)}})
+}
+
+// blocksOf decodes the blocks of a chat.postMessage or chat.update call
+func blocksOf(t *testing.T, call apiCall) []Block {
+	raw, err := json.Marshal(call.body["blocks"])
+	require.NoError(t, err)
+	var blocks []Block
+	require.NoError(t, json.Unmarshal(raw, &blocks))
+	return blocks
+}
+
+func TestSlashCommandAndVotes(t *testing.T) {
+	ctx := context.Background()
+	router, polls, fake := setup(t)
+
+	w := command(router, "C1", `"Where to lunch?" "Tacos" "Noodle bar"`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	calls := fake.received()
+	require.Len(t, calls, 1)
+	assert.Equal(t, "chat.postMessage", calls[0].method)
+	assert.Equal(t, "C1", calls[0].body["channel"])
+
+	// The poll was created like any other.
+	page, err := polls.List(ctx, store.ListQuery{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Polls, 1)
+	poll := page.Polls[0]
+	assert.Equal(t, "Where to lunch?", poll.Title)
+	assert.Equal(t, models.StateOpen, poll.State)
+	assert.NotEmpty(t, poll.TieBreakSeed)
+
+	blocks := blocksOf(t, calls[0])
+	require.Len(t, blocks, 4, "Question, two options and the status line")
+	assert.Contains(t, blocks[0].Text.Text, "Where to lunch?")
+	require.NotNil(t, blocks[1].Accessory)
+	assert.Equal(t, voteValue(poll.ID, poll.Options[0].ID), blocks[1].Accessory.Value)
+
+	// Pressing a button votes and updates the message with the counts.
+	w = pressVote(t, router, "U1", blocks[1].Accessory.Value)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	calls = fake.received()
+	require.Len(t, calls, 2)
+	assert.Equal(t, "chat.update", calls[1].method)
+	assert.Equal(t, "1700000000.000100", calls[1].body["ts"])
+	updated := blocksOf(t, calls[1])
+	assert.Contains(t, updated[1].Text.Text, "1 vote")
+	assert.Contains(t, updated[3].Elements[0].Text, "1 vote in total")
+
+	got, err := polls.Get(ctx, poll.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, got.Options[0].VoteCount)
+
+	// A second vote by the same Slack user is refused, to them alone.
+	w = pressVote(t, router, "U1", voteValue(poll.ID, poll.Options[1].ID))
+	require.Equal(t, http.StatusOK, w.Code)
+	calls = fake.received()
+	require.Len(t, calls, 3)
+	assert.Equal(t, "chat.postEphemeral", calls[2].method)
+	assert.Equal(t, "U1", calls[2].body["user"])
+	assert.Equal(t, "You have already voted on this poll", calls[2].body["text"])
+
+	w = pressVote(t, router, "U2", voteValue(poll.ID, poll.Options[1].ID))
+	require.Equal(t, http.StatusOK, w.Code)
+	got, err = polls.Get(ctx, poll.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, got.TotalVotes)
+}
+
+func TestSlashCommandProblems(t *testing.T) {
+	router, _, fake := setup(t)
+
+	w := command(router, "C1", `"Only a question"`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Usage")
+	assert.Contains(t, w.Body.String(), "ephemeral")
+
+	w = command(router, "C1", `"Too many?" 1 2 3 4 5 6 7 8 9 10 11`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Could not create the poll: poll cannot have more than 10 options")
+
+	w = command(router, "C-not-a-member", `"Q" "A" "B"`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "could not be posted")
+	assert.Len(t, fake.received(), 1, "Only the failed chat.postMessage reached Slack")
+}
+
+func TestSlackSignatureRequired(t *testing.T) {
+	ctx := context.Background()
+	router, polls, fake := setup(t)
+
+	form := url.Values{"command": {"/instapoll"}, "text": {`"Q" "A" "B"`}, "channel_id": {"C1"}}
+	req := httptest.NewRequest("POST", "/api/slack/commands", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set(TimestampHeader, strconv.FormatInt(time.Now().Unix(), 10))
+	req.Header.Set(SignatureHeader, Sign("wrong-secret", time.Now().Unix(), []byte(form.Encode())))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Empty(t, fake.received())
+	page, err := polls.List(ctx, store.ListQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, page.Polls)
+}
+
+func TestPollBlocksClosed(t *testing.T) {
+	now := time.Now()
+	poll := &models.Poll{
+		ID:         "poll",
+		Title:      "Closed <b>",
+		State:      models.StateClosed,
+		TotalVotes: 3,
+		Options: []models.Option{
+			{ID: "a", Text: "A", VoteCount: 2},
+			{ID: "b", Text: "B", VoteCount: 1},
+		},
+	}
+	blocks := PollBlocks(poll, now)
+	require.Len(t, blocks, 4)
+	assert.Equal(t, "*Closed &lt;b&gt;*", blocks[0].Text.Text)
+	assert.Nil(t, blocks[1].Accessory, "Closed polls have no vote buttons")
+	assert.Equal(t, "*A*\n███████░░░ 2 votes", blocks[1].Text.Text)
+	assert.Contains(t, blocks[3].Elements[0].Text, "Voting is closed")
+}
@@ -0,0 +1,56 @@
+// Package slack lets a Slack workspace run polls: the /instapoll slash
+// command creates a poll and posts it to the channel with a button per
+// option, and pressing a button votes, after which the message is updated
+// with the new counts.
+package slack
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers Slack signs its requests with
+const (
+	// SignatureHeader carries "v0=" and the hex HMAC-SHA256 of "v0:", the
+	// timestamp, ":" and the request body, keyed with the app's signing secret
+	SignatureHeader = "X-Slack-Signature"
+	// TimestampHeader carries the Unix time Slack sent the request at
+	TimestampHeader = "X-Slack-Request-Timestamp"
+)
+
+// MaxRequestAge is how old a signed request may be before it is refused,
+// as Slack recommends, so captured requests cannot be replayed
+const MaxRequestAge = 5 * time.Minute
+
+// ErrInvalidSignature is returned by Verify for requests Slack did not sign
+var ErrInvalidSignature = errors.New("invalid Slack request signature")
+
+// Sign returns the signature Slack sends with a body sent at timestamp
+func Sign(signingSecret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(signingSecret))
+	mac.Write([]byte("v0:" + strconv.FormatInt(timestamp, 10) + ":"))
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks that a request with the given headers and body was signed
+// by Slack no longer than MaxRequestAge before now
+func Verify(signingSecret string, header http.Header, body []byte, now time.Time) error {
+	timestamp, err := strconv.ParseInt(header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(timestamp, 0)); age > MaxRequestAge || age < -MaxRequestAge {
+		return ErrInvalidSignature
+	}
+	expected := Sign(signingSecret, timestamp, body)
+	if !hmac.Equal([]byte(header.Get(SignatureHeader)), []byte(expected)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package slack
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	body := []byte("command=%2Finstapoll&text=hello")
+	signed := func(secret string, at time.Time) http.Header {
+		header := http.Header{}
+		header.Set(TimestampHeader, strconv.FormatInt(at.Unix(), 10))
+		header.Set(SignatureHeader, Sign(secret, at.Unix(), body))
+		return header
+	}
+
+	assert.NoError(t, Verify("signing-secret", signed("signing-secret", now), body, now))
+	assert.ErrorIs(t, Verify("signing-secret", signed("other-secret", now), body, now), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("signing-secret", signed("signing-secret", now), []byte("text=tampered"), now), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("signing-secret", signed("signing-secret", now.Add(-10*time.Minute)), body, now), ErrInvalidSignature, "Old requests may be replays")
+	assert.ErrorIs(t, Verify("signing-secret", http.Header{}, body, now), ErrInvalidSignature)
+}
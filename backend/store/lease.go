@@ -0,0 +1,21 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// LeaseStore hands out named leases that expire unless renewed, so that
+// of several replicas of the service only one runs a background job at a
+// time. A replica that stops renewing its lease, for instance because it
+// crashed, loses it once the lease expires.
+// Implementations must be safe for concurrent use.
+type LeaseStore interface {
+	// Acquire takes the named lease for holder until now plus ttl, or
+	// extends it if holder already has it. It reports false, without an
+	// error, if another holder has the lease and it has not expired.
+	Acquire(ctx context.Context, name, holder string, now time.Time, ttl time.Duration) (bool, error)
+	// Release gives up the named lease if holder has it, so another holder
+	// can take it without waiting for it to expire.
+	Release(ctx context.Context, name, holder string) error
+}
@@ -0,0 +1,44 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryLeaseStore is a LeaseStore that keeps leases in process memory.
+// It is meant for tests and single-instance local development.
+type MemoryLeaseStore struct {
+	mu     sync.Mutex
+	leases map[string]lease // Lease name -> current holder
+}
+
+// lease is who holds a lease and until when
+type lease struct {
+	holder    string
+	expiresAt time.Time
+}
+
+// NewMemoryLeaseStore creates an in-memory lease store with no leases held
+func NewMemoryLeaseStore() *MemoryLeaseStore {
+	return &MemoryLeaseStore{leases: make(map[string]lease)}
+}
+
+func (s *MemoryLeaseStore) Acquire(ctx context.Context, name, holder string, now time.Time, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if current, ok := s.leases[name]; ok && current.holder != holder && current.expiresAt.After(now) {
+		return false, nil
+	}
+	s.leases[name] = lease{holder: holder, expiresAt: now.Add(ttl)}
+	return true, nil
+}
+
+func (s *MemoryLeaseStore) Release(ctx context.Context, name, holder string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if current, ok := s.leases[name]; ok && current.holder == holder {
+		delete(s.leases, name)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoLeaseStore is a LeaseStore backed by a MongoDB collection, shared by
+// every replica using the same database
+type MongoLeaseStore struct {
+	leases *mongo.Collection // One document per lease, keyed by name
+}
+
+// NewMongoLeaseStore creates a lease store using the given collection
+func NewMongoLeaseStore(leases *mongo.Collection) *MongoLeaseStore {
+	return &MongoLeaseStore{leases: leases}
+}
+
+func (s *MongoLeaseStore) Acquire(ctx context.Context, name, holder string, now time.Time, ttl time.Duration) (bool, error) {
+	// Take the lease if we hold it or it has expired. If another holder's
+	// lease is still valid the filter matches nothing, and the upsert then
+	// fails on the lease's _id, which is how we learn it is taken.
+	filter := bson.M{
+		"_id": name,
+		"$or": bson.A{
+			bson.M{"holder": holder},
+			bson.M{"expires_at": bson.M{"$lte": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"holder": holder, "expires_at": now.Add(ttl)}}
+	_, err := s.leases.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *MongoLeaseStore) Release(ctx context.Context, name, holder string) error {
+	_, err := s.leases.DeleteOne(ctx, bson.M{"_id": name, "holder": holder})
+	return err
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testLeaseStore runs the behaviour every LeaseStore implementation must share.
+// newStore must return a store with no leases held.
+func testLeaseStore(t *testing.T, newStore func(t *testing.T) LeaseStore) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Millisecond) // MongoDB stores millisecond precision
+
+	t.Run("acquire renew expire", func(t *testing.T) {
+		s := newStore(t)
+
+		ok, err := s.Acquire(ctx, "job", "a", now, time.Minute)
+		require.NoError(t, err)
+		assert.True(t, ok, "a free lease should be taken")
+
+		ok, err = s.Acquire(ctx, "job", "b", now.Add(30*time.Second), time.Minute)
+		require.NoError(t, err)
+		assert.False(t, ok, "a held lease should not be taken")
+
+		ok, err = s.Acquire(ctx, "job", "a", now.Add(30*time.Second), time.Minute)
+		require.NoError(t, err)
+		assert.True(t, ok, "the holder should renew its lease")
+
+		ok, err = s.Acquire(ctx, "other-job", "b", now, time.Minute)
+		require.NoError(t, err)
+		assert.True(t, ok, "leases are independent")
+
+		// The renewed lease runs until 90s; after that anyone may take it.
+		ok, err = s.Acquire(ctx, "job", "b", now.Add(80*time.Second), time.Minute)
+		require.NoError(t, err)
+		assert.False(t, ok)
+		ok, err = s.Acquire(ctx, "job", "b", now.Add(90*time.Second), time.Minute)
+		require.NoError(t, err)
+		assert.True(t, ok, "an expired lease should be taken")
+	})
+
+	t.Run("release", func(t *testing.T) {
+		s := newStore(t)
+		ok, err := s.Acquire(ctx, "job", "a", now, time.Minute)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		require.NoError(t, s.Release(ctx, "job", "b"), "releasing someone else's lease is a no-op")
+		ok, err = s.Acquire(ctx, "job", "b", now, time.Minute)
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		require.NoError(t, s.Release(ctx, "job", "a"))
+		ok, err = s.Acquire(ctx, "job", "b", now, time.Minute)
+		require.NoError(t, err)
+		assert.True(t, ok, "a released lease should be taken")
+	})
+
+	t.Run("concurrent acquire", func(t *testing.T) {
+		s := newStore(t)
+		var wins atomic.Int32
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(holder string) {
+				defer wg.Done()
+				ok, err := s.Acquire(ctx, "job", holder, now, time.Minute)
+				assert.NoError(t, err)
+				if ok {
+					wins.Add(1)
+				}
+			}(string(rune('a' + i)))
+		}
+		wg.Wait()
+		assert.Equal(t, int32(1), wins.Load(), "exactly one holder should get the lease")
+	})
+}
+
+func TestMemoryLeaseStore(t *testing.T) {
+	testLeaseStore(t, func(t *testing.T) LeaseStore {
+		return NewMemoryLeaseStore()
+	})
+}
@@ -0,0 +1,135 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"instapoll/backend/models"
+)
+
+// Sort orders accepted by ListQuery.Sort
+const (
+	SortCreated = "created"
+	SortUpdated = "updated"
+	SortExpires = "expires" // Polls without an expiry sort before all others
+	SortVotes   = "votes"
+)
+
+// Status filters accepted by ListQuery.Status
+const (
+	StatusAll     = ""
+	StatusActive  = "active"  // Polls that have not expired
+	StatusExpired = "expired" // Polls whose expiry has passed
+)
+
+// DefaultListLimit is the page size used when ListQuery.Limit is not set
+const DefaultListLimit = 20
+
+// ErrInvalidCursor is returned when a list cursor cannot be decoded or
+// belongs to a listing with a different sort order
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListQuery selects one page of polls
+type ListQuery struct {
+	Sort   string    // One of the Sort constants; defaults to SortCreated
+	Desc   bool      // Sort in descending order
+	Status string    // One of the Status constants
+	Title  string    // Only polls whose title contains this text, ignoring case
+	Now    time.Time // Reference time for the status filter
+	After  *Cursor   // Start after this poll; nil for the first page
+	Limit  int       // Maximum number of polls to return; defaults to DefaultListLimit
+
+	// State only returns polls in this state; if empty, polls in every
+	// state except models.StateDraft are returned
+	State string
+	// CreatorID only returns polls created by this user, if set
+	CreatorID string
+}
+
+// ListPage is one page of a poll listing
+type ListPage struct {
+	Polls []models.Poll
+	// Next is the cursor of the last poll on the page, or nil if no polls follow it
+	Next *Cursor
+}
+
+// Cursor marks a position in a poll listing: the sort key and ID of the
+// last poll returned. Ties on the sort key are broken by ID, so every poll
+// has a distinct position.
+type Cursor struct {
+	Sort  string    `json:"s"`
+	Desc  bool      `json:"d,omitempty"`
+	Time  time.Time `json:"t"`           // Sort key for the time-based orders
+	Votes int       `json:"v,omitempty"` // Sort key for SortVotes
+	ID    string    `json:"id"`
+}
+
+// cursorFor returns the position of a poll in a listing sorted as q is
+func cursorFor(p *models.Poll, q ListQuery) Cursor {
+	c := Cursor{Sort: q.Sort, Desc: q.Desc, ID: p.ID}
+	switch q.Sort {
+	case SortUpdated:
+		c.Time = p.UpdatedAt
+	case SortExpires:
+		c.Time = p.ExpiresAt
+	case SortVotes:
+		c.Votes = p.TotalVotes
+	default:
+		c.Time = p.CreatedAt
+	}
+	return c
+}
+
+// compare orders two cursors of the same listing in ascending order
+func (c Cursor) compare(other Cursor) int {
+	if c.Sort == SortVotes {
+		if c.Votes != other.Votes {
+			if c.Votes < other.Votes {
+				return -1
+			}
+			return 1
+		}
+	} else if n := c.Time.Compare(other.Time); n != 0 {
+		return n
+	}
+	return strings.Compare(c.ID, other.ID)
+}
+
+// Encode returns the cursor as an opaque, URL-safe token
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c) // Cannot fail for this struct
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a token produced by Cursor.Encode
+func DecodeCursor(token string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// normalize fills in defaults and checks that the cursor matches the query
+func (q *ListQuery) normalize() error {
+	if q.Sort == "" {
+		q.Sort = SortCreated
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultListLimit
+	}
+	if q.Now.IsZero() {
+		q.Now = time.Now()
+	}
+	if q.After != nil && (q.After.Sort != q.Sort || q.After.Desc != q.Desc) {
+		return ErrInvalidCursor
+	}
+	return nil
+}
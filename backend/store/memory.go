@@ -0,0 +1,313 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"instapoll/backend/models"
+)
+
+// MemoryStore is a PollStore that keeps everything in process memory.
+// It is meant for tests and local development; data is lost on restart.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	polls   map[string]models.Poll
+	ballots map[string][]models.Ballot // Poll ID -> ballots in the order they were cast
+	voters  map[string]map[string]bool // Poll ID -> voter keys that have voted
+	outbox  map[string][]models.Event  // Poll ID -> events not yet acknowledged
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		polls:   make(map[string]models.Poll),
+		ballots: make(map[string][]models.Ballot),
+		voters:  make(map[string]map[string]bool),
+		outbox:  make(map[string][]models.Event),
+	}
+}
+
+// clonePoll copies a poll so callers never share the stored options slice.
+// The outbox is kept separately, so the copy has none.
+func clonePoll(p models.Poll) models.Poll {
+	p.Options = append([]models.Option(nil), p.Options...)
+	p.Outbox = nil
+	return p
+}
+
+// queue adds events to a poll's outbox; s.mu must be held
+func (s *MemoryStore) queue(pollID string, events ...models.Event) {
+	if len(events) > 0 {
+		s.outbox[pollID] = append(s.outbox[pollID], events...)
+	}
+}
+
+func (s *MemoryStore) Create(ctx context.Context, poll *models.Poll) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.polls[poll.ID] = clonePoll(*poll)
+	s.queue(poll.ID, poll.Outbox...)
+	return nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, id string) (*models.Poll, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	poll, ok := s.polls[id]
+	if !ok || poll.IsDeleted() {
+		return nil, ErrNotFound
+	}
+	poll = clonePoll(poll)
+	return &poll, nil
+}
+
+func (s *MemoryStore) GetIncludingDeleted(ctx context.Context, id string) (*models.Poll, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	poll, ok := s.polls[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	poll = clonePoll(poll)
+	return &poll, nil
+}
+
+func (s *MemoryStore) List(ctx context.Context, q ListQuery) (*ListPage, error) {
+	if err := q.normalize(); err != nil {
+		return nil, err
+	}
+	title := strings.ToLower(q.Title)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	polls := make([]models.Poll, 0, len(s.polls))
+	for _, poll := range s.polls {
+		if poll.IsDeleted() {
+			continue
+		}
+		if (q.Status == StatusActive && poll.IsExpired(q.Now)) || (q.Status == StatusExpired && !poll.IsExpired(q.Now)) {
+			continue
+		}
+		if title != "" && !strings.Contains(strings.ToLower(poll.Title), title) {
+			continue
+		}
+		if (q.State == "" && poll.Lifecycle() == models.StateDraft) || (q.State != "" && poll.Lifecycle() != q.State) {
+			continue
+		}
+		if q.CreatorID != "" && poll.CreatorID != q.CreatorID {
+			continue
+		}
+		// Skip everything up to and including the cursor.
+		if q.After != nil {
+			n := cursorFor(&poll, q).compare(*q.After)
+			if (!q.Desc && n <= 0) || (q.Desc && n >= 0) {
+				continue
+			}
+		}
+		polls = append(polls, poll)
+	}
+
+	sort.Slice(polls, func(i, j int) bool {
+		n := cursorFor(&polls[i], q).compare(cursorFor(&polls[j], q))
+		if q.Desc {
+			return n > 0
+		}
+		return n < 0
+	})
+
+	page := &ListPage{Polls: []models.Poll{}}
+	for i := range polls {
+		if len(page.Polls) == q.Limit {
+			last := cursorFor(&page.Polls[len(page.Polls)-1], q)
+			page.Next = &last
+			break
+		}
+		page.Polls = append(page.Polls, clonePoll(polls[i]))
+	}
+	return page, nil
+}
+
+func (s *MemoryStore) Update(ctx context.Context, poll *models.Poll, expectedVersion int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.polls[poll.ID]
+	if !ok || stored.IsDeleted() {
+		return ErrNotFound
+	}
+	if stored.Version != expectedVersion {
+		return ErrVersionConflict
+	}
+
+	updated := clonePoll(*poll)
+	updated.TotalVotes = stored.TotalVotes
+	counts := make(map[string]int, len(stored.Options))
+	for _, option := range stored.Options {
+		counts[option.ID] = option.VoteCount
+	}
+	for i := range updated.Options {
+		updated.Options[i].VoteCount = counts[updated.Options[i].ID]
+		delete(counts, updated.Options[i].ID)
+	}
+	// Whatever is left in counts is being dropped; it must not have votes.
+	for _, count := range counts {
+		if count > 0 {
+			return ErrVersionConflict
+		}
+	}
+	s.polls[poll.ID] = updated
+	s.queue(poll.ID, poll.Outbox...)
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	poll, ok := s.polls[id]
+	if !ok || poll.IsDeleted() {
+		return ErrNotFound
+	}
+	poll.DeletedAt = at
+	s.polls[id] = poll
+	return nil
+}
+
+func (s *MemoryStore) Restore(ctx context.Context, id string) (*models.Poll, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	poll, ok := s.polls[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	poll.DeletedAt = time.Time{}
+	s.polls[id] = poll
+	poll = clonePoll(poll)
+	return &poll, nil
+}
+
+func (s *MemoryStore) RecordVote(ctx context.Context, ballot *models.Ballot) (*models.Poll, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	poll, ok := s.polls[ballot.PollID]
+	if !ok || poll.IsDeleted() {
+		return nil, ErrNotFound
+	}
+	if !poll.AcceptsVotes(ballot.CreatedAt) {
+		return nil, ErrPollClosed
+	}
+	if ballot.VoterKey != "" {
+		if s.voters[poll.ID][ballot.VoterKey] {
+			return nil, ErrAlreadyVoted
+		}
+		if s.voters[poll.ID] == nil {
+			s.voters[poll.ID] = make(map[string]bool)
+		}
+		s.voters[poll.ID][ballot.VoterKey] = true
+	}
+
+	poll = clonePoll(poll)
+	poll.TotalVotes++
+	for _, optionID := range ballot.Counted() {
+		if option := poll.FindOption(optionID); option != nil {
+			option.VoteCount++
+		}
+	}
+	s.polls[poll.ID] = poll
+	s.ballots[poll.ID] = append(s.ballots[poll.ID], *ballot)
+	event := models.NewEvent(models.EventVoteCast, ballot.CreatedAt)
+	event.BallotID = ballot.ID
+	s.queue(poll.ID, event)
+
+	result := clonePoll(poll)
+	return &result, nil
+}
+
+func (s *MemoryStore) Ballots(ctx context.Context, pollID string) ([]models.Ballot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]models.Ballot{}, s.ballots[pollID]...), nil
+}
+
+func (s *MemoryStore) Due(ctx context.Context, now time.Time, limit int) ([]models.Poll, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	due := []models.Poll{}
+	for _, poll := range s.polls {
+		if len(due) == limit {
+			break
+		}
+		if poll.IsDeleted() {
+			continue
+		}
+		switch poll.Lifecycle() {
+		case models.StateScheduled:
+			if poll.OpensAt.After(now) {
+				continue
+			}
+		case models.StateOpen:
+			if !poll.IsExpired(now) {
+				continue
+			}
+		default:
+			continue
+		}
+		due = append(due, clonePoll(poll))
+	}
+	return due, nil
+}
+
+func (s *MemoryStore) HasVoted(ctx context.Context, pollID, voterKey string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return voterKey != "" && s.voters[pollID][voterKey], nil
+}
+
+func (s *MemoryStore) PendingEvents(ctx context.Context, limit int) ([]models.Poll, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	pending := []models.Poll{}
+	for id, events := range s.outbox {
+		if len(events) == 0 {
+			continue
+		}
+		poll := clonePoll(s.polls[id])
+		poll.Outbox = append([]models.Event(nil), events...)
+		pending = append(pending, poll)
+	}
+	// Each outbox is in the order its events were queued, so its first
+	// event is the oldest.
+	sort.Slice(pending, func(i, j int) bool {
+		a, b := pending[i].Outbox[0].CreatedAt, pending[j].Outbox[0].CreatedAt
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return pending[i].ID < pending[j].ID
+	})
+	if len(pending) > limit {
+		pending = pending[:limit]
+	}
+	return pending, nil
+}
+
+func (s *MemoryStore) AckEvents(ctx context.Context, pollID string, eventIDs []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acked := make(map[string]bool, len(eventIDs))
+	for _, id := range eventIDs {
+		acked[id] = true
+	}
+	remaining := []models.Event{}
+	for _, event := range s.outbox[pollID] {
+		if !acked[event.ID] {
+			remaining = append(remaining, event)
+		}
+	}
+	if len(remaining) == 0 {
+		delete(s.outbox, pollID)
+	} else {
+		s.outbox[pollID] = remaining
+	}
+	return nil
+}
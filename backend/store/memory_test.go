@@ -0,0 +1,34 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	testPollStore(t, func(t *testing.T) PollStore {
+		return NewMemoryStore()
+	})
+}
+
+func TestMemoryStoreReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	poll := newPoll(time.Time{})
+	require.NoError(t, s.Create(ctx, poll))
+
+	// Mutating a poll after storing or reading it must not change the stored poll.
+	poll.Options[0].Text = "changed by caller"
+	got, err := s.Get(ctx, poll.ID)
+	require.NoError(t, err)
+	got.Options[1].VoteCount = 99
+
+	again, err := s.Get(ctx, poll.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "A", again.Options[0].Text)
+	assert.Equal(t, 0, again.Options[1].VoteCount)
+}
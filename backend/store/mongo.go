@@ -0,0 +1,459 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"instapoll/backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStore is a PollStore backed by MongoDB collections
+type MongoStore struct {
+	polls   *mongo.Collection // Poll documents, keyed by poll ID
+	ballots *mongo.Collection // One document per ballot cast
+}
+
+// NewMongoStore creates a store using the given poll and ballot collections
+func NewMongoStore(polls, ballots *mongo.Collection) *MongoStore {
+	return &MongoStore{
+		polls:   polls,
+		ballots: ballots,
+	}
+}
+
+// EnsureIndexes creates the indexes the store's queries rely on.
+// It is safe to call on every startup.
+func (s *MongoStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.ballots.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		// Index ballots by poll so tabulating results does not scan the whole collection.
+		{Keys: bson.D{{Key: "poll_id", Value: 1}}},
+		// Allow each voter one ballot per poll. The uniqueness check is part
+		// of inserting the ballot, so concurrent votes cannot slip past it.
+		// Ballots cast before voter keys existed have none and are exempt.
+		{
+			Keys: bson.D{{Key: "poll_id", Value: 1}, {Key: "voter_key", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"voter_key": bson.M{"$exists": true}}),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Index every sort order List supports, with _id as the tie-break its
+	// cursors rely on. Each index serves both directions.
+	indexes := make([]mongo.IndexModel, 0, len(listSortFields))
+	for _, field := range listSortFields {
+		indexes = append(indexes, mongo.IndexModel{
+			Keys: bson.D{{Key: field, Value: 1}, {Key: "_id", Value: 1}},
+		})
+	}
+	// Index the times Due looks for, by state.
+	indexes = append(indexes,
+		mongo.IndexModel{Keys: bson.D{{Key: "state", Value: 1}, {Key: "opens_at", Value: 1}}},
+		mongo.IndexModel{Keys: bson.D{{Key: "state", Value: 1}, {Key: "expires_at", Value: 1}}},
+	)
+	// Only polls with pending events have outbox entries, so this sparse
+	// index lets PendingEvents skip all others and find the oldest events first.
+	indexes = append(indexes, mongo.IndexModel{
+		Keys:    bson.D{{Key: "outbox.created_at", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	})
+	_, err = s.polls.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// openState is the state condition matching open polls, including those
+// created before states existed, which have no state field
+var openState = bson.M{"$in": bson.A{models.StateOpen, nil}}
+
+// notDeleted is the deleted_at condition matching polls that have not been soft-deleted
+var notDeleted = bson.M{"$exists": false}
+
+// withoutOutbox is the projection leaving out a poll's outbox, which only
+// PendingEvents returns
+var withoutOutbox = bson.M{"outbox": 0}
+
+func (s *MongoStore) Create(ctx context.Context, poll *models.Poll) error {
+	_, err := s.polls.InsertOne(ctx, poll)
+	return err
+}
+
+func (s *MongoStore) Get(ctx context.Context, id string) (*models.Poll, error) {
+	return s.findOne(ctx, bson.M{"_id": id, "deleted_at": notDeleted})
+}
+
+func (s *MongoStore) GetIncludingDeleted(ctx context.Context, id string) (*models.Poll, error) {
+	return s.findOne(ctx, bson.M{"_id": id})
+}
+
+// findOne returns the poll matching filter, or ErrNotFound
+func (s *MongoStore) findOne(ctx context.Context, filter bson.M) (*models.Poll, error) {
+	var poll models.Poll
+	err := s.polls.FindOne(ctx, filter, options.FindOne().SetProjection(withoutOutbox)).Decode(&poll)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &poll, nil
+}
+
+func (s *MongoStore) List(ctx context.Context, q ListQuery) (*ListPage, error) {
+	if err := q.normalize(); err != nil {
+		return nil, err
+	}
+
+	// Conditions are ANDed; each may use $or itself.
+	conditions := bson.A{bson.M{"deleted_at": notDeleted}}
+	switch q.Status {
+	case StatusActive:
+		conditions = append(conditions, bson.M{"$or": bson.A{
+			bson.M{"expires_at": bson.M{"$exists": false}},
+			bson.M{"expires_at": bson.M{"$gt": q.Now}},
+		}})
+	case StatusExpired:
+		conditions = append(conditions, bson.M{"expires_at": bson.M{"$lte": q.Now}})
+	}
+	if q.State == "" {
+		conditions = append(conditions, bson.M{"state": bson.M{"$ne": models.StateDraft}})
+	} else if q.State == models.StateOpen {
+		conditions = append(conditions, bson.M{"state": openState})
+	} else {
+		conditions = append(conditions, bson.M{"state": q.State})
+	}
+	if q.CreatorID != "" {
+		conditions = append(conditions, bson.M{"creator_id": q.CreatorID})
+	}
+	if q.Title != "" {
+		conditions = append(conditions, bson.M{"title": bson.M{
+			"$regex":   regexp.QuoteMeta(q.Title),
+			"$options": "i",
+		}})
+	}
+
+	field := listSortFields[q.Sort]
+	if q.After != nil {
+		conditions = append(conditions, afterCursor(field, *q.After))
+	}
+
+	direction := 1
+	if q.Desc {
+		direction = -1
+	}
+	// Fetch one extra poll to find out whether another page follows.
+	opts := options.Find().
+		SetSort(bson.D{{Key: field, Value: direction}, {Key: "_id", Value: direction}}).
+		SetLimit(int64(q.Limit) + 1).
+		SetProjection(withoutOutbox)
+
+	cursor, err := s.polls.Find(ctx, bson.M{"$and": conditions}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	page := &ListPage{Polls: []models.Poll{}}
+	if err := cursor.All(ctx, &page.Polls); err != nil {
+		return nil, err
+	}
+	if len(page.Polls) > q.Limit {
+		page.Polls = page.Polls[:q.Limit]
+		last := cursorFor(&page.Polls[q.Limit-1], q)
+		page.Next = &last
+	}
+	return page, nil
+}
+
+// listSortFields maps ListQuery sort orders to document fields
+var listSortFields = map[string]string{
+	SortCreated: "created_at",
+	SortUpdated: "updated_at",
+	SortExpires: "expires_at",
+	SortVotes:   "total_votes",
+}
+
+// afterCursor returns the condition matching polls that come after the
+// cursor when sorted by field and then _id.
+// Polls without an expiry have no expires_at field, which MongoDB sorts
+// before any date; a zero cursor time stands for such a poll.
+func afterCursor(field string, c Cursor) bson.M {
+	cmp, idCmp := "$gt", "$gt"
+	if c.Desc {
+		cmp, idCmp = "$lt", "$lt"
+	}
+
+	if field == "expires_at" && c.Time.IsZero() {
+		missing := bson.M{field: bson.M{"$exists": false}, "_id": bson.M{idCmp: c.ID}}
+		if c.Desc {
+			return missing
+		}
+		return bson.M{"$or": bson.A{missing, bson.M{field: bson.M{"$exists": true}}}}
+	}
+
+	var value interface{} = c.Time
+	if field == "total_votes" {
+		value = c.Votes
+	}
+	after := bson.A{
+		bson.M{field: bson.M{cmp: value}},
+		bson.M{field: value, "_id": bson.M{idCmp: c.ID}},
+	}
+	if field == "expires_at" && c.Desc {
+		after = append(after, bson.M{field: bson.M{"$exists": false}})
+	}
+	return bson.M{"$or": after}
+}
+
+func (s *MongoStore) Update(ctx context.Context, poll *models.Poll, expectedVersion int) error {
+	// Only replace the document if nobody else has updated it since the
+	// caller read it. Polls created before versioning have no version field.
+	filter := bson.M{"_id": poll.ID, "deleted_at": notDeleted}
+	if expectedVersion == 0 {
+		filter["$or"] = []bson.M{{"version": 0}, {"version": bson.M{"$exists": false}}}
+	} else {
+		filter["version"] = expectedVersion
+	}
+	// Refuse to drop an option that received votes after the caller checked.
+	keep := make([]string, len(poll.Options))
+	for i, option := range poll.Options {
+		keep[i] = option.ID
+	}
+	filter["options"] = bson.M{"$not": bson.M{"$elemMatch": bson.M{
+		"_id":        bson.M{"$nin": keep},
+		"vote_count": bson.M{"$gt": 0},
+	}}}
+
+	// Replace the document in an update pipeline so the vote counts are
+	// copied from the stored document rather than from the caller's copy,
+	// which may be missing votes recorded in the meantime. The new poll is
+	// wrapped in $literal so user text starting with '$' is not evaluated.
+	storedCount := bson.M{"$let": bson.M{
+		"vars": bson.M{"stored": bson.M{"$arrayElemAt": bson.A{
+			bson.M{"$filter": bson.M{
+				"input": "$options",
+				"as":    "cur",
+				"cond":  bson.M{"$eq": bson.A{"$$cur._id", "$$opt._id"}},
+			}},
+			0,
+		}}},
+		"in": bson.M{"$ifNull": bson.A{"$$stored.vote_count", 0}},
+	}}
+	mergedOptions := bson.M{"$map": bson.M{
+		"input": bson.M{"$literal": poll.Options},
+		"as":    "opt",
+		"in":    bson.M{"$mergeObjects": bson.A{"$$opt", bson.M{"vote_count": storedCount}}},
+	}}
+	// The poll's events are appended to the stored outbox.
+	replacement := *poll
+	replacement.Outbox = nil
+	events := poll.Outbox
+	if events == nil {
+		events = []models.Event{}
+	}
+	update := bson.A{bson.M{"$replaceWith": bson.M{"$mergeObjects": bson.A{
+		bson.M{"$literal": replacement},
+		bson.M{
+			"options":     mergedOptions,
+			"total_votes": bson.M{"$ifNull": bson.A{"$total_votes", 0}},
+			"outbox": bson.M{"$concatArrays": bson.A{
+				bson.M{"$ifNull": bson.A{"$outbox", bson.A{}}},
+				bson.M{"$literal": events},
+			}},
+		},
+	}}}}
+
+	res, err := s.polls.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount > 0 {
+		return nil
+	}
+	// Nothing matched: tell a missing poll apart from a stale version.
+	if _, err := s.Get(ctx, poll.ID); err != nil {
+		return err
+	}
+	return ErrVersionConflict
+}
+
+func (s *MongoStore) Delete(ctx context.Context, id string, at time.Time) error {
+	res, err := s.polls.UpdateOne(ctx,
+		bson.M{"_id": id, "deleted_at": notDeleted},
+		bson.M{"$set": bson.M{"deleted_at": at}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoStore) Restore(ctx context.Context, id string) (*models.Poll, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(withoutOutbox)
+	var poll models.Poll
+	err := s.polls.FindOneAndUpdate(ctx,
+		bson.M{"_id": id},
+		bson.M{"$unset": bson.M{"deleted_at": ""}},
+		opts,
+	).Decode(&poll)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &poll, nil
+}
+
+func (s *MongoStore) RecordVote(ctx context.Context, ballot *models.Ballot) (*models.Poll, error) {
+	// Inserting the ballot first claims its voter key.
+	if _, err := s.ballots.InsertOne(ctx, ballot); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrAlreadyVoted
+		}
+		return nil, err
+	}
+
+	// Increment the chosen elements of the options array in a single atomic
+	// update. The state and expiry conditions are part of the filter so a
+	// poll that closes or expires while the vote is in flight cannot
+	// receive it; scheduled polls take votes once their opening time has
+	// passed, even if the scheduler has not marked them open yet.
+	filter := bson.M{
+		"_id":        ballot.PollID,
+		"deleted_at": notDeleted,
+		"$and": []bson.M{
+			{"$or": []bson.M{
+				{"state": openState},
+				{"state": models.StateScheduled, "opens_at": bson.M{"$lte": ballot.CreatedAt}},
+			}},
+			{"$or": []bson.M{
+				{"expires_at": bson.M{"$exists": false}},
+				{"expires_at": bson.M{"$gt": ballot.CreatedAt}},
+			}},
+		},
+	}
+	counted := ballot.Counted()
+	if counted == nil {
+		counted = []string{} // $in needs an array even when nothing is counted
+	}
+	event := models.NewEvent(models.EventVoteCast, ballot.CreatedAt)
+	event.BallotID = ballot.ID
+	update := bson.M{
+		"$inc": bson.M{
+			"options.$[chosen].vote_count": 1,
+			"total_votes":                  1,
+		},
+		"$push": bson.M{"outbox": event},
+	}
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(withoutOutbox).
+		SetArrayFilters(options.ArrayFilters{
+			Filters: []interface{}{bson.M{"chosen._id": bson.M{"$in": counted}}},
+		})
+
+	var poll models.Poll
+	err := s.polls.FindOneAndUpdate(ctx, filter, update, opts).Decode(&poll)
+	if err == nil {
+		return &poll, nil
+	}
+
+	// Remove the ballot again so it is not counted in the results.
+	if _, delErr := s.ballots.DeleteOne(ctx, bson.M{"_id": ballot.ID}); delErr != nil {
+		return nil, errors.Join(err, delErr)
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+	// Nothing matched: tell a missing poll apart from an expired one.
+	if _, getErr := s.Get(ctx, ballot.PollID); getErr != nil {
+		return nil, getErr
+	}
+	return nil, ErrPollClosed
+}
+
+func (s *MongoStore) Ballots(ctx context.Context, pollID string) ([]models.Ballot, error) {
+	cursor, err := s.ballots.Find(ctx, bson.M{"poll_id": pollID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	ballots := []models.Ballot{}
+	if err := cursor.All(ctx, &ballots); err != nil {
+		return nil, err
+	}
+	return ballots, nil
+}
+
+func (s *MongoStore) Due(ctx context.Context, now time.Time, limit int) ([]models.Poll, error) {
+	filter := bson.M{
+		"deleted_at": notDeleted,
+		"$or": bson.A{
+			bson.M{"state": models.StateScheduled, "opens_at": bson.M{"$lte": now}},
+			bson.M{"state": openState, "expires_at": bson.M{"$lte": now}},
+		},
+	}
+	cursor, err := s.polls.Find(ctx, filter, options.Find().SetLimit(int64(limit)).SetProjection(withoutOutbox))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	polls := []models.Poll{}
+	if err := cursor.All(ctx, &polls); err != nil {
+		return nil, err
+	}
+	return polls, nil
+}
+
+func (s *MongoStore) HasVoted(ctx context.Context, pollID, voterKey string) (bool, error) {
+	if voterKey == "" {
+		return false, nil
+	}
+	// Served by the unique (poll_id, voter_key) index.
+	n, err := s.ballots.CountDocuments(ctx, bson.M{"poll_id": pollID, "voter_key": voterKey}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (s *MongoStore) PendingEvents(ctx context.Context, limit int) ([]models.Poll, error) {
+	// An ascending sort on an array field goes by its smallest value, so
+	// polls come in the order of their oldest pending event.
+	opts := options.Find().
+		SetSort(bson.D{{Key: "outbox.created_at", Value: 1}, {Key: "_id", Value: 1}}).
+		SetLimit(int64(limit))
+	cursor, err := s.polls.Find(ctx, bson.M{"outbox.created_at": bson.M{"$exists": true}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	polls := []models.Poll{}
+	if err := cursor.All(ctx, &polls); err != nil {
+		return nil, err
+	}
+	return polls, nil
+}
+
+func (s *MongoStore) AckEvents(ctx context.Context, pollID string, eventIDs []string) error {
+	_, err := s.polls.UpdateOne(ctx,
+		bson.M{"_id": pollID},
+		bson.M{"$pull": bson.M{"outbox": bson.M{"id": bson.M{"$in": eventIDs}}}},
+	)
+	return err
+}
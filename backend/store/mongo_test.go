@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// TestMongoStore runs the shared store tests against a real MongoDB.
+// It is skipped unless TEST_MONGODB_URI points at a running server.
+func TestMongoStore(t *testing.T) {
+	mongoURI := os.Getenv("TEST_MONGODB_URI")
+	if mongoURI == "" {
+		t.Skip("TEST_MONGODB_URI not set, skipping MongoDB store tests")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoURI))
+	require.NoError(t, err, "Failed to connect to test MongoDB")
+	require.NoError(t, client.Ping(ctx, readpref.Primary()), "Failed to ping test MongoDB")
+
+	// Each subtest gets its own throwaway database.
+	var databases []*mongo.Database
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		for _, db := range databases {
+			_ = db.Drop(ctx)
+		}
+		_ = client.Disconnect(ctx)
+	})
+
+	testPollStore(t, func(t *testing.T) PollStore {
+		db := client.Database("instapoll_test_" + uuid.New().String()[:8])
+		databases = append(databases, db)
+		s := NewMongoStore(db.Collection("polls"), db.Collection("ballots"))
+		require.NoError(t, s.EnsureIndexes(context.Background()))
+		return s
+	})
+
+	testUserStore(t, func(t *testing.T) UserStore {
+		db := client.Database("instapoll_test_" + uuid.New().String()[:8])
+		databases = append(databases, db)
+		s := NewMongoUserStore(db.Collection("users"))
+		require.NoError(t, s.EnsureIndexes(context.Background()))
+		return s
+	})
+
+	testLeaseStore(t, func(t *testing.T) LeaseStore {
+		db := client.Database("instapoll_test_" + uuid.New().String()[:8])
+		databases = append(databases, db)
+		return NewMongoLeaseStore(db.Collection("leases"))
+	})
+
+	testWebhookStore(t, func(t *testing.T) WebhookStore {
+		db := client.Database("instapoll_test_" + uuid.New().String()[:8])
+		databases = append(databases, db)
+		s := NewMongoWebhookStore(db.Collection("webhooks"), db.Collection("webhook_deliveries"))
+		require.NoError(t, s.EnsureIndexes(context.Background()))
+		return s
+	})
+
+	testRevocationStore(t, func(t *testing.T) RevocationStore {
+		db := client.Database("instapoll_test_" + uuid.New().String()[:8])
+		databases = append(databases, db)
+		s := NewMongoRevocationStore(db.Collection("revoked_tokens"))
+		require.NoError(t, s.EnsureIndexes(context.Background()))
+		return s
+	})
+}
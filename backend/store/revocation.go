@@ -0,0 +1,19 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// RevocationStore is the list of revoked session tokens.
+// Entries are keyed by token ID (or token family ID) and only need to be
+// kept until the token they revoke would have expired anyway.
+// Implementations must be safe for concurrent use.
+type RevocationStore interface {
+	// Revoke adds id to the list until expiresAt. It reports whether id
+	// was newly revoked, so exactly one of several concurrent callers
+	// revoking the same id sees true.
+	Revoke(ctx context.Context, id string, expiresAt time.Time) (bool, error)
+	// IsRevoked reports whether any of the given ids has been revoked.
+	IsRevoked(ctx context.Context, ids ...string) (bool, error)
+}
@@ -0,0 +1,49 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryRevocationStore is a RevocationStore that keeps the list in process
+// memory. It is meant for tests and local development.
+type MemoryRevocationStore struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time // ID -> when the entry can be forgotten
+}
+
+// NewMemoryRevocationStore creates an empty in-memory revocation list
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{revoked: make(map[string]time.Time)}
+}
+
+func (s *MemoryRevocationStore) Revoke(ctx context.Context, id string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Forget entries whose tokens have expired, as MongoDB's TTL index does.
+	now := time.Now()
+	for revokedID, until := range s.revoked {
+		if until.Before(now) {
+			delete(s.revoked, revokedID)
+		}
+	}
+
+	if _, ok := s.revoked[id]; ok {
+		return false, nil
+	}
+	s.revoked[id] = expiresAt
+	return true, nil
+}
+
+func (s *MemoryRevocationStore) IsRevoked(ctx context.Context, ids ...string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		if _, ok := s.revoked[id]; ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoRevocationStore is a RevocationStore backed by a MongoDB collection
+type MongoRevocationStore struct {
+	revoked *mongo.Collection // One document per revoked ID
+}
+
+// NewMongoRevocationStore creates a revocation list using the given collection
+func NewMongoRevocationStore(revoked *mongo.Collection) *MongoRevocationStore {
+	return &MongoRevocationStore{revoked: revoked}
+}
+
+// EnsureIndexes creates a TTL index so MongoDB deletes entries once the
+// token they revoke has expired. It is safe to call on every startup.
+func (s *MongoRevocationStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.revoked.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+func (s *MongoRevocationStore) Revoke(ctx context.Context, id string, expiresAt time.Time) (bool, error) {
+	_, err := s.revoked.InsertOne(ctx, bson.M{"_id": id, "expires_at": expiresAt})
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *MongoRevocationStore) IsRevoked(ctx context.Context, ids ...string) (bool, error) {
+	err := s.revoked.FindOne(ctx, bson.M{"_id": bson.M{"$in": ids}}).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRevocationStore runs the behaviour every RevocationStore implementation must share.
+// newStore must return an empty store.
+func testRevocationStore(t *testing.T, newStore func(t *testing.T) RevocationStore) {
+	ctx := context.Background()
+
+	t.Run("revoke", func(t *testing.T) {
+		s := newStore(t)
+		until := time.Now().Add(time.Hour)
+
+		revoked, err := s.IsRevoked(ctx, "a", "b")
+		require.NoError(t, err)
+		assert.False(t, revoked)
+
+		added, err := s.Revoke(ctx, "b", until)
+		require.NoError(t, err)
+		assert.True(t, added)
+		added, err = s.Revoke(ctx, "b", until)
+		require.NoError(t, err)
+		assert.False(t, added, "revoking twice should report the id as already revoked")
+
+		revoked, err = s.IsRevoked(ctx, "a", "b")
+		require.NoError(t, err)
+		assert.True(t, revoked, "any revoked id should match")
+		revoked, err = s.IsRevoked(ctx, "a")
+		require.NoError(t, err)
+		assert.False(t, revoked)
+	})
+
+	t.Run("concurrent revoke", func(t *testing.T) {
+		s := newStore(t)
+		var wins atomic.Int32
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				added, err := s.Revoke(ctx, "token", time.Now().Add(time.Hour))
+				assert.NoError(t, err)
+				if added {
+					wins.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, int32(1), wins.Load(), "exactly one caller should revoke the id")
+	})
+}
+
+func TestMemoryRevocationStore(t *testing.T) {
+	testRevocationStore(t, func(t *testing.T) RevocationStore {
+		return NewMemoryRevocationStore()
+	})
+}
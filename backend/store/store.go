@@ -0,0 +1,84 @@
+// Package store persists polls and their ballots.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"instapoll/backend/models"
+)
+
+var (
+	// ErrNotFound is returned when the requested poll does not exist
+	ErrNotFound = errors.New("poll not found")
+	// ErrPollClosed is returned when a vote is recorded on a poll that is
+	// not open, or has expired
+	ErrPollClosed = errors.New("poll is not accepting votes")
+	// ErrAlreadyVoted is returned when a ballot's voter key has already
+	// been used on the poll
+	ErrAlreadyVoted = errors.New("voter has already voted on this poll")
+	// ErrVersionConflict is returned when a poll was updated by someone else
+	// since the caller read it
+	ErrVersionConflict = errors.New("poll was modified by another request")
+)
+
+// PollStore is the storage used by the poll handlers.
+// Deleted polls are soft-deleted: every method except Restore and
+// GetIncludingDeleted treats them
+// as if they did not exist, but their data is kept so they can be restored.
+//
+// Each poll has an outbox of events for webhooks (see models.Poll.Outbox).
+// Events are queued by the same write as the change they describe: Create
+// and Update queue the events in poll.Outbox and RecordVote queues a
+// models.EventVoteCast event. Polls returned by every method except
+// PendingEvents have an empty Outbox.
+// Implementations must be safe for concurrent use.
+type PollStore interface {
+	// Create stores a new poll.
+	Create(ctx context.Context, poll *models.Poll) error
+	// Get returns the poll with the given ID, or ErrNotFound.
+	Get(ctx context.Context, id string) (*models.Poll, error)
+	// GetIncludingDeleted is like Get but also returns soft-deleted polls.
+	GetIncludingDeleted(ctx context.Context, id string) (*models.Poll, error)
+	// List returns a page of polls matching the query, in the requested
+	// order. It returns ErrInvalidCursor if q.After comes from a listing
+	// with a different sort order.
+	List(ctx context.Context, q ListQuery) (*ListPage, error)
+	// Update replaces an existing poll if its stored version still equals
+	// expectedVersion. Vote counts are owned by RecordVote: the stored total
+	// and the count of every option the poll keeps are preserved, whatever
+	// the caller sends.
+	// It returns ErrVersionConflict if the poll has been updated since or an
+	// option it drops has received votes, or ErrNotFound.
+	Update(ctx context.Context, poll *models.Poll, expectedVersion int) error
+	// Delete soft-deletes a poll at the given time, or returns ErrNotFound.
+	Delete(ctx context.Context, id string, at time.Time) error
+	// Restore undoes a soft delete and returns the restored poll.
+	// Restoring a poll that is not deleted is a no-op.
+	Restore(ctx context.Context, id string) (*models.Poll, error)
+	// RecordVote stores the ballot and atomically increments the poll's
+	// total votes and the vote counts of the options it chooses, returning
+	// the updated poll. It returns ErrNotFound if the poll does not exist,
+	// ErrPollClosed if the poll did not accept votes at ballot.CreatedAt
+	// (see models.Poll.AcceptsVotes) and ErrAlreadyVoted if a ballot with
+	// the same non-empty voter key was already recorded on the poll. Voter
+	// keys are checked atomically, so concurrent ballots from one voter
+	// cannot both be recorded.
+	RecordVote(ctx context.Context, ballot *models.Ballot) (*models.Poll, error)
+	// Ballots returns every ballot cast on the given poll.
+	Ballots(ctx context.Context, pollID string) ([]models.Ballot, error)
+	// Due returns up to limit polls with a lifecycle transition due at now:
+	// scheduled polls whose opening time has passed and open polls that
+	// have expired.
+	Due(ctx context.Context, now time.Time, limit int) ([]models.Poll, error)
+	// HasVoted reports whether a ballot with the given voter key has been
+	// recorded on the poll.
+	HasVoted(ctx context.Context, pollID, voterKey string) (bool, error)
+	// PendingEvents returns up to limit polls, deleted ones included, with
+	// events in their outbox, in the order the events were queued.
+	PendingEvents(ctx context.Context, limit int) ([]models.Poll, error)
+	// AckEvents removes the given events from the poll's outbox once they
+	// have been handed on. Unknown events are ignored.
+	AckEvents(ctx context.Context, pollID string, eventIDs []string) error
+}
@@ -0,0 +1,515 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"instapoll/backend/models"
+	"instapoll/backend/tabulation"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPoll returns a valid two-option poll with fresh IDs
+func newPoll(expiresAt time.Time) *models.Poll {
+	now := time.Now().UTC().Truncate(time.Millisecond) // MongoDB stores millisecond precision
+	return &models.Poll{
+		ID:           uuid.New().String(),
+		Title:        "Store Test",
+		VotingMethod: "approval",
+		Options: []models.Option{
+			{ID: uuid.New().String(), Text: "A"},
+			{ID: uuid.New().String(), Text: "B"},
+		},
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: expiresAt,
+	}
+}
+
+// newBallot returns a ballot approving the given options
+func newBallot(pollID string, approvals ...string) *models.Ballot {
+	return &models.Ballot{
+		ID:        uuid.New().String(),
+		PollID:    pollID,
+		Ballot:    tabulation.Ballot{Approvals: approvals},
+		CreatedAt: time.Now(),
+	}
+}
+
+// testPollStore runs the behaviour every PollStore implementation must share.
+// newStore must return an empty store.
+func testPollStore(t *testing.T, newStore func(t *testing.T) PollStore) {
+	ctx := context.Background()
+
+	t.Run("create get list", func(t *testing.T) {
+		s := newStore(t)
+		poll := newPoll(time.Time{})
+		require.NoError(t, s.Create(ctx, poll))
+
+		got, err := s.Get(ctx, poll.ID)
+		require.NoError(t, err)
+		assert.Equal(t, poll.Title, got.Title)
+		assert.Len(t, got.Options, 2)
+
+		page, err := s.List(ctx, ListQuery{})
+		require.NoError(t, err)
+		assert.Len(t, page.Polls, 1)
+		assert.Nil(t, page.Next)
+
+		_, err = s.Get(ctx, uuid.New().String())
+		assert.True(t, errors.Is(err, ErrNotFound), "Get of a missing poll should return ErrNotFound")
+	})
+
+	t.Run("list pages", func(t *testing.T) {
+		s := newStore(t)
+		// Five polls created a second apart; two never expire, three share
+		// an expiry, and votes are tied in pairs so every order needs the
+		// ID tie-break.
+		base := time.Now().UTC().Truncate(time.Millisecond)
+		expiry := base.Add(time.Hour)
+		var ids []string
+		for i := 0; i < 5; i++ {
+			poll := newPoll(time.Time{})
+			poll.CreatedAt = base.Add(time.Duration(i) * time.Second)
+			poll.TotalVotes = i / 2
+			if i >= 2 {
+				poll.ExpiresAt = expiry
+			}
+			require.NoError(t, s.Create(ctx, poll))
+			ids = append(ids, poll.ID)
+		}
+
+		// listAll follows the cursors two polls at a time.
+		listAll := func(q ListQuery) []string {
+			q.Limit = 2
+			var got []string
+			for pages := 0; pages < 5; pages++ {
+				page, err := s.List(ctx, q)
+				require.NoError(t, err)
+				for _, poll := range page.Polls {
+					got = append(got, poll.ID)
+				}
+				if page.Next == nil {
+					return got
+				}
+				// Cursors must survive the round trip through their token.
+				q.After, err = DecodeCursor(page.Next.Encode())
+				require.NoError(t, err)
+			}
+			t.Fatal("listing did not end")
+			return nil
+		}
+
+		assert.Equal(t, ids, listAll(ListQuery{}), "default order is oldest first")
+		reversed := []string{ids[4], ids[3], ids[2], ids[1], ids[0]}
+		assert.Equal(t, reversed, listAll(ListQuery{Desc: true}))
+
+		for _, sortBy := range []string{SortExpires, SortVotes} {
+			asc := listAll(ListQuery{Sort: sortBy})
+			desc := listAll(ListQuery{Sort: sortBy, Desc: true})
+			assert.ElementsMatch(t, ids, asc, "sort %s should list every poll once", sortBy)
+			for i := range asc {
+				assert.Equal(t, asc[i], desc[len(desc)-1-i], "sort %s descending should reverse ascending", sortBy)
+			}
+		}
+		asc := listAll(ListQuery{Sort: SortExpires})
+		assert.ElementsMatch(t, ids[:2], asc[:2], "polls without an expiry sort first")
+
+		_, err := s.List(ctx, ListQuery{Sort: SortVotes, After: &Cursor{Sort: SortCreated, ID: ids[0]}})
+		assert.True(t, errors.Is(err, ErrInvalidCursor), "cursor from another sort order should be rejected")
+	})
+
+	t.Run("list filters", func(t *testing.T) {
+		s := newStore(t)
+		active := newPoll(time.Now().Add(time.Hour))
+		active.Title = "Lunch options"
+		open := newPoll(time.Time{})
+		open.Title = "Team LUNCH"
+		expired := newPoll(time.Now().Add(-time.Hour))
+		expired.Title = "Offsite (lunch.*)"
+		for _, poll := range []*models.Poll{active, open, expired} {
+			require.NoError(t, s.Create(ctx, poll))
+		}
+
+		listIDs := func(q ListQuery) []string {
+			page, err := s.List(ctx, q)
+			require.NoError(t, err)
+			var got []string
+			for _, poll := range page.Polls {
+				got = append(got, poll.ID)
+			}
+			return got
+		}
+
+		assert.ElementsMatch(t, []string{active.ID, open.ID}, listIDs(ListQuery{Status: StatusActive}))
+		assert.ElementsMatch(t, []string{expired.ID}, listIDs(ListQuery{Status: StatusExpired}))
+		assert.ElementsMatch(t, []string{active.ID, open.ID, expired.ID}, listIDs(ListQuery{Title: "lunch"}))
+		assert.ElementsMatch(t, []string{expired.ID}, listIDs(ListQuery{Title: "(lunch.*)"}), "title search is literal")
+		assert.ElementsMatch(t, []string{open.ID}, listIDs(ListQuery{Title: "team", Status: StatusActive}))
+	})
+
+	t.Run("update", func(t *testing.T) {
+		s := newStore(t)
+		poll := newPoll(time.Time{})
+		poll.Version = 1
+		require.NoError(t, s.Create(ctx, poll))
+
+		poll.Title = "Renamed"
+		poll.Version = 2
+		require.NoError(t, s.Update(ctx, poll, 1))
+		got, err := s.Get(ctx, poll.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Renamed", got.Title)
+		assert.Equal(t, 2, got.Version)
+
+		// A second writer that read version 1 must not overwrite the change.
+		stale := *poll
+		stale.Title = "Stale"
+		err = s.Update(ctx, &stale, 1)
+		assert.True(t, errors.Is(err, ErrVersionConflict), "stale update should return ErrVersionConflict")
+
+		missing := newPoll(time.Time{})
+		assert.True(t, errors.Is(s.Update(ctx, missing, 0), ErrNotFound), "update of a missing poll should return ErrNotFound")
+	})
+
+	t.Run("update keeps stored vote counts", func(t *testing.T) {
+		s := newStore(t)
+		poll := newPoll(time.Time{})
+		poll.Version = 1
+		require.NoError(t, s.Create(ctx, poll))
+		_, err := s.RecordVote(ctx, newBallot(poll.ID, poll.Options[0].ID))
+		require.NoError(t, err)
+
+		// The caller's copy predates the vote; the vote must survive the update.
+		poll.Title = "Renamed"
+		poll.Version = 2
+		poll.Options = append(poll.Options, models.Option{ID: uuid.New().String(), Text: "C", VoteCount: 7})
+		require.NoError(t, s.Update(ctx, poll, 1))
+
+		got, err := s.Get(ctx, poll.ID)
+		require.NoError(t, err)
+		require.Len(t, got.Options, 3)
+		assert.Equal(t, 1, got.Options[0].VoteCount)
+		assert.Equal(t, 0, got.Options[2].VoteCount, "new options start without votes")
+		assert.Equal(t, 1, got.TotalVotes, "total votes should survive the update")
+
+		// Dropping an option that has votes is refused.
+		poll.Options = poll.Options[1:]
+		poll.Version = 3
+		err = s.Update(ctx, poll, 2)
+		assert.True(t, errors.Is(err, ErrVersionConflict), "dropping a voted option should return ErrVersionConflict")
+	})
+
+	t.Run("soft delete and restore", func(t *testing.T) {
+		s := newStore(t)
+		poll := newPoll(time.Time{})
+		require.NoError(t, s.Create(ctx, poll))
+		_, err := s.RecordVote(ctx, newBallot(poll.ID, poll.Options[0].ID))
+		require.NoError(t, err)
+
+		require.NoError(t, s.Delete(ctx, poll.ID, time.Now()))
+		_, err = s.Get(ctx, poll.ID)
+		assert.True(t, errors.Is(err, ErrNotFound), "deleted poll should not be found")
+		page, err := s.List(ctx, ListQuery{})
+		require.NoError(t, err)
+		assert.Empty(t, page.Polls, "deleted poll should not be listed")
+		assert.True(t, errors.Is(s.Delete(ctx, poll.ID, time.Now()), ErrNotFound), "second delete should return ErrNotFound")
+		assert.True(t, errors.Is(s.Update(ctx, poll, 0), ErrNotFound), "update of a deleted poll should return ErrNotFound")
+		_, err = s.RecordVote(ctx, newBallot(poll.ID, poll.Options[0].ID))
+		assert.True(t, errors.Is(err, ErrNotFound), "vote on a deleted poll should return ErrNotFound")
+
+		deleted, err := s.GetIncludingDeleted(ctx, poll.ID)
+		require.NoError(t, err)
+		assert.True(t, deleted.IsDeleted(), "GetIncludingDeleted should return the deleted poll")
+
+		restored, err := s.Restore(ctx, poll.ID)
+		require.NoError(t, err)
+		assert.False(t, restored.IsDeleted())
+		assert.Equal(t, 1, restored.Options[0].VoteCount, "votes should survive a delete and restore")
+
+		_, err = s.Restore(ctx, uuid.New().String())
+		assert.True(t, errors.Is(err, ErrNotFound), "restore of a missing poll should return ErrNotFound")
+	})
+
+	t.Run("record vote", func(t *testing.T) {
+		s := newStore(t)
+		poll := newPoll(time.Time{})
+		require.NoError(t, s.Create(ctx, poll))
+
+		updated, err := s.RecordVote(ctx, newBallot(poll.ID, poll.Options[0].ID, poll.Options[1].ID))
+		require.NoError(t, err)
+		assert.Equal(t, 1, updated.Options[0].VoteCount)
+		assert.Equal(t, 1, updated.Options[1].VoteCount)
+		assert.Equal(t, 1, updated.TotalVotes, "an approval ballot counts once in the total")
+
+		ballots, err := s.Ballots(ctx, poll.ID)
+		require.NoError(t, err)
+		assert.Len(t, ballots, 1)
+
+		_, err = s.RecordVote(ctx, newBallot(uuid.New().String(), poll.Options[0].ID))
+		assert.True(t, errors.Is(err, ErrNotFound), "vote on a missing poll should return ErrNotFound")
+	})
+
+	t.Run("record vote on expired poll", func(t *testing.T) {
+		s := newStore(t)
+		poll := newPoll(time.Now().Add(-time.Minute))
+		require.NoError(t, s.Create(ctx, poll))
+
+		_, err := s.RecordVote(ctx, newBallot(poll.ID, poll.Options[0].ID))
+		assert.True(t, errors.Is(err, ErrPollClosed), "vote on an expired poll should return ErrPollClosed")
+
+		ballots, err := s.Ballots(ctx, poll.ID)
+		require.NoError(t, err)
+		assert.Empty(t, ballots, "rejected ballot should not be kept")
+	})
+
+	t.Run("record vote by state", func(t *testing.T) {
+		s := newStore(t)
+		now := time.Now()
+		tests := []struct {
+			state   string
+			opensAt time.Time
+			wantErr error
+		}{
+			{state: "", wantErr: nil},
+			{state: models.StateOpen, wantErr: nil},
+			{state: models.StateScheduled, opensAt: now.Add(-time.Minute), wantErr: nil},
+			{state: models.StateScheduled, opensAt: now.Add(time.Hour), wantErr: ErrPollClosed},
+			{state: models.StateDraft, wantErr: ErrPollClosed},
+			{state: models.StateClosed, wantErr: ErrPollClosed},
+			{state: models.StateArchived, wantErr: ErrPollClosed},
+		}
+		for _, tt := range tests {
+			poll := newPoll(time.Time{})
+			poll.State = tt.state
+			poll.OpensAt = tt.opensAt
+			require.NoError(t, s.Create(ctx, poll))
+
+			_, err := s.RecordVote(ctx, newBallot(poll.ID, poll.Options[0].ID))
+			if tt.wantErr == nil {
+				assert.NoError(t, err, "vote on a %q poll", tt.state)
+			} else {
+				assert.True(t, errors.Is(err, tt.wantErr), "vote on a %q poll should return %v, got %v", tt.state, tt.wantErr, err)
+			}
+		}
+	})
+
+	t.Run("due", func(t *testing.T) {
+		s := newStore(t)
+		now := time.Now().UTC().Truncate(time.Millisecond)
+		newStatePoll := func(state string, opensAt, expiresAt time.Time) *models.Poll {
+			poll := newPoll(expiresAt)
+			poll.State = state
+			poll.OpensAt = opensAt
+			require.NoError(t, s.Create(ctx, poll))
+			return poll
+		}
+		opening := newStatePoll(models.StateScheduled, now.Add(-time.Second), time.Time{})
+		newStatePoll(models.StateScheduled, now.Add(time.Hour), time.Time{})
+		expired := newStatePoll(models.StateOpen, time.Time{}, now.Add(-time.Second))
+		legacy := newStatePoll("", time.Time{}, now)
+		newStatePoll(models.StateOpen, time.Time{}, now.Add(time.Hour))
+		newStatePoll(models.StateClosed, time.Time{}, now.Add(-time.Hour))
+		newStatePoll(models.StateDraft, now.Add(-time.Hour), time.Time{})
+		deleted := newStatePoll(models.StateOpen, time.Time{}, now.Add(-time.Hour))
+		require.NoError(t, s.Delete(ctx, deleted.ID, now))
+
+		due, err := s.Due(ctx, now, 10)
+		require.NoError(t, err)
+		var ids []string
+		for _, poll := range due {
+			ids = append(ids, poll.ID)
+		}
+		assert.ElementsMatch(t, []string{opening.ID, expired.ID, legacy.ID}, ids)
+
+		due, err = s.Due(ctx, now, 2)
+		require.NoError(t, err)
+		assert.Len(t, due, 2, "Due should respect the limit")
+	})
+
+	t.Run("list by state", func(t *testing.T) {
+		s := newStore(t)
+		open := newPoll(time.Time{})
+		open.State = models.StateOpen
+		legacy := newPoll(time.Time{}) // Created before states existed
+		closed := newPoll(time.Time{})
+		closed.State = models.StateClosed
+		draft := newPoll(time.Time{})
+		draft.State = models.StateDraft
+		draft.CreatorID = "creator"
+		otherDraft := newPoll(time.Time{})
+		otherDraft.State = models.StateDraft
+		for _, poll := range []*models.Poll{open, legacy, closed, draft, otherDraft} {
+			require.NoError(t, s.Create(ctx, poll))
+		}
+
+		listIDs := func(q ListQuery) []string {
+			page, err := s.List(ctx, q)
+			require.NoError(t, err)
+			var got []string
+			for _, poll := range page.Polls {
+				got = append(got, poll.ID)
+			}
+			return got
+		}
+
+		assert.ElementsMatch(t, []string{open.ID, legacy.ID, closed.ID}, listIDs(ListQuery{}), "drafts are not listed by default")
+		assert.ElementsMatch(t, []string{open.ID, legacy.ID}, listIDs(ListQuery{State: models.StateOpen}))
+		assert.ElementsMatch(t, []string{closed.ID}, listIDs(ListQuery{State: models.StateClosed}))
+		assert.ElementsMatch(t, []string{draft.ID}, listIDs(ListQuery{State: models.StateDraft, CreatorID: "creator"}))
+	})
+
+	t.Run("one ballot per voter key", func(t *testing.T) {
+		s := newStore(t)
+		poll := newPoll(time.Time{})
+		require.NoError(t, s.Create(ctx, poll))
+		other := newPoll(time.Time{})
+		require.NoError(t, s.Create(ctx, other))
+
+		// Many concurrent ballots from one voter: exactly one is recorded.
+		const attempts = 10
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		recorded := 0
+		for i := 0; i < attempts; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ballot := newBallot(poll.ID, poll.Options[0].ID)
+				ballot.VoterKey = "voter-1"
+				_, err := s.RecordVote(ctx, ballot)
+				if err == nil {
+					mu.Lock()
+					recorded++
+					mu.Unlock()
+					return
+				}
+				assert.True(t, errors.Is(err, ErrAlreadyVoted), "repeat ballot should return ErrAlreadyVoted, got %v", err)
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 1, recorded)
+
+		got, err := s.Get(ctx, poll.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, got.TotalVotes, "rejected ballots should not be counted")
+
+		voted, err := s.HasVoted(ctx, poll.ID, "voter-1")
+		require.NoError(t, err)
+		assert.True(t, voted)
+		voted, err = s.HasVoted(ctx, other.ID, "voter-1")
+		require.NoError(t, err)
+		assert.False(t, voted, "voter has not voted on the other poll yet")
+
+		// The same voter may vote on another poll, and ballots without a
+		// voter key are not limited.
+		ballot := newBallot(other.ID, other.Options[0].ID)
+		ballot.VoterKey = "voter-1"
+		_, err = s.RecordVote(ctx, ballot)
+		assert.NoError(t, err)
+		for i := 0; i < 2; i++ {
+			_, err = s.RecordVote(ctx, newBallot(poll.ID, poll.Options[0].ID))
+			assert.NoError(t, err)
+		}
+	})
+
+	t.Run("concurrent votes", func(t *testing.T) {
+		s := newStore(t)
+		poll := newPoll(time.Time{})
+		require.NoError(t, s.Create(ctx, poll))
+
+		const voters = 25
+		var wg sync.WaitGroup
+		for i := 0; i < voters; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := s.RecordVote(ctx, newBallot(poll.ID, poll.Options[1].ID))
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+
+		got, err := s.Get(ctx, poll.ID)
+		require.NoError(t, err)
+		assert.Equal(t, voters, got.Options[1].VoteCount, "no increments should be lost")
+		assert.Equal(t, voters, got.TotalVotes)
+	})
+
+	t.Run("outbox", func(t *testing.T) {
+		s := newStore(t)
+		now := time.Now().UTC().Truncate(time.Millisecond)
+		poll := newPoll(time.Time{})
+		created := models.NewEvent(models.EventPollCreated, now)
+		poll.Outbox = []models.Event{created}
+		require.NoError(t, s.Create(ctx, poll))
+		quiet := newPoll(time.Time{})
+		require.NoError(t, s.Create(ctx, quiet))
+
+		// Events are only returned by PendingEvents.
+		got, err := s.Get(ctx, poll.ID)
+		require.NoError(t, err)
+		assert.Empty(t, got.Outbox)
+
+		// Votes and updates queue their events in the same write.
+		ballot := newBallot(poll.ID, poll.Options[0].ID)
+		voted, err := s.RecordVote(ctx, ballot)
+		require.NoError(t, err)
+		assert.Empty(t, voted.Outbox)
+		closed := models.NewEvent(models.EventPollClosed, now)
+		got.Outbox = []models.Event{closed}
+		got.Version = 1
+		require.NoError(t, s.Update(ctx, got, 0))
+
+		pending, err := s.PendingEvents(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, pending, 1, "Polls without events are not pending")
+		assert.Equal(t, poll.ID, pending[0].ID)
+		assert.Equal(t, 1, pending[0].TotalVotes)
+		events := pending[0].Outbox
+		require.Len(t, events, 3)
+		assert.Equal(t, created.ID, events[0].ID)
+		assert.Equal(t, models.EventVoteCast, events[1].Type)
+		assert.Equal(t, ballot.ID, events[1].BallotID)
+		assert.Equal(t, closed.ID, events[2].ID)
+
+		// Deleted polls keep their events; acknowledged events are removed.
+		require.NoError(t, s.Delete(ctx, poll.ID, now))
+		require.NoError(t, s.AckEvents(ctx, poll.ID, []string{created.ID, events[1].ID}))
+		pending, err = s.PendingEvents(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, pending, 1)
+		assert.Equal(t, []models.Event{closed}, pending[0].Outbox)
+
+		require.NoError(t, s.AckEvents(ctx, poll.ID, []string{closed.ID}))
+		pending, err = s.PendingEvents(ctx, 10)
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+	})
+
+	t.Run("outbox order", func(t *testing.T) {
+		s := newStore(t)
+		now := time.Now().UTC().Truncate(time.Millisecond)
+		var ids []string
+		for _, age := range []time.Duration{time.Minute, 3 * time.Minute, 2 * time.Minute} {
+			poll := newPoll(time.Time{})
+			poll.Outbox = []models.Event{models.NewEvent(models.EventPollCreated, now.Add(-age))}
+			require.NoError(t, s.Create(ctx, poll))
+			ids = append(ids, poll.ID)
+		}
+
+		// Polls come oldest event first, so no poll's events wait behind
+		// newer ones when there are more than limit.
+		pending, err := s.PendingEvents(ctx, 2)
+		require.NoError(t, err)
+		require.Len(t, pending, 2)
+		assert.Equal(t, ids[1], pending[0].ID)
+		assert.Equal(t, ids[2], pending[1].ID)
+	})
+}
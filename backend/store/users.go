@@ -0,0 +1,28 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"instapoll/backend/models"
+)
+
+var (
+	// ErrUserNotFound is returned when the requested user does not exist
+	ErrUserNotFound = errors.New("user not found")
+	// ErrEmailTaken is returned when registering an email that already has an account
+	ErrEmailTaken = errors.New("email is already registered")
+)
+
+// UserStore is the storage used for user accounts.
+// Emails are stored normalized (see models.NormalizeEmail) and are unique.
+// Implementations must be safe for concurrent use.
+type UserStore interface {
+	// CreateUser stores a new user, or returns ErrEmailTaken.
+	CreateUser(ctx context.Context, user *models.User) error
+	// GetUser returns the user with the given ID, or ErrUserNotFound.
+	GetUser(ctx context.Context, id string) (*models.User, error)
+	// GetUserByEmail returns the user with the given normalized email,
+	// or ErrUserNotFound.
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+}
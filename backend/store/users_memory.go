@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+	"sync"
+
+	"instapoll/backend/models"
+)
+
+// MemoryUserStore is a UserStore that keeps accounts in process memory.
+// It is meant for tests and local development; data is lost on restart.
+type MemoryUserStore struct {
+	mu      sync.RWMutex
+	users   map[string]models.User
+	byEmail map[string]string // Email -> user ID
+}
+
+// NewMemoryUserStore creates an empty in-memory user store
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{
+		users:   make(map[string]models.User),
+		byEmail: make(map[string]string),
+	}
+}
+
+func (s *MemoryUserStore) CreateUser(ctx context.Context, user *models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.byEmail[user.Email]; ok {
+		return ErrEmailTaken
+	}
+	s.users[user.ID] = *user
+	s.byEmail[user.Email] = user.ID
+	return nil
+}
+
+func (s *MemoryUserStore) GetUser(ctx context.Context, id string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	user, ok := s.users[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return &user, nil
+}
+
+func (s *MemoryUserStore) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	id, ok := s.byEmail[email]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	user := s.users[id]
+	return &user, nil
+}
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"instapoll/backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoUserStore is a UserStore backed by a MongoDB collection
+type MongoUserStore struct {
+	users *mongo.Collection // User documents, keyed by user ID
+}
+
+// NewMongoUserStore creates a user store using the given collection
+func NewMongoUserStore(users *mongo.Collection) *MongoUserStore {
+	return &MongoUserStore{users: users}
+}
+
+// EnsureIndexes creates the unique email index that CreateUser relies on.
+// It is safe to call on every startup.
+func (s *MongoUserStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.users.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "email", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+func (s *MongoUserStore) CreateUser(ctx context.Context, user *models.User) error {
+	_, err := s.users.InsertOne(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrEmailTaken
+	}
+	return err
+}
+
+func (s *MongoUserStore) GetUser(ctx context.Context, id string) (*models.User, error) {
+	return s.findOne(ctx, bson.M{"_id": id})
+}
+
+func (s *MongoUserStore) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return s.findOne(ctx, bson.M{"email": email})
+}
+
+// findOne returns the user matching filter, or ErrUserNotFound
+func (s *MongoUserStore) findOne(ctx context.Context, filter bson.M) (*models.User, error) {
+	var user models.User
+	err := s.users.FindOne(ctx, filter).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
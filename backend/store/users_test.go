@@ -0,0 +1,63 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"instapoll/backend/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newUser returns a user with a fresh ID and the given email
+func newUser(email string) *models.User {
+	return &models.User{
+		ID:           uuid.New().String(),
+		Email:        email,
+		Name:         "Test User",
+		PasswordHash: "hash",
+		CreatedAt:    time.Now().UTC().Truncate(time.Millisecond),
+	}
+}
+
+// testUserStore runs the behaviour every UserStore implementation must share.
+// newStore must return an empty store.
+func testUserStore(t *testing.T, newStore func(t *testing.T) UserStore) {
+	ctx := context.Background()
+
+	t.Run("create and get", func(t *testing.T) {
+		s := newStore(t)
+		user := newUser("ada@example.com")
+		require.NoError(t, s.CreateUser(ctx, user))
+
+		got, err := s.GetUser(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, user, got)
+
+		got, err = s.GetUserByEmail(ctx, "ada@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, got.ID)
+
+		_, err = s.GetUser(ctx, uuid.New().String())
+		assert.True(t, errors.Is(err, ErrUserNotFound), "GetUser of a missing user should return ErrUserNotFound")
+		_, err = s.GetUserByEmail(ctx, "bob@example.com")
+		assert.True(t, errors.Is(err, ErrUserNotFound), "GetUserByEmail of a missing user should return ErrUserNotFound")
+	})
+
+	t.Run("email is unique", func(t *testing.T) {
+		s := newStore(t)
+		require.NoError(t, s.CreateUser(ctx, newUser("ada@example.com")))
+		err := s.CreateUser(ctx, newUser("ada@example.com"))
+		assert.True(t, errors.Is(err, ErrEmailTaken), "second account with the same email should return ErrEmailTaken")
+	})
+}
+
+func TestMemoryUserStore(t *testing.T) {
+	testUserStore(t, func(t *testing.T) UserStore {
+		return NewMemoryUserStore()
+	})
+}
@@ -0,0 +1,84 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"instapoll/backend/logging"
+	"instapoll/backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Delays between attempts to reopen a failed change stream
+const (
+	watchRetryMin = time.Second
+	watchRetryMax = 30 * time.Second
+)
+
+// WatchPolls follows the polls collection's change stream and calls fn with
+// the full document of every updated poll. This is how a replica learns
+// about votes recorded by other replicas, and about polls they opened or
+// closed.
+//
+// Change streams need MongoDB to run as a replica set; if the stream cannot
+// be opened at all, WatchPolls returns the error straight away. Once running
+// it blocks until ctx is cancelled, reopening the stream after the last seen
+// event whenever it fails.
+func (s *MongoStore) WatchPolls(ctx context.Context, fn func(*models.Poll)) error {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"operationType": bson.M{"$in": bson.A{"update", "replace"}}}}},
+	}
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+
+	stream, err := s.polls.Watch(ctx, pipeline, opts)
+	if err != nil {
+		return err
+	}
+
+	retry := watchRetryMin
+	for {
+		for stream.Next(ctx) {
+			retry = watchRetryMin
+			var event struct {
+				FullDocument *models.Poll `bson:"fullDocument"`
+			}
+			if err := stream.Decode(&event); err != nil {
+				logging.FromContext(ctx).Error("Failed to decode poll change event", "error", err)
+				continue
+			}
+			if event.FullDocument != nil {
+				fn(event.FullDocument)
+			}
+		}
+
+		resumeToken := stream.ResumeToken()
+		streamErr := stream.Err()
+		stream.Close(context.Background())
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		logging.FromContext(ctx).Warn("Poll change stream failed, reopening", "retry_in", retry, "error", streamErr)
+
+		// Keep trying to reopen the stream, resuming after the last event seen.
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(retry):
+			}
+			retry = min(retry*2, watchRetryMax)
+
+			if resumeToken != nil {
+				opts.SetResumeAfter(resumeToken)
+			}
+			stream, err = s.polls.Watch(ctx, pipeline, opts)
+			if err == nil {
+				break
+			}
+			logging.FromContext(ctx).Warn("Failed to reopen poll change stream", "retry_in", retry, "error", err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"instapoll/backend/models"
+)
+
+var (
+	// ErrWebhookNotFound is returned when the requested webhook does not exist
+	ErrWebhookNotFound = errors.New("webhook not found")
+	// ErrDeliveryNotFound is returned when the requested delivery does not exist
+	ErrDeliveryNotFound = errors.New("delivery not found")
+)
+
+// WebhookStore is the storage used for webhook subscriptions and the
+// deliveries of poll events to them.
+// Implementations must be safe for concurrent use.
+type WebhookStore interface {
+	// CreateWebhook stores a new webhook.
+	CreateWebhook(ctx context.Context, webhook *models.Webhook) error
+	// GetWebhook returns the webhook with the given ID, or ErrWebhookNotFound.
+	GetWebhook(ctx context.Context, id string) (*models.Webhook, error)
+	// ListWebhooks returns the user's webhooks, oldest first.
+	ListWebhooks(ctx context.Context, ownerID string) ([]models.Webhook, error)
+	// DeleteWebhook removes a webhook, or returns ErrWebhookNotFound. Its
+	// deliveries are kept for the log.
+	DeleteWebhook(ctx context.Context, id string) error
+	// Subscribers returns the webhooks receiving events of a poll created
+	// by ownerID: the owner's webhooks for every poll and for this one.
+	Subscribers(ctx context.Context, ownerID, pollID string) ([]models.Webhook, error)
+
+	// Enqueue stores new deliveries. Deliveries whose ID is already stored
+	// are skipped, so handing the same event on twice is harmless.
+	Enqueue(ctx context.Context, deliveries []models.Delivery) error
+	// DueDeliveries returns up to limit pending deliveries whose next
+	// attempt is due at now, the longest overdue first.
+	DueDeliveries(ctx context.Context, now time.Time, limit int) ([]models.Delivery, error)
+	// SaveDelivery replaces a stored delivery, or returns ErrDeliveryNotFound.
+	SaveDelivery(ctx context.Context, delivery *models.Delivery) error
+	// GetDelivery returns the delivery with the given ID, or ErrDeliveryNotFound.
+	GetDelivery(ctx context.Context, id string) (*models.Delivery, error)
+	// ListDeliveries returns up to limit of a webhook's deliveries, newest first.
+	ListDeliveries(ctx context.Context, webhookID string, limit int) ([]models.Delivery, error)
+}
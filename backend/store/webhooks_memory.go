@@ -0,0 +1,166 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"instapoll/backend/models"
+)
+
+// MemoryWebhookStore is a WebhookStore that keeps webhooks and deliveries
+// in process memory.
+// It is meant for tests and local development; data is lost on restart.
+type MemoryWebhookStore struct {
+	mu         sync.RWMutex
+	webhooks   map[string]models.Webhook
+	deliveries map[string]models.Delivery
+}
+
+// NewMemoryWebhookStore creates an empty in-memory webhook store
+func NewMemoryWebhookStore() *MemoryWebhookStore {
+	return &MemoryWebhookStore{
+		webhooks:   make(map[string]models.Webhook),
+		deliveries: make(map[string]models.Delivery),
+	}
+}
+
+// cloneDelivery copies a delivery so callers never share its slices
+func cloneDelivery(d models.Delivery) models.Delivery {
+	d.Payload = append([]byte(nil), d.Payload...)
+	d.Attempts = append([]models.DeliveryAttempt{}, d.Attempts...)
+	return d
+}
+
+func (s *MemoryWebhookStore) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hook := *webhook
+	hook.Events = append([]string(nil), webhook.Events...)
+	s.webhooks[webhook.ID] = hook
+	return nil
+}
+
+func (s *MemoryWebhookStore) GetWebhook(ctx context.Context, id string) (*models.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	webhook, ok := s.webhooks[id]
+	if !ok {
+		return nil, ErrWebhookNotFound
+	}
+	return &webhook, nil
+}
+
+func (s *MemoryWebhookStore) ListWebhooks(ctx context.Context, ownerID string) ([]models.Webhook, error) {
+	return s.find(func(w *models.Webhook) bool { return w.OwnerID == ownerID }), nil
+}
+
+func (s *MemoryWebhookStore) DeleteWebhook(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.webhooks[id]; !ok {
+		return ErrWebhookNotFound
+	}
+	delete(s.webhooks, id)
+	return nil
+}
+
+func (s *MemoryWebhookStore) Subscribers(ctx context.Context, ownerID, pollID string) ([]models.Webhook, error) {
+	return s.find(func(w *models.Webhook) bool {
+		return w.OwnerID == ownerID && (w.PollID == "" || w.PollID == pollID)
+	}), nil
+}
+
+// find returns the webhooks matching keep, oldest first
+func (s *MemoryWebhookStore) find(keep func(*models.Webhook) bool) []models.Webhook {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	found := []models.Webhook{}
+	for _, webhook := range s.webhooks {
+		if keep(&webhook) {
+			found = append(found, webhook)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if !found[i].CreatedAt.Equal(found[j].CreatedAt) {
+			return found[i].CreatedAt.Before(found[j].CreatedAt)
+		}
+		return found[i].ID < found[j].ID
+	})
+	return found
+}
+
+func (s *MemoryWebhookStore) Enqueue(ctx context.Context, deliveries []models.Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, delivery := range deliveries {
+		if _, ok := s.deliveries[delivery.ID]; !ok {
+			s.deliveries[delivery.ID] = cloneDelivery(delivery)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryWebhookStore) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]models.Delivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	due := []models.Delivery{}
+	for _, delivery := range s.deliveries {
+		if delivery.Status == models.DeliveryPending && !delivery.NextAttemptAt.After(now) {
+			due = append(due, cloneDelivery(delivery))
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].NextAttemptAt.Equal(due[j].NextAttemptAt) {
+			return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+		}
+		return due[i].ID < due[j].ID
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+func (s *MemoryWebhookStore) SaveDelivery(ctx context.Context, delivery *models.Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.deliveries[delivery.ID]; !ok {
+		return ErrDeliveryNotFound
+	}
+	s.deliveries[delivery.ID] = cloneDelivery(*delivery)
+	return nil
+}
+
+func (s *MemoryWebhookStore) GetDelivery(ctx context.Context, id string) (*models.Delivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	delivery, ok := s.deliveries[id]
+	if !ok {
+		return nil, ErrDeliveryNotFound
+	}
+	delivery = cloneDelivery(delivery)
+	return &delivery, nil
+}
+
+func (s *MemoryWebhookStore) ListDeliveries(ctx context.Context, webhookID string, limit int) ([]models.Delivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	deliveries := []models.Delivery{}
+	for _, delivery := range s.deliveries {
+		if delivery.WebhookID == webhookID {
+			deliveries = append(deliveries, cloneDelivery(delivery))
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if !deliveries[i].CreatedAt.Equal(deliveries[j].CreatedAt) {
+			return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+		}
+		return deliveries[i].ID > deliveries[j].ID
+	})
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,179 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"instapoll/backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoWebhookStore is a WebhookStore backed by MongoDB collections
+type MongoWebhookStore struct {
+	webhooks   *mongo.Collection // One document per webhook
+	deliveries *mongo.Collection // One document per event and webhook
+}
+
+// NewMongoWebhookStore creates a webhook store using the given webhook and
+// delivery collections
+func NewMongoWebhookStore(webhooks, deliveries *mongo.Collection) *MongoWebhookStore {
+	return &MongoWebhookStore{webhooks: webhooks, deliveries: deliveries}
+}
+
+// EnsureIndexes creates the indexes the store's queries rely on.
+// It is safe to call on every startup.
+func (s *MongoWebhookStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.webhooks.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "owner_id", Value: 1}, {Key: "created_at", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.deliveries.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		// DueDeliveries
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+		// ListDeliveries
+		{Keys: bson.D{{Key: "webhook_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+	return err
+}
+
+func (s *MongoWebhookStore) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	_, err := s.webhooks.InsertOne(ctx, webhook)
+	return err
+}
+
+func (s *MongoWebhookStore) GetWebhook(ctx context.Context, id string) (*models.Webhook, error) {
+	var webhook models.Webhook
+	err := s.webhooks.FindOne(ctx, bson.M{"_id": id}).Decode(&webhook)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (s *MongoWebhookStore) ListWebhooks(ctx context.Context, ownerID string) ([]models.Webhook, error) {
+	return s.findWebhooks(ctx, bson.M{"owner_id": ownerID})
+}
+
+func (s *MongoWebhookStore) DeleteWebhook(ctx context.Context, id string) error {
+	res, err := s.webhooks.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (s *MongoWebhookStore) Subscribers(ctx context.Context, ownerID, pollID string) ([]models.Webhook, error) {
+	return s.findWebhooks(ctx, bson.M{
+		"owner_id": ownerID,
+		"$or": bson.A{
+			bson.M{"poll_id": bson.M{"$exists": false}},
+			bson.M{"poll_id": pollID},
+		},
+	})
+}
+
+// findWebhooks returns the webhooks matching filter, oldest first
+func (s *MongoWebhookStore) findWebhooks(ctx context.Context, filter bson.M) ([]models.Webhook, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := s.webhooks.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	webhooks := []models.Webhook{}
+	if err := cursor.All(ctx, &webhooks); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (s *MongoWebhookStore) Enqueue(ctx context.Context, deliveries []models.Delivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	docs := make([]interface{}, len(deliveries))
+	for i := range deliveries {
+		docs[i] = deliveries[i]
+	}
+	// Insert everything, reporting only errors other than deliveries that
+	// are already stored.
+	_, err := s.deliveries.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+		for _, writeErr := range bulkErr.WriteErrors {
+			if !mongo.IsDuplicateKeyError(writeErr) {
+				return err
+			}
+		}
+		return nil
+	}
+	return err
+}
+
+func (s *MongoWebhookStore) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]models.Delivery, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "next_attempt_at", Value: 1}, {Key: "_id", Value: 1}}).
+		SetLimit(int64(limit))
+	return s.findDeliveries(ctx, bson.M{
+		"status":          models.DeliveryPending,
+		"next_attempt_at": bson.M{"$lte": now},
+	}, opts)
+}
+
+func (s *MongoWebhookStore) SaveDelivery(ctx context.Context, delivery *models.Delivery) error {
+	res, err := s.deliveries.ReplaceOne(ctx, bson.M{"_id": delivery.ID}, delivery)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrDeliveryNotFound
+	}
+	return nil
+}
+
+func (s *MongoWebhookStore) GetDelivery(ctx context.Context, id string) (*models.Delivery, error) {
+	var delivery models.Delivery
+	err := s.deliveries.FindOne(ctx, bson.M{"_id": id}).Decode(&delivery)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (s *MongoWebhookStore) ListDeliveries(ctx context.Context, webhookID string, limit int) ([]models.Delivery, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(limit))
+	return s.findDeliveries(ctx, bson.M{"webhook_id": webhookID}, opts)
+}
+
+// findDeliveries returns the deliveries matching filter
+func (s *MongoWebhookStore) findDeliveries(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]models.Delivery, error) {
+	cursor, err := s.deliveries.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	deliveries := []models.Delivery{}
+	if err := cursor.All(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
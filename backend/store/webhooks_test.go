@@ -0,0 +1,136 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"instapoll/backend/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newWebhook returns a webhook of the given owner, for one poll or all if
+// pollID is empty
+func newWebhook(ownerID, pollID string, created time.Time) *models.Webhook {
+	return &models.Webhook{
+		ID:        uuid.New().String(),
+		OwnerID:   ownerID,
+		PollID:    pollID,
+		URL:       "https://example.com/hooks",
+		Secret:    "secret",
+		CreatedAt: created,
+	}
+}
+
+// newDelivery returns a pending delivery to the webhook, due at the given time
+func newDelivery(webhookID string, due time.Time) models.Delivery {
+	eventID := uuid.New().String()
+	return models.Delivery{
+		ID:            models.DeliveryID(eventID, webhookID),
+		WebhookID:     webhookID,
+		EventID:       eventID,
+		EventType:     models.EventVoteCast,
+		PollID:        "poll",
+		Payload:       json.RawMessage(`{"id":"` + eventID + `"}`),
+		Status:        models.DeliveryPending,
+		NextAttemptAt: due,
+		CreatedAt:     due,
+	}
+}
+
+// testWebhookStore runs the behaviour every WebhookStore implementation must
+// share. newStore must return an empty store.
+func testWebhookStore(t *testing.T, newStore func(t *testing.T) WebhookStore) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Millisecond) // MongoDB stores millisecond precision
+
+	t.Run("webhooks", func(t *testing.T) {
+		s := newStore(t)
+		all := newWebhook("ada", "", now)
+		all.Events = []string{models.EventPollClosed}
+		one := newWebhook("ada", "poll-1", now.Add(time.Second))
+		other := newWebhook("ada", "poll-2", now.Add(2*time.Second))
+		someoneElse := newWebhook("bob", "", now)
+		for _, webhook := range []*models.Webhook{all, one, other, someoneElse} {
+			require.NoError(t, s.CreateWebhook(ctx, webhook))
+		}
+
+		got, err := s.GetWebhook(ctx, all.ID)
+		require.NoError(t, err)
+		assert.Equal(t, all, got)
+
+		list, err := s.ListWebhooks(ctx, "ada")
+		require.NoError(t, err)
+		require.Len(t, list, 3)
+		assert.Equal(t, []string{all.ID, one.ID, other.ID}, []string{list[0].ID, list[1].ID, list[2].ID})
+
+		subscribers, err := s.Subscribers(ctx, "ada", "poll-1")
+		require.NoError(t, err)
+		require.Len(t, subscribers, 2, "Account-wide webhooks and the poll's own")
+		assert.Equal(t, all.ID, subscribers[0].ID)
+		assert.Equal(t, one.ID, subscribers[1].ID)
+
+		require.NoError(t, s.DeleteWebhook(ctx, one.ID))
+		_, err = s.GetWebhook(ctx, one.ID)
+		assert.True(t, errors.Is(err, ErrWebhookNotFound), "GetWebhook of a deleted webhook should return ErrWebhookNotFound")
+		assert.True(t, errors.Is(s.DeleteWebhook(ctx, one.ID), ErrWebhookNotFound))
+	})
+
+	t.Run("deliveries", func(t *testing.T) {
+		s := newStore(t)
+		early := newDelivery("hook", now.Add(-time.Minute))
+		due := newDelivery("hook", now)
+		later := newDelivery("hook", now.Add(time.Minute))
+		require.NoError(t, s.Enqueue(ctx, []models.Delivery{due, later}))
+
+		// Enqueueing again skips what is already stored.
+		changed := due
+		changed.Payload = json.RawMessage(`{"changed":true}`)
+		require.NoError(t, s.Enqueue(ctx, []models.Delivery{changed, early}))
+		got, err := s.GetDelivery(ctx, due.ID)
+		require.NoError(t, err)
+		assert.JSONEq(t, string(due.Payload), string(got.Payload))
+
+		pending, err := s.DueDeliveries(ctx, now, 10)
+		require.NoError(t, err)
+		require.Len(t, pending, 2)
+		assert.Equal(t, early.ID, pending[0].ID, "The longest overdue comes first")
+		assert.Equal(t, due.ID, pending[1].ID)
+
+		// Finished deliveries are no longer due.
+		early.Status = models.DeliverySucceeded
+		early.Attempts = []models.DeliveryAttempt{{At: now, StatusCode: 204}}
+		require.NoError(t, s.SaveDelivery(ctx, &early))
+		pending, err = s.DueDeliveries(ctx, now, 10)
+		require.NoError(t, err)
+		require.Len(t, pending, 1)
+		assert.Equal(t, due.ID, pending[0].ID)
+
+		log, err := s.ListDeliveries(ctx, "hook", 2)
+		require.NoError(t, err)
+		require.Len(t, log, 2)
+		assert.Equal(t, later.ID, log[0].ID, "Newest first")
+		assert.Equal(t, due.ID, log[1].ID)
+
+		got, err = s.GetDelivery(ctx, early.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.DeliverySucceeded, got.Status)
+		assert.Equal(t, early.Attempts, got.Attempts)
+
+		missing := newDelivery("hook", now)
+		assert.True(t, errors.Is(s.SaveDelivery(ctx, &missing), ErrDeliveryNotFound))
+		_, err = s.GetDelivery(ctx, missing.ID)
+		assert.True(t, errors.Is(err, ErrDeliveryNotFound))
+	})
+}
+
+func TestMemoryWebhookStore(t *testing.T) {
+	testWebhookStore(t, func(t *testing.T) WebhookStore {
+		return NewMemoryWebhookStore()
+	})
+}
@@ -0,0 +1,65 @@
+package tabulation
+
+import (
+	"errors"
+	"fmt"
+)
+
+// approval lets each voter approve any number of options, or a number
+// between the poll's MinSelections and MaxSelections; the option approved
+// by the most voters wins.
+type approval struct{}
+
+func init() {
+	Register(approval{})
+}
+
+func (approval) Name() string { return "approval" }
+
+func (approval) ValidateSettings(s Settings, numOptions int) error {
+	if s.MinSelections < 0 || s.MinSelections > numOptions {
+		return fmt.Errorf("min_selections must be between 0 and the number of options (%d)", numOptions)
+	}
+	if s.MaxSelections < 0 || s.MaxSelections > numOptions {
+		return fmt.Errorf("max_selections must be between 0 and the number of options (%d)", numOptions)
+	}
+	if s.MaxSelections > 0 && s.MinSelections > s.MaxSelections {
+		return errors.New("min_selections cannot be greater than max_selections")
+	}
+	return nil
+}
+
+func (approval) ValidateBallot(c Contest, b Ballot) error {
+	if len(b.Approvals) == 0 {
+		return errors.New("ballot must approve at least one option")
+	}
+	if least := c.Settings.MinSelections; len(b.Approvals) < least {
+		return fmt.Errorf("ballot must approve at least %d options", least)
+	}
+	if most := c.Settings.MaxSelections; most > 0 && len(b.Approvals) > most {
+		return fmt.Errorf("ballot can approve at most %d options", most)
+	}
+	return validateOptionSet(c, b.Approvals, "approves")
+}
+
+func (approval) Tabulate(c Contest, ballots []Ballot) Result {
+	tallies := make(map[string]float64, len(c.Options))
+	for _, id := range c.Options {
+		tallies[id] = 0
+	}
+	for _, b := range ballots {
+		for _, id := range b.Approvals {
+			tallies[id]++
+		}
+	}
+
+	result := tallyResult("approval", c, ballots, tallies)
+	result.Percentages = make(map[string]float64, len(tallies))
+	for id, approvals := range tallies {
+		result.Percentages[id] = 0
+		if len(ballots) > 0 {
+			result.Percentages[id] = approvals * 100 / float64(len(ballots))
+		}
+	}
+	return result
+}
@@ -0,0 +1,101 @@
+package tabulation
+
+import (
+	"fmt"
+)
+
+// Borda point schemes
+const (
+	// BordaStandard gives n-1 points for first place on a poll with n
+	// options, n-2 for second and so on down to 0 for last.
+	BordaStandard = "standard"
+	// BordaDowdall gives 1 point for first place, 1/2 for second, 1/3 for
+	// third and so on, favouring options that voters rank near the top.
+	BordaDowdall = "dowdall"
+)
+
+// Truncation rules for ballots that leave options unranked
+const (
+	// TruncationZero gives unranked options no points. Voters can help
+	// their favourites by ranking only them.
+	TruncationZero = "zero"
+	// TruncationAverage treats the unranked options as tied for the
+	// remaining places, sharing those places' points equally.
+	TruncationAverage = "average"
+	// TruncationReject only accepts ballots that rank every option.
+	TruncationReject = "reject"
+)
+
+// borda is the Borda count: each ballot awards points to options by the
+// place it ranks them in, under the poll's BordaScheme, and the option with
+// the most points wins. Options tied on a ballot share the points of the
+// places they take up; unranked options are scored by the poll's
+// Truncation rule. Options with equal points are ordered by the poll's
+// tie-break policy.
+type borda struct{}
+
+func init() {
+	Register(borda{})
+}
+
+func (borda) Name() string { return "borda" }
+
+func (borda) ValidateSettings(s Settings, numOptions int) error {
+	switch s.BordaScheme {
+	case "", BordaStandard, BordaDowdall:
+	default:
+		return fmt.Errorf("borda_scheme must be %q or %q", BordaStandard, BordaDowdall)
+	}
+	return validateTruncation(s)
+}
+
+func (borda) ValidateBallot(c Contest, b Ballot) error {
+	return validateTieredRanking(c, b)
+}
+
+func (borda) Tabulate(c Contest, ballots []Ballot) Result {
+	n := len(c.Options)
+	points := func(place int) float64 {
+		if c.Settings.BordaScheme == BordaDowdall {
+			return 1 / float64(place+1)
+		}
+		return float64(n - 1 - place)
+	}
+	// share awards each option the average points of the places from
+	// first to first+len(ids)-1
+	tallies := make(map[string]float64, n)
+	share := func(ids []string, first int) {
+		total := 0.0
+		for place := first; place < first+len(ids); place++ {
+			total += points(place)
+		}
+		for _, id := range ids {
+			tallies[id] += total / float64(len(ids))
+		}
+	}
+
+	for _, id := range c.Options {
+		tallies[id] = 0
+	}
+	for _, b := range ballots {
+		place := 0
+		ranked := make(map[string]bool, n)
+		for _, tier := range b.tiers() {
+			share(tier, place)
+			place += len(tier)
+			for _, id := range tier {
+				ranked[id] = true
+			}
+		}
+		if c.Settings.Truncation == TruncationAverage && place < n {
+			var unranked []string
+			for _, id := range c.Options {
+				if !ranked[id] {
+					unranked = append(unranked, id)
+				}
+			}
+			share(unranked, place)
+		}
+	}
+	return tallyResult("borda", c, ballots, tallies)
+}
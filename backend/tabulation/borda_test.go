@@ -0,0 +1,140 @@
+package tabulation
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestBordaSchemes(t *testing.T) {
+	options := []string{"a", "b", "c", "d"}
+	ballots := []Ballot{
+		{Ranking: []string{"a", "b", "c", "d"}},
+		{Ranking: []string{"b"}},
+		{Tiers: [][]string{{"c", "d"}, {"a"}}},
+	}
+
+	tests := []struct {
+		name     string
+		settings Settings
+		want     map[string]float64
+	}{
+		{
+			// a: 3+0+1, b: 2+3, c: 1+2.5, d: 0+2.5
+			name:     "standard",
+			settings: Settings{AllowTies: true},
+			want:     map[string]float64{"a": 4, "b": 5, "c": 3.5, "d": 2.5},
+		},
+		{
+			// The second ballot's unranked a, c and d share 2+1+0 points, and
+			// the third ballot's unranked b takes the 0 for last place.
+			name:     "standard averaging unranked options",
+			settings: Settings{AllowTies: true, Truncation: TruncationAverage},
+			want:     map[string]float64{"a": 5, "b": 5, "c": 4.5, "d": 3.5},
+		},
+		{
+			// a: 1+0+1/3, b: 1/2+1, c: 1/3+3/4, d: 1/4+3/4
+			name:     "dowdall",
+			settings: Settings{AllowTies: true, BordaScheme: BordaDowdall},
+			want:     map[string]float64{"a": 4.0 / 3, "b": 1.5, "c": 13.0 / 12, "d": 1},
+		},
+	}
+
+	m, _ := Lookup("borda")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := m.Tabulate(Contest{Options: options, Settings: tt.settings}, ballots)
+			for id, want := range tt.want {
+				if got := result.Tallies[id]; math.Abs(got-want) > 1e-9 {
+					t.Errorf("Borda points of %s = %v, want %v", id, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRankingRules(t *testing.T) {
+	options := []string{"a", "b", "c"}
+
+	tests := []struct {
+		name     string
+		settings Settings
+		ballot   Ballot
+		wantErr  bool
+	}{
+		{name: "partial ranking allowed", ballot: Ballot{Ranking: []string{"a"}}},
+		{name: "partial ranking rejected", settings: Settings{Truncation: TruncationReject}, ballot: Ballot{Ranking: []string{"a", "b"}}, wantErr: true},
+		{name: "full ranking when required", settings: Settings{Truncation: TruncationReject}, ballot: Ballot{Ranking: []string{"c", "a", "b"}}},
+		{name: "ties not allowed", ballot: Ballot{Tiers: [][]string{{"a", "b"}, {"c"}}}, wantErr: true},
+		{name: "ties allowed", settings: Settings{AllowTies: true}, ballot: Ballot{Tiers: [][]string{{"a", "b"}, {"c"}}}},
+		{name: "tied options must all be ranked", settings: Settings{AllowTies: true, Truncation: TruncationReject}, ballot: Ballot{Tiers: [][]string{{"a", "b"}}}, wantErr: true},
+		{name: "empty tier", settings: Settings{AllowTies: true}, ballot: Ballot{Tiers: [][]string{{"a"}, {}}}, wantErr: true},
+		{name: "option in two tiers", settings: Settings{AllowTies: true}, ballot: Ballot{Tiers: [][]string{{"a"}, {"a", "b"}}}, wantErr: true},
+		{name: "ranking and tiers", settings: Settings{AllowTies: true}, ballot: Ballot{Ranking: []string{"a"}, Tiers: [][]string{{"b"}}}, wantErr: true},
+	}
+
+	for _, name := range []string{"borda", "copeland"} {
+		m, _ := Lookup(name)
+		for _, tt := range tests {
+			t.Run(name+" "+tt.name, func(t *testing.T) {
+				err := m.ValidateBallot(Contest{Options: options, Settings: tt.settings}, tt.ballot)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("%s.ValidateBallot() error = %v, wantErr %v", name, err, tt.wantErr)
+				}
+			})
+		}
+	}
+
+	// Other ranked methods never accept tiers.
+	irv, _ := Lookup("irv")
+	if err := irv.ValidateBallot(Contest{Options: options, Settings: Settings{AllowTies: true}}, Ballot{Tiers: [][]string{{"a", "b"}}}); err == nil {
+		t.Errorf("irv.ValidateBallot() accepted a ballot with ties")
+	}
+}
+
+func TestBordaValidateSettings(t *testing.T) {
+	m, _ := Lookup("borda")
+	if err := m.ValidateSettings(Settings{BordaScheme: BordaDowdall, Truncation: TruncationAverage}, 3); err != nil {
+		t.Errorf("borda.ValidateSettings(dowdall, average) error = %v", err)
+	}
+	if err := m.ValidateSettings(Settings{BordaScheme: "nauru"}, 3); err == nil {
+		t.Errorf("borda.ValidateSettings(unknown scheme) expected an error")
+	}
+	if err := m.ValidateSettings(Settings{Truncation: "ignore"}, 3); err == nil {
+		t.Errorf("borda.ValidateSettings(unknown truncation) expected an error")
+	}
+}
+
+func TestCopelandValidateSettings(t *testing.T) {
+	m, _ := Lookup("copeland")
+	if err := m.ValidateSettings(Settings{Truncation: TruncationReject}, 3); err != nil {
+		t.Errorf("copeland.ValidateSettings(reject) error = %v", err)
+	}
+	if err := m.ValidateSettings(Settings{Truncation: TruncationAverage}, 3); err == nil {
+		t.Errorf("copeland.ValidateSettings(average) expected an error")
+	}
+}
+
+func TestCopeland(t *testing.T) {
+	// a beats b and c; b and c tie head-to-head once the tied ballot is
+	// counted, so they share half a point each.
+	contest := Contest{Options: []string{"a", "b", "c"}, Settings: Settings{AllowTies: true}}
+	ballots := []Ballot{
+		{Ranking: []string{"a", "b", "c"}},
+		{Ranking: []string{"c", "a", "b"}},
+		{Tiers: [][]string{{"a"}, {"b", "c"}}},
+	}
+
+	m, _ := Lookup("copeland")
+	result := m.Tabulate(contest, ballots)
+
+	if want := map[string]float64{"a": 2, "b": 0.5, "c": 0.5}; !reflect.DeepEqual(result.Tallies, want) {
+		t.Errorf("Copeland scores = %v, want %v", result.Tallies, want)
+	}
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(result.Ranking, want) {
+		t.Errorf("Copeland ranking = %v, want %v", result.Ranking, want)
+	}
+	if result.Pairwise["b"]["c"] != 1 || result.Pairwise["c"]["b"] != 1 {
+		t.Errorf("Pairwise b/c = %d/%d, want 1/1", result.Pairwise["b"]["c"], result.Pairwise["c"]["b"])
+	}
+}
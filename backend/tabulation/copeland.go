@@ -0,0 +1,53 @@
+package tabulation
+
+import "fmt"
+
+// copeland is Copeland's method, a Condorcet method: every option scores 1
+// point for each other option it beats head-to-head and half a point for
+// each it ties with, and the option with the most points wins. Ballots may
+// leave options unranked, which places them below every ranked option, and
+// options tied on a ballot are not preferred either way. Options with equal
+// points are ordered by the poll's tie-break policy.
+//
+// TruncationAverage shares out Borda points, so Copeland has no use for it:
+// unranked options are already tied with each other below the ranked ones.
+type copeland struct{}
+
+func init() {
+	Register(copeland{})
+}
+
+func (copeland) Name() string { return "copeland" }
+
+func (copeland) ValidateSettings(s Settings, numOptions int) error {
+	if s.Truncation == TruncationAverage {
+		return fmt.Errorf("truncation must be %q or %q for copeland", TruncationZero, TruncationReject)
+	}
+	return validateTruncation(s)
+}
+
+func (copeland) ValidateBallot(c Contest, b Ballot) error {
+	return validateTieredRanking(c, b)
+}
+
+func (copeland) Tabulate(c Contest, ballots []Ballot) Result {
+	d := pairwiseCounts(c.Options, ballots)
+
+	tallies := make(map[string]float64, len(c.Options))
+	for i, a := range c.Options {
+		tallies[a] = 0
+		for j := range c.Options {
+			switch {
+			case i == j:
+			case d[i][j] > d[j][i]:
+				tallies[a]++
+			case d[i][j] == d[j][i]:
+				tallies[a] += 0.5
+			}
+		}
+	}
+
+	result := tallyResult("copeland", c, ballots, tallies)
+	result.Pairwise = pairwiseMap(c.Options, d)
+	return result
+}
@@ -0,0 +1,555 @@
+package tabulation
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Actions of explanation steps
+const (
+	StepCount     = "count"     // Ballots or votes were counted
+	StepElect     = "elect"     // An option won or was elected
+	StepEliminate = "eliminate" // An option was eliminated
+	StepTransfer  = "transfer"  // Votes moved between options
+	StepTieBreak  = "tie_break" // A tie was broken
+	StepRunoff    = "runoff"    // The STAR runoff
+	StepLock      = "lock"      // Ranked Pairs locked in a majority
+	StepSkip      = "skip"      // Ranked Pairs skipped a majority
+	StepCompare   = "compare"   // Two options were compared head-to-head
+)
+
+// Step is one step of an explanation
+type Step struct {
+	Round   int      `json:"round,omitempty"`   // Counting round, for methods that count in rounds
+	Action  string   `json:"action"`            // One of the Step constants
+	Options []string `json:"options,omitempty"` // IDs of the options the step is about
+	Text    string   `json:"text"`
+}
+
+// Explanation is a step-by-step, plain-language account of a Result
+type Explanation struct {
+	Method   string `json:"method"`
+	Summary  string `json:"summary"`
+	Steps    []Step `json:"steps"`
+	Markdown string `json:"markdown"` // The same explanation as a Markdown document
+}
+
+// Explain describes how a Result was reached. It only reads the result,
+// so the explanation always matches what the results show. names maps
+// option IDs to the text shown to voters; IDs without a name are shown as
+// they are.
+func Explain(r Result, names map[string]string) Explanation {
+	e := &explainer{result: r, names: names}
+	e.explain()
+	explanation := Explanation{Method: r.Method, Summary: e.summary(), Steps: e.steps}
+	if explanation.Steps == nil {
+		explanation.Steps = []Step{}
+	}
+	explanation.Markdown = explanation.markdown()
+	return explanation
+}
+
+// explainer builds the steps of an explanation
+type explainer struct {
+	result Result
+	names  map[string]string
+	steps  []Step
+}
+
+// name returns an option's display text, quoted
+func (e *explainer) name(id string) string {
+	if name, ok := e.names[id]; ok && name != "" {
+		return strconv.Quote(name)
+	}
+	return strconv.Quote(id)
+}
+
+// list joins the names of several options: "A", "B" and "C"
+func (e *explainer) list(ids []string) string {
+	quoted := make([]string, len(ids))
+	for i, id := range ids {
+		quoted[i] = e.name(id)
+	}
+	if len(quoted) <= 1 {
+		return strings.Join(quoted, "")
+	}
+	return strings.Join(quoted[:len(quoted)-1], ", ") + " and " + quoted[len(quoted)-1]
+}
+
+func (e *explainer) add(round int, action string, options []string, format string, args ...any) {
+	e.steps = append(e.steps, Step{Round: round, Action: action, Options: options, Text: fmt.Sprintf(format, args...)})
+}
+
+// number formats a vote value without trailing zeros
+func number(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64)
+}
+
+// plural returns "1 ballot" or "2 ballots"
+func plural(n float64, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return number(n) + " " + noun + "s"
+}
+
+func (e *explainer) summary() string {
+	r := e.result
+	switch {
+	case r.TotalBallots == 0:
+		return "No ballots have been cast yet."
+	case r.Method == "stv":
+		return fmt.Sprintf("%s elected from %s.", e.list(r.Winners), plural(float64(r.TotalBallots), "ballot"))
+	case len(r.Winners) > 0:
+		return fmt.Sprintf("%s wins with %s counted.", e.name(r.Winners[0]), plural(float64(r.TotalBallots), "ballot"))
+	default:
+		return "No option won."
+	}
+}
+
+func (e *explainer) explain() {
+	r := e.result
+	if r.TotalBallots == 0 {
+		return
+	}
+	e.add(0, StepCount, nil, "%s were counted using %s.", plural(float64(r.TotalBallots), "ballot"), methodNames[r.Method])
+
+	switch r.Method {
+	case "irv":
+		e.explainIRV()
+	case "stv":
+		e.explainSTV()
+	case "schulze":
+		e.explainSchulze()
+	case "ranked_pairs":
+		e.explainRankedPairs()
+	case "star":
+		e.explainTallies("total score")
+		e.explainRunoff()
+	case "majority_judgment":
+		e.explainMajorityJudgment()
+	default:
+		e.explainTallies(tallyUnits[r.Method])
+		e.explainTallyTie()
+	}
+}
+
+// methodNames are the names methods are described by
+var methodNames = map[string]string{
+	"plurality":         "plurality voting",
+	"approval":          "approval voting",
+	"borda":             "the Borda count",
+	"copeland":          "Copeland's method",
+	"irv":               "instant-runoff voting",
+	"stv":               "the single transferable vote",
+	"schulze":           "the Schulze method",
+	"ranked_pairs":      "Ranked Pairs",
+	"score":             "score voting",
+	"star":              "STAR voting",
+	"majority_judgment": "Majority Judgment",
+}
+
+// tallyUnits describe what a method's tallies count
+var tallyUnits = map[string]string{
+	"plurality": "vote",
+	"approval":  "approval",
+	"borda":     "point",
+	"copeland":  "point",
+	"score":     "average score",
+}
+
+// explainTallies lists each option's tally, best first
+func (e *explainer) explainTallies(unit string) {
+	r := e.result
+	parts := make([]string, len(r.Ranking))
+	for i, id := range r.Ranking {
+		switch {
+		case r.Percentages != nil:
+			parts[i] = fmt.Sprintf("%s %s (%s%% of voters)", e.name(id), plural(r.Tallies[id], unit), number(r.Percentages[id]))
+		case strings.Contains(unit, " "):
+			parts[i] = fmt.Sprintf("%s %s %s", e.name(id), unit, number(r.Tallies[id]))
+		default:
+			parts[i] = fmt.Sprintf("%s %s", e.name(id), plural(r.Tallies[id], unit))
+		}
+	}
+	e.add(0, StepCount, r.Ranking, "Results: %s.", strings.Join(parts, ", "))
+}
+
+// explainTallyTie explains the winner of a method with one tally per
+// option, including the tie-break if the top options tied
+func (e *explainer) explainTallyTie() {
+	r := e.result
+	if len(r.Winners) == 0 {
+		return
+	}
+	winner := r.Winners[0]
+	e.explainWinnerTie(0, "are tied for first place", nil)
+	e.add(0, StepElect, []string{winner}, "%s has the highest total and wins.", e.name(winner))
+}
+
+func (e *explainer) explainIRV() {
+	r := e.result
+	history := make([]map[string]float64, 0, len(r.Rounds))
+	for _, round := range r.Rounds {
+		tallies := make(map[string]float64, len(round.Tallies))
+		for id, v := range round.Tallies {
+			tallies[id] = float64(v)
+		}
+		history = append(history, tallies)
+
+		e.add(round.Round, StepCount, nil, "%s", e.describeTallies(tallies, "vote"))
+		if round.Eliminated == "" {
+			for _, id := range r.Winners {
+				if len(tallies) == 1 {
+					e.add(round.Round, StepElect, []string{id}, "%s is the only option left and wins.", e.name(id))
+				} else {
+					e.add(round.Round, StepElect, []string{id}, "%s has %s, at least the %s needed for a majority of the %s still counting, and wins.",
+						e.name(id), plural(tallies[id], "vote"), number(float64(round.Threshold)), plural(float64(r.TotalBallots-round.Exhausted), "ballot"))
+				}
+			}
+			continue
+		}
+
+		e.add(round.Round, StepEliminate, []string{round.Eliminated}, "No option has a majority (%s needed). %s has the fewest votes and is eliminated.",
+			number(float64(round.Threshold)), e.name(round.Eliminated))
+		e.explainLastPlaceTie(round.Round, history[:len(history)-1])
+		transfers := make(map[string]float64, len(round.Transfers))
+		for id, v := range round.Transfers {
+			transfers[id] = float64(v)
+		}
+		e.explainTransfers(round.Round, round.Eliminated, transfers, float64(round.ExhaustedByTransfer), "Its ballots")
+	}
+}
+
+func (e *explainer) explainSTV() {
+	r := e.result
+	history := make([]map[string]float64, 0, len(r.STVRounds))
+	elected := 0
+	seats := 0
+	if len(r.STVRounds) > 0 {
+		seats = len(r.Winners)
+	}
+	for _, round := range r.STVRounds {
+		history = append(history, round.Tallies)
+		e.add(round.Round, StepCount, nil, "The quota is %s. %s", number(round.Quota), e.describeTallies(round.Tallies, "vote"))
+
+		e.explainWinnerTie(round.Round, "reach the quota with the same votes, with too few seats left for all of them", history[:len(history)-1])
+		for _, id := range round.Elected {
+			elected++
+			if round.Tallies[id] >= round.Quota {
+				e.add(round.Round, StepElect, []string{id}, "%s reaches the quota with %s and is elected.", e.name(id), plural(round.Tallies[id], "vote"))
+			} else {
+				e.add(round.Round, StepElect, []string{id}, "%s is elected because the options still in the count only just fill the remaining seats.", e.name(id))
+			}
+		}
+		if elected == seats && round.Surplus == "" && round.Eliminated == "" {
+			continue
+		}
+
+		if round.Surplus != "" {
+			if tb, ok := e.tieBreak(round.Round, TieSurplus); ok {
+				e.add(round.Round, StepTieBreak, tb.Tied, "%s have equal surpluses; %s is transferred first because %s.",
+					e.list(tb.Tied), e.name(round.Surplus), e.tieReason(tb, history[:len(history)-1]))
+			}
+			surplus := round.Tallies[round.Surplus] - round.Quota
+			e.add(round.Round, StepTransfer, []string{round.Surplus}, "%s has a surplus of %s over the quota, which moves to the next preferences on its ballots at reduced weight.",
+				e.name(round.Surplus), plural(surplus, "vote"))
+			e.explainTransfers(round.Round, round.Surplus, round.Transfers, round.ExhaustedByTransfer, "Its surplus")
+		}
+		if round.Eliminated != "" {
+			e.add(round.Round, StepEliminate, []string{round.Eliminated}, "No other option reaches the quota, so %s, with the fewest votes, is eliminated.", e.name(round.Eliminated))
+			e.explainLastPlaceTie(round.Round, history[:len(history)-1])
+			e.explainTransfers(round.Round, round.Eliminated, round.Transfers, round.ExhaustedByTransfer, "Its votes")
+		}
+		if round.Surplus == "" && round.Eliminated == "" && len(round.Transfers) > 0 {
+			// Meek counts move surpluses by lowering keep factors instead.
+			e.explainTransfers(round.Round, "", round.Transfers, round.ExhaustedByTransfer, "The surplus of the elected options")
+		}
+	}
+}
+
+// describeTallies lists the tallies of a round, highest first
+func (e *explainer) describeTallies(tallies map[string]float64, unit string) string {
+	ids := make([]string, 0, len(tallies))
+	for id := range tallies {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if tallies[ids[i]] != tallies[ids[j]] {
+			return tallies[ids[i]] > tallies[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = fmt.Sprintf("%s %s", e.name(id), plural(tallies[id], unit))
+	}
+	return "Current totals: " + strings.Join(parts, ", ") + "."
+}
+
+// explainTransfers says where an option's votes went
+func (e *explainer) explainTransfers(round int, from string, transfers map[string]float64, exhausted float64, subject string) {
+	ids := make([]string, 0, len(transfers))
+	for id := range transfers {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if transfers[ids[i]] != transfers[ids[j]] {
+			return transfers[ids[i]] > transfers[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	parts := make([]string, 0, len(ids)+1)
+	for _, id := range ids {
+		parts = append(parts, fmt.Sprintf("%s to %s", number(transfers[id]), e.name(id)))
+	}
+	if exhausted > 0 {
+		parts = append(parts, fmt.Sprintf("%s had no further preference", number(exhausted)))
+	}
+	if len(parts) == 0 {
+		return
+	}
+	var options []string
+	if from != "" {
+		options = append([]string{from}, ids...)
+	} else {
+		options = ids
+	}
+	e.add(round, StepTransfer, options, "%s went on: %s.", subject, strings.Join(parts, "; "))
+}
+
+// tieBreak returns the tie-break recorded for a round and purpose
+func (e *explainer) tieBreak(round int, purpose string) (TieBreak, bool) {
+	for _, tb := range e.result.TieBreaks {
+		if tb.Round == round && tb.Purpose == purpose {
+			return tb, true
+		}
+	}
+	return TieBreak{}, false
+}
+
+// tieReason explains why a tie-break separated the options it chose from
+// the rest. earlier holds the tallies of the rounds before the tie.
+func (e *explainer) tieReason(tb TieBreak, earlier []map[string]float64) string {
+	// The reason is about the option chosen: behind the others if it is
+	// eliminated, ahead of them otherwise.
+	behind := tb.Purpose == TieElimination
+	k := len(tb.Chosen)
+	if tb.Purpose == TieElimination {
+		k = len(tb.Order) - len(tb.Chosen)
+	}
+	a, b := tb.Order[k-1], tb.Order[k]
+
+	switch tb.Rule {
+	case TieBreakPreviousRounds:
+		for r := len(earlier) - 1; r >= 0; r-- {
+			if number(earlier[r][a]) == number(earlier[r][b]) {
+				continue
+			}
+			if behind {
+				return fmt.Sprintf("it had the fewest votes of them in round %d", r+1)
+			}
+			return fmt.Sprintf("it had more votes in round %d", r+1)
+		}
+	case TieBreakFirstPreferences:
+		if behind {
+			return "it has the fewest first preferences of them"
+		}
+		return "it has the most first preferences of them"
+	case TieBreakCreator:
+		if behind {
+			return "the poll's creator ranks it lowest of them"
+		}
+		return "the poll's creator ranks it highest of them"
+	case TieBreakRandom:
+		if behind {
+			return fmt.Sprintf("it comes last of them in the order drawn from the poll's seed %q", e.result.TieBreakSeed)
+		}
+		return fmt.Sprintf("it comes first of them in the order drawn from the poll's seed %q", e.result.TieBreakSeed)
+	}
+	if behind {
+		return "it is listed last in the poll"
+	}
+	return "it is listed first in the poll"
+}
+
+// explainWinnerTie explains the tie-break that picked a winner in a round,
+// if there was one. tied describes the tie.
+func (e *explainer) explainWinnerTie(round int, tied string, earlier []map[string]float64) {
+	tb, ok := e.tieBreak(round, TieWinner)
+	if !ok {
+		return
+	}
+	verb := "wins"
+	switch {
+	case e.result.Method == "stv" && len(tb.Chosen) > 1:
+		verb = "are elected"
+	case e.result.Method == "stv":
+		verb = "is elected"
+	}
+	e.add(round, StepTieBreak, tb.Tied, "%s %s; %s %s because %s.", e.list(tb.Tied), tied, e.list(tb.Chosen), verb, e.tieReason(tb, earlier))
+}
+
+// explainLastPlaceTie explains how a tie for last place was broken, if
+// there was one. earlier holds the tallies of the rounds before it.
+func (e *explainer) explainLastPlaceTie(round int, earlier []map[string]float64) {
+	tb, ok := e.tieBreak(round, TieElimination)
+	if !ok {
+		return
+	}
+	e.add(round, StepTieBreak, tb.Tied, "%s are tied for last place. %s is eliminated because %s.",
+		e.list(tb.Tied), e.list(tb.Chosen), e.tieReason(tb, earlier))
+}
+
+// beats reports whether more ballots prefer a to b than b to a
+func (e *explainer) beats(a, b string) bool {
+	return e.result.Pairwise[a][b] > e.result.Pairwise[b][a]
+}
+
+// explainCondorcetWinner says whether the winner beats every other
+// option head-to-head, and returns true if it does
+func (e *explainer) explainCondorcetWinner() bool {
+	r := e.result
+	if len(r.Winners) == 0 {
+		return false
+	}
+	winner := r.Winners[0]
+	parts := make([]string, 0, len(r.Ranking)-1)
+	for _, id := range r.Ranking {
+		if id == winner {
+			continue
+		}
+		if !e.beats(winner, id) {
+			return false
+		}
+		parts = append(parts, fmt.Sprintf("%s %d-%d", e.name(id), r.Pairwise[winner][id], r.Pairwise[id][winner]))
+	}
+	e.add(0, StepElect, []string{winner}, "%s beats every other option head-to-head (%s) and wins.", e.name(winner), strings.Join(parts, ", "))
+	return true
+}
+
+func (e *explainer) explainSchulze() {
+	r := e.result
+	if e.explainCondorcetWinner() {
+		return
+	}
+	e.add(0, StepCompare, nil, "No option beats every other option head-to-head, so options are compared by their strongest chains of head-to-head wins.")
+	for i := 0; i+1 < len(r.Ranking); i++ {
+		a, b := r.Ranking[i], r.Ranking[i+1]
+		forward, backward := r.StrongestPaths[a][b], r.StrongestPaths[b][a]
+		if forward > backward {
+			e.add(0, StepCompare, []string{a, b}, "%s ranks above %s: its strongest path has strength %d against %d.", e.name(a), e.name(b), forward, backward)
+		} else if tb, ok := e.tieBreak(0, TieWinner); ok && i == 0 {
+			e.add(0, StepTieBreak, []string{a, b}, "%s and %s have equally strong paths (%d); %s ranks higher because %s.",
+				e.name(a), e.name(b), forward, e.name(a), e.tieReason(tb, nil))
+		} else {
+			e.add(0, StepTieBreak, []string{a, b}, "%s and %s have equally strong paths (%d); the tie-break ranks %s higher.",
+				e.name(a), e.name(b), forward, e.name(a))
+		}
+	}
+	if len(r.Winners) > 0 {
+		e.add(0, StepElect, r.Winners, "%s wins.", e.name(r.Winners[0]))
+	}
+}
+
+func (e *explainer) explainRankedPairs() {
+	r := e.result
+	if e.explainCondorcetWinner() {
+		return
+	}
+	e.add(0, StepCompare, nil, "No option beats every other option head-to-head, so head-to-head wins are locked in from strongest to weakest.")
+	for _, pair := range r.Pairs {
+		options := []string{pair.Winner, pair.Loser}
+		if pair.Locked {
+			e.add(0, StepLock, options, "Lock in %s over %s (%d-%d).", e.name(pair.Winner), e.name(pair.Loser), pair.For, pair.Against)
+		} else {
+			e.add(0, StepSkip, options, "Skip %s over %s (%d-%d): it would contradict the wins already locked in.", e.name(pair.Winner), e.name(pair.Loser), pair.For, pair.Against)
+		}
+	}
+	e.explainWinnerTie(0, "are not beaten by any locked-in win", nil)
+	if len(r.Winners) > 0 {
+		e.add(0, StepElect, r.Winners, "%s is not beaten by any locked-in win and wins.", e.name(r.Winners[0]))
+	}
+}
+
+func (e *explainer) explainRunoff() {
+	r := e.result
+	if r.Runoff == nil {
+		e.explainTallyTie()
+		return
+	}
+	a, b := r.Runoff.Finalists[0], r.Runoff.Finalists[1]
+	if tb, ok := e.tieBreak(0, TieFinalists); ok && len(tb.Chosen) == 1 {
+		e.add(0, StepTieBreak, tb.Tied, "%s are tied on total score for a place in the runoff; %s goes through because %s.",
+			e.list(tb.Tied), e.name(tb.Chosen[0]), e.tieReason(tb, nil))
+	} else if ok {
+		e.add(0, StepTieBreak, tb.Tied, "%s are tied on total score for the runoff; %s go through, decided by %s.",
+			e.list(tb.Tied), e.list(tb.Chosen), ruleNames[tb.Rule])
+	}
+	e.add(0, StepRunoff, []string{a, b}, "%s and %s have the highest totals and go to the runoff. %s scored %s higher, %s scored %s higher and %s scored them equally.",
+		e.name(a), e.name(b), plural(float64(r.Runoff.Votes[a]), "ballot"), e.name(a), plural(float64(r.Runoff.Votes[b]), "ballot"), e.name(b),
+		plural(float64(r.Runoff.NoPreference), "ballot"))
+	winner := r.Winners[0]
+	if tb, ok := e.tieBreak(0, TieWinner); ok {
+		e.add(0, StepTieBreak, []string{a, b}, "The runoff and the total scores are tied; %s wins because %s.", e.name(winner), e.tieReason(tb, nil))
+	} else if r.Runoff.Votes[a] == r.Runoff.Votes[b] {
+		e.add(0, StepTieBreak, []string{a, b}, "The runoff is tied; %s wins on total score.", e.name(winner))
+	}
+	e.add(0, StepElect, []string{winner}, "%s wins the runoff.", e.name(winner))
+}
+
+func (e *explainer) explainMajorityJudgment() {
+	r := e.result
+	e.explainTallies("median grade")
+	if len(r.Winners) == 0 {
+		return
+	}
+	winner := r.Winners[0]
+	var tied []string
+	for _, id := range r.Ranking {
+		if r.Tallies[id] == r.Tallies[winner] {
+			tied = append(tied, id)
+		}
+	}
+	if len(tied) > 1 {
+		e.add(0, StepTieBreak, tied, "%s share the best median grade. Their median grades are removed one at a time and the new medians compared until they differ, which puts %s ahead.",
+			e.list(tied), e.name(winner))
+	}
+	e.explainWinnerTie(0, "have identical grades", nil)
+	e.add(0, StepElect, []string{winner}, "%s has the best median grade and wins.", e.name(winner))
+}
+
+// ruleNames describe what decided a tie-break
+var ruleNames = map[string]string{
+	TieBreakPreviousRounds:   "earlier rounds",
+	TieBreakFirstPreferences: "first preferences",
+	TieBreakCreator:          "the creator's order",
+	TieBreakRandom:           "the poll's random seed",
+	TieBreakDisplayOrder:     "the order of the options in the poll",
+}
+
+// markdown renders the explanation as a Markdown document
+func (x Explanation) markdown() string {
+	var b strings.Builder
+	b.WriteString("## How the result was reached\n\n")
+	b.WriteString(x.Summary + "\n")
+	round := -1
+	for _, step := range x.Steps {
+		if step.Round != round {
+			round = step.Round
+			if round > 0 {
+				fmt.Fprintf(&b, "\n### Round %d\n", round)
+			}
+			b.WriteString("\n")
+		}
+		text := step.Text
+		if step.Action == StepTieBreak {
+			text = "**Tie-break:** " + text
+		}
+		b.WriteString("- " + text + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,125 @@
+package tabulation
+
+import (
+	"strings"
+	"testing"
+)
+
+// stepsWith returns the steps with the given action
+func stepsWith(x Explanation, action string) []Step {
+	var steps []Step
+	for _, step := range x.Steps {
+		if step.Action == action {
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+func TestExplainIRV(t *testing.T) {
+	contest := Contest{Options: []string{"a", "b", "c"}}
+	ballots := []Ballot{
+		{Ranking: []string{"a"}}, {Ranking: []string{"a"}}, {Ranking: []string{"a"}},
+		{Ranking: []string{"b"}}, {Ranking: []string{"b"}},
+		{Ranking: []string{"c"}}, {Ranking: []string{"c", "b"}},
+	}
+	m, _ := Lookup("irv")
+	names := map[string]string{"a": "Pizza", "b": "Sushi", "c": "Tacos"}
+	x := Explain(m.Tabulate(contest, ballots), names)
+
+	if x.Method != "irv" || x.Summary != `"Pizza" wins with 7 ballots counted.` {
+		t.Errorf("Explain() method = %q summary = %q", x.Method, x.Summary)
+	}
+
+	eliminated := stepsWith(x, StepEliminate)
+	if len(eliminated) != 2 || eliminated[0].Options[0] != "c" || eliminated[1].Options[0] != "b" {
+		t.Fatalf("Explain() eliminations = %+v, want c then b", eliminated)
+	}
+
+	// Round 1: b and c tie and there is no earlier round.
+	// Round 2: a and b tie, and b had fewer votes in round 1.
+	ties := stepsWith(x, StepTieBreak)
+	if len(ties) != 2 {
+		t.Fatalf("Explain() tie-breaks = %+v, want 2", ties)
+	}
+	if ties[0].Round != 1 || !strings.Contains(ties[0].Text, "listed last") {
+		t.Errorf("round 1 tie-break = %+v, want one decided by option order", ties[0])
+	}
+	if ties[1].Round != 2 || !strings.Contains(ties[1].Text, "in round 1") {
+		t.Errorf("round 2 tie-break = %+v, want one decided by round 1", ties[1])
+	}
+
+	transfers := stepsWith(x, StepTransfer)
+	if len(transfers) == 0 || transfers[0].Text != `Its ballots went on: 1 to "Sushi"; 1 had no further preference.` {
+		t.Errorf("Explain() first transfer = %+v", transfers)
+	}
+
+	for _, want := range []string{"### Round 1", "### Round 3", `**Tie-break:** "Sushi" and "Tacos" are tied`} {
+		if !strings.Contains(x.Markdown, want) {
+			t.Errorf("Explain() markdown does not contain %q:\n%s", want, x.Markdown)
+		}
+	}
+}
+
+func TestExplainRankedPairs(t *testing.T) {
+	contest := Contest{Options: []string{"A", "B", "C"}}
+	var ballots []Ballot
+	ballots = append(ballots, repeat(7, "A", "B", "C")...)
+	ballots = append(ballots, repeat(5, "B", "C", "A")...)
+	ballots = append(ballots, repeat(4, "C", "A", "B")...)
+
+	m, _ := Lookup("ranked_pairs")
+	x := Explain(m.Tabulate(contest, ballots), nil)
+
+	if got := len(stepsWith(x, StepLock)); got != 2 {
+		t.Errorf("Explain() locked %d pairs, want 2", got)
+	}
+	skipped := stepsWith(x, StepSkip)
+	if len(skipped) != 1 || skipped[0].Text != `Skip "C" over "A" (9-7): it would contradict the wins already locked in.` {
+		t.Errorf("Explain() skipped = %+v", skipped)
+	}
+}
+
+func TestExplainTallyTie(t *testing.T) {
+	contest := Contest{Options: []string{"a", "b"}}
+	ballots := []Ballot{{Ranking: []string{"b"}}, {Ranking: []string{"a"}}}
+
+	m, _ := Lookup("plurality")
+	x := Explain(m.Tabulate(contest, ballots), map[string]string{"a": "Yes", "b": "No"})
+
+	ties := stepsWith(x, StepTieBreak)
+	if len(ties) != 1 || ties[0].Text != `"Yes" and "No" are tied for first place; "Yes" wins because it is listed first in the poll.` {
+		t.Errorf("Explain() tie-breaks = %+v", ties)
+	}
+}
+
+func TestExplainNoBallots(t *testing.T) {
+	for _, name := range Names() {
+		m, _ := Lookup(name)
+		contest := Contest{Options: []string{"a", "b", "c"}, Settings: Settings{MaxScore: 5, Seats: 1}}
+		x := Explain(m.Tabulate(contest, nil), nil)
+		if x.Summary != "No ballots have been cast yet." || len(x.Steps) != 0 {
+			t.Errorf("Explain(%s, no ballots) = %+v", name, x)
+		}
+	}
+}
+
+func TestExplainEveryMethod(t *testing.T) {
+	// Every method produces an explanation that names the winner.
+	contest := Contest{Options: []string{"a", "b", "c"}, Settings: Settings{MaxScore: 5, Seats: 1}}
+	ballots := []Ballot{
+		{Ranking: []string{"a", "b"}, Approvals: []string{"a"}, Scores: map[string]int{"a": 5, "b": 3}},
+		{Ranking: []string{"a", "c"}, Approvals: []string{"a", "c"}, Scores: map[string]int{"a": 4, "c": 1}},
+		{Ranking: []string{"b", "a"}, Approvals: []string{"b"}, Scores: map[string]int{"b": 5, "a": 2}},
+	}
+	for _, name := range Names() {
+		m, _ := Lookup(name)
+		x := Explain(m.Tabulate(contest, ballots), map[string]string{"a": "Alpha"})
+		if len(stepsWith(x, StepElect)) == 0 {
+			t.Errorf("Explain(%s) has no elect step: %+v", name, x.Steps)
+		}
+		if !strings.Contains(x.Summary, `"Alpha"`) {
+			t.Errorf("Explain(%s) summary = %q, want Alpha to win", name, x.Summary)
+		}
+	}
+}
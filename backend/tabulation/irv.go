@@ -0,0 +1,171 @@
+package tabulation
+
+// IRVRound records the state of a single instant-runoff counting round
+type IRVRound struct {
+	Round      int            `json:"round"`
+	Tallies    map[string]int `json:"tallies"`   // Continuing option ID -> ballots currently counting for it
+	Threshold  int            `json:"threshold"` // Votes needed for a majority of the active ballots
+	Exhausted  int            `json:"exhausted"` // Ballots with no continuing preference left (cumulative)
+	Eliminated string         `json:"eliminated,omitempty"`
+	// Transfers records where the eliminated option's ballots went at the
+	// end of this round. Ballots with no further preference are counted in
+	// ExhaustedByTransfer instead.
+	Transfers           map[string]int `json:"transfers,omitempty"`
+	ExhaustedByTransfer int            `json:"exhausted_by_transfer,omitempty"`
+}
+
+// IRVResult is the full round-by-round outcome of an instant-runoff count
+type IRVResult struct {
+	TotalBallots int        `json:"total_ballots"`
+	Rounds       []IRVRound `json:"rounds"`
+	Winner       string     `json:"winner,omitempty"`
+}
+
+// instantRunoff counts an instant-runoff contest, breaking ties for last
+// place with tb.
+//
+// Each round every ballot counts for its highest-ranked continuing option.
+// An option with more than half of the non-exhausted ballots wins; otherwise
+// the option with the fewest votes is eliminated and its ballots transfer to
+// their next continuing preference.
+func instantRunoff(c Contest, contents []Ballot, tb *tieBreaker) IRVResult {
+	options := c.Options
+	ballots := make([][]string, len(contents))
+	for i, b := range contents {
+		ballots[i] = b.Ranking
+	}
+	result := IRVResult{TotalBallots: len(ballots), Rounds: []IRVRound{}}
+	if len(options) == 0 || len(ballots) == 0 {
+		return result
+	}
+
+	continuing := make(map[string]bool, len(options))
+	for _, id := range options {
+		continuing[id] = true
+	}
+
+	// topChoice returns the ballot's highest-ranked continuing option, or "" if exhausted.
+	topChoice := func(ballot []string) string {
+		for _, id := range ballot {
+			if continuing[id] {
+				return id
+			}
+		}
+		return ""
+	}
+
+	for round := 1; ; round++ {
+		current := IRVRound{Round: round, Tallies: make(map[string]int, len(continuing))}
+		for _, id := range options {
+			if continuing[id] {
+				current.Tallies[id] = 0
+			}
+		}
+		for _, ballot := range ballots {
+			if choice := topChoice(ballot); choice != "" {
+				current.Tallies[choice]++
+			} else {
+				current.Exhausted++
+			}
+		}
+		active := len(ballots) - current.Exhausted
+		current.Threshold = active/2 + 1
+
+		// Check for a majority winner, or a sole survivor.
+		for _, id := range options {
+			if continuing[id] && current.Tallies[id] >= current.Threshold {
+				result.Winner = id
+			}
+		}
+		if result.Winner == "" && len(current.Tallies) == 1 {
+			for id := range current.Tallies {
+				result.Winner = id
+			}
+		}
+		if result.Winner != "" || active == 0 {
+			result.Rounds = append(result.Rounds, current)
+			return result
+		}
+
+		loser := lowestOption(options, current.Tallies, round, tb)
+		current.Eliminated = loser
+		current.Transfers = make(map[string]int)
+
+		// Work out where the eliminated option's ballots go next.
+		holders := make([][]string, 0, current.Tallies[loser])
+		for _, ballot := range ballots {
+			if topChoice(ballot) == loser {
+				holders = append(holders, ballot)
+			}
+		}
+		continuing[loser] = false
+		for _, ballot := range holders {
+			if next := topChoice(ballot); next != "" {
+				current.Transfers[next]++
+			} else {
+				current.ExhaustedByTransfer++
+			}
+		}
+
+		result.Rounds = append(result.Rounds, current)
+		tallies := make(map[string]float64, len(current.Tallies))
+		for id, count := range current.Tallies {
+			tallies[id] = float64(count)
+		}
+		tb.addRound(tallies)
+	}
+}
+
+// lowestOption picks the continuing option to eliminate from tallies,
+// breaking ties for last place with the tie-breaker
+func lowestOption(options []string, tallies map[string]int, round int, tb *tieBreaker) string {
+	var tied []string
+	for _, id := range options {
+		count, ok := tallies[id]
+		if !ok {
+			continue
+		}
+		if len(tied) == 0 || count < tallies[tied[0]] {
+			tied = []string{id}
+		} else if count == tallies[tied[0]] {
+			tied = append(tied, id)
+		}
+	}
+
+	if len(tied) == 1 {
+		return tied[0]
+	}
+	ordered := tb.order(round, TieElimination, tied, len(tied)-1)
+	return ordered[len(ordered)-1]
+}
+
+// irv is the instant-runoff voting method
+type irv struct{}
+
+func init() {
+	Register(irv{})
+}
+
+func (irv) Name() string { return "irv" }
+
+func (irv) ValidateSettings(s Settings, numOptions int) error { return nil }
+
+func (irv) ValidateBallot(c Contest, b Ballot) error {
+	return validateRanking(c, b.Ranking)
+}
+
+func (irv) Tabulate(c Contest, ballots []Ballot) Result {
+	tb := newTieBreaker(c, ballots)
+	irvResult := instantRunoff(c, ballots, tb)
+
+	result := Result{
+		Method:       "irv",
+		TotalBallots: irvResult.TotalBallots,
+		Winners:      []string{},
+		Rounds:       irvResult.Rounds,
+	}
+	if irvResult.Winner != "" {
+		result.Winners = []string{irvResult.Winner}
+	}
+	return tb.finish(result)
+}
@@ -0,0 +1,109 @@
+package tabulation
+
+import (
+	"reflect"
+	"testing"
+)
+
+// rankings makes a ballot of each ranking
+func rankings(orders ...[]string) []Ballot {
+	ballots := make([]Ballot, len(orders))
+	for i, ranking := range orders {
+		ballots[i] = Ballot{Ranking: ranking}
+	}
+	return ballots
+}
+
+func TestInstantRunoff(t *testing.T) {
+	contest := Contest{Options: []string{"a", "b", "c"}}
+	m, _ := Lookup("irv")
+
+	tests := []struct {
+		name       string
+		ballots    []Ballot
+		wantWinner []string
+		wantRounds int
+	}{
+		{
+			name:       "no ballots",
+			ballots:    nil,
+			wantWinner: []string{},
+			wantRounds: 0,
+		},
+		{
+			name: "first round majority",
+			ballots: rankings(
+				[]string{"a", "b"}, []string{"a"}, []string{"a", "c"}, []string{"b"}, []string{"c"},
+			),
+			wantWinner: []string{"a"},
+			wantRounds: 1,
+		},
+		{
+			name: "winner after transfers",
+			ballots: rankings(
+				[]string{"a"}, []string{"a"}, []string{"a"},
+				[]string{"b", "c"}, []string{"b", "c"},
+				[]string{"c", "b"}, []string{"c", "b"}, []string{"c", "b"},
+			),
+			wantWinner: []string{"c"},
+			wantRounds: 2,
+		},
+		{
+			name: "exhausted ballots shrink the majority threshold",
+			ballots: rankings(
+				[]string{"a"}, []string{"a"}, []string{"a"},
+				[]string{"b"}, []string{"b"},
+				[]string{"c"}, []string{"c", "b"},
+			),
+			wantWinner: []string{"a"},
+			wantRounds: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := m.Tabulate(contest, tt.ballots)
+			if !reflect.DeepEqual(result.Winners, tt.wantWinner) {
+				t.Errorf("irv winners = %v, want %v", result.Winners, tt.wantWinner)
+			}
+			if len(result.Rounds) != tt.wantRounds {
+				t.Errorf("irv rounds = %d, want %d", len(result.Rounds), tt.wantRounds)
+			}
+			if result.TotalBallots != len(tt.ballots) {
+				t.Errorf("irv total ballots = %d, want %d", result.TotalBallots, len(tt.ballots))
+			}
+		})
+	}
+}
+
+func TestInstantRunoffTransfers(t *testing.T) {
+	contest := Contest{Options: []string{"a", "b", "c"}}
+	ballots := rankings(
+		[]string{"a"}, []string{"a"}, []string{"a"},
+		[]string{"b"}, []string{"b"},
+		[]string{"c"}, []string{"c", "b"},
+	)
+
+	m, _ := Lookup("irv")
+	result := m.Tabulate(contest, ballots)
+
+	// Round 1: a=3 b=2 c=2. b and c tie and neither has an earlier round,
+	// so the later-listed option (c) is eliminated.
+	first := result.Rounds[0]
+	if first.Eliminated != "c" {
+		t.Fatalf("round 1 eliminated = %q, want c", first.Eliminated)
+	}
+	if first.Transfers["b"] != 1 || first.ExhaustedByTransfer != 1 {
+		t.Errorf("round 1 transfers = %v exhausted %d, want b:1 exhausted 1", first.Transfers, first.ExhaustedByTransfer)
+	}
+
+	// Round 2: a=3 b=3 with one exhausted ballot; a 3-3 tie goes back to
+	// round 1 where b had fewer votes, so b is eliminated.
+	second := result.Rounds[1]
+	if second.Exhausted != 1 || second.Threshold != 4 {
+		t.Errorf("round 2 exhausted = %d threshold = %d, want 1 and 4", second.Exhausted, second.Threshold)
+	}
+	if second.Eliminated != "b" {
+		t.Errorf("round 2 eliminated = %q, want b", second.Eliminated)
+	}
+}
@@ -0,0 +1,83 @@
+package tabulation
+
+import (
+	"sort"
+)
+
+// majorityJudgment is Majority Judgment: voters grade every option and
+// each option's grade is its median grade, the best grade a majority of
+// voters agree it deserves (the lower median for an even number of
+// ballots). The option with the best median wins.
+//
+// Options with the same median are separated by the standard tie-break:
+// one copy of the median grade is removed from each of their grade lists
+// and the new medians compared, repeating until they differ. Ties between
+// options with identical grades are broken by the poll's tie-break policy.
+type majorityJudgment struct{}
+
+func init() {
+	Register(majorityJudgment{})
+}
+
+func (majorityJudgment) Name() string { return "majority_judgment" }
+
+func (majorityJudgment) ValidateSettings(s Settings, numOptions int) error {
+	return validateScale(s)
+}
+
+func (majorityJudgment) ValidateBallot(c Contest, b Ballot) error {
+	return validateScores(c, b)
+}
+
+func (majorityJudgment) Tabulate(c Contest, ballots []Ballot) Result {
+	// Each option's majority value is the sequence of medians obtained by
+	// repeatedly removing the median grade; comparing these sequences is
+	// the same as running the tie-break.
+	values := make(map[string][]int, len(c.Options))
+	medians := make(map[string]float64, len(c.Options))
+	for _, id := range c.Options {
+		grades := make([]int, len(ballots))
+		for i, b := range ballots {
+			grades[i] = grade(c, b, id)
+		}
+		sort.Ints(grades)
+
+		value := make([]int, 0, len(grades))
+		for len(grades) > 0 {
+			m := (len(grades) - 1) / 2
+			value = append(value, grades[m])
+			grades = append(grades[:m], grades[m+1:]...)
+		}
+		values[id] = value
+		medians[id] = 0
+		if len(value) > 0 {
+			medians[id] = float64(value[0])
+		}
+	}
+
+	// better compares majority values; identical values are tied
+	better := func(x, y string) bool {
+		a, b := values[x], values[y]
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] > b[k]
+			}
+		}
+		return false
+	}
+
+	tb := newTieBreaker(c, ballots)
+	result := Result{
+		Method:        "majority_judgment",
+		TotalBallots:  len(ballots),
+		Winners:       []string{},
+		Ranking:       tb.sorted(c.Options, better),
+		Tallies:       medians,
+		Distributions: distributions(c, ballots),
+	}
+	if len(ballots) > 0 && len(result.Ranking) > 0 {
+		result.Winners = []string{result.Ranking[0]}
+		tb.recordTies(0, TieWinner, result.Ranking, 1, func(a, b string) bool { return !better(a, b) && !better(b, a) })
+	}
+	return tb.finish(result)
+}
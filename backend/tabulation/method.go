@@ -0,0 +1,266 @@
+// Package tabulation turns stored ballots into poll results using a
+// registry of voting methods.
+package tabulation
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Ballot is the method-neutral content of a single voter's ballot.
+// Each voting method reads the field that matches its ballot style.
+type Ballot struct {
+	Ranking   []string       `json:"ranking,omitempty" bson:"ranking,omitempty"`     // Option IDs, most preferred first
+	Approvals []string       `json:"approvals,omitempty" bson:"approvals,omitempty"` // Option IDs the voter approves of
+	Scores    map[string]int `json:"scores,omitempty" bson:"scores,omitempty"`       // Option ID -> score given by the voter
+	// Tiers is a ranking that allows ties: groups of equally preferred
+	// option IDs, most preferred group first. Only polls whose settings
+	// allow ties accept it, in place of Ranking.
+	Tiers [][]string `json:"tiers,omitempty" bson:"tiers,omitempty"`
+}
+
+// Counted returns the option IDs whose live vote counter this ballot
+// increments: the first preference of a ranked ballot (every option tied
+// first, for a ballot with tiers) or every approved option of an approval
+// ballot. Score ballots have no single choice to count.
+func (b Ballot) Counted() []string {
+	if len(b.Ranking) > 0 {
+		return b.Ranking[:1]
+	}
+	if len(b.Tiers) > 0 {
+		return b.Tiers[0]
+	}
+	return b.Approvals
+}
+
+// Options returns every option ID the ballot mentions, in any field
+func (b Ballot) Options() []string {
+	ids := append(append([]string(nil), b.Ranking...), b.Approvals...)
+	for _, tier := range b.Tiers {
+		ids = append(ids, tier...)
+	}
+	for id := range b.Scores {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// tiers returns the ballot's ranking as groups of equally preferred
+// options; a ranking without ties has one option per group
+func (b Ballot) tiers() [][]string {
+	if len(b.Tiers) > 0 {
+		return b.Tiers
+	}
+	tiers := make([][]string, len(b.Ranking))
+	for i, id := range b.Ranking {
+		tiers[i] = []string{id}
+	}
+	return tiers
+}
+
+// Settings holds the method-specific settings of a poll.
+// Fields that do not apply to a poll's voting method are left empty.
+type Settings struct {
+	// Score, STAR and Majority Judgment: ballots grade options from
+	// MinScore to MaxScore. Options a ballot leaves ungraded get MinScore.
+	MinScore int `json:"min_score,omitempty" bson:"min_score,omitempty"`
+	MaxScore int `json:"max_score,omitempty" bson:"max_score,omitempty"`
+	// Approval voting: how many options each ballot must approve.
+	// Zero means at least one and no upper limit respectively.
+	MinSelections int `json:"min_selections,omitempty" bson:"min_selections,omitempty"`
+	MaxSelections int `json:"max_selections,omitempty" bson:"max_selections,omitempty"`
+	// STV: how many options are elected, and the surplus transfer rule
+	// (TransferGregory if empty, or TransferMeek)
+	Seats           int    `json:"seats,omitempty" bson:"seats,omitempty"`
+	SurplusTransfer string `json:"surplus_transfer,omitempty" bson:"surplus_transfer,omitempty"`
+	// Borda: the points scheme (BordaStandard if empty, or BordaDowdall)
+	BordaScheme string `json:"borda_scheme,omitempty" bson:"borda_scheme,omitempty"`
+	// Borda and Copeland: how ballots that leave options unranked are
+	// treated (TruncationZero if empty, TruncationAverage for Borda only,
+	// or TruncationReject), and whether ballots may rank options equally
+	Truncation string `json:"truncation,omitempty" bson:"truncation,omitempty"`
+	AllowTies  bool   `json:"allow_ties,omitempty" bson:"allow_ties,omitempty"`
+	// Every method: how ties the method cannot decide are broken
+	// (DefaultTieBreak if empty, or one of the other TieBreak policies)
+	TieBreak string `json:"tie_break,omitempty" bson:"tie_break,omitempty"`
+}
+
+// Contest describes what is being voted on: the poll's option IDs in
+// display order and its method-specific settings.
+type Contest struct {
+	Options  []string
+	Settings Settings
+	// TieBreakSeed is the poll's published seed for TieBreakRandom, and
+	// TieBreakOrder the creator's preferred option IDs for TieBreakCreator
+	TieBreakSeed  string
+	TieBreakOrder []string
+}
+
+// Result is the structured outcome of tabulating a poll's ballots.
+// Only the fields produced by the poll's voting method are filled in.
+type Result struct {
+	Method       string             `json:"method"`
+	TotalBallots int                `json:"total_ballots"`
+	Winners      []string           `json:"winners"`           // Winning option IDs, empty if there were no ballots
+	Ranking      []string           `json:"ranking,omitempty"` // Every option ID, best first
+	Tallies      map[string]float64 `json:"tallies,omitempty"` // Option ID -> votes, approvals, points, average, total or median score
+	// Percentages maps option IDs to the percentage of ballots approving
+	// them. They can add up to more than 100 as ballots approve several options.
+	Percentages map[string]float64 `json:"percentages,omitempty"`
+	// Distributions[id][g] is the number of ballots giving the option grade
+	// MinScore+g, for the score-based methods
+	Distributions map[string][]int `json:"distributions,omitempty"`
+	Runoff        *Runoff          `json:"runoff,omitempty"`     // STAR automatic runoff
+	Rounds        []IRVRound       `json:"rounds,omitempty"`     // Instant-runoff counting rounds
+	STVRounds     []STVRound       `json:"stv_rounds,omitempty"` // STV counting rounds; Winners lists the elected options in order of election
+	// Pairwise[a][b] is the number of ballots preferring option a over option b.
+	Pairwise map[string]map[string]int `json:"pairwise,omitempty"`
+	// StrongestPaths[a][b] is the strength of the strongest chain of
+	// pairwise wins from option a to option b (Schulze), or 0 if there is none.
+	StrongestPaths map[string]map[string]int `json:"strongest_paths,omitempty"`
+	// Pairs lists every head-to-head majority in the order Ranked Pairs
+	// considered them, and whether each was locked in.
+	Pairs []RankedPair `json:"pairs,omitempty"`
+	// TieBreakPolicy is the tie-break policy the count used, with its seed
+	// (TieBreakRandom) or the creator's order (TieBreakCreator). TieBreaks
+	// lists every tie that decided a winner, finalist, elimination or
+	// surplus transfer.
+	TieBreakPolicy string     `json:"tie_break_policy"`
+	TieBreakSeed   string     `json:"tie_break_seed,omitempty"`
+	TieBreakOrder  []string   `json:"tie_break_order,omitempty"`
+	TieBreaks      []TieBreak `json:"tie_breaks,omitempty"`
+}
+
+// Method is a voting method that can validate and tabulate ballots.
+// New methods are added by implementing Method and calling Register from
+// an init function; nothing outside this package needs to change.
+type Method interface {
+	// Name returns the identifier stored in Poll.VotingMethod.
+	Name() string
+	// ValidateSettings checks that the settings make sense for this method
+	// on a poll with the given number of options.
+	ValidateSettings(s Settings, numOptions int) error
+	// ValidateBallot checks that a ballot is well-formed for this method.
+	ValidateBallot(c Contest, b Ballot) error
+	// Tabulate counts the ballots and returns the result.
+	Tabulate(c Contest, ballots []Ballot) Result
+}
+
+// registry maps method names to their implementations
+var registry = map[string]Method{}
+
+// Register makes a voting method available by its name.
+// It panics if a method with the same name is already registered.
+func Register(m Method) {
+	if _, exists := registry[m.Name()]; exists {
+		panic(fmt.Sprintf("tabulation: method %q registered twice", m.Name()))
+	}
+	registry[m.Name()] = m
+}
+
+// Lookup returns the voting method registered under name
+func Lookup(name string) (Method, bool) {
+	m, ok := registry[name]
+	return m, ok
+}
+
+// Names returns the names of all registered voting methods, sorted
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validateRanking checks that a ranking is non-empty, only lists the
+// contest's options and lists each of them at most once.
+func validateRanking(c Contest, ranking []string) error {
+	if len(ranking) == 0 {
+		return errors.New("ballot must rank at least one option")
+	}
+	if len(ranking) > len(c.Options) {
+		return errors.New("ballot ranks more options than the poll has")
+	}
+	return validateOptionSet(c, ranking, "ranks")
+}
+
+// validateTieredRanking checks a ranked ballot under the contest's rules
+// for ties and truncation, for the methods that support them. Ballots use
+// Tiers if the contest allows ties, and Ranking otherwise.
+func validateTieredRanking(c Contest, b Ballot) error {
+	ranked := b.Ranking
+	if len(b.Tiers) > 0 {
+		if !c.Settings.AllowTies {
+			return errors.New("ballot cannot rank options equally on this poll")
+		}
+		if len(b.Ranking) > 0 {
+			return errors.New("ballot must give either a ranking or tiers, not both")
+		}
+		ranked = nil
+		for _, tier := range b.Tiers {
+			if len(tier) == 0 {
+				return errors.New("ballot tiers cannot be empty")
+			}
+			ranked = append(ranked, tier...)
+		}
+	}
+	if err := validateRanking(c, ranked); err != nil {
+		return err
+	}
+	if c.Settings.Truncation == TruncationReject && len(ranked) < len(c.Options) {
+		return errors.New("ballot must rank every option")
+	}
+	return nil
+}
+
+// validateTruncation checks a contest's truncation and tie settings
+func validateTruncation(s Settings) error {
+	switch s.Truncation {
+	case "", TruncationZero, TruncationAverage, TruncationReject:
+		return nil
+	default:
+		return fmt.Errorf("truncation must be %q, %q or %q", TruncationZero, TruncationAverage, TruncationReject)
+	}
+}
+
+// validateOptionSet checks that every ID belongs to the contest and
+// appears only once. verb describes what the ballot does with the options.
+func validateOptionSet(c Contest, ids []string, verb string) error {
+	known := make(map[string]bool, len(c.Options))
+	for _, id := range c.Options {
+		known[id] = true
+	}
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if !known[id] {
+			return fmt.Errorf("ballot %s an option that does not belong to the poll", verb)
+		}
+		if seen[id] {
+			return fmt.Errorf("ballot %s the same option more than once", verb)
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// tallyResult builds a Result for methods that produce one number per
+// option, where the option with the highest tally wins. Equal tallies are
+// ordered by the contest's tie-break policy.
+func tallyResult(method string, c Contest, ballots []Ballot, tallies map[string]float64) Result {
+	tb := newTieBreaker(c, ballots)
+	result := Result{
+		Method:       method,
+		TotalBallots: len(ballots),
+		Winners:      []string{},
+		Ranking:      tb.rankByTally(c.Options, tallies),
+		Tallies:      tallies,
+	}
+	if len(ballots) > 0 && len(result.Ranking) > 0 {
+		result.Winners = []string{result.Ranking[0]}
+		tb.recordTies(0, TieWinner, result.Ranking, 1, func(a, b string) bool { return tallies[a] == tallies[b] })
+	}
+	return tb.finish(result)
+}
@@ -0,0 +1,229 @@
+package tabulation
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	want := []string{"approval", "borda", "copeland", "irv", "majority_judgment", "plurality", "ranked_pairs", "schulze", "score", "star", "stv"}
+	if got := Names(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Names() = %v, want %v", got, want)
+	}
+
+	for _, name := range want {
+		m, ok := Lookup(name)
+		if !ok {
+			t.Errorf("Lookup(%q) not found", name)
+			continue
+		}
+		if m.Name() != name {
+			t.Errorf("Lookup(%q).Name() = %q", name, m.Name())
+		}
+	}
+
+	if _, ok := Lookup("dictator"); ok {
+		t.Errorf("Lookup(\"dictator\") found an unregistered method")
+	}
+}
+
+func TestRegisterDuplicatePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Register() of a duplicate name did not panic")
+		}
+	}()
+	Register(plurality{})
+}
+
+func TestBallotCounted(t *testing.T) {
+	if got := (Ballot{Ranking: []string{"b", "a"}}).Counted(); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("ranked Counted() = %v, want [b]", got)
+	}
+	if got := (Ballot{Approvals: []string{"a", "c"}}).Counted(); !reflect.DeepEqual(got, []string{"a", "c"}) {
+		t.Errorf("approval Counted() = %v, want [a c]", got)
+	}
+	if got := (Ballot{Scores: map[string]int{"a": 3}}).Counted(); len(got) != 0 {
+		t.Errorf("score Counted() = %v, want none", got)
+	}
+}
+
+func TestValidateBallot(t *testing.T) {
+	contest := Contest{Options: []string{"a", "b", "c"}, Settings: Settings{MaxScore: 5}}
+
+	tests := []struct {
+		name    string
+		method  string
+		ballot  Ballot
+		wantErr bool
+	}{
+		{name: "plurality single choice", method: "plurality", ballot: Ballot{Ranking: []string{"a"}}},
+		{name: "plurality two choices", method: "plurality", ballot: Ballot{Ranking: []string{"a", "b"}}, wantErr: true},
+		{name: "irv full ranking", method: "irv", ballot: Ballot{Ranking: []string{"c", "a", "b"}}},
+		{name: "irv duplicate", method: "irv", ballot: Ballot{Ranking: []string{"a", "a"}}, wantErr: true},
+		{name: "borda unknown option", method: "borda", ballot: Ballot{Ranking: []string{"z"}}, wantErr: true},
+		{name: "schulze empty", method: "schulze", ballot: Ballot{}, wantErr: true},
+		{name: "approval two options", method: "approval", ballot: Ballot{Approvals: []string{"a", "c"}}},
+		{name: "approval empty", method: "approval", ballot: Ballot{}, wantErr: true},
+		{name: "score in range", method: "score", ballot: Ballot{Scores: map[string]int{"a": 5, "b": 0}}},
+		{name: "score above scale", method: "score", ballot: Ballot{Scores: map[string]int{"a": 6}}, wantErr: true},
+		{name: "score negative", method: "score", ballot: Ballot{Scores: map[string]int{"a": -1}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := Lookup(tt.method)
+			err := m.ValidateBallot(contest, tt.ballot)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("%s.ValidateBallot() error = %v, wantErr %v", tt.method, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateSettings(t *testing.T) {
+	m, _ := Lookup("score")
+	if err := m.ValidateSettings(Settings{MaxScore: 10}, 3); err != nil {
+		t.Errorf("score.ValidateSettings(max 10) error = %v", err)
+	}
+	if err := m.ValidateSettings(Settings{}, 3); err == nil {
+		t.Errorf("score.ValidateSettings(max 0) expected an error")
+	}
+}
+
+func TestApprovalSelections(t *testing.T) {
+	m, _ := Lookup("approval")
+
+	settings := []struct {
+		name     string
+		settings Settings
+		wantErr  bool
+	}{
+		{name: "no limits", settings: Settings{}},
+		{name: "between one and two", settings: Settings{MinSelections: 1, MaxSelections: 2}},
+		{name: "exactly three", settings: Settings{MinSelections: 3, MaxSelections: 3}},
+		{name: "minimum only", settings: Settings{MinSelections: 2}},
+		{name: "minimum above maximum", settings: Settings{MinSelections: 3, MaxSelections: 2}, wantErr: true},
+		{name: "minimum above option count", settings: Settings{MinSelections: 4}, wantErr: true},
+		{name: "maximum above option count", settings: Settings{MaxSelections: 4}, wantErr: true},
+		{name: "negative minimum", settings: Settings{MinSelections: -1}, wantErr: true},
+	}
+	for _, tt := range settings {
+		t.Run(tt.name, func(t *testing.T) {
+			err := m.ValidateSettings(tt.settings, 3)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("approval.ValidateSettings() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	contest := Contest{Options: []string{"a", "b", "c"}, Settings: Settings{MinSelections: 2, MaxSelections: 2}}
+	ballots := []struct {
+		approvals []string
+		wantErr   bool
+	}{
+		{approvals: []string{"a"}, wantErr: true},
+		{approvals: []string{"a", "b"}},
+		{approvals: []string{"a", "b", "c"}, wantErr: true},
+	}
+	for _, tt := range ballots {
+		err := m.ValidateBallot(contest, Ballot{Approvals: tt.approvals})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("approval.ValidateBallot(%v) error = %v, wantErr %v", tt.approvals, err, tt.wantErr)
+		}
+	}
+}
+
+func TestApprovalPercentages(t *testing.T) {
+	contest := Contest{Options: []string{"a", "b", "c"}}
+	m, _ := Lookup("approval")
+
+	ballots := []Ballot{
+		{Approvals: []string{"a", "b"}},
+		{Approvals: []string{"a"}},
+		{Approvals: []string{"a", "b"}},
+		{Approvals: []string{"c"}},
+	}
+	result := m.Tabulate(contest, ballots)
+	want := map[string]float64{"a": 75, "b": 50, "c": 25}
+	if !reflect.DeepEqual(result.Percentages, want) {
+		t.Errorf("Tabulate() percentages = %v, want %v", result.Percentages, want)
+	}
+
+	result = m.Tabulate(contest, nil)
+	want = map[string]float64{"a": 0, "b": 0, "c": 0}
+	if !reflect.DeepEqual(result.Percentages, want) {
+		t.Errorf("Tabulate(no ballots) percentages = %v, want %v", result.Percentages, want)
+	}
+}
+
+func TestTallyMethods(t *testing.T) {
+	contest := Contest{Options: []string{"a", "b", "c"}, Settings: Settings{MaxScore: 10}}
+
+	tests := []struct {
+		name        string
+		method      string
+		ballots     []Ballot
+		wantTallies map[string]float64
+		wantRanking []string
+	}{
+		{
+			name:        "plurality",
+			method:      "plurality",
+			ballots:     []Ballot{{Ranking: []string{"b"}}, {Ranking: []string{"b"}}, {Ranking: []string{"c"}}},
+			wantTallies: map[string]float64{"a": 0, "b": 2, "c": 1},
+			wantRanking: []string{"b", "c", "a"},
+		},
+		{
+			name:        "approval",
+			method:      "approval",
+			ballots:     []Ballot{{Approvals: []string{"a", "c"}}, {Approvals: []string{"c"}}},
+			wantTallies: map[string]float64{"a": 1, "b": 0, "c": 2},
+			wantRanking: []string{"c", "a", "b"},
+		},
+		{
+			name:        "borda with partial ranking",
+			method:      "borda",
+			ballots:     []Ballot{{Ranking: []string{"a", "b", "c"}}, {Ranking: []string{"b"}}},
+			wantTallies: map[string]float64{"a": 2, "b": 3, "c": 0},
+			wantRanking: []string{"b", "a", "c"},
+		},
+		{
+			name:        "score averages",
+			method:      "score",
+			ballots:     []Ballot{{Scores: map[string]int{"a": 10, "b": 4}}, {Scores: map[string]int{"a": 2, "b": 6, "c": 9}}},
+			wantTallies: map[string]float64{"a": 6, "b": 5, "c": 4.5},
+			wantRanking: []string{"a", "b", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := Lookup(tt.method)
+			result := m.Tabulate(contest, tt.ballots)
+			if result.Method != tt.method {
+				t.Errorf("Tabulate() method = %q, want %q", result.Method, tt.method)
+			}
+			if !reflect.DeepEqual(result.Tallies, tt.wantTallies) {
+				t.Errorf("Tabulate() tallies = %v, want %v", result.Tallies, tt.wantTallies)
+			}
+			if !reflect.DeepEqual(result.Ranking, tt.wantRanking) {
+				t.Errorf("Tabulate() ranking = %v, want %v", result.Ranking, tt.wantRanking)
+			}
+			if !reflect.DeepEqual(result.Winners, tt.wantRanking[:1]) {
+				t.Errorf("Tabulate() winners = %v, want %v", result.Winners, tt.wantRanking[:1])
+			}
+		})
+	}
+}
+
+func TestTabulateNoBallots(t *testing.T) {
+	contest := Contest{Options: []string{"a", "b"}, Settings: Settings{MaxScore: 5}}
+	for _, name := range Names() {
+		m, _ := Lookup(name)
+		result := m.Tabulate(contest, nil)
+		if len(result.Winners) != 0 || result.TotalBallots != 0 {
+			t.Errorf("%s.Tabulate(no ballots) = winners %v total %d, want none", name, result.Winners, result.TotalBallots)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package tabulation
+
+import (
+	"errors"
+)
+
+// plurality is first-past-the-post voting: each ballot names exactly one
+// option and the option with the most votes wins.
+type plurality struct{}
+
+func init() {
+	Register(plurality{})
+}
+
+func (plurality) Name() string { return "plurality" }
+
+func (plurality) ValidateSettings(s Settings, numOptions int) error { return nil }
+
+func (plurality) ValidateBallot(c Contest, b Ballot) error {
+	if len(b.Ranking) != 1 {
+		return errors.New("ballot must choose exactly one option")
+	}
+	return validateRanking(c, b.Ranking)
+}
+
+func (plurality) Tabulate(c Contest, ballots []Ballot) Result {
+	tallies := make(map[string]float64, len(c.Options))
+	for _, id := range c.Options {
+		tallies[id] = 0
+	}
+	for _, b := range ballots {
+		if len(b.Ranking) > 0 {
+			tallies[b.Ranking[0]]++
+		}
+	}
+	return tallyResult("plurality", c, ballots, tallies)
+}
@@ -0,0 +1,152 @@
+package tabulation
+
+import (
+	"sort"
+)
+
+// RankedPair is one head-to-head majority considered by Ranked Pairs
+type RankedPair struct {
+	Winner  string `json:"winner"`
+	Loser   string `json:"loser"`
+	For     int    `json:"for"`     // Ballots preferring Winner over Loser
+	Against int    `json:"against"` // Ballots preferring Loser over Winner
+	// Locked is false if locking the pair in would have created a cycle
+	// with the pairs locked before it.
+	Locked bool `json:"locked"`
+}
+
+// rankedPairs is Tideman's Ranked Pairs, a Condorcet method. Every pair of
+// options where one beats the other head-to-head is a majority. Majorities
+// are locked in from strongest to weakest, skipping any that would
+// contradict the ones already locked (create a cycle). The locked pairs
+// then order the options; the winner is the option no locked pair beats.
+//
+// Majorities are sorted by the winning side's votes, then by the losing
+// side's votes (fewest first). Majorities tied on both are ordered by how
+// the poll's tie-break policy ranks their winner and then their loser.
+// Options that no locked pair orders, because they tied head-to-head, are
+// also ordered by the tie-break.
+type rankedPairs struct{}
+
+func init() {
+	Register(rankedPairs{})
+}
+
+func (rankedPairs) Name() string { return "ranked_pairs" }
+
+func (rankedPairs) ValidateSettings(s Settings, numOptions int) error { return nil }
+
+func (rankedPairs) ValidateBallot(c Contest, b Ballot) error {
+	return validateRanking(c, b.Ranking)
+}
+
+func (rankedPairs) Tabulate(c Contest, ballots []Ballot) Result {
+	n := len(c.Options)
+	d := pairwiseCounts(c.Options, ballots)
+	index := make(map[string]int, n)
+	for i, id := range c.Options {
+		index[id] = i
+	}
+
+	// majority is a pair of option indexes where w beats l head-to-head
+	type majority struct{ w, l int }
+	var majorities []majority
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if d[i][j] > d[j][i] {
+				majorities = append(majorities, majority{i, j})
+			}
+		}
+	}
+	tb := newTieBreaker(c, ballots)
+	sort.SliceStable(majorities, func(a, b int) bool {
+		x, y := majorities[a], majorities[b]
+		if d[x.w][x.l] != d[y.w][y.l] {
+			return d[x.w][x.l] > d[y.w][y.l]
+		}
+		if d[x.l][x.w] != d[y.l][y.w] {
+			return d[x.l][x.w] < d[y.l][y.w]
+		}
+		if x.w != y.w {
+			return tb.less(c.Options[x.w], c.Options[y.w])
+		}
+		return tb.less(c.Options[x.l], c.Options[y.l])
+	})
+
+	// locked[i][j] means option i is ranked above option j
+	locked := make([][]bool, n)
+	for i := range locked {
+		locked[i] = make([]bool, n)
+	}
+	// reaches reports whether a chain of locked pairs leads from i to j
+	var reaches func(i, j int) bool
+	reaches = func(i, j int) bool {
+		if i == j {
+			return true
+		}
+		for k := 0; k < n; k++ {
+			if locked[i][k] && reaches(k, j) {
+				return true
+			}
+		}
+		return false
+	}
+
+	pairs := make([]RankedPair, len(majorities))
+	for i, m := range majorities {
+		pairs[i] = RankedPair{
+			Winner:  c.Options[m.w],
+			Loser:   c.Options[m.l],
+			For:     d[m.w][m.l],
+			Against: d[m.l][m.w],
+		}
+		// Locking w over l creates a cycle if l already leads to w.
+		if !reaches(m.l, m.w) {
+			locked[m.w][m.l] = true
+			pairs[i].Locked = true
+		}
+	}
+
+	// Order the options by repeatedly taking the remaining option that no
+	// remaining option is locked above, breaking ties between several.
+	result := Result{
+		Method:       "ranked_pairs",
+		TotalBallots: len(ballots),
+		Winners:      []string{},
+		Ranking:      make([]string, 0, n),
+		Pairwise:     pairwiseMap(c.Options, d),
+		Pairs:        pairs,
+	}
+	placed := make([]bool, n)
+	for len(result.Ranking) < n {
+		var unbeaten []string
+		for j := 0; j < n; j++ {
+			if placed[j] {
+				continue
+			}
+			beaten := false
+			for i := 0; i < n; i++ {
+				if !placed[i] && locked[i][j] {
+					beaten = true
+					break
+				}
+			}
+			if !beaten {
+				unbeaten = append(unbeaten, c.Options[j])
+			}
+		}
+		// Only a tie for first place decides the winner.
+		var next string
+		if len(result.Ranking) == 0 && len(ballots) > 0 {
+			next = tb.order(0, TieWinner, unbeaten, 1)[0]
+		} else {
+			next = tb.sortTied(unbeaten)[0]
+		}
+		placed[index[next]] = true
+		result.Ranking = append(result.Ranking, next)
+	}
+	if len(ballots) > 0 && n > 0 {
+		result.Winners = []string{result.Ranking[0]}
+	}
+	return tb.finish(result)
+}
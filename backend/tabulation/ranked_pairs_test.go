@@ -0,0 +1,82 @@
+package tabulation
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRankedPairs(t *testing.T) {
+	// The Tennessee capital example: Memphis has the most first preferences,
+	// but Nashville beats every other city head-to-head.
+	contest := Contest{Options: []string{"Memphis", "Nashville", "Chattanooga", "Knoxville"}}
+	var ballots []Ballot
+	ballots = append(ballots, repeat(42, "Memphis", "Nashville", "Chattanooga", "Knoxville")...)
+	ballots = append(ballots, repeat(26, "Nashville", "Chattanooga", "Knoxville", "Memphis")...)
+	ballots = append(ballots, repeat(15, "Chattanooga", "Knoxville", "Nashville", "Memphis")...)
+	ballots = append(ballots, repeat(17, "Knoxville", "Chattanooga", "Nashville", "Memphis")...)
+
+	m, _ := Lookup("ranked_pairs")
+	result := m.Tabulate(contest, ballots)
+
+	if want := []string{"Nashville", "Chattanooga", "Knoxville", "Memphis"}; !reflect.DeepEqual(result.Ranking, want) {
+		t.Errorf("Ranked Pairs ranking = %v, want %v", result.Ranking, want)
+	}
+	if !reflect.DeepEqual(result.Winners, []string{"Nashville"}) {
+		t.Errorf("Ranked Pairs winners = %v, want [Nashville]", result.Winners)
+	}
+	// Equal majorities keep the display order of their winner, then loser.
+	wantPairs := []RankedPair{
+		{Winner: "Chattanooga", Loser: "Knoxville", For: 83, Against: 17, Locked: true},
+		{Winner: "Nashville", Loser: "Chattanooga", For: 68, Against: 32, Locked: true},
+		{Winner: "Nashville", Loser: "Knoxville", For: 68, Against: 32, Locked: true},
+		{Winner: "Nashville", Loser: "Memphis", For: 58, Against: 42, Locked: true},
+		{Winner: "Chattanooga", Loser: "Memphis", For: 58, Against: 42, Locked: true},
+		{Winner: "Knoxville", Loser: "Memphis", For: 58, Against: 42, Locked: true},
+	}
+	if !reflect.DeepEqual(result.Pairs, wantPairs) {
+		t.Errorf("Ranked Pairs pairs = %+v, want %+v", result.Pairs, wantPairs)
+	}
+	if result.Pairwise["Chattanooga"]["Knoxville"] != 83 {
+		t.Errorf("Pairwise Chattanooga/Knoxville = %d, want 83", result.Pairwise["Chattanooga"]["Knoxville"])
+	}
+}
+
+func TestRankedPairsCycle(t *testing.T) {
+	// A beats B 11-5, B beats C 12-4 and C beats A 9-7. The weakest
+	// majority, C over A, would complete a cycle and is not locked.
+	contest := Contest{Options: []string{"A", "B", "C"}}
+	var ballots []Ballot
+	ballots = append(ballots, repeat(7, "A", "B", "C")...)
+	ballots = append(ballots, repeat(5, "B", "C", "A")...)
+	ballots = append(ballots, repeat(4, "C", "A", "B")...)
+
+	m, _ := Lookup("ranked_pairs")
+	result := m.Tabulate(contest, ballots)
+
+	wantPairs := []RankedPair{
+		{Winner: "B", Loser: "C", For: 12, Against: 4, Locked: true},
+		{Winner: "A", Loser: "B", For: 11, Against: 5, Locked: true},
+		{Winner: "C", Loser: "A", For: 9, Against: 7, Locked: false},
+	}
+	if !reflect.DeepEqual(result.Pairs, wantPairs) {
+		t.Errorf("Ranked Pairs pairs = %+v, want %+v", result.Pairs, wantPairs)
+	}
+	if want := []string{"A", "B", "C"}; !reflect.DeepEqual(result.Ranking, want) {
+		t.Errorf("Ranked Pairs ranking = %v, want %v", result.Ranking, want)
+	}
+}
+
+func TestRankedPairsTieBreak(t *testing.T) {
+	// A and B tie head-to-head, so no pair is locked and display order decides.
+	contest := Contest{Options: []string{"B", "A"}}
+	ballots := []Ballot{{Ranking: []string{"A", "B"}}, {Ranking: []string{"B", "A"}}}
+
+	m, _ := Lookup("ranked_pairs")
+	result := m.Tabulate(contest, ballots)
+	if len(result.Pairs) != 0 {
+		t.Errorf("Ranked Pairs pairs = %+v, want none", result.Pairs)
+	}
+	if want := []string{"B", "A"}; !reflect.DeepEqual(result.Ranking, want) {
+		t.Errorf("Ranked Pairs ranking = %v, want %v", result.Ranking, want)
+	}
+}
@@ -0,0 +1,132 @@
+package tabulation
+
+// schulze is the Schulze method, a Condorcet method: if one option beats
+// every other option head-to-head it wins. Otherwise options are compared
+// by the strength of the strongest chain of pairwise wins between them.
+//
+// Options are ranked by how many other options they beat on strongest
+// paths. Options that beat the same number of others, which only happens
+// when their strongest paths tie, are ordered by the poll's tie-break
+// policy.
+type schulze struct{}
+
+func init() {
+	Register(schulze{})
+}
+
+func (schulze) Name() string { return "schulze" }
+
+func (schulze) ValidateSettings(s Settings, numOptions int) error { return nil }
+
+func (schulze) ValidateBallot(c Contest, b Ballot) error {
+	return validateRanking(c, b.Ranking)
+}
+
+func (schulze) Tabulate(c Contest, ballots []Ballot) Result {
+	n := len(c.Options)
+	d := pairwiseCounts(c.Options, ballots)
+
+	// p[i][j] is the strength of the strongest path from option i to option j,
+	// computed with a widest-path variant of Floyd–Warshall.
+	p := make([][]int, n)
+	for i := range p {
+		p[i] = make([]int, n)
+		for j := 0; j < n; j++ {
+			if i != j && d[i][j] > d[j][i] {
+				p[i][j] = d[i][j]
+			}
+		}
+	}
+	for k := 0; k < n; k++ {
+		for i := 0; i < n; i++ {
+			if i == k {
+				continue
+			}
+			for j := 0; j < n; j++ {
+				if j == i || j == k {
+					continue
+				}
+				if through := min(p[i][k], p[k][j]); through > p[i][j] {
+					p[i][j] = through
+				}
+			}
+		}
+	}
+
+	// Order options by how many others they beat on strongest paths.
+	// Options with the same number of wins go to the tie-break.
+	wins := make(map[string]float64, n)
+	for i := 0; i < n; i++ {
+		wins[c.Options[i]] = 0
+		for j := 0; j < n; j++ {
+			if i != j && p[i][j] > p[j][i] {
+				wins[c.Options[i]]++
+			}
+		}
+	}
+
+	tb := newTieBreaker(c, ballots)
+	result := Result{
+		Method:         "schulze",
+		TotalBallots:   len(ballots),
+		Winners:        []string{},
+		Ranking:        tb.rankByTally(c.Options, wins),
+		Pairwise:       pairwiseMap(c.Options, d),
+		StrongestPaths: pairwiseMap(c.Options, p),
+	}
+	if len(ballots) > 0 && n > 0 {
+		result.Winners = []string{result.Ranking[0]}
+		tb.recordTies(0, TieWinner, result.Ranking, 1, func(a, b string) bool { return wins[a] == wins[b] })
+	}
+	return tb.finish(result)
+}
+
+// pairwiseCounts returns d where d[i][j] is the number of ballots ranking
+// option i above option j. A ranked option is preferred over every option
+// the ballot leaves unranked; two unranked options, or two options in the
+// same tier of a ballot with ties, are not compared.
+func pairwiseCounts(options []string, ballots []Ballot) [][]int {
+	n := len(options)
+	index := make(map[string]int, n)
+	for i, id := range options {
+		index[id] = i
+	}
+
+	d := make([][]int, n)
+	for i := range d {
+		d[i] = make([]int, n)
+	}
+	for _, b := range ballots {
+		ranked := make([]bool, n)
+		for _, tier := range b.tiers() {
+			for _, id := range tier {
+				ranked[index[id]] = true
+			}
+			// Every option not yet seen on this ballot is ranked below the tier.
+			for _, id := range tier {
+				i := index[id]
+				for j := 0; j < n; j++ {
+					if !ranked[j] {
+						d[i][j]++
+					}
+				}
+			}
+		}
+	}
+	return d
+}
+
+// pairwiseMap converts a matrix indexed like options, such as pairwise
+// counts or path strengths, into a map keyed by option ID
+func pairwiseMap(options []string, d [][]int) map[string]map[string]int {
+	m := make(map[string]map[string]int, len(options))
+	for i, a := range options {
+		m[a] = make(map[string]int, len(options)-1)
+		for j, b := range options {
+			if i != j {
+				m[a][b] = d[i][j]
+			}
+		}
+	}
+	return m
+}
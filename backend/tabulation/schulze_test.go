@@ -0,0 +1,78 @@
+package tabulation
+
+import (
+	"reflect"
+	"testing"
+)
+
+// repeat returns n copies of the given ranking as ballots
+func repeat(n int, ranking ...string) []Ballot {
+	ballots := make([]Ballot, n)
+	for i := range ballots {
+		ballots[i] = Ballot{Ranking: ranking}
+	}
+	return ballots
+}
+
+func TestSchulze(t *testing.T) {
+	// The example election from Markus Schulze's paper, with five options
+	// and 45 voters. The Schulze ranking is E > A > C > B > D.
+	contest := Contest{Options: []string{"A", "B", "C", "D", "E"}}
+	var ballots []Ballot
+	ballots = append(ballots, repeat(5, "A", "C", "B", "E", "D")...)
+	ballots = append(ballots, repeat(5, "A", "D", "E", "C", "B")...)
+	ballots = append(ballots, repeat(8, "B", "E", "D", "A", "C")...)
+	ballots = append(ballots, repeat(3, "C", "A", "B", "E", "D")...)
+	ballots = append(ballots, repeat(7, "C", "A", "E", "B", "D")...)
+	ballots = append(ballots, repeat(2, "C", "B", "A", "D", "E")...)
+	ballots = append(ballots, repeat(7, "D", "C", "E", "B", "A")...)
+	ballots = append(ballots, repeat(8, "E", "B", "A", "D", "C")...)
+
+	m, _ := Lookup("schulze")
+	result := m.Tabulate(contest, ballots)
+
+	if want := []string{"E", "A", "C", "B", "D"}; !reflect.DeepEqual(result.Ranking, want) {
+		t.Errorf("Schulze ranking = %v, want %v", result.Ranking, want)
+	}
+	if !reflect.DeepEqual(result.Winners, []string{"E"}) {
+		t.Errorf("Schulze winners = %v, want [E]", result.Winners)
+	}
+	if result.Pairwise["A"]["B"] != 20 || result.Pairwise["B"]["A"] != 25 {
+		t.Errorf("Pairwise A/B = %d/%d, want 20/25", result.Pairwise["A"]["B"], result.Pairwise["B"]["A"])
+	}
+	// Strongest paths from the paper: A reaches B through A > C > B with
+	// strength 28, and B reaches A only through weaker chains.
+	if result.StrongestPaths["A"]["B"] != 28 || result.StrongestPaths["B"]["A"] != 25 {
+		t.Errorf("StrongestPaths A/B = %d/%d, want 28/25", result.StrongestPaths["A"]["B"], result.StrongestPaths["B"]["A"])
+	}
+	if result.StrongestPaths["E"]["D"] != 31 {
+		t.Errorf("StrongestPaths E/D = %d, want 31", result.StrongestPaths["E"]["D"])
+	}
+}
+
+func TestSchulzeTieBreak(t *testing.T) {
+	// A and B tie head-to-head, so the option listed first wins.
+	contest := Contest{Options: []string{"B", "A"}}
+	ballots := []Ballot{{Ranking: []string{"A", "B"}}, {Ranking: []string{"B", "A"}}}
+
+	m, _ := Lookup("schulze")
+	result := m.Tabulate(contest, ballots)
+	if want := []string{"B", "A"}; !reflect.DeepEqual(result.Ranking, want) {
+		t.Errorf("Schulze ranking = %v, want %v", result.Ranking, want)
+	}
+}
+
+func TestPairwiseCountsPartialRanking(t *testing.T) {
+	options := []string{"a", "b", "c"}
+	d := pairwiseCounts(options, []Ballot{{Ranking: []string{"b"}}})
+
+	// b is preferred over both unranked options; a and c are not compared.
+	want := [][]int{
+		{0, 0, 0},
+		{1, 0, 1},
+		{0, 0, 0},
+	}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("pairwiseCounts() = %v, want %v", d, want)
+	}
+}
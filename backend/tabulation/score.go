@@ -0,0 +1,102 @@
+package tabulation
+
+import (
+	"errors"
+	"fmt"
+)
+
+// maxScoreLimit caps both ends of a score voting scale
+const maxScoreLimit = 100
+
+// score is score (range) voting: voters score every option from the poll's
+// MinScore to its MaxScore and the option with the highest average score
+// wins. Options a ballot leaves unscored count as MinScore.
+type score struct{}
+
+func init() {
+	Register(score{})
+}
+
+func (score) Name() string { return "score" }
+
+func (score) ValidateSettings(s Settings, numOptions int) error {
+	return validateScale(s)
+}
+
+func (score) ValidateBallot(c Contest, b Ballot) error {
+	return validateScores(c, b)
+}
+
+func (score) Tabulate(c Contest, ballots []Ballot) Result {
+	tallies := make(map[string]float64, len(c.Options))
+	for _, id := range c.Options {
+		tallies[id] = 0
+	}
+	for _, b := range ballots {
+		for _, id := range c.Options {
+			tallies[id] += float64(grade(c, b, id))
+		}
+	}
+	if len(ballots) > 0 {
+		for id := range tallies {
+			tallies[id] /= float64(len(ballots))
+		}
+	}
+	result := tallyResult("score", c, ballots, tallies)
+	result.Distributions = distributions(c, ballots)
+	return result
+}
+
+// validateScale checks the MinScore to MaxScore scale shared by the
+// score-based methods
+func validateScale(s Settings) error {
+	if s.MinScore < -maxScoreLimit || s.MaxScore > maxScoreLimit {
+		return fmt.Errorf("scores must be between %d and %d", -maxScoreLimit, maxScoreLimit)
+	}
+	if s.MaxScore <= s.MinScore {
+		return errors.New("max_score must be greater than min_score")
+	}
+	return nil
+}
+
+// validateScores checks that a ballot scores at least one option and only
+// uses scores on the contest's scale
+func validateScores(c Contest, b Ballot) error {
+	if len(b.Scores) == 0 {
+		return errors.New("ballot must score at least one option")
+	}
+	ids := make([]string, 0, len(b.Scores))
+	for id, value := range b.Scores {
+		if value < c.Settings.MinScore || value > c.Settings.MaxScore {
+			return fmt.Errorf("scores must be between %d and %d", c.Settings.MinScore, c.Settings.MaxScore)
+		}
+		ids = append(ids, id)
+	}
+	return validateOptionSet(c, ids, "scores")
+}
+
+// grade returns the score a ballot gives an option, or the bottom of the
+// scale if it leaves the option unscored
+func grade(c Contest, b Ballot, id string) int {
+	if value, ok := b.Scores[id]; ok {
+		return value
+	}
+	return c.Settings.MinScore
+}
+
+// distributions counts how many ballots give each option each grade
+func distributions(c Contest, ballots []Ballot) map[string][]int {
+	grades := c.Settings.MaxScore - c.Settings.MinScore + 1
+	result := make(map[string][]int, len(c.Options))
+	for _, id := range c.Options {
+		result[id] = make([]int, max(grades, 0))
+	}
+	for _, b := range ballots {
+		for _, id := range c.Options {
+			if g := grade(c, b, id) - c.Settings.MinScore; g >= 0 && g < grades {
+				result[id][g]++
+			}
+		}
+	}
+	return result
+}
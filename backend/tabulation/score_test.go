@@ -0,0 +1,124 @@
+package tabulation
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScoreScale(t *testing.T) {
+	m, _ := Lookup("score")
+
+	tests := []struct {
+		name     string
+		settings Settings
+		wantErr  bool
+	}{
+		{name: "zero to ten", settings: Settings{MaxScore: 10}},
+		{name: "minus two to two", settings: Settings{MinScore: -2, MaxScore: 2}},
+		{name: "empty scale", settings: Settings{MinScore: 3, MaxScore: 3}, wantErr: true},
+		{name: "upside down", settings: Settings{MinScore: 5, MaxScore: 1}, wantErr: true},
+		{name: "too wide", settings: Settings{MinScore: -101, MaxScore: 1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := m.ValidateSettings(tt.settings, 3)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("score.ValidateSettings() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	contest := Contest{Options: []string{"a", "b"}, Settings: Settings{MinScore: -2, MaxScore: 2}}
+	if err := m.ValidateBallot(contest, Ballot{Scores: map[string]int{"a": -2, "b": 2}}); err != nil {
+		t.Errorf("score.ValidateBallot(-2, 2) error = %v", err)
+	}
+	if err := m.ValidateBallot(contest, Ballot{Scores: map[string]int{"a": -3}}); err == nil {
+		t.Errorf("score.ValidateBallot(-3) expected an error")
+	}
+
+	// Unscored options count as the bottom of the scale.
+	result := m.Tabulate(contest, []Ballot{{Scores: map[string]int{"a": 2}}, {Scores: map[string]int{"a": 1, "b": 0}}})
+	if want := map[string]float64{"a": 1.5, "b": -1}; !reflect.DeepEqual(result.Tallies, want) {
+		t.Errorf("score tallies = %v, want %v", result.Tallies, want)
+	}
+	if want := map[string][]int{"a": {0, 0, 0, 1, 1}, "b": {1, 0, 1, 0, 0}}; !reflect.DeepEqual(result.Distributions, want) {
+		t.Errorf("score distributions = %v, want %v", result.Distributions, want)
+	}
+}
+
+func TestSTAR(t *testing.T) {
+	// a has the highest total, but more voters prefer b to a.
+	contest := Contest{Options: []string{"a", "b", "c"}, Settings: Settings{MaxScore: 5}}
+	ballots := []Ballot{
+		{Scores: map[string]int{"a": 5, "b": 0, "c": 1}},
+		{Scores: map[string]int{"a": 5, "b": 0, "c": 1}},
+		{Scores: map[string]int{"a": 3, "b": 4, "c": 1}},
+		{Scores: map[string]int{"a": 3, "b": 4, "c": 1}},
+		{Scores: map[string]int{"a": 3, "b": 4, "c": 1}},
+		{Scores: map[string]int{"a": 2, "b": 2, "c": 5}},
+	}
+
+	m, _ := Lookup("star")
+	result := m.Tabulate(contest, ballots)
+
+	if want := map[string]float64{"a": 21, "b": 14, "c": 10}; !reflect.DeepEqual(result.Tallies, want) {
+		t.Errorf("STAR totals = %v, want %v", result.Tallies, want)
+	}
+	want := &Runoff{Finalists: []string{"a", "b"}, Votes: map[string]int{"a": 2, "b": 3}, NoPreference: 1}
+	if !reflect.DeepEqual(result.Runoff, want) {
+		t.Errorf("STAR runoff = %+v, want %+v", result.Runoff, want)
+	}
+	if !reflect.DeepEqual(result.Winners, []string{"b"}) {
+		t.Errorf("STAR winners = %v, want [b]", result.Winners)
+	}
+	if want := []string{"b", "a", "c"}; !reflect.DeepEqual(result.Ranking, want) {
+		t.Errorf("STAR ranking = %v, want %v", result.Ranking, want)
+	}
+	if got := result.Distributions["c"]; !reflect.DeepEqual(got, []int{0, 5, 0, 0, 0, 1}) {
+		t.Errorf("STAR distribution of c = %v, want [0 5 0 0 0 1]", got)
+	}
+}
+
+func TestSTARTiedRunoff(t *testing.T) {
+	// One voter each way: the runoff ties and the higher total wins.
+	contest := Contest{Options: []string{"a", "b"}, Settings: Settings{MaxScore: 5}}
+	ballots := []Ballot{
+		{Scores: map[string]int{"a": 1, "b": 0}},
+		{Scores: map[string]int{"a": 0, "b": 5}},
+	}
+
+	m, _ := Lookup("star")
+	result := m.Tabulate(contest, ballots)
+	if !reflect.DeepEqual(result.Winners, []string{"b"}) {
+		t.Errorf("STAR winners = %v, want [b]", result.Winners)
+	}
+}
+
+func TestMajorityJudgment(t *testing.T) {
+	contest := Contest{Options: []string{"c", "b", "a"}, Settings: Settings{MaxScore: 4}}
+	ballots := []Ballot{
+		{Scores: map[string]int{"a": 1, "b": 0, "c": 3}},
+		{Scores: map[string]int{"a": 2, "b": 2, "c": 3}},
+		{Scores: map[string]int{"a": 2, "b": 2}},
+		{Scores: map[string]int{"a": 3, "b": 4, "c": 0}},
+	}
+
+	m, _ := Lookup("majority_judgment")
+	result := m.Tabulate(contest, ballots)
+
+	// a and b share a median of 2. Removing it leaves 2 for both, then a's
+	// next median (1) beats b's (0).
+	if want := map[string]float64{"a": 2, "b": 2, "c": 0}; !reflect.DeepEqual(result.Tallies, want) {
+		t.Errorf("Majority Judgment medians = %v, want %v", result.Tallies, want)
+	}
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(result.Ranking, want) {
+		t.Errorf("Majority Judgment ranking = %v, want %v", result.Ranking, want)
+	}
+	if !reflect.DeepEqual(result.Winners, []string{"a"}) {
+		t.Errorf("Majority Judgment winners = %v, want [a]", result.Winners)
+	}
+	want := map[string][]int{"a": {0, 1, 2, 1, 0}, "b": {1, 0, 2, 0, 1}, "c": {2, 0, 0, 2, 0}}
+	if !reflect.DeepEqual(result.Distributions, want) {
+		t.Errorf("Majority Judgment distributions = %v, want %v", result.Distributions, want)
+	}
+}
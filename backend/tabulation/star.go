@@ -0,0 +1,93 @@
+package tabulation
+
+// Runoff is the automatic runoff between the two finalists of a STAR count
+type Runoff struct {
+	Finalists []string       `json:"finalists"` // The two options with the highest total scores
+	Votes     map[string]int `json:"votes"`     // Finalist ID -> ballots scoring it above the other finalist
+	// NoPreference counts ballots giving both finalists the same score
+	NoPreference int `json:"no_preference"`
+}
+
+// star is STAR voting (Score Then Automatic Runoff): voters score options
+// like score voting. The two options with the highest total scores go to a
+// runoff, which the finalist scored higher on more ballots wins.
+//
+// Ties for the final are broken by the poll's tie-break policy. A tied
+// runoff goes to the finalist with the higher total score and then to the
+// one the tie-break prefers.
+type star struct{}
+
+func init() {
+	Register(star{})
+}
+
+func (star) Name() string { return "star" }
+
+func (star) ValidateSettings(s Settings, numOptions int) error {
+	return validateScale(s)
+}
+
+func (star) ValidateBallot(c Contest, b Ballot) error {
+	return validateScores(c, b)
+}
+
+func (star) Tabulate(c Contest, ballots []Ballot) Result {
+	totals := make(map[string]float64, len(c.Options))
+	for _, id := range c.Options {
+		totals[id] = 0
+	}
+	for _, b := range ballots {
+		for _, id := range c.Options {
+			totals[id] += float64(grade(c, b, id))
+		}
+	}
+
+	tb := newTieBreaker(c, ballots)
+	result := Result{
+		Method:        "star",
+		TotalBallots:  len(ballots),
+		Winners:       []string{},
+		Ranking:       tb.rankByTally(c.Options, totals),
+		Tallies:       totals,
+		Distributions: distributions(c, ballots),
+	}
+	if len(ballots) == 0 || len(result.Ranking) < 2 {
+		if len(ballots) > 0 && len(result.Ranking) > 0 {
+			result.Winners = []string{result.Ranking[0]}
+		}
+		return tb.finish(result)
+	}
+
+	// The ranking is sorted by total with ties broken, so the finalists are
+	// its first two options.
+	equal := func(a, b string) bool { return totals[a] == totals[b] }
+	tb.recordTies(0, TieFinalists, result.Ranking, 2, equal)
+	first, second := result.Ranking[0], result.Ranking[1]
+	runoff := &Runoff{
+		Finalists: []string{first, second},
+		Votes:     map[string]int{first: 0, second: 0},
+	}
+	for _, b := range ballots {
+		switch a, z := grade(c, b, first), grade(c, b, second); {
+		case a > z:
+			runoff.Votes[first]++
+		case z > a:
+			runoff.Votes[second]++
+		default:
+			runoff.NoPreference++
+		}
+	}
+	result.Runoff = runoff
+
+	// The runoff decides first and second place; the rest keep their order.
+	// first is ahead on totals (or tied and preferred by the tie-break), so
+	// it wins ties.
+	switch {
+	case runoff.Votes[second] > runoff.Votes[first]:
+		result.Ranking[0], result.Ranking[1] = second, first
+	case runoff.Votes[second] == runoff.Votes[first] && equal(first, second):
+		tb.order(0, TieWinner, []string{first, second}, 1)
+	}
+	result.Winners = []string{result.Ranking[0]}
+	return tb.finish(result)
+}
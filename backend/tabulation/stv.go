@@ -0,0 +1,472 @@
+package tabulation
+
+import (
+	"errors"
+	"math"
+)
+
+// Surplus transfer rules for STV polls
+const (
+	// TransferGregory moves an elected option's surplus by sending all of
+	// its ballots on to their next preference at a reduced weight
+	// (weighted inclusive Gregory). Surpluses are transferred one at a time,
+	// largest first.
+	TransferGregory = "gregory"
+	// TransferMeek gives each elected option a keep factor: the share of
+	// every ballot reaching it that it keeps, with the rest passing on.
+	// Keep factors are recomputed until each elected option holds exactly
+	// a quota, so surpluses also reach options elected earlier in a ballot's
+	// ranking.
+	TransferMeek = "meek"
+)
+
+const (
+	// meekTolerance is how close to the quota Meek's iteration brings
+	// every elected option's votes
+	meekTolerance = 1e-9
+	// meekMaxIterations bounds the keep factor iteration of one round
+	meekMaxIterations = 1000
+)
+
+// STVRound records a single counting round of an STV count.
+// Vote values are fractional because surpluses move ballots at reduced
+// weight; they are rounded to six decimal places.
+type STVRound struct {
+	Round     int                `json:"round"`
+	Quota     float64            `json:"quota"`
+	Tallies   map[string]float64 `json:"tallies"`   // Elected and continuing option ID -> votes at the start of the round
+	Exhausted float64            `json:"exhausted"` // Votes with no continuing preference left (cumulative)
+	Elected   []string           `json:"elected,omitempty"`
+	// Surplus is the elected option whose surplus was transferred this
+	// round (Gregory only; Meek transfers surpluses continuously).
+	Surplus    string `json:"surplus,omitempty"`
+	Eliminated string `json:"eliminated,omitempty"`
+	// Transfers records how many votes each continuing option gained from
+	// this round's surplus transfer or elimination. Votes that had no
+	// further preference are counted in ExhaustedByTransfer instead.
+	Transfers           map[string]float64 `json:"transfers,omitempty"`
+	ExhaustedByTransfer float64            `json:"exhausted_by_transfer,omitempty"`
+	// KeepFactors maps the options elected before the round to the Meek
+	// keep factors its tallies were counted with
+	KeepFactors map[string]float64 `json:"keep_factors,omitempty"`
+}
+
+// stv is the single transferable vote, which elects Settings.Seats options
+// from ranked ballots. Each ballot counts for its highest-ranked continuing
+// option. An option reaching the Droop quota is elected and the votes it
+// does not need (its surplus) move on to the ballots' next preferences.
+// When no option reaches the quota, the option with the fewest votes is
+// eliminated and its ballots transfer. Once the continuing options only
+// just fill the remaining seats, they are all elected.
+//
+// Gregory counts use the classic quota floor(ballots/(seats+1))+1. Meek
+// counts use the exact quota (ballots - exhausted)/(seats+1) and elect
+// options with more votes than it.
+//
+// Options elected in the same round are ordered by votes. Ties for last
+// place, between equal surpluses and between options reaching the quota
+// with the same votes are broken by the poll's tie-break policy.
+type stv struct{}
+
+func init() {
+	Register(stv{})
+}
+
+func (stv) Name() string { return "stv" }
+
+func (stv) ValidateSettings(s Settings, numOptions int) error {
+	if s.Seats < 1 {
+		return errors.New("seats must be at least 1")
+	}
+	if s.Seats >= numOptions {
+		return errors.New("seats must be less than the number of options")
+	}
+	switch s.SurplusTransfer {
+	case "", TransferGregory, TransferMeek:
+	default:
+		return errors.New("surplus_transfer must be \"gregory\" or \"meek\"")
+	}
+	return nil
+}
+
+func (stv) ValidateBallot(c Contest, b Ballot) error {
+	return validateRanking(c, b.Ranking)
+}
+
+func (stv) Tabulate(c Contest, ballots []Ballot) Result {
+	result := Result{
+		Method:       "stv",
+		TotalBallots: len(ballots),
+		Winners:      []string{},
+		STVRounds:    []STVRound{},
+	}
+	if len(ballots) == 0 || len(c.Options) == 0 {
+		return result
+	}
+
+	count := newSTVCount(c, ballots)
+	count.run()
+	for _, i := range count.electedOrder {
+		result.Winners = append(result.Winners, c.Options[i])
+	}
+	result.STVRounds = count.rounds
+	return count.tb.finish(result)
+}
+
+// Status of an option during an STV count
+const (
+	stvHopeful = iota
+	stvElected
+	stvExcluded
+)
+
+// stvCount holds the state of one STV count. Options and ballots are
+// referred to by index; rankings are converted to option indexes up front.
+type stvCount struct {
+	options  []string
+	seats    int
+	meek     bool
+	rankings [][]int
+	status   []int
+
+	electedOrder []int
+	rounds       []STVRound
+	tb           *tieBreaker
+
+	// Gregory state: each ballot's weight and the option holding it (-1 if
+	// exhausted), and the votes kept by options whose surplus has moved.
+	weight      []float64
+	holder      []int
+	kept        []float64
+	transferred []bool
+
+	// Meek state: each option's keep factor
+	keep []float64
+}
+
+func newSTVCount(c Contest, ballots []Ballot) *stvCount {
+	n := len(c.Options)
+	index := make(map[string]int, n)
+	for i, id := range c.Options {
+		index[id] = i
+	}
+
+	count := &stvCount{
+		options:  c.Options,
+		seats:    c.Settings.Seats,
+		meek:     c.Settings.SurplusTransfer == TransferMeek,
+		rankings: make([][]int, len(ballots)),
+		status:   make([]int, n),
+		tb:       newTieBreaker(c, ballots),
+	}
+	if count.seats < 1 {
+		count.seats = 1
+	}
+	for b, ballot := range ballots {
+		count.rankings[b] = make([]int, 0, len(ballot.Ranking))
+		for _, id := range ballot.Ranking {
+			if i, ok := index[id]; ok {
+				count.rankings[b] = append(count.rankings[b], i)
+			}
+		}
+	}
+
+	if count.meek {
+		count.keep = make([]float64, n)
+		for i := range count.keep {
+			count.keep[i] = 1
+		}
+	} else {
+		count.weight = make([]float64, len(ballots))
+		count.holder = make([]int, len(ballots))
+		count.kept = make([]float64, n)
+		count.transferred = make([]bool, n)
+		for b := range ballots {
+			count.weight[b] = 1
+			count.holder[b] = count.nextHopeful(b, -1)
+		}
+	}
+	return count
+}
+
+// nextHopeful returns the first hopeful option ranked on ballot b after
+// option after (from the top if after is -1), or -1 if there is none
+func (s *stvCount) nextHopeful(b, after int) int {
+	ranking := s.rankings[b]
+	start := 0
+	if after >= 0 {
+		for start < len(ranking) && ranking[start] != after {
+			start++
+		}
+		start++
+	}
+	for _, i := range ranking[min(start, len(ranking)):] {
+		if s.status[i] == stvHopeful {
+			return i
+		}
+	}
+	return -1
+}
+
+// tally returns every option's current votes, the exhausted votes and the
+// quota. Meek counts first iterate the keep factors to convergence.
+func (s *stvCount) tally() (votes []float64, exhausted, quota float64) {
+	total := float64(len(s.rankings))
+	if !s.meek {
+		votes = make([]float64, len(s.options))
+		for b, h := range s.holder {
+			if h >= 0 {
+				votes[h] += s.weight[b]
+			} else {
+				exhausted += s.weight[b]
+			}
+		}
+		for i, done := range s.transferred {
+			if done {
+				votes[i] = s.kept[i]
+			}
+		}
+		return votes, exhausted, math.Floor(total/float64(s.seats+1)) + 1
+	}
+
+	for iteration := 0; ; iteration++ {
+		votes = make([]float64, len(s.options))
+		exhausted = 0
+		for _, ranking := range s.rankings {
+			remaining := 1.0
+			for _, i := range ranking {
+				votes[i] += remaining * s.keep[i]
+				remaining *= 1 - s.keep[i]
+				if remaining == 0 {
+					break
+				}
+			}
+			exhausted += remaining
+		}
+		quota = (total - exhausted) / float64(s.seats+1)
+
+		converged := true
+		for i, status := range s.status {
+			if status == stvElected && votes[i] > 0 {
+				if math.Abs(votes[i]-quota) > meekTolerance {
+					converged = false
+				}
+				s.keep[i] = math.Min(1, s.keep[i]*quota/votes[i])
+			}
+		}
+		if converged || iteration == meekMaxIterations {
+			return votes, exhausted, quota
+		}
+	}
+}
+
+// elect marks an option as elected
+func (s *stvCount) elect(i int, round *STVRound) {
+	s.status[i] = stvElected
+	s.electedOrder = append(s.electedOrder, i)
+	round.Elected = append(round.Elected, s.options[i])
+}
+
+// run counts rounds until every seat is filled
+func (s *stvCount) run() {
+	votes, exhausted, quota := s.tally()
+	for number := 1; ; number++ {
+		round := STVRound{
+			Round:     number,
+			Quota:     roundVotes(quota),
+			Tallies:   make(map[string]float64),
+			Exhausted: roundVotes(exhausted),
+		}
+		for i, status := range s.status {
+			if status != stvExcluded {
+				round.Tallies[s.options[i]] = roundVotes(votes[i])
+			}
+		}
+		if s.meek {
+			round.KeepFactors = s.keepFactors()
+		}
+
+		// Elect every hopeful option that reached the quota, most votes first.
+		// If more reach it than there are seats left, the tie-break decides
+		// between those with equal votes for the last seat.
+		var reached []string
+		for i, status := range s.status {
+			if status == stvHopeful && s.meetsQuota(votes[i], quota) {
+				reached = append(reached, s.options[i])
+			}
+		}
+		reached = s.tb.rankByTally(reached, round.Tallies)
+		open := s.seats - len(s.electedOrder)
+		s.tb.recordTies(number, TieWinner, reached, open, func(a, b string) bool { return round.Tallies[a] == round.Tallies[b] })
+		for _, id := range reached {
+			if len(s.electedOrder) < s.seats {
+				s.elect(s.index(id), &round)
+			}
+		}
+
+		// Once the hopefuls only just fill the remaining seats, elect them all.
+		var hopeful []string
+		for i, status := range s.status {
+			if status == stvHopeful {
+				hopeful = append(hopeful, s.options[i])
+			}
+		}
+		if open := s.seats - len(s.electedOrder); open > 0 && len(hopeful) <= open {
+			for _, id := range s.tb.rankByTally(hopeful, round.Tallies) {
+				s.elect(s.index(id), &round)
+			}
+		}
+		if len(s.electedOrder) >= s.seats {
+			s.rounds = append(s.rounds, round)
+			return
+		}
+
+		// Move a surplus if there is one to move, otherwise eliminate.
+		if surplus := s.largestSurplus(number, votes, quota); surplus >= 0 {
+			round.Surplus = s.options[surplus]
+			s.transferSurplus(surplus, votes[surplus], quota)
+		} else if len(reached) == 0 || !s.meek {
+			loser := s.lowestHopeful(number, votes)
+			round.Eliminated = s.options[loser]
+			s.eliminate(loser)
+		}
+		s.tb.addRound(round.Tallies)
+
+		// Record where the votes went by comparing with the next count.
+		next, nextExhausted, nextQuota := s.tally()
+		round.Transfers = make(map[string]float64)
+		for i, status := range s.status {
+			if status == stvHopeful && next[i]-votes[i] > meekTolerance {
+				round.Transfers[s.options[i]] = roundVotes(next[i] - votes[i])
+			}
+		}
+		round.ExhaustedByTransfer = roundVotes(math.Max(0, nextExhausted-exhausted))
+		s.rounds = append(s.rounds, round)
+
+		votes, exhausted, quota = next, nextExhausted, nextQuota
+	}
+}
+
+// meetsQuota reports whether an option with the given votes is elected.
+// Meek's exact quota must be exceeded, otherwise seats+1 options could all
+// reach it.
+func (s *stvCount) meetsQuota(votes, quota float64) bool {
+	if s.meek {
+		return votes > quota+meekTolerance
+	}
+	return votes >= quota-meekTolerance
+}
+
+// largestSurplus returns the elected option with the largest surplus still
+// to transfer, or -1. Meek counts never have one, as the keep factor
+// iteration transfers surpluses as part of every tally.
+func (s *stvCount) largestSurplus(round int, votes []float64, quota float64) int {
+	if s.meek {
+		return -1
+	}
+	var tied []int
+	for i, status := range s.status {
+		if status != stvElected || s.transferred[i] || votes[i] <= quota {
+			continue
+		}
+		switch {
+		case len(tied) == 0 || roundVotes(votes[i]) > roundVotes(votes[tied[0]]):
+			tied = []int{i}
+		case roundVotes(votes[i]) == roundVotes(votes[tied[0]]):
+			tied = append(tied, i)
+		}
+	}
+	switch len(tied) {
+	case 0:
+		return -1
+	case 1:
+		return tied[0]
+	}
+	return s.index(s.tb.order(round, TieSurplus, s.ids(tied), 1)[0])
+}
+
+// transferSurplus sends every ballot held by the elected option on to its
+// next hopeful preference, keeping a quota's worth of votes behind
+func (s *stvCount) transferSurplus(i int, votes, quota float64) {
+	factor := (votes - quota) / votes
+	for b, h := range s.holder {
+		if h == i {
+			s.weight[b] *= factor
+			s.holder[b] = s.nextHopeful(b, i)
+		}
+	}
+	s.kept[i] = quota
+	s.transferred[i] = true
+}
+
+// eliminate excludes a hopeful option, passing its ballots on at their
+// current weight
+func (s *stvCount) eliminate(i int) {
+	s.status[i] = stvExcluded
+	if s.meek {
+		s.keep[i] = 0
+		return
+	}
+	for b, h := range s.holder {
+		if h == i {
+			s.holder[b] = s.nextHopeful(b, i)
+		}
+	}
+}
+
+// lowestHopeful picks the hopeful option to eliminate, breaking ties for
+// last place with the tie-breaker
+func (s *stvCount) lowestHopeful(round int, votes []float64) int {
+	var tied []int
+	for i, status := range s.status {
+		if status != stvHopeful {
+			continue
+		}
+		switch {
+		case len(tied) == 0 || roundVotes(votes[i]) < roundVotes(votes[tied[0]]):
+			tied = []int{i}
+		case roundVotes(votes[i]) == roundVotes(votes[tied[0]]):
+			tied = append(tied, i)
+		}
+	}
+	if len(tied) == 1 {
+		return tied[0]
+	}
+	ordered := s.tb.order(round, TieElimination, s.ids(tied), len(tied)-1)
+	return s.index(ordered[len(ordered)-1])
+}
+
+// ids converts option indexes to option IDs
+func (s *stvCount) ids(indexes []int) []string {
+	ids := make([]string, len(indexes))
+	for k, i := range indexes {
+		ids[k] = s.options[i]
+	}
+	return ids
+}
+
+// index returns the index of an option ID
+func (s *stvCount) index(id string) int {
+	for i, option := range s.options {
+		if option == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// keepFactors returns the Meek keep factors of the elected options
+func (s *stvCount) keepFactors() map[string]float64 {
+	factors := make(map[string]float64)
+	for i, status := range s.status {
+		if status == stvElected {
+			factors[s.options[i]] = roundVotes(s.keep[i])
+		}
+	}
+	return factors
+}
+
+// roundVotes rounds a fractional vote value to six decimal places
+func roundVotes(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
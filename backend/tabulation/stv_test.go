@@ -0,0 +1,122 @@
+package tabulation
+
+import (
+	"reflect"
+	"testing"
+)
+
+// stvBallots is a two-seat election where a is elected with a surplus,
+// half of which goes to b, whose ballots then have nowhere left to go
+func stvBallots() []Ballot {
+	var ballots []Ballot
+	ballots = append(ballots, repeat(6, "a", "b")...)
+	ballots = append(ballots, repeat(2, "a", "c")...)
+	ballots = append(ballots, repeat(3, "c")...)
+	ballots = append(ballots, repeat(4, "d")...)
+	return ballots
+}
+
+func TestSTVGregory(t *testing.T) {
+	contest := Contest{Options: []string{"a", "b", "c", "d"}, Settings: Settings{Seats: 2}}
+	m, _ := Lookup("stv")
+	result := m.Tabulate(contest, stvBallots())
+
+	if want := []string{"a", "d"}; !reflect.DeepEqual(result.Winners, want) {
+		t.Errorf("STV winners = %v, want %v", result.Winners, want)
+	}
+
+	want := []STVRound{
+		{
+			// Quota floor(15/3)+1 = 6. a's surplus of 2 moves at 2/8 = 0.25
+			// per ballot.
+			Round: 1, Quota: 6, Tallies: map[string]float64{"a": 8, "b": 0, "c": 3, "d": 4},
+			Elected: []string{"a"}, Surplus: "a",
+			Transfers: map[string]float64{"b": 1.5, "c": 0.5},
+		},
+		{
+			Round: 2, Quota: 6, Tallies: map[string]float64{"a": 6, "b": 1.5, "c": 3.5, "d": 4},
+			Eliminated: "b", Transfers: map[string]float64{}, ExhaustedByTransfer: 1.5,
+		},
+		{
+			Round: 3, Quota: 6, Tallies: map[string]float64{"a": 6, "c": 3.5, "d": 4}, Exhausted: 1.5,
+			Eliminated: "c", Transfers: map[string]float64{}, ExhaustedByTransfer: 3.5,
+		},
+		{
+			// d is the only option left for the last seat.
+			Round: 4, Quota: 6, Tallies: map[string]float64{"a": 6, "d": 4}, Exhausted: 5,
+			Elected: []string{"d"},
+		},
+	}
+	if !reflect.DeepEqual(result.STVRounds, want) {
+		t.Errorf("STV rounds = %+v, want %+v", result.STVRounds, want)
+	}
+}
+
+func TestSTVMeek(t *testing.T) {
+	contest := Contest{Options: []string{"a", "b", "c", "d"}, Settings: Settings{Seats: 2, SurplusTransfer: TransferMeek}}
+	m, _ := Lookup("stv")
+	result := m.Tabulate(contest, stvBallots())
+
+	if want := []string{"a", "d"}; !reflect.DeepEqual(result.Winners, want) {
+		t.Errorf("STV winners = %v, want %v", result.Winners, want)
+	}
+	if len(result.STVRounds) != 4 {
+		t.Fatalf("STV rounds = %+v, want 4 rounds", result.STVRounds)
+	}
+
+	// Round 1: quota 15/3 = 5 and a is elected with 8.
+	first := result.STVRounds[0]
+	if first.Quota != 5 || !reflect.DeepEqual(first.Elected, []string{"a"}) {
+		t.Errorf("round 1 quota = %v elected = %v, want 5 and [a]", first.Quota, first.Elected)
+	}
+	if want := map[string]float64{"b": 2.25, "c": 0.75}; !reflect.DeepEqual(first.Transfers, want) {
+		t.Errorf("round 1 transfers = %v, want %v", first.Transfers, want)
+	}
+
+	// Round 2: a keeps 5/8 of each ballot. Eliminating b exhausts the rest
+	// of its ballots, which lowers the quota to 4 and a's keep factor to 0.5.
+	second := result.STVRounds[1]
+	if second.Eliminated != "b" || second.KeepFactors["a"] != 0.625 {
+		t.Errorf("round 2 eliminated = %q keep factors = %v, want b and a:0.625", second.Eliminated, second.KeepFactors)
+	}
+	if second.ExhaustedByTransfer != 3 {
+		t.Errorf("round 2 exhausted by transfer = %v, want 3", second.ExhaustedByTransfer)
+	}
+
+	// Round 3: c and d tie on 4 with the quota, which must be exceeded.
+	// c had fewer votes in round 2 and is eliminated.
+	third := result.STVRounds[2]
+	if want := map[string]float64{"a": 4, "c": 4, "d": 4}; third.Quota != 4 || !reflect.DeepEqual(third.Tallies, want) {
+		t.Errorf("round 3 quota = %v tallies = %v, want 4 and %v", third.Quota, third.Tallies, want)
+	}
+	if third.KeepFactors["a"] != 0.5 {
+		t.Errorf("round 3 keep factors = %v, want a:0.5", third.KeepFactors)
+	}
+	if third.Eliminated != "c" {
+		t.Errorf("round 3 eliminated = %q, want c", third.Eliminated)
+	}
+}
+
+func TestSTVValidateSettings(t *testing.T) {
+	m, _ := Lookup("stv")
+
+	tests := []struct {
+		name     string
+		settings Settings
+		wantErr  bool
+	}{
+		{name: "two seats", settings: Settings{Seats: 2}},
+		{name: "meek", settings: Settings{Seats: 1, SurplusTransfer: TransferMeek}},
+		{name: "no seats", settings: Settings{}, wantErr: true},
+		{name: "a seat for every option", settings: Settings{Seats: 3}, wantErr: true},
+		{name: "unknown transfer rule", settings: Settings{Seats: 1, SurplusTransfer: "random"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := m.ValidateSettings(tt.settings, 3)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("stv.ValidateSettings() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
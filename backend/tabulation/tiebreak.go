@@ -0,0 +1,297 @@
+package tabulation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// Tie-break policies decide between options a voting method cannot
+// otherwise separate. Every method uses the poll's policy, and whatever
+// the policy cannot separate falls back to display order.
+const (
+	// TieBreakPreviousRounds prefers the option that was ahead in the most
+	// recent earlier round where the tied options differed. Methods that
+	// count in a single round have no earlier rounds to look at.
+	TieBreakPreviousRounds = "previous_rounds"
+	// TieBreakFirstPreferences prefers the option with more first
+	// preferences: ballots ranking it first, approving it or giving it their
+	// highest score.
+	TieBreakFirstPreferences = "first_preferences"
+	// TieBreakCreator prefers the option the poll's creator ranked higher
+	// in the poll's tie-break order. Options left out of the order come
+	// after those in it.
+	TieBreakCreator = "creator"
+	// TieBreakRandom orders options by the SHA-256 hash of the poll's
+	// published seed, a colon and the option ID, lowest hash first. Anyone
+	// with the seed can reproduce the order.
+	TieBreakRandom = "random"
+)
+
+// DefaultTieBreak is used for polls that do not choose a tie-break policy
+const DefaultTieBreak = TieBreakPreviousRounds
+
+// TieBreakDisplayOrder is recorded as the rule of a tie-break the poll's
+// policy could not decide, where the option listed first in the poll was
+// preferred
+const TieBreakDisplayOrder = "display_order"
+
+// What a tie-break decided
+const (
+	TieWinner      = "winner"      // Which of the tied options wins
+	TieFinalists   = "finalists"   // Which tied options go to the STAR runoff
+	TieElimination = "elimination" // Which tied option is eliminated
+	TieSurplus     = "surplus"     // Which equal STV surplus is transferred first
+)
+
+// TieBreak records one tie and how it was broken, so anyone can check the
+// outcome from the ballots, the poll's policy and its seed
+type TieBreak struct {
+	Round   int      `json:"round,omitempty"` // Counting round, for methods that count in rounds
+	Purpose string   `json:"purpose"`         // One of the Tie constants
+	Tied    []string `json:"tied"`            // The tied option IDs, in display order
+	Order   []string `json:"order"`           // The tied options as the tie-break ordered them, preferred first
+	// Chosen lists the options the tie-break decided on: the winner, the
+	// finalists, the option eliminated or the surplus transferred first
+	Chosen []string `json:"chosen"`
+	// Rule is the policy that decided the tie, or TieBreakDisplayOrder if
+	// the policy could not
+	Rule string `json:"rule"`
+}
+
+// ValidateTieBreak checks the tie-break policy, which every voting method
+// shares
+func ValidateTieBreak(s Settings) error {
+	switch s.TieBreak {
+	case "", TieBreakPreviousRounds, TieBreakFirstPreferences, TieBreakCreator, TieBreakRandom:
+		return nil
+	default:
+		return fmt.Errorf("tie_break must be %q, %q, %q or %q", TieBreakPreviousRounds, TieBreakFirstPreferences, TieBreakCreator, TieBreakRandom)
+	}
+}
+
+// tieBreaker orders tied options under a contest's tie-break policy and
+// records the ties it breaks. Methods create one per count.
+type tieBreaker struct {
+	policy       string
+	seed         string
+	creatorOrder []string
+	// position maps option IDs to their display order
+	position map[string]int
+	// rank holds each option's place under the policy, for the policies
+	// that fix one up front (first preferences, creator and random); lower
+	// is preferred and equal places are not separated.
+	rank map[string]int
+	// history holds the tallies of the rounds counted so far, for
+	// TieBreakPreviousRounds
+	history []map[string]float64
+	records []TieBreak
+}
+
+func newTieBreaker(c Contest, ballots []Ballot) *tieBreaker {
+	t := &tieBreaker{
+		policy:       c.Settings.TieBreak,
+		seed:         c.TieBreakSeed,
+		creatorOrder: c.TieBreakOrder,
+		position:     make(map[string]int, len(c.Options)),
+	}
+	if t.policy == "" {
+		t.policy = DefaultTieBreak
+	}
+	for i, id := range c.Options {
+		t.position[id] = i
+	}
+
+	switch t.policy {
+	case TieBreakFirstPreferences:
+		// More first preferences is better, so count down from zero.
+		t.rank = make(map[string]int, len(c.Options))
+		for _, b := range ballots {
+			for _, id := range firstPreferences(c, b) {
+				t.rank[id]--
+			}
+		}
+	case TieBreakCreator:
+		t.rank = make(map[string]int, len(c.Options))
+		for _, id := range c.Options {
+			t.rank[id] = len(c.TieBreakOrder)
+		}
+		for i, id := range c.TieBreakOrder {
+			if _, ok := t.position[id]; ok {
+				t.rank[id] = i
+			}
+		}
+	case TieBreakRandom:
+		hashes := make([]string, len(c.Options))
+		for i, id := range c.Options {
+			hashes[i] = seededHash(c.TieBreakSeed, id)
+		}
+		sorted := append([]string(nil), hashes...)
+		sort.Strings(sorted)
+		t.rank = make(map[string]int, len(c.Options))
+		for i, id := range c.Options {
+			t.rank[id] = sort.SearchStrings(sorted, hashes[i])
+		}
+	}
+	return t
+}
+
+// seededHash is the hex SHA-256 of seed, a colon and the option ID, which
+// TieBreakRandom sorts options by
+func seededHash(seed, id string) string {
+	sum := sha256.Sum256([]byte(seed + ":" + id))
+	return hex.EncodeToString(sum[:])
+}
+
+// firstPreferences returns the options a ballot gives its first
+// preference: its first choice or first tier, every option it approves,
+// or every option sharing its highest score
+func firstPreferences(c Contest, b Ballot) []string {
+	if len(b.Scores) == 0 {
+		return b.Counted()
+	}
+	best := c.Settings.MinScore
+	for _, id := range c.Options {
+		best = max(best, grade(c, b, id))
+	}
+	var top []string
+	for _, id := range c.Options {
+		if grade(c, b, id) == best {
+			top = append(top, id)
+		}
+	}
+	return top
+}
+
+// addRound records the tallies of a finished counting round
+func (t *tieBreaker) addRound(tallies map[string]float64) {
+	t.history = append(t.history, tallies)
+}
+
+// decides compares two options under the policy alone, returning a
+// negative number if a is preferred, a positive one if b is and zero if
+// the policy cannot separate them
+func (t *tieBreaker) decides(a, b string) int {
+	if t.policy == TieBreakPreviousRounds {
+		for r := len(t.history) - 1; r >= 0; r-- {
+			x, y := roundVotes(t.history[r][a]), roundVotes(t.history[r][b])
+			switch {
+			case x > y:
+				return -1
+			case x < y:
+				return 1
+			}
+		}
+		return 0
+	}
+	return t.rank[a] - t.rank[b]
+}
+
+// less reports whether option a is preferred to option b: by the policy
+// and then by display order
+func (t *tieBreaker) less(a, b string) bool {
+	if d := t.decides(a, b); d != 0 {
+		return d < 0
+	}
+	return t.position[a] < t.position[b]
+}
+
+// rule names what decided between two adjacent options of a tie-break
+// order
+func (t *tieBreaker) rule(a, b string) string {
+	if t.decides(a, b) != 0 {
+		return t.policy
+	}
+	return TieBreakDisplayOrder
+}
+
+// sorted returns the options ordered by better to worse, where better
+// reports whether one option is ahead of another on the method's own
+// terms; options neither is ahead of are ordered by the tie-break
+func (t *tieBreaker) sorted(options []string, better func(a, b string) bool) []string {
+	ordered := append([]string(nil), options...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+		if better(a, b) {
+			return true
+		}
+		if better(b, a) {
+			return false
+		}
+		return t.less(a, b)
+	})
+	return ordered
+}
+
+// sortTied orders options the method considers equal by the tie-break
+func (t *tieBreaker) sortTied(options []string) []string {
+	return t.sorted(options, func(a, b string) bool { return false })
+}
+
+// rankByTally orders the options by tally, highest first, breaking ties
+func (t *tieBreaker) rankByTally(options []string, tallies map[string]float64) []string {
+	return t.sorted(options, func(a, b string) bool { return tallies[a] > tallies[b] })
+}
+
+// order breaks a tie between the given options, records it and returns
+// them preferred first. keep is how many of them stay ahead: 1 to pick a
+// winner, all but one to pick the option to eliminate. The recorded rule
+// is what separated the last option kept from the first one not.
+func (t *tieBreaker) order(round int, purpose string, tied []string, keep int) []string {
+	ordered := t.sortTied(tied)
+	t.record(round, purpose, ordered, keep)
+	return ordered
+}
+
+// recordTies records the tie-break that decided a place in a ranking the
+// tie-breaker has already sorted, if one did. places is the number of
+// options at the top of the ranking that were picked, and equal reports
+// whether the method could not separate two options.
+func (t *tieBreaker) recordTies(round int, purpose string, ranking []string, places int, equal func(a, b string) bool) {
+	if places < 1 || places >= len(ranking) || !equal(ranking[places-1], ranking[places]) {
+		return
+	}
+	first, last := places-1, places
+	for first > 0 && equal(ranking[first-1], ranking[places]) {
+		first--
+	}
+	for last+1 < len(ranking) && equal(ranking[last+1], ranking[places]) {
+		last++
+	}
+	t.record(round, purpose, ranking[first:last+1], places-first)
+}
+
+func (t *tieBreaker) record(round int, purpose string, ordered []string, keep int) {
+	if len(ordered) < 2 || keep < 1 || keep >= len(ordered) {
+		return
+	}
+	tied := append([]string(nil), ordered...)
+	sort.SliceStable(tied, func(i, j int) bool { return t.position[tied[i]] < t.position[tied[j]] })
+	chosen := ordered[:keep]
+	if purpose == TieElimination {
+		chosen = ordered[keep:]
+	}
+	t.records = append(t.records, TieBreak{
+		Round:   round,
+		Purpose: purpose,
+		Tied:    tied,
+		Order:   append([]string(nil), ordered...),
+		Chosen:  append([]string(nil), chosen...),
+		Rule:    t.rule(ordered[keep-1], ordered[keep]),
+	})
+}
+
+// finish adds the policy, what it was based on and the ties broken to a
+// result
+func (t *tieBreaker) finish(r Result) Result {
+	r.TieBreakPolicy = t.policy
+	switch t.policy {
+	case TieBreakRandom:
+		r.TieBreakSeed = t.seed
+	case TieBreakCreator:
+		r.TieBreakOrder = t.creatorOrder
+	}
+	r.TieBreaks = t.records
+	return r
+}
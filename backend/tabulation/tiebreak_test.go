@@ -0,0 +1,161 @@
+package tabulation
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTieBreakPolicies(t *testing.T) {
+	// a and b tie on plurality with one first preference each.
+	options := []string{"a", "b", "c"}
+	ballots := []Ballot{
+		{Ranking: []string{"a"}},
+		{Ranking: []string{"b"}},
+	}
+
+	tests := []struct {
+		name       string
+		contest    Contest
+		wantWinner string
+		wantRule   string
+	}{
+		{
+			name:       "previous rounds falls back to display order",
+			contest:    Contest{Options: options},
+			wantWinner: "a",
+			wantRule:   TieBreakDisplayOrder,
+		},
+		{
+			name:       "creator",
+			contest:    Contest{Options: options, Settings: Settings{TieBreak: TieBreakCreator}, TieBreakOrder: []string{"b", "a"}},
+			wantWinner: "b",
+			wantRule:   TieBreakCreator,
+		},
+		{
+			name:       "creator without an order",
+			contest:    Contest{Options: options, Settings: Settings{TieBreak: TieBreakCreator}},
+			wantWinner: "a",
+			wantRule:   TieBreakDisplayOrder,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := Lookup("plurality")
+			result := m.Tabulate(tt.contest, ballots)
+			if !reflect.DeepEqual(result.Winners, []string{tt.wantWinner}) {
+				t.Fatalf("winners = %v, want [%s]", result.Winners, tt.wantWinner)
+			}
+			if len(result.TieBreaks) != 1 {
+				t.Fatalf("tie-breaks = %+v, want one", result.TieBreaks)
+			}
+			tb := result.TieBreaks[0]
+			if tb.Purpose != TieWinner || tb.Rule != tt.wantRule {
+				t.Errorf("tie-break = %+v, want purpose %s rule %s", tb, TieWinner, tt.wantRule)
+			}
+			if !reflect.DeepEqual(tb.Tied, []string{"a", "b"}) || !reflect.DeepEqual(tb.Chosen, []string{tt.wantWinner}) {
+				t.Errorf("tie-break tied = %v chosen = %v", tb.Tied, tb.Chosen)
+			}
+		})
+	}
+}
+
+func TestTieBreakFirstPreferences(t *testing.T) {
+	// Borda ties a and b on points; b is ranked first on more ballots.
+	contest := Contest{Options: []string{"a", "b", "c"}, Settings: Settings{TieBreak: TieBreakFirstPreferences}}
+	var ballots []Ballot
+	ballots = append(ballots, repeat(2, "b", "a", "c")...)
+	ballots = append(ballots, repeat(1, "a", "c", "b")...)
+
+	m, _ := Lookup("borda")
+	result := m.Tabulate(contest, ballots)
+	if result.Tallies["a"] != result.Tallies["b"] {
+		t.Fatalf("tallies = %v, want a and b tied", result.Tallies)
+	}
+	if result.Tallies["c"] > result.Tallies["a"] {
+		t.Fatalf("tallies = %v, want c behind", result.Tallies)
+	}
+	if !reflect.DeepEqual(result.Winners, []string{"b"}) {
+		t.Errorf("winners = %v, want [b]", result.Winners)
+	}
+	if result.TieBreakPolicy != TieBreakFirstPreferences || len(result.TieBreaks) != 1 || result.TieBreaks[0].Rule != TieBreakFirstPreferences {
+		t.Errorf("tie-break policy = %s, tie-breaks = %+v", result.TieBreakPolicy, result.TieBreaks)
+	}
+}
+
+func TestTieBreakRandomIsReproducible(t *testing.T) {
+	options := []string{"a", "b", "c", "d"}
+	ballots := []Ballot{{Ranking: []string{"a"}}, {Ranking: []string{"b"}}, {Ranking: []string{"c"}}, {Ranking: []string{"d"}}}
+	m, _ := Lookup("plurality")
+
+	// The winner is the option with the lowest hash of the seed and its ID.
+	for _, seed := range []string{"one", "two", "three"} {
+		contest := Contest{Options: options, Settings: Settings{TieBreak: TieBreakRandom}, TieBreakSeed: seed}
+		want := options[0]
+		for _, id := range options[1:] {
+			if seededHash(seed, id) < seededHash(seed, want) {
+				want = id
+			}
+		}
+
+		first := m.Tabulate(contest, ballots)
+		again := m.Tabulate(contest, ballots)
+		if !reflect.DeepEqual(first, again) {
+			t.Errorf("seed %q: tabulating twice gave %+v and %+v", seed, first, again)
+		}
+		if !reflect.DeepEqual(first.Winners, []string{want}) {
+			t.Errorf("seed %q: winners = %v, want [%s]", seed, first.Winners, want)
+		}
+		if first.TieBreakSeed != seed || len(first.TieBreaks) != 1 || first.TieBreaks[0].Rule != TieBreakRandom {
+			t.Errorf("seed %q: result seed = %q, tie-breaks = %+v", seed, first.TieBreakSeed, first.TieBreaks)
+		}
+	}
+}
+
+func TestTieBreakElimination(t *testing.T) {
+	// Round 1: a=3 b=2 c=2. The creator prefers c to b, so b is eliminated
+	// instead of c, which the default policy would eliminate.
+	contest := Contest{
+		Options:       []string{"a", "b", "c"},
+		Settings:      Settings{TieBreak: TieBreakCreator},
+		TieBreakOrder: []string{"c", "b"},
+	}
+	var ballots []Ballot
+	ballots = append(ballots, repeat(3, "a")...)
+	ballots = append(ballots, repeat(2, "b", "c")...)
+	ballots = append(ballots, repeat(2, "c")...)
+
+	m, _ := Lookup("irv")
+	result := m.Tabulate(contest, ballots)
+	if result.Rounds[0].Eliminated != "b" {
+		t.Fatalf("round 1 eliminated = %q, want b", result.Rounds[0].Eliminated)
+	}
+	if !reflect.DeepEqual(result.Winners, []string{"c"}) {
+		t.Errorf("winners = %v, want [c]", result.Winners)
+	}
+	want := TieBreak{
+		Round:   1,
+		Purpose: TieElimination,
+		Tied:    []string{"b", "c"},
+		Order:   []string{"c", "b"},
+		Chosen:  []string{"b"},
+		Rule:    TieBreakCreator,
+	}
+	if len(result.TieBreaks) != 1 || !reflect.DeepEqual(result.TieBreaks[0], want) {
+		t.Errorf("tie-breaks = %+v, want [%+v]", result.TieBreaks, want)
+	}
+	if !reflect.DeepEqual(result.TieBreakOrder, []string{"c", "b"}) {
+		t.Errorf("tie-break order = %v, want [c b]", result.TieBreakOrder)
+	}
+}
+
+func TestValidateTieBreak(t *testing.T) {
+	for _, policy := range []string{"", TieBreakPreviousRounds, TieBreakFirstPreferences, TieBreakCreator, TieBreakRandom} {
+		if err := ValidateTieBreak(Settings{TieBreak: policy}); err != nil {
+			t.Errorf("ValidateTieBreak(%q) = %v", policy, err)
+		}
+	}
+	if err := ValidateTieBreak(Settings{TieBreak: "coin_flip"}); err == nil {
+		t.Error("ValidateTieBreak(coin_flip) should fail")
+	}
+}
@@ -0,0 +1,70 @@
+// Package webhooks sends poll events to the webhooks users subscribe. Events
+// queued in poll outboxes are handed on as one delivery per subscribed
+// webhook, and deliveries are POSTed, signed, until the receiver accepts
+// them or they have failed too often.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers sent with every delivery
+const (
+	// SignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the
+	// timestamp, a dot and the request body, keyed with the webhook secret
+	SignatureHeader = "X-InstaPoll-Signature"
+	// TimestampHeader carries the Unix time the delivery was sent at
+	TimestampHeader = "X-InstaPoll-Timestamp"
+	// EventHeader carries the event type
+	EventHeader = "X-InstaPoll-Event"
+	// DeliveryHeader carries the delivery ID, which stays the same when a
+	// delivery is retried so receivers can ignore duplicates
+	DeliveryHeader = "X-InstaPoll-Delivery"
+)
+
+// signaturePrefix names the algorithm in the signature header
+const signaturePrefix = "sha256="
+
+var (
+	// ErrInvalidSignature is returned by Verify for a missing or wrong signature
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrStaleTimestamp is returned by Verify for a delivery sent too long ago
+	ErrStaleTimestamp = errors.New("webhook timestamp outside tolerance")
+)
+
+// Sign returns the signature header value of a body sent at timestamp.
+// The timestamp is signed too, so a captured delivery cannot be replayed
+// later under a new timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a received delivery the way receivers should: signature
+// and timestamp are the header values, and the timestamp must be within
+// tolerance of now.
+func Verify(secret, signature, timestamp string, body []byte, now time.Time, tolerance time.Duration) error {
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrStaleTimestamp
+	}
+	if age := now.Sub(time.Unix(sent, 0)); age > tolerance || age < -tolerance {
+		return ErrStaleTimestamp
+	}
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, sent, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package webhooks
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"id":"event"}`)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := Sign("secret", now.Unix(), body)
+
+	assert.Regexp(t, `^sha256=[0-9a-f]{64}$`, signature)
+	assert.NoError(t, Verify("secret", signature, timestamp, body, now.Add(time.Minute), 5*time.Minute))
+
+	assert.ErrorIs(t, Verify("other", signature, timestamp, body, now, time.Minute), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("secret", signature, timestamp, []byte(`{"id":"forged"}`), now, time.Minute), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("secret", signature[len("sha256="):], timestamp, body, now, time.Minute), ErrInvalidSignature)
+
+	// The timestamp is part of what is signed.
+	later := strconv.FormatInt(now.Unix()+1, 10)
+	assert.ErrorIs(t, Verify("secret", signature, later, body, now, time.Minute), ErrInvalidSignature)
+
+	assert.ErrorIs(t, Verify("secret", signature, timestamp, body, now.Add(time.Hour), time.Minute), ErrStaleTimestamp)
+	assert.ErrorIs(t, Verify("secret", signature, "yesterday", body, now, time.Minute), ErrStaleTimestamp)
+}
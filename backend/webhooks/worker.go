@@ -0,0 +1,368 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"instapoll/backend/logging"
+	"instapoll/backend/models"
+	"instapoll/backend/store"
+)
+
+// LeaseName is the lease a replica must hold to send deliveries
+const LeaseName = "webhooks"
+
+const (
+	// DefaultInterval is how often new events and due deliveries are looked for
+	DefaultInterval = 5 * time.Second
+	// MaxAttempts is how many times a delivery is tried before it is dead
+	MaxAttempts = 8
+	// RetryDelay is the wait after the first failed attempt; it doubles
+	// after every further failure, up to MaxRetryDelay
+	RetryDelay = 30 * time.Second
+	// MaxRetryDelay caps the wait between attempts
+	MaxRetryDelay = time.Hour
+	// RequestTimeout bounds each POST to a receiver
+	RequestTimeout = 10 * time.Second
+	// batchSize is how many polls or deliveries are handled per store query
+	batchSize = 50
+	// opTimeout bounds each store operation of a tick
+	opTimeout = 10 * time.Second
+	// maxResponseBody is how much of a receiver's response is read
+	maxResponseBody = 64 << 10
+)
+
+// Worker hands queued poll events on to webhooks and sends the deliveries.
+// Like the lifecycle scheduler, every replica runs one but only the replica
+// holding the lease works, so deliveries are not sent twice at once.
+type Worker struct {
+	polls    store.PollStore
+	hooks    store.WebhookStore
+	leases   store.LeaseStore
+	holder   string        // Identifies this replica to the lease store
+	interval time.Duration // Time between ticks; the lease lasts three ticks
+	client   *http.Client
+}
+
+// New creates a worker that ticks every interval, or DefaultInterval if
+// interval is not positive. holder must be unique to this replica.
+func New(polls store.PollStore, hooks store.WebhookStore, leases store.LeaseStore, holder string, interval time.Duration) *Worker {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Worker{
+		polls:    polls,
+		hooks:    hooks,
+		leases:   leases,
+		holder:   holder,
+		interval: interval,
+		client:   newClient(refuseInternal),
+	}
+}
+
+// errInternalAddress is the error of a delivery whose receiver resolved to
+// an address deliveries may not be sent to
+var errInternalAddress = errors.New("receivers cannot be on loopback, private or link-local addresses")
+
+// newClient returns the client deliveries are sent with. control, if not
+// nil, vets every address the client connects to. Redirects are not
+// followed, as they could lead anywhere; receivers must answer themselves.
+func newClient(control func(network, address string, c syscall.RawConn) error) *http.Client {
+	dialer := &net.Dialer{Timeout: RequestTimeout, Control: control}
+	return &http.Client{
+		Timeout: RequestTimeout,
+		// No proxy either, so that the address vetted is the receiver's.
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			ForceAttemptHTTP2:   true,
+			TLSHandshakeTimeout: RequestTimeout,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// refuseInternal refuses connections to addresses that are not public, see
+// models.IsPublicAddr. It runs once the receiver's host name has been
+// resolved, so a name pointing at an internal address is refused too.
+func refuseInternal(network, address string, _ syscall.RawConn) error {
+	addr, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !models.IsPublicAddr(addr.Addr()) {
+		return errInternalAddress
+	}
+	return nil
+}
+
+// Run ticks until ctx is cancelled, then gives up the lease
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	defer func() {
+		releaseCtx, cancel := context.WithTimeout(context.Background(), opTimeout)
+		defer cancel()
+		if err := w.leases.Release(releaseCtx, LeaseName, w.holder); err != nil {
+			logging.FromContext(ctx).Error("Failed to release lease", "lease", LeaseName, "error", err)
+		}
+	}()
+
+	for {
+		if err := w.Tick(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			logging.FromContext(ctx).Error("Failed to send webhook deliveries", "error", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Tick takes or renews the lease and, if this replica holds it, hands on
+// every queued event and sends every delivery due at now.
+func (w *Worker) Tick(ctx context.Context, now time.Time) error {
+	start := time.Now()
+	held, err := w.acquire(ctx, now)
+	if err != nil || !held {
+		return err // Without the lease another replica sends deliveries
+	}
+	if err := w.fanOut(ctx, now); err != nil {
+		return err
+	}
+
+	for {
+		// Renew the lease for every batch, which takes at most about
+		// RequestTimeout to send, so a long backlog does not outlast it.
+		// The renewal runs from the current time, i.e. now plus the time
+		// the tick has taken so far, not from the start of the tick. If
+		// another replica has taken the lease meanwhile, stop sending.
+		held, err := w.acquire(ctx, now.Add(time.Since(start)))
+		if err != nil || !held {
+			return err
+		}
+		dueCtx, cancel := context.WithTimeout(ctx, opTimeout)
+		due, err := w.hooks.DueDeliveries(dueCtx, now, batchSize)
+		cancel()
+		if err != nil {
+			return err
+		}
+
+		// Send the batch concurrently so one slow receiver does not hold
+		// up the others.
+		errs := make([]error, len(due))
+		var wg sync.WaitGroup
+		for i := range due {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = w.send(ctx, &due[i], now)
+			}(i)
+		}
+		wg.Wait()
+		if err := errors.Join(errs...); err != nil {
+			return err
+		}
+		if len(due) < batchSize {
+			return nil
+		}
+	}
+}
+
+// acquire takes or renews the lease
+func (w *Worker) acquire(ctx context.Context, now time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, opTimeout)
+	defer cancel()
+	return w.leases.Acquire(ctx, LeaseName, w.holder, now, 3*w.interval)
+}
+
+// payload is the JSON body of a delivery
+type payload struct {
+	ID        string       `json:"id"` // Event ID
+	Type      string       `json:"type"`
+	CreatedAt time.Time    `json:"created_at"`
+	BallotID  string       `json:"ballot_id,omitempty"`
+	Poll      *models.Poll `json:"poll"` // The poll when the event was handed on
+}
+
+// fanOut turns the events queued in poll outboxes into deliveries to the
+// webhooks subscribed to them, then removes the events from the outboxes.
+// Should it stop in between, the events are handed on again next tick and
+// Enqueue skips the deliveries already made.
+func (w *Worker) fanOut(ctx context.Context, now time.Time) error {
+	for {
+		pendingCtx, cancel := context.WithTimeout(ctx, opTimeout)
+		polls, err := w.polls.PendingEvents(pendingCtx, batchSize)
+		cancel()
+		if err != nil {
+			return err
+		}
+		for i := range polls {
+			if err := w.fanOutPoll(ctx, &polls[i], now); err != nil {
+				return err
+			}
+		}
+		if len(polls) < batchSize {
+			return nil
+		}
+	}
+}
+
+// fanOutPoll hands on the events queued in one poll's outbox
+func (w *Worker) fanOutPoll(ctx context.Context, poll *models.Poll, now time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, opTimeout)
+	defer cancel()
+
+	var subscribers []models.Webhook
+	if poll.CreatorID != "" { // Anonymous polls have nobody to subscribe to them
+		var err error
+		subscribers, err = w.hooks.Subscribers(ctx, poll.CreatorID, poll.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	events := poll.Outbox
+	snapshot := *poll
+	snapshot.Outbox = nil
+	var deliveries []models.Delivery
+	eventIDs := make([]string, len(events))
+	for i, event := range events {
+		eventIDs[i] = event.ID
+		var body []byte
+		for _, webhook := range subscribers {
+			if !webhook.Wants(event.Type) {
+				continue
+			}
+			if body == nil {
+				var err error
+				body, err = json.Marshal(payload{
+					ID:        event.ID,
+					Type:      event.Type,
+					CreatedAt: event.CreatedAt,
+					BallotID:  event.BallotID,
+					Poll:      &snapshot,
+				})
+				if err != nil {
+					return fmt.Errorf("encoding event %s of poll %s: %w", event.ID, poll.ID, err)
+				}
+			}
+			deliveries = append(deliveries, models.Delivery{
+				ID:            models.DeliveryID(event.ID, webhook.ID),
+				WebhookID:     webhook.ID,
+				EventID:       event.ID,
+				EventType:     event.Type,
+				PollID:        poll.ID,
+				Payload:       body,
+				Status:        models.DeliveryPending,
+				Attempts:      []models.DeliveryAttempt{},
+				NextAttemptAt: now,
+				CreatedAt:     now,
+			})
+		}
+	}
+
+	if err := w.hooks.Enqueue(ctx, deliveries); err != nil {
+		return err
+	}
+	return w.polls.AckEvents(ctx, poll.ID, eventIDs)
+}
+
+// send makes one attempt at a delivery and records its outcome: the
+// delivery succeeds on a 2xx response, and is otherwise retried later or,
+// after MaxAttempts, dead. Only store errors are returned.
+func (w *Worker) send(ctx context.Context, delivery *models.Delivery, now time.Time) error {
+	attempt := models.DeliveryAttempt{At: now}
+
+	getCtx, cancel := context.WithTimeout(ctx, opTimeout)
+	webhook, err := w.hooks.GetWebhook(getCtx, delivery.WebhookID)
+	cancel()
+	switch {
+	case errors.Is(err, store.ErrWebhookNotFound):
+		attempt.Error = "webhook was deleted"
+		delivery.Attempts = append(delivery.Attempts, attempt)
+		delivery.Status = models.DeliveryDead
+		delivery.NextAttemptAt = time.Time{}
+		return w.save(ctx, delivery)
+	case err != nil:
+		return err
+	}
+
+	attempt.StatusCode, err = w.post(ctx, webhook, delivery, now)
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	delivery.Attempts = append(delivery.Attempts, attempt)
+	switch {
+	case err == nil:
+		delivery.Status = models.DeliverySucceeded
+		delivery.NextAttemptAt = time.Time{}
+	case len(delivery.Attempts) >= MaxAttempts:
+		logging.FromContext(ctx).Warn("Giving up on delivery", "webhook_id", webhook.ID, "delivery_id", delivery.ID, "poll_id", delivery.PollID, "attempts", len(delivery.Attempts), "error", err)
+		delivery.Status = models.DeliveryDead
+		delivery.NextAttemptAt = time.Time{}
+	default:
+		delivery.NextAttemptAt = now.Add(retryDelay(len(delivery.Attempts)))
+	}
+	return w.save(ctx, delivery)
+}
+
+// post sends a delivery to its webhook, returning the response status code
+// and an error unless the receiver accepted it
+func (w *Worker) post(ctx context.Context, webhook *models.Webhook, delivery *models.Delivery, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "InstaPoll-Webhooks")
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, delivery.Payload))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody)) // Lets the connection be reused
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// save stores a delivery after an attempt
+func (w *Worker) save(ctx context.Context, delivery *models.Delivery) error {
+	ctx, cancel := context.WithTimeout(ctx, opTimeout)
+	defer cancel()
+	return w.hooks.SaveDelivery(ctx, delivery)
+}
+
+// retryDelay is the wait before the next attempt of a delivery that has
+// failed the given number of times
+func retryDelay(failures int) time.Duration {
+	delay := RetryDelay
+	for i := 1; i < failures && delay < MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > MaxRetryDelay {
+		delay = MaxRetryDelay
+	}
+	return delay
+}
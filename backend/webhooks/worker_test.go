@@ -0,0 +1,367 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"instapoll/backend/models"
+	"instapoll/backend/store"
+	"instapoll/backend/tabulation"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// received is a request a receiver was sent
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver is a webhook endpoint answering with the status codes it is
+// given in turn, then 204 No Content
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	requests []received
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	r := &receiver{statuses: statuses}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.requests = append(r.requests, received{header: req.Header.Clone(), body: body})
+		status := http.StatusNoContent
+		if len(r.statuses) > 0 {
+			status, r.statuses = r.statuses[0], r.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// received returns the requests sent so far
+func (r *receiver) received() []received {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]received(nil), r.requests...)
+}
+
+// allowLoopback lets a worker deliver to the test receivers, which listen
+// on the loopback address
+func allowLoopback(w *Worker) *Worker {
+	w.client = newClient(nil)
+	return w
+}
+
+// fixture is a worker with in-memory stores and a poll created by "ada"
+type fixture struct {
+	polls  *store.MemoryStore
+	hooks  *store.MemoryWebhookStore
+	worker *Worker
+	poll   *models.Poll
+}
+
+func newFixture(t *testing.T, now time.Time) *fixture {
+	f := &fixture{
+		polls: store.NewMemoryStore(),
+		hooks: store.NewMemoryWebhookStore(),
+	}
+	f.worker = allowLoopback(New(f.polls, f.hooks, store.NewMemoryLeaseStore(), "replica-1", time.Second))
+	f.poll = &models.Poll{
+		ID:        uuid.New().String(),
+		Title:     "Webhook Test",
+		CreatorID: "ada",
+		Options: []models.Option{
+			{ID: uuid.New().String(), Text: "A"},
+			{ID: uuid.New().String(), Text: "B"},
+		},
+		State:   models.StateOpen,
+		Version: 1,
+		Outbox:  []models.Event{models.NewEvent(models.EventPollCreated, now)},
+	}
+	require.NoError(t, f.polls.Create(context.Background(), f.poll))
+	return f
+}
+
+// subscribe adds a webhook of the given owner posting to url
+func (f *fixture) subscribe(t *testing.T, ownerID, pollID, url string, events ...string) *models.Webhook {
+	webhook := &models.Webhook{
+		ID:        uuid.New().String(),
+		OwnerID:   ownerID,
+		PollID:    pollID,
+		URL:       url,
+		Events:    events,
+		Secret:    "secret-" + ownerID,
+		CreatedAt: time.Now(),
+	}
+	require.NoError(t, f.hooks.CreateWebhook(context.Background(), webhook))
+	return webhook
+}
+
+// deliveries returns a webhook's delivery log, oldest first
+func (f *fixture) deliveries(t *testing.T, webhookID string) []models.Delivery {
+	log, err := f.hooks.ListDeliveries(context.Background(), webhookID, 100)
+	require.NoError(t, err)
+	for i, j := 0, len(log)-1; i < j; i, j = i+1, j-1 {
+		log[i], log[j] = log[j], log[i]
+	}
+	return log
+}
+
+func TestWorkerDelivers(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	f := newFixture(t, now)
+	r := newReceiver(t)
+	all := f.subscribe(t, "ada", "", r.URL)
+	votesOnly := f.subscribe(t, "ada", f.poll.ID, r.URL, models.EventVoteCast)
+	f.subscribe(t, "ada", "another-poll", r.URL)
+	f.subscribe(t, "bob", "", r.URL)
+
+	ballot := &models.Ballot{
+		ID:        uuid.New().String(),
+		PollID:    f.poll.ID,
+		Ballot:    tabulation.Ballot{Approvals: []string{f.poll.Options[0].ID}},
+		CreatedAt: now,
+	}
+	_, err := f.polls.RecordVote(ctx, ballot)
+	require.NoError(t, err)
+
+	require.NoError(t, f.worker.Tick(ctx, now))
+
+	requests := r.received()
+	require.Len(t, requests, 3, "poll.created and vote.cast to the account-wide webhook, vote.cast to the poll's")
+	secrets := map[string]string{all.ID: all.Secret, votesOnly.ID: votesOnly.Secret}
+	byWebhook := map[string][]string{}
+	for _, req := range requests {
+		assert.Equal(t, "application/json", req.header.Get("Content-Type"))
+		deliveryID := req.header.Get(DeliveryHeader)
+		delivery, err := f.hooks.GetDelivery(ctx, deliveryID)
+		require.NoError(t, err)
+		assert.NoError(t, Verify(secrets[delivery.WebhookID], req.header.Get(SignatureHeader), req.header.Get(TimestampHeader), req.body, now, time.Minute))
+
+		var body struct {
+			ID       string      `json:"id"`
+			Type     string      `json:"type"`
+			BallotID string      `json:"ballot_id"`
+			Poll     models.Poll `json:"poll"`
+		}
+		require.NoError(t, json.Unmarshal(req.body, &body))
+		assert.Equal(t, delivery.EventID, body.ID)
+		assert.Equal(t, req.header.Get(EventHeader), body.Type)
+		assert.Equal(t, f.poll.ID, body.Poll.ID)
+		if body.Type == models.EventVoteCast {
+			assert.Equal(t, ballot.ID, body.BallotID)
+		}
+		byWebhook[delivery.WebhookID] = append(byWebhook[delivery.WebhookID], body.Type)
+	}
+	assert.ElementsMatch(t, []string{models.EventPollCreated, models.EventVoteCast}, byWebhook[all.ID])
+	assert.Equal(t, []string{models.EventVoteCast}, byWebhook[votesOnly.ID])
+
+	for _, delivery := range f.deliveries(t, all.ID) {
+		assert.Equal(t, models.DeliverySucceeded, delivery.Status)
+		require.Len(t, delivery.Attempts, 1)
+		assert.Equal(t, http.StatusNoContent, delivery.Attempts[0].StatusCode)
+	}
+
+	// Handed-on events leave the outbox and are not sent again.
+	pending, err := f.polls.PendingEvents(ctx, 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+	require.NoError(t, f.worker.Tick(ctx, now.Add(time.Minute)))
+	assert.Len(t, r.received(), 3)
+}
+
+func TestWorkerRetries(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	f := newFixture(t, now)
+	r := newReceiver(t, http.StatusInternalServerError, http.StatusServiceUnavailable)
+	webhook := f.subscribe(t, "ada", "", r.URL)
+
+	require.NoError(t, f.worker.Tick(ctx, now))
+	log := f.deliveries(t, webhook.ID)
+	require.Len(t, log, 1)
+	assert.Equal(t, models.DeliveryPending, log[0].Status)
+	assert.Equal(t, http.StatusInternalServerError, log[0].Attempts[0].StatusCode)
+	assert.NotEmpty(t, log[0].Attempts[0].Error)
+	assert.True(t, log[0].NextAttemptAt.Equal(now.Add(RetryDelay)))
+
+	// Nothing is sent before the retry is due, and the wait then doubles.
+	require.NoError(t, f.worker.Tick(ctx, now.Add(RetryDelay-time.Second)))
+	assert.Len(t, r.received(), 1)
+	retried := now.Add(RetryDelay)
+	require.NoError(t, f.worker.Tick(ctx, retried))
+	log = f.deliveries(t, webhook.ID)
+	assert.Len(t, log[0].Attempts, 2)
+	assert.True(t, log[0].NextAttemptAt.Equal(retried.Add(2*RetryDelay)))
+
+	require.NoError(t, f.worker.Tick(ctx, retried.Add(2*RetryDelay)))
+	log = f.deliveries(t, webhook.ID)
+	assert.Equal(t, models.DeliverySucceeded, log[0].Status)
+	assert.Len(t, log[0].Attempts, 3)
+
+	// Every attempt sends the same delivery with the same body.
+	requests := r.received()
+	require.Len(t, requests, 3)
+	for _, req := range requests[1:] {
+		assert.Equal(t, requests[0].header.Get(DeliveryHeader), req.header.Get(DeliveryHeader))
+		assert.Equal(t, requests[0].body, req.body)
+	}
+}
+
+func TestWorkerDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	f := newFixture(t, now)
+	statuses := make([]int, MaxAttempts)
+	for i := range statuses {
+		statuses[i] = http.StatusBadGateway
+	}
+	r := newReceiver(t, statuses...)
+	webhook := f.subscribe(t, "ada", "", r.URL)
+
+	at := now
+	for i := 0; i < MaxAttempts+2; i++ {
+		require.NoError(t, f.worker.Tick(ctx, at))
+		at = at.Add(MaxRetryDelay)
+	}
+	assert.Len(t, r.received(), MaxAttempts, "Dead deliveries are not retried")
+	log := f.deliveries(t, webhook.ID)
+	require.Len(t, log, 1)
+	assert.Equal(t, models.DeliveryDead, log[0].Status)
+	assert.Len(t, log[0].Attempts, MaxAttempts)
+
+	// Deliveries to a deleted webhook die without being sent.
+	gone := f.subscribe(t, "ada", "", r.URL)
+	require.NoError(t, f.hooks.Enqueue(ctx, []models.Delivery{{
+		ID:            models.DeliveryID("event", gone.ID),
+		WebhookID:     gone.ID,
+		EventID:       "event",
+		EventType:     models.EventPollClosed,
+		PollID:        f.poll.ID,
+		Payload:       json.RawMessage(`{}`),
+		Status:        models.DeliveryPending,
+		NextAttemptAt: at,
+		CreatedAt:     at,
+	}}))
+	require.NoError(t, f.hooks.DeleteWebhook(ctx, gone.ID))
+	require.NoError(t, f.worker.Tick(ctx, at))
+	assert.Len(t, r.received(), MaxAttempts)
+	log = f.deliveries(t, gone.ID)
+	require.Len(t, log, 1)
+	assert.Equal(t, models.DeliveryDead, log[0].Status)
+}
+
+func TestWorkerRefusesInternalAddresses(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	f := newFixture(t, now)
+	f.worker.client = newClient(refuseInternal)
+	r := newReceiver(t)
+	webhook := f.subscribe(t, "ada", "", r.URL)
+
+	// The receiver's address is only refused when connecting, as a host
+	// name could resolve to it.
+	require.NoError(t, f.worker.Tick(ctx, now))
+	assert.Empty(t, r.received())
+	log := f.deliveries(t, webhook.ID)
+	require.Len(t, log, 1)
+	require.Len(t, log[0].Attempts, 1)
+	assert.Zero(t, log[0].Attempts[0].StatusCode)
+	assert.Contains(t, log[0].Attempts[0].Error, errInternalAddress.Error())
+}
+
+func TestWorkerIgnoresRedirects(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	f := newFixture(t, now)
+	target := newReceiver(t)
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	t.Cleanup(redirect.Close)
+	webhook := f.subscribe(t, "ada", "", redirect.URL)
+
+	require.NoError(t, f.worker.Tick(ctx, now))
+	assert.Empty(t, target.received(), "Redirects are not followed")
+	log := f.deliveries(t, webhook.ID)
+	require.Len(t, log, 1)
+	assert.Equal(t, models.DeliveryPending, log[0].Status)
+	assert.Equal(t, http.StatusTemporaryRedirect, log[0].Attempts[0].StatusCode)
+}
+
+func TestWorkerLease(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	f := newFixture(t, now)
+	r := newReceiver(t)
+	f.subscribe(t, "ada", "", r.URL)
+
+	leases := store.NewMemoryLeaseStore()
+	held, err := leases.Acquire(ctx, LeaseName, "replica-2", now, time.Minute)
+	require.NoError(t, err)
+	require.True(t, held)
+
+	worker := allowLoopback(New(f.polls, f.hooks, leases, "replica-1", time.Second))
+	require.NoError(t, worker.Tick(ctx, now))
+	assert.Empty(t, r.received(), "Only the lease holder sends deliveries")
+
+	require.NoError(t, worker.Tick(ctx, now.Add(2*time.Minute)))
+	assert.Len(t, r.received(), 1, "The lease is taken over once it expires")
+}
+
+// recordingLeases records the time of every lease acquisition
+type recordingLeases struct {
+	store.LeaseStore
+	mu    sync.Mutex
+	times []time.Time
+}
+
+func (l *recordingLeases) Acquire(ctx context.Context, name, holder string, now time.Time, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	l.times = append(l.times, now)
+	l.mu.Unlock()
+	return l.LeaseStore.Acquire(ctx, name, holder, now, ttl)
+}
+
+func TestWorkerRenewsLeasePerBatch(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	f := newFixture(t, now)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(slow.Close)
+	for i := 0; i <= batchSize; i++ {
+		f.subscribe(t, "ada", "", slow.URL)
+	}
+
+	leases := &recordingLeases{LeaseStore: store.NewMemoryLeaseStore()}
+	worker := allowLoopback(New(f.polls, f.hooks, leases, "replica-1", time.Second))
+	require.NoError(t, worker.Tick(ctx, now))
+
+	// The lease is taken, then renewed before each of the two batches, each
+	// time from the time the tick has reached rather than from its start.
+	require.Len(t, leases.times, 3)
+	assert.True(t, leases.times[2].After(leases.times[1]), "renewals should extend the lease, got %v", leases.times)
+	assert.GreaterOrEqual(t, leases.times[2].Sub(now), 20*time.Millisecond)
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, RetryDelay, retryDelay(1))
+	assert.Equal(t, 2*RetryDelay, retryDelay(2))
+	assert.Equal(t, 8*RetryDelay, retryDelay(4))
+	assert.Equal(t, MaxRetryDelay, retryDelay(20))
+}